@@ -97,6 +97,20 @@ func annotationsForTool(name string) mcp.ToolAnnotation {
 			DestructiveHint: &destructive,
 			OpenWorldHint:   &openWorld,
 		}
+	case "slide_jobs":
+		// Local job bookkeeping. cancel only stops the server following
+		// a job, never the Slide-side work, and nothing here calls out.
+		ro := false
+		destructive := false
+		idempotent := true
+		notOpenWorld := false
+		return mcp.ToolAnnotation{
+			Title:           humanTitle(name),
+			ReadOnlyHint:    &ro,
+			DestructiveHint: &destructive,
+			IdempotentHint:  &idempotent,
+			OpenWorldHint:   &notOpenWorld,
+		}
 	case "slide_backups":
 		// Settings updates + backup-start. Reversible-ish.
 		ro := false
@@ -146,6 +160,8 @@ func humanTitle(name string) string {
 		return "Slide Backups"
 	case "slide_alerts":
 		return "Slide Alerts"
+	case "slide_jobs":
+		return "Slide Background Jobs"
 	case "list_all_clients_devices_and_agents":
 		return "List Clients/Devices/Agents (legacy alias)"
 	}
@@ -22,17 +22,28 @@ var (
 	}
 	apiKey string
 
-	// Client cache to avoid redundant API calls
-	clientCache = struct {
-		sync.RWMutex
-		clients    map[string]Client
-		lastUpdate time.Time
-	}{
-		clients: make(map[string]Client),
-	}
 	clientCacheExpiry = 5 * time.Minute // Cache clients for 5 minutes
 )
 
+// clientCacheStore avoids redundant client lookups. Each apiSession owns
+// one so client names never cross Slide identities.
+type clientCacheStore struct {
+	sync.RWMutex
+	clients    map[string]Client
+	lastUpdate time.Time
+}
+
+func newClientCacheStore() *clientCacheStore {
+	return &clientCacheStore{clients: make(map[string]Client)}
+}
+
+func (c *clientCacheStore) reset() {
+	c.Lock()
+	defer c.Unlock()
+	c.clients = make(map[string]Client)
+	c.lastUpdate = time.Time{}
+}
+
 const (
 	apiOperationTimeout  = 45 * time.Second
 	maxRetryAfter        = 5 * time.Second
@@ -455,47 +466,9 @@ func generateVNCViewerURL(virtID, websocketURI, vncPassword string) string {
 		virtID, encodedWebsocketURI, base64Password)
 }
 
-// Helper function to generate WireGuard config file
-func generateWireGuardConfig(peer NetworkWGPeer, serverPublicKey, networkPrefix string) string {
-	config := fmt.Sprintf(`[Interface]
-PrivateKey = %s
-Address = %s
-
-[Peer]
-PublicKey = %s
-Endpoint = %s
-AllowedIPs = %s
-PersistentKeepalive = 25
-`,
-		peer.WGPrivateKey,
-		peer.WGAddress,
-		serverPublicKey,
-		peer.WGEndpoint,
-		joinNetworks(peer.RemoteNetworks, networkPrefix))
-
-	return config
-}
-
-// Helper function to join networks with commas for WireGuard config
-func joinNetworks(networks []string, defaultNetwork string) string {
-	if len(networks) == 0 {
-		if defaultNetwork != "" {
-			return defaultNetwork // Default to the network scope if no specific networks
-		}
-		return "0.0.0.0/0, ::/0" // Fallback to all traffic if no network prefix available
-	}
-	result := ""
-	for i, network := range networks {
-		if i > 0 {
-			result += ", "
-		}
-		result += network
-	}
-	return result
-}
-
 // Client cache helper functions
-func refreshClientCache() error {
+func refreshClientCache(ctx context.Context) error {
+	clientCache := sessionFromContext(ctx).clients
 	clientCache.Lock()
 	defer clientCache.Unlock()
 
@@ -505,7 +478,7 @@ func refreshClientCache() error {
 	}
 
 	// Fetch all clients from API
-	data, err := makeAPIRequest("GET", "/v1/client?limit=200", nil)
+	data, err := makeAPIRequest(ctx, "GET", "/v1/client?limit=200", nil)
 	if err != nil {
 		return fmt.Errorf("failed to fetch clients for cache: %w", err)
 	}
@@ -525,17 +498,18 @@ func refreshClientCache() error {
 	return nil
 }
 
-func getClientName(clientID string) string {
+func getClientName(ctx context.Context, clientID string) string {
 	if clientID == "" {
 		return ""
 	}
 
 	// Try to refresh cache if needed
-	if err := refreshClientCache(); err != nil {
+	if err := refreshClientCache(ctx); err != nil {
 		// If cache refresh fails, return empty string to avoid breaking the response
 		return ""
 	}
 
+	clientCache := sessionFromContext(ctx).clients
 	clientCache.RLock()
 	defer clientCache.RUnlock()
 
@@ -546,19 +520,19 @@ func getClientName(clientID string) string {
 	return ""
 }
 
-func enrichWithClientName(data interface{}) interface{} {
+func enrichWithClientName(ctx context.Context, data interface{}) interface{} {
 	switch v := data.(type) {
 	case map[string]interface{}:
 		// Create a new map to avoid modifying the original
 		enriched := make(map[string]interface{})
 		for key, value := range v {
-			enriched[key] = enrichWithClientName(value)
+			enriched[key] = enrichWithClientName(ctx, value)
 		}
 
 		// Add client_name if client_id exists
 		if clientID, exists := enriched["client_id"]; exists {
 			if clientIDStr, ok := clientID.(string); ok && clientIDStr != "" {
-				enriched["client_name"] = getClientName(clientIDStr)
+				enriched["client_name"] = getClientName(ctx, clientIDStr)
 			}
 		}
 
@@ -568,7 +542,7 @@ func enrichWithClientName(data interface{}) interface{} {
 		// Process each item in the array
 		enriched := make([]interface{}, len(v))
 		for i, item := range v {
-			enriched[i] = enrichWithClientName(item)
+			enriched[i] = enrichWithClientName(ctx, item)
 		}
 		return enriched
 
@@ -595,7 +569,7 @@ func enrichWithClientName(data interface{}) interface{} {
 		}
 
 		// Now recursively enrich the converted data
-		return enrichWithClientName(interfaceData)
+		return enrichWithClientName(ctx, interfaceData)
 	}
 }
 
@@ -604,8 +578,13 @@ func enrichWithClientName(data interface{}) interface{} {
 //   - Single retry on transient 5xx (502, 503, 504).
 //   - Structured APIError with status + endpoint + truncated body so the
 //     LLM can reason about the failure instead of getting a wall of HTML.
-func makeAPIRequest(method, endpoint string, body []byte) ([]byte, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), apiOperationTimeout)
+//
+// ctx is the MCP request context: it carries the caller's apiSession
+// (token + caches), and when the host cancels the tool call
+// (notifications/cancelled) the in-flight request and any pending retry
+// are abandoned. apiOperationTimeout still caps each operation.
+func makeAPIRequest(ctx context.Context, method, endpoint string, body []byte) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, apiOperationTimeout)
 	defer cancel()
 	return makeAPIRequestContext(ctx, method, endpoint, body)
 }
@@ -613,25 +592,46 @@ func makeAPIRequest(method, endpoint string, body []byte) ([]byte, error) {
 func makeAPIRequestContext(ctx context.Context, method, endpoint string, body []byte) ([]byte, error) {
 	const maxRetries = 3
 	retryable := isIdempotentHTTPMethod(method)
+	gate := sessionFromContext(ctx).rateLimit
+	sleptForRateLimit := false
 
 	for attempt := 0; attempt <= maxRetries; attempt++ {
+		// Another request on this identity may have been told to back off;
+		// skip the gate only right after sitting out our own Retry-After.
+		if !sleptForRateLimit {
+			if waitErr := gate.wait(ctx); waitErr != nil {
+				return nil, fmt.Errorf("Slide API %s %s canceled while rate limited: %w", method, endpoint, waitErr)
+			}
+		}
+		sleptForRateLimit = false
+
 		responseBody, statusCode, retryAfter, err := performSingleAPIRequestContext(ctx, method, endpoint, body)
 		if err == nil {
 			return responseBody, nil
 		}
+		// A canceled or expired context is final; don't dress it up as a
+		// transport failure worth retrying.
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, fmt.Errorf("Slide API %s %s canceled: %w", method, endpoint, ctxErr)
+		}
+
+		wait := retryAfter
+		if wait <= 0 {
+			wait = time.Duration(1<<attempt) * time.Second
+		}
+		if wait > maxRetryAfter {
+			wait = maxRetryAfter
+		}
+		if statusCode == 429 {
+			gate.hold(wait)
+		}
 
 		switch {
 		case retryable && statusCode == 429 && attempt < maxRetries:
-			wait := retryAfter
-			if wait <= 0 {
-				wait = time.Duration(1<<attempt) * time.Second
-			}
-			if wait > maxRetryAfter {
-				wait = maxRetryAfter
-			}
 			if sleepErr := retrySleep(ctx, wait); sleepErr != nil {
 				return nil, fmt.Errorf("Slide API %s %s retry canceled: %w", method, endpoint, sleepErr)
 			}
+			sleptForRateLimit = true
 			continue
 		case retryable && (statusCode == 0 || statusCode == 502 || statusCode == 503 || statusCode == 504) && attempt < 1:
 			if sleepErr := retrySleep(ctx, 500*time.Millisecond); sleepErr != nil {
@@ -645,6 +645,35 @@ func makeAPIRequestContext(ctx context.Context, method, endpoint string, body []
 	return nil, fmt.Errorf("unexpected error in retry logic")
 }
 
+// rateLimitGate makes a 429 seen by one request hold back every request on
+// the same Slide identity until its Retry-After has passed, so fan-out
+// workers back off together instead of each burning its own retries.
+type rateLimitGate struct {
+	mu    sync.Mutex
+	until time.Time
+}
+
+// hold blocks new requests for d, extending (never shortening) any
+// existing hold.
+func (g *rateLimitGate) hold(d time.Duration) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if t := time.Now().Add(d); t.After(g.until) {
+		g.until = t
+	}
+}
+
+// wait returns once the gate is open or ctx is done.
+func (g *rateLimitGate) wait(ctx context.Context) error {
+	g.mu.Lock()
+	d := time.Until(g.until)
+	g.mu.Unlock()
+	if d <= 0 {
+		return nil
+	}
+	return retrySleep(ctx, d)
+}
+
 func isIdempotentHTTPMethod(method string) bool {
 	switch strings.ToUpper(method) {
 	case http.MethodGet, http.MethodHead, http.MethodOptions:
@@ -665,7 +694,7 @@ func sleepWithContext(ctx context.Context, d time.Duration) error {
 	}
 }
 
-// APIError is the typed error returned by performSingleAPIRequest.
+// APIError is the typed error returned by performSingleAPIRequestContext.
 // It carries enough structured detail that handlers can decide whether to
 // surface "your token is invalid" vs "Slide is having a bad day" to the LLM.
 type APIError struct {
@@ -673,6 +702,8 @@ type APIError struct {
 	Endpoint   string
 	StatusCode int
 	Body       string
+
+	token string // session token the request used; redacted from Body
 }
 
 func (e *APIError) Error() string {
@@ -690,6 +721,9 @@ func (e *APIError) Error() string {
 		hint = " (Slide API server-side error; check https://status.slide.tech for an active incident, otherwise retry.)"
 	}
 	body := redactSensitive(e.Body)
+	if e.token != "" {
+		body = strings.ReplaceAll(body, e.token, "[REDACTED]")
+	}
 	if len(body) > 300 {
 		body = body[:300] + "..."
 	}
@@ -697,8 +731,8 @@ func (e *APIError) Error() string {
 }
 
 func redactSensitive(value string) string {
-	if apiKey != "" {
-		value = strings.ReplaceAll(value, apiKey, "[REDACTED]")
+	if token := processSession.token(); token != "" {
+		value = strings.ReplaceAll(value, token, "[REDACTED]")
 	}
 	return value
 }
@@ -734,15 +768,13 @@ func (e *APIError) idPrefixHint() string {
 	return ""
 }
 
-// performSingleAPIRequest performs a single API request without retry logic.
-// Returns (body, statusCode, retryAfter, err). retryAfter is parsed from the
-// Retry-After header when present so the caller can honor it precisely.
-func performSingleAPIRequest(method, endpoint string, body []byte) ([]byte, int, time.Duration, error) {
-	return performSingleAPIRequestContext(context.Background(), method, endpoint, body)
-}
-
+// performSingleAPIRequestContext performs a single API request without
+// retry logic. Returns (body, statusCode, retryAfter, err). retryAfter is
+// parsed from the Retry-After header when present so the caller can honor
+// it precisely.
 func performSingleAPIRequestContext(ctx context.Context, method, endpoint string, body []byte) ([]byte, int, time.Duration, error) {
-	url := APIBaseURL + endpoint
+	session := sessionFromContext(ctx)
+	url := session.baseURL() + endpoint
 
 	var req *http.Request
 	var err error
@@ -757,7 +789,8 @@ func performSingleAPIRequestContext(ctx context.Context, method, endpoint string
 		return nil, 0, 0, fmt.Errorf("failed to create request: %w", err)
 	}
 
-	req.Header.Set("Authorization", "Bearer "+apiKey)
+	token := session.token()
+	req.Header.Set("Authorization", "Bearer "+token)
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Accept", "application/json")
 	req.Header.Set("User-Agent", ServerName+"/"+Version)
@@ -784,6 +817,7 @@ func performSingleAPIRequestContext(ctx context.Context, method, endpoint string
 			Endpoint:   endpoint,
 			StatusCode: resp.StatusCode,
 			Body:       string(responseBody),
+			token:      token,
 		}
 	}
 
@@ -811,7 +845,7 @@ func parseRetryAfter(h string) time.Duration {
 }
 
 // API implementations
-func listDevices(args map[string]interface{}) (string, error) {
+func listDevices(ctx context.Context, args map[string]interface{}) (string, error) {
 	params := url.Values{}
 
 	if limit, ok := args["limit"]; ok {
@@ -848,7 +882,7 @@ func listDevices(args map[string]interface{}) (string, error) {
 		endpoint += "?" + params.Encode()
 	}
 
-	data, err := makeAPIRequest("GET", endpoint, nil)
+	data, err := makeAPIRequest(ctx, "GET", endpoint, nil)
 	if err != nil {
 		return "", err
 	}
@@ -861,7 +895,7 @@ func listDevices(args map[string]interface{}) (string, error) {
 	// Add metadata for better LLM interaction and enrich with client names
 	enhancedResult := map[string]interface{}{
 		"pagination": result.Pagination,
-		"data":       enrichWithClientName(result.Data),
+		"data":       enrichWithClientName(ctx, result.Data),
 		"_metadata": map[string]interface{}{
 			"primary_identifier":    "hostname",
 			"presentation_guidance": "When referring to devices, use the hostname as the primary identifier. Device IDs are internal identifiers not commonly used by humans.",
@@ -877,14 +911,14 @@ func listDevices(args map[string]interface{}) (string, error) {
 	return string(jsonData), nil
 }
 
-func getDevice(args map[string]interface{}) (string, error) {
+func getDevice(ctx context.Context, args map[string]interface{}) (string, error) {
 	deviceID, ok := args["device_id"].(string)
 	if !ok {
 		return "", fmt.Errorf("device_id is required")
 	}
 
 	endpoint := fmt.Sprintf("/v1/device/%s", deviceID)
-	data, err := makeAPIRequest("GET", endpoint, nil)
+	data, err := makeAPIRequest(ctx, "GET", endpoint, nil)
 	if err != nil {
 		return "", err
 	}
@@ -895,7 +929,7 @@ func getDevice(args map[string]interface{}) (string, error) {
 	}
 
 	// Enrich with client name
-	enriched := enrichWithClientName(result)
+	enriched := enrichWithClientName(ctx, result)
 
 	jsonData, err := json.MarshalIndent(enriched, "", "  ")
 	if err != nil {
@@ -905,7 +939,7 @@ func getDevice(args map[string]interface{}) (string, error) {
 	return string(jsonData), nil
 }
 
-func updateDevice(args map[string]interface{}) (string, error) {
+func updateDevice(ctx context.Context, args map[string]interface{}) (string, error) {
 	deviceID, ok := args["device_id"].(string)
 	if !ok {
 		return "", fmt.Errorf("device_id is required")
@@ -929,7 +963,7 @@ func updateDevice(args map[string]interface{}) (string, error) {
 	}
 
 	endpoint := fmt.Sprintf("/v1/device/%s", deviceID)
-	data, err := makeAPIRequest("PATCH", endpoint, body)
+	data, err := makeAPIRequest(ctx, "PATCH", endpoint, body)
 	if err != nil {
 		return "", err
 	}
@@ -940,7 +974,7 @@ func updateDevice(args map[string]interface{}) (string, error) {
 	}
 
 	// Enrich with client name
-	enriched := enrichWithClientName(result)
+	enriched := enrichWithClientName(ctx, result)
 
 	jsonData, err := json.MarshalIndent(enriched, "", "  ")
 	if err != nil {
@@ -950,14 +984,14 @@ func updateDevice(args map[string]interface{}) (string, error) {
 	return string(jsonData), nil
 }
 
-func powerOffDevice(args map[string]interface{}) (string, error) {
+func powerOffDevice(ctx context.Context, args map[string]interface{}) (string, error) {
 	deviceID, ok := args["device_id"].(string)
 	if !ok {
 		return "", fmt.Errorf("device_id is required")
 	}
 
 	endpoint := fmt.Sprintf("/v1/device/%s/shutdown/poweroff", deviceID)
-	data, err := makeAPIRequest("POST", endpoint, nil)
+	data, err := makeAPIRequest(ctx, "POST", endpoint, nil)
 	if err != nil {
 		return "", err
 	}
@@ -968,7 +1002,7 @@ func powerOffDevice(args map[string]interface{}) (string, error) {
 	}
 
 	// Enrich with client name
-	enriched := enrichWithClientName(result)
+	enriched := enrichWithClientName(ctx, result)
 
 	jsonData, err := json.MarshalIndent(enriched, "", "  ")
 	if err != nil {
@@ -978,14 +1012,14 @@ func powerOffDevice(args map[string]interface{}) (string, error) {
 	return string(jsonData), nil
 }
 
-func rebootDevice(args map[string]interface{}) (string, error) {
+func rebootDevice(ctx context.Context, args map[string]interface{}) (string, error) {
 	deviceID, ok := args["device_id"].(string)
 	if !ok {
 		return "", fmt.Errorf("device_id is required")
 	}
 
 	endpoint := fmt.Sprintf("/v1/device/%s/shutdown/reboot", deviceID)
-	data, err := makeAPIRequest("POST", endpoint, nil)
+	data, err := makeAPIRequest(ctx, "POST", endpoint, nil)
 	if err != nil {
 		return "", err
 	}
@@ -996,7 +1030,7 @@ func rebootDevice(args map[string]interface{}) (string, error) {
 	}
 
 	// Enrich with client name
-	enriched := enrichWithClientName(result)
+	enriched := enrichWithClientName(ctx, result)
 
 	jsonData, err := json.MarshalIndent(enriched, "", "  ")
 	if err != nil {
@@ -1006,7 +1040,7 @@ func rebootDevice(args map[string]interface{}) (string, error) {
 	return string(jsonData), nil
 }
 
-func listAgents(args map[string]interface{}) (string, error) {
+func listAgents(ctx context.Context, args map[string]interface{}) (string, error) {
 	params := url.Values{}
 
 	if limit, ok := args["limit"]; ok {
@@ -1045,7 +1079,7 @@ func listAgents(args map[string]interface{}) (string, error) {
 		endpoint += "?" + params.Encode()
 	}
 
-	data, err := makeAPIRequest("GET", endpoint, nil)
+	data, err := makeAPIRequest(ctx, "GET", endpoint, nil)
 	if err != nil {
 		return "", err
 	}
@@ -1058,7 +1092,7 @@ func listAgents(args map[string]interface{}) (string, error) {
 	// Add metadata for better LLM interaction and enrich with client names
 	enhancedResult := map[string]interface{}{
 		"pagination": result.Pagination,
-		"data":       enrichWithClientName(result.Data),
+		"data":       enrichWithClientName(ctx, result.Data),
 		"_metadata": map[string]interface{}{
 			"primary_identifier":    "display_name",
 			"presentation_guidance": "When referring to agents, use the display name as the primary identifier. If display name is blank, use hostname instead. Agent IDs are internal identifiers not commonly used by humans.",
@@ -1075,14 +1109,14 @@ func listAgents(args map[string]interface{}) (string, error) {
 }
 
 // Agent API functions
-func getAgent(args map[string]interface{}) (string, error) {
+func getAgent(ctx context.Context, args map[string]interface{}) (string, error) {
 	agentID, ok := args["agent_id"].(string)
 	if !ok {
 		return "", fmt.Errorf("agent_id is required")
 	}
 
 	endpoint := fmt.Sprintf("/v1/agent/%s", agentID)
-	data, err := makeAPIRequest("GET", endpoint, nil)
+	data, err := makeAPIRequest(ctx, "GET", endpoint, nil)
 	if err != nil {
 		return "", err
 	}
@@ -1093,7 +1127,7 @@ func getAgent(args map[string]interface{}) (string, error) {
 	}
 
 	// Enrich with client name
-	enriched := enrichWithClientName(result)
+	enriched := enrichWithClientName(ctx, result)
 
 	jsonData, err := json.MarshalIndent(enriched, "", "  ")
 	if err != nil {
@@ -1103,7 +1137,21 @@ func getAgent(args map[string]interface{}) (string, error) {
 	return string(jsonData), nil
 }
 
-func createAgent(args map[string]interface{}) (string, error) {
+// fetchAgent returns one agent, for handlers that need its schedule or
+// timezone rather than a formatted tool result.
+func fetchAgent(ctx context.Context, agentID string) (*Agent, error) {
+	data, err := makeAPIRequest(ctx, "GET", fmt.Sprintf("/v1/agent/%s", agentID), nil)
+	if err != nil {
+		return nil, err
+	}
+	var a Agent
+	if err := json.Unmarshal(data, &a); err != nil {
+		return nil, fmt.Errorf("parse agent: %w", err)
+	}
+	return &a, nil
+}
+
+func createAgent(ctx context.Context, args map[string]interface{}) (string, error) {
 	displayName, ok := args["display_name"].(string)
 	if !ok {
 		return "", fmt.Errorf("display_name is required")
@@ -1122,7 +1170,7 @@ func createAgent(args map[string]interface{}) (string, error) {
 		return "", fmt.Errorf("failed to marshal request: %w", err)
 	}
 
-	data, err := makeAPIRequest("POST", "/v1/agent", body)
+	data, err := makeAPIRequest(ctx, "POST", "/v1/agent", body)
 	if err != nil {
 		return "", err
 	}
@@ -1140,7 +1188,7 @@ func createAgent(args map[string]interface{}) (string, error) {
 	return string(jsonData), nil
 }
 
-func pairAgent(args map[string]interface{}) (string, error) {
+func pairAgent(ctx context.Context, args map[string]interface{}) (string, error) {
 	pairCode, ok := args["pair_code"].(string)
 	if !ok {
 		return "", fmt.Errorf("pair_code is required")
@@ -1159,7 +1207,7 @@ func pairAgent(args map[string]interface{}) (string, error) {
 		return "", fmt.Errorf("failed to marshal request: %w", err)
 	}
 
-	data, err := makeAPIRequest("POST", "/v1/agent/pair", body)
+	data, err := makeAPIRequest(ctx, "POST", "/v1/agent/pair", body)
 	if err != nil {
 		return "", err
 	}
@@ -1170,7 +1218,7 @@ func pairAgent(args map[string]interface{}) (string, error) {
 	}
 
 	// Enrich with client name
-	enriched := enrichWithClientName(result)
+	enriched := enrichWithClientName(ctx, result)
 
 	jsonData, err := json.MarshalIndent(enriched, "", "  ")
 	if err != nil {
@@ -1180,7 +1228,7 @@ func pairAgent(args map[string]interface{}) (string, error) {
 	return string(jsonData), nil
 }
 
-func updateAgent(args map[string]interface{}) (string, error) {
+func updateAgent(ctx context.Context, args map[string]interface{}) (string, error) {
 	agentID, ok := args["agent_id"].(string)
 	if !ok {
 		return "", fmt.Errorf("agent_id is required")
@@ -1207,7 +1255,7 @@ func updateAgent(args map[string]interface{}) (string, error) {
 	}
 
 	endpoint := fmt.Sprintf("/v1/agent/%s", agentID)
-	data, err := makeAPIRequest("PATCH", endpoint, body)
+	data, err := makeAPIRequest(ctx, "PATCH", endpoint, body)
 	if err != nil {
 		return "", err
 	}
@@ -1218,7 +1266,7 @@ func updateAgent(args map[string]interface{}) (string, error) {
 	}
 
 	// Enrich with client name
-	enriched := enrichWithClientName(result)
+	enriched := enrichWithClientName(ctx, result)
 
 	jsonData, err := json.MarshalIndent(enriched, "", "  ")
 	if err != nil {
@@ -1228,7 +1276,7 @@ func updateAgent(args map[string]interface{}) (string, error) {
 	return string(jsonData), nil
 }
 
-func addAgentPassphrase(args map[string]interface{}) (string, error) {
+func addAgentPassphrase(ctx context.Context, args map[string]interface{}) (string, error) {
 	agentID, ok := args["agent_id"].(string)
 	if !ok {
 		return "", fmt.Errorf("agent_id is required")
@@ -1252,7 +1300,7 @@ func addAgentPassphrase(args map[string]interface{}) (string, error) {
 	}
 
 	endpoint := fmt.Sprintf("/v1/agent/%s/passphrase", agentID)
-	data, err := makeAPIRequest("POST", endpoint, body)
+	data, err := makeAPIRequest(ctx, "POST", endpoint, body)
 	if err != nil {
 		return "", err
 	}
@@ -1270,7 +1318,7 @@ func addAgentPassphrase(args map[string]interface{}) (string, error) {
 	return string(jsonData), nil
 }
 
-func deleteAgentPassphrase(args map[string]interface{}) (string, error) {
+func deleteAgentPassphrase(ctx context.Context, args map[string]interface{}) (string, error) {
 	agentID, ok := args["agent_id"].(string)
 	if !ok {
 		return "", fmt.Errorf("agent_id is required")
@@ -1293,7 +1341,7 @@ func deleteAgentPassphrase(args map[string]interface{}) (string, error) {
 	}
 
 	endpoint := fmt.Sprintf("/v1/agent/%s/passphrase/%s", agentID, agentPassphraseID)
-	_, err = makeAPIRequest("DELETE", endpoint, body)
+	_, err = makeAPIRequest(ctx, "DELETE", endpoint, body)
 	if err != nil {
 		return "", err
 	}
@@ -1302,7 +1350,7 @@ func deleteAgentPassphrase(args map[string]interface{}) (string, error) {
 }
 
 // Backup API functions
-func listBackups(args map[string]interface{}) (string, error) {
+func listBackups(ctx context.Context, args map[string]interface{}) (string, error) {
 	params := url.Values{}
 
 	if limit, ok := args["limit"]; ok {
@@ -1348,7 +1396,7 @@ func listBackups(args map[string]interface{}) (string, error) {
 		endpoint += "?" + params.Encode()
 	}
 
-	data, err := makeAPIRequest("GET", endpoint, nil)
+	data, err := makeAPIRequest(ctx, "GET", endpoint, nil)
 	if err != nil {
 		return "", err
 	}
@@ -1376,14 +1424,14 @@ func listBackups(args map[string]interface{}) (string, error) {
 	return string(jsonData), nil
 }
 
-func getBackup(args map[string]interface{}) (string, error) {
+func getBackup(ctx context.Context, args map[string]interface{}) (string, error) {
 	backupID, ok := args["backup_id"].(string)
 	if !ok {
 		return "", fmt.Errorf("backup_id is required")
 	}
 
 	endpoint := fmt.Sprintf("/v1/backup/%s", backupID)
-	data, err := makeAPIRequest("GET", endpoint, nil)
+	data, err := makeAPIRequest(ctx, "GET", endpoint, nil)
 	if err != nil {
 		return "", err
 	}
@@ -1401,7 +1449,7 @@ func getBackup(args map[string]interface{}) (string, error) {
 	return string(jsonData), nil
 }
 
-func startBackup(args map[string]interface{}) (string, error) {
+func startBackup(ctx context.Context, args map[string]interface{}) (string, error) {
 	agentID, ok := args["agent_id"].(string)
 	if !ok {
 		return "", fmt.Errorf("agent_id is required")
@@ -1415,7 +1463,7 @@ func startBackup(args map[string]interface{}) (string, error) {
 		return "", fmt.Errorf("failed to marshal request: %w", err)
 	}
 
-	data, err := makeAPIRequest("POST", "/v1/backup", body)
+	data, err := makeAPIRequest(ctx, "POST", "/v1/backup", body)
 	if err != nil {
 		return "", err
 	}
@@ -1434,7 +1482,7 @@ func startBackup(args map[string]interface{}) (string, error) {
 }
 
 // Snapshot API functions
-func listSnapshots(args map[string]interface{}) (string, error) {
+func listSnapshots(ctx context.Context, args map[string]interface{}) (string, error) {
 	params := url.Values{}
 
 	if limit, ok := args["limit"]; ok {
@@ -1478,7 +1526,7 @@ func listSnapshots(args map[string]interface{}) (string, error) {
 		endpoint += "?" + params.Encode()
 	}
 
-	data, err := makeAPIRequest("GET", endpoint, nil)
+	data, err := makeAPIRequest(ctx, "GET", endpoint, nil)
 	if err != nil {
 		return "", err
 	}
@@ -1508,22 +1556,29 @@ func listSnapshots(args map[string]interface{}) (string, error) {
 	return string(jsonData), nil
 }
 
-func getSnapshot(args map[string]interface{}) (string, error) {
+func fetchSnapshot(ctx context.Context, snapshotID string) (*Snapshot, error) {
+	data, err := makeAPIRequest(ctx, "GET", fmt.Sprintf("/v1/snapshot/%s", snapshotID), nil)
+	if err != nil {
+		return nil, err
+	}
+	var s Snapshot
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+	return &s, nil
+}
+
+func getSnapshot(ctx context.Context, args map[string]interface{}) (string, error) {
 	snapshotID, ok := args["snapshot_id"].(string)
 	if !ok {
 		return "", fmt.Errorf("snapshot_id is required")
 	}
 
-	endpoint := fmt.Sprintf("/v1/snapshot/%s", snapshotID)
-	data, err := makeAPIRequest("GET", endpoint, nil)
+	snapshot, err := fetchSnapshot(ctx, snapshotID)
 	if err != nil {
 		return "", err
 	}
-
-	var result Snapshot
-	if err := json.Unmarshal(data, &result); err != nil {
-		return "", fmt.Errorf("failed to parse response: %w", err)
-	}
+	result := *snapshot
 
 	// Add metadata for better LLM interaction
 	enhancedResult := map[string]interface{}{
@@ -1555,7 +1610,7 @@ func getSnapshot(args map[string]interface{}) (string, error) {
 }
 
 // File restore API functions
-func listFileRestores(args map[string]interface{}) (string, error) {
+func listFileRestores(ctx context.Context, args map[string]interface{}) (string, error) {
 	params := url.Values{}
 
 	if limit, ok := args["limit"]; ok {
@@ -1586,7 +1641,7 @@ func listFileRestores(args map[string]interface{}) (string, error) {
 		endpoint += "?" + params.Encode()
 	}
 
-	data, err := makeAPIRequest("GET", endpoint, nil)
+	data, err := makeAPIRequest(ctx, "GET", endpoint, nil)
 	if err != nil {
 		return "", err
 	}
@@ -1614,14 +1669,14 @@ func listFileRestores(args map[string]interface{}) (string, error) {
 	return string(jsonData), nil
 }
 
-func getFileRestore(args map[string]interface{}) (string, error) {
+func getFileRestore(ctx context.Context, args map[string]interface{}) (string, error) {
 	fileRestoreID, ok := args["file_restore_id"].(string)
 	if !ok {
 		return "", fmt.Errorf("file_restore_id is required")
 	}
 
 	endpoint := fmt.Sprintf("/v1/restore/file/%s", fileRestoreID)
-	data, err := makeAPIRequest("GET", endpoint, nil)
+	data, err := makeAPIRequest(ctx, "GET", endpoint, nil)
 	if err != nil {
 		return "", err
 	}
@@ -1639,7 +1694,7 @@ func getFileRestore(args map[string]interface{}) (string, error) {
 	return string(jsonData), nil
 }
 
-func createFileRestore(args map[string]interface{}) (string, error) {
+func createFileRestore(ctx context.Context, args map[string]interface{}) (string, error) {
 	snapshotID, ok := args["snapshot_id"].(string)
 	if !ok {
 		return "", fmt.Errorf("snapshot_id is required")
@@ -1658,7 +1713,7 @@ func createFileRestore(args map[string]interface{}) (string, error) {
 		return "", fmt.Errorf("failed to marshal request: %w", err)
 	}
 
-	data, err := makeAPIRequest("POST", "/v1/restore/file", body)
+	data, err := makeAPIRequest(ctx, "POST", "/v1/restore/file", body)
 	if err != nil {
 		return "", err
 	}
@@ -1676,14 +1731,14 @@ func createFileRestore(args map[string]interface{}) (string, error) {
 	return string(jsonData), nil
 }
 
-func deleteFileRestore(args map[string]interface{}) (string, error) {
+func deleteFileRestore(ctx context.Context, args map[string]interface{}) (string, error) {
 	fileRestoreID, ok := args["file_restore_id"].(string)
 	if !ok {
 		return "", fmt.Errorf("file_restore_id is required")
 	}
 
 	endpoint := fmt.Sprintf("/v1/restore/file/%s", fileRestoreID)
-	_, err := makeAPIRequest("DELETE", endpoint, nil)
+	_, err := makeAPIRequest(ctx, "DELETE", endpoint, nil)
 	if err != nil {
 		return "", err
 	}
@@ -1691,7 +1746,7 @@ func deleteFileRestore(args map[string]interface{}) (string, error) {
 	return "File restore deleted successfully", nil
 }
 
-func browseFileRestore(args map[string]interface{}) (string, error) {
+func browseFileRestore(ctx context.Context, args map[string]interface{}) (string, error) {
 	fileRestoreID, ok := args["file_restore_id"].(string)
 	if !ok {
 		return "", fmt.Errorf("file_restore_id is required")
@@ -1716,7 +1771,7 @@ func browseFileRestore(args map[string]interface{}) (string, error) {
 	}
 
 	endpoint := fmt.Sprintf("/v1/restore/file/%s/browse?%s", fileRestoreID, params.Encode())
-	data, err := makeAPIRequest("GET", endpoint, nil)
+	data, err := makeAPIRequest(ctx, "GET", endpoint, nil)
 	if err != nil {
 		return "", err
 	}
@@ -1745,7 +1800,7 @@ func browseFileRestore(args map[string]interface{}) (string, error) {
 }
 
 // File restore push API functions
-func listFileRestorePushes(args map[string]interface{}) (string, error) {
+func listFileRestorePushes(ctx context.Context, args map[string]interface{}) (string, error) {
 	fileRestoreID, ok := args["file_restore_id"].(string)
 	if !ok {
 		return "", fmt.Errorf("file_restore_id is required")
@@ -1781,7 +1836,7 @@ func listFileRestorePushes(args map[string]interface{}) (string, error) {
 		endpoint += "?" + params.Encode()
 	}
 
-	data, err := makeAPIRequest("GET", endpoint, nil)
+	data, err := makeAPIRequest(ctx, "GET", endpoint, nil)
 	if err != nil {
 		return "", err
 	}
@@ -1809,7 +1864,25 @@ func listFileRestorePushes(args map[string]interface{}) (string, error) {
 	return string(jsonData), nil
 }
 
-func createFileRestorePush(args map[string]interface{}) (string, error) {
+// validateSlideRestoreFolder enforces the push destination rule: files can
+// only be restored to X:\SlideRestore.
+func validateSlideRestoreFolder(destinationFolder string) error {
+	if !strings.HasSuffix(destinationFolder, "\\SlideRestore") {
+		return fmt.Errorf("destination_folder must be in the format 'X:\\SlideRestore' where X is a drive letter (e.g., 'C:\\SlideRestore'). Security restriction: files can only be restored to the SlideRestore folder")
+	}
+
+	if len(destinationFolder) < 3 || destinationFolder[1] != ':' || destinationFolder[2] != '\\' {
+		return fmt.Errorf("destination_folder must start with a drive letter followed by ':\\' (e.g., 'C:\\SlideRestore')")
+	}
+
+	driveLetter := destinationFolder[0]
+	if driveLetter < 'A' || driveLetter > 'Z' {
+		return fmt.Errorf("destination_folder must start with a valid drive letter A-Z (e.g., 'C:\\SlideRestore')")
+	}
+	return nil
+}
+
+func createFileRestorePush(ctx context.Context, args map[string]interface{}) (string, error) {
 	fileRestoreID, ok := args["file_restore_id"].(string)
 	if !ok {
 		return "", fmt.Errorf("file_restore_id is required")
@@ -1823,18 +1896,8 @@ func createFileRestorePush(args map[string]interface{}) (string, error) {
 		return "", fmt.Errorf("destination_folder is required")
 	}
 
-	// Validate destination folder format
-	if !strings.HasSuffix(destinationFolder, "\\SlideRestore") {
-		return "", fmt.Errorf("destination_folder must be in the format 'X:\\SlideRestore' where X is a drive letter (e.g., 'C:\\SlideRestore'). Security restriction: files can only be restored to the SlideRestore folder")
-	}
-
-	if len(destinationFolder) < 3 || destinationFolder[1] != ':' || destinationFolder[2] != '\\' {
-		return "", fmt.Errorf("destination_folder must start with a drive letter followed by ':\\' (e.g., 'C:\\SlideRestore')")
-	}
-
-	driveLetter := destinationFolder[0]
-	if driveLetter < 'A' || driveLetter > 'Z' {
-		return "", fmt.Errorf("destination_folder must start with a valid drive letter A-Z (e.g., 'C:\\SlideRestore')")
+	if err := validateSlideRestoreFolder(destinationFolder); err != nil {
+		return "", err
 	}
 
 	payload := map[string]interface{}{
@@ -1847,7 +1910,7 @@ func createFileRestorePush(args map[string]interface{}) (string, error) {
 	}
 
 	endpoint := fmt.Sprintf("/v1/restore/file/%s/push", fileRestoreID)
-	data, err := makeAPIRequest("POST", endpoint, body)
+	data, err := makeAPIRequest(ctx, "POST", endpoint, body)
 	if err != nil {
 		return "", err
 	}
@@ -1882,7 +1945,7 @@ func createFileRestorePush(args map[string]interface{}) (string, error) {
 	return string(jsonData), nil
 }
 
-func updateFileRestorePush(args map[string]interface{}) (string, error) {
+func updateFileRestorePush(ctx context.Context, args map[string]interface{}) (string, error) {
 	fileRestoreID, ok := args["file_restore_id"].(string)
 	if !ok {
 		return "", fmt.Errorf("file_restore_id is required")
@@ -1909,7 +1972,7 @@ func updateFileRestorePush(args map[string]interface{}) (string, error) {
 	}
 
 	endpoint := fmt.Sprintf("/v1/restore/file/%s/push/%s", fileRestoreID, fileRestorePushID)
-	data, err := makeAPIRequest("PATCH", endpoint, body)
+	data, err := makeAPIRequest(ctx, "PATCH", endpoint, body)
 	if err != nil {
 		return "", err
 	}
@@ -1928,7 +1991,7 @@ func updateFileRestorePush(args map[string]interface{}) (string, error) {
 }
 
 // Image export API functions
-func listImageExports(args map[string]interface{}) (string, error) {
+func listImageExports(ctx context.Context, args map[string]interface{}) (string, error) {
 	params := url.Values{}
 
 	if limit, ok := args["limit"]; ok {
@@ -1959,7 +2022,7 @@ func listImageExports(args map[string]interface{}) (string, error) {
 		endpoint += "?" + params.Encode()
 	}
 
-	data, err := makeAPIRequest("GET", endpoint, nil)
+	data, err := makeAPIRequest(ctx, "GET", endpoint, nil)
 	if err != nil {
 		return "", err
 	}
@@ -1987,14 +2050,14 @@ func listImageExports(args map[string]interface{}) (string, error) {
 	return string(jsonData), nil
 }
 
-func getImageExport(args map[string]interface{}) (string, error) {
+func getImageExport(ctx context.Context, args map[string]interface{}) (string, error) {
 	imageExportID, ok := args["image_export_id"].(string)
 	if !ok {
 		return "", fmt.Errorf("image_export_id is required")
 	}
 
 	endpoint := fmt.Sprintf("/v1/restore/image/%s", imageExportID)
-	data, err := makeAPIRequest("GET", endpoint, nil)
+	data, err := makeAPIRequest(ctx, "GET", endpoint, nil)
 	if err != nil {
 		return "", err
 	}
@@ -2012,7 +2075,7 @@ func getImageExport(args map[string]interface{}) (string, error) {
 	return string(jsonData), nil
 }
 
-func createImageExport(args map[string]interface{}) (string, error) {
+func createImageExport(ctx context.Context, args map[string]interface{}) (string, error) {
 	snapshotID, ok := args["snapshot_id"].(string)
 	if !ok {
 		return "", fmt.Errorf("snapshot_id is required")
@@ -2041,7 +2104,7 @@ func createImageExport(args map[string]interface{}) (string, error) {
 		return "", fmt.Errorf("failed to marshal request: %w", err)
 	}
 
-	data, err := makeAPIRequest("POST", "/v1/restore/image", body)
+	data, err := makeAPIRequest(ctx, "POST", "/v1/restore/image", body)
 	if err != nil {
 		return "", err
 	}
@@ -2059,14 +2122,14 @@ func createImageExport(args map[string]interface{}) (string, error) {
 	return string(jsonData), nil
 }
 
-func deleteImageExport(args map[string]interface{}) (string, error) {
+func deleteImageExport(ctx context.Context, args map[string]interface{}) (string, error) {
 	imageExportID, ok := args["image_export_id"].(string)
 	if !ok {
 		return "", fmt.Errorf("image_export_id is required")
 	}
 
 	endpoint := fmt.Sprintf("/v1/restore/image/%s", imageExportID)
-	_, err := makeAPIRequest("DELETE", endpoint, nil)
+	_, err := makeAPIRequest(ctx, "DELETE", endpoint, nil)
 	if err != nil {
 		return "", err
 	}
@@ -2074,7 +2137,7 @@ func deleteImageExport(args map[string]interface{}) (string, error) {
 	return "Image export deleted successfully", nil
 }
 
-func browseImageExport(args map[string]interface{}) (string, error) {
+func browseImageExport(ctx context.Context, args map[string]interface{}) (string, error) {
 	imageExportID, ok := args["image_export_id"].(string)
 	if !ok {
 		return "", fmt.Errorf("image_export_id is required")
@@ -2098,7 +2161,7 @@ func browseImageExport(args map[string]interface{}) (string, error) {
 		endpoint += "?" + params.Encode()
 	}
 
-	data, err := makeAPIRequest("GET", endpoint, nil)
+	data, err := makeAPIRequest(ctx, "GET", endpoint, nil)
 	if err != nil {
 		return "", err
 	}
@@ -2127,7 +2190,7 @@ func browseImageExport(args map[string]interface{}) (string, error) {
 }
 
 // Virtual machine API functions
-func listVirtualMachines(args map[string]interface{}) (string, error) {
+func listVirtualMachines(ctx context.Context, args map[string]interface{}) (string, error) {
 	params := url.Values{}
 
 	if limit, ok := args["limit"]; ok {
@@ -2158,7 +2221,7 @@ func listVirtualMachines(args map[string]interface{}) (string, error) {
 		endpoint += "?" + params.Encode()
 	}
 
-	data, err := makeAPIRequest("GET", endpoint, nil)
+	data, err := makeAPIRequest(ctx, "GET", endpoint, nil)
 	if err != nil {
 		return "", err
 	}
@@ -2226,14 +2289,14 @@ func listVirtualMachines(args map[string]interface{}) (string, error) {
 	return string(jsonData), nil
 }
 
-func getVirtualMachine(args map[string]interface{}) (string, error) {
+func getVirtualMachine(ctx context.Context, args map[string]interface{}) (string, error) {
 	virtID, ok := args["virt_id"].(string)
 	if !ok {
 		return "", fmt.Errorf("virt_id is required")
 	}
 
 	endpoint := fmt.Sprintf("/v1/restore/virt/%s", virtID)
-	data, err := makeAPIRequest("GET", endpoint, nil)
+	data, err := makeAPIRequest(ctx, "GET", endpoint, nil)
 	if err != nil {
 		return "", err
 	}
@@ -2290,7 +2353,7 @@ func getVirtualMachine(args map[string]interface{}) (string, error) {
 	return string(jsonData), nil
 }
 
-func createVirtualMachine(args map[string]interface{}) (string, error) {
+func createVirtualMachine(ctx context.Context, args map[string]interface{}) (string, error) {
 	snapshotID, ok := args["snapshot_id"].(string)
 	if !ok {
 		return "", fmt.Errorf("snapshot_id is required")
@@ -2333,7 +2396,7 @@ func createVirtualMachine(args map[string]interface{}) (string, error) {
 		return "", fmt.Errorf("failed to marshal request: %w", err)
 	}
 
-	data, err := makeAPIRequest("POST", "/v1/restore/virt", body)
+	data, err := makeAPIRequest(ctx, "POST", "/v1/restore/virt", body)
 	if err != nil {
 		return "", err
 	}
@@ -2399,7 +2462,7 @@ func createVirtualMachine(args map[string]interface{}) (string, error) {
 	return string(jsonData), nil
 }
 
-func updateVirtualMachine(args map[string]interface{}) (string, error) {
+func updateVirtualMachine(ctx context.Context, args map[string]interface{}) (string, error) {
 	virtID, ok := args["virt_id"].(string)
 	if !ok {
 		return "", fmt.Errorf("virt_id is required")
@@ -2426,7 +2489,7 @@ func updateVirtualMachine(args map[string]interface{}) (string, error) {
 	}
 
 	endpoint := fmt.Sprintf("/v1/restore/virt/%s", virtID)
-	data, err := makeAPIRequest("PATCH", endpoint, body)
+	data, err := makeAPIRequest(ctx, "PATCH", endpoint, body)
 	if err != nil {
 		return "", err
 	}
@@ -2480,14 +2543,14 @@ func updateVirtualMachine(args map[string]interface{}) (string, error) {
 	return string(jsonData), nil
 }
 
-func deleteVirtualMachine(args map[string]interface{}) (string, error) {
+func deleteVirtualMachine(ctx context.Context, args map[string]interface{}) (string, error) {
 	virtID, ok := args["virt_id"].(string)
 	if !ok {
 		return "", fmt.Errorf("virt_id is required")
 	}
 
 	endpoint := fmt.Sprintf("/v1/restore/virt/%s", virtID)
-	_, err := makeAPIRequest("DELETE", endpoint, nil)
+	_, err := makeAPIRequest(ctx, "DELETE", endpoint, nil)
 	if err != nil {
 		return "", err
 	}
@@ -2495,157 +2558,8 @@ func deleteVirtualMachine(args map[string]interface{}) (string, error) {
 	return "Virtual machine deleted successfully", nil
 }
 
-// storeRDPInCache stores RDP content in the cache service and returns the rdpID
-func storeRDPInCache(rdpContent string) (string, error) {
-	resp, err := httpClient.Post("https://www.slide.recipes/mcpTools/rdpCache.php", "text/plain", strings.NewReader(rdpContent))
-	if err != nil {
-		return "", fmt.Errorf("failed to store RDP in cache: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("cache service returned status: %d", resp.StatusCode)
-	}
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return "", fmt.Errorf("failed to read cache response: %w", err)
-	}
-
-	var cacheResponse struct {
-		Success bool   `json:"success"`
-		RdpID   string `json:"rdpID"`
-		Size    int    `json:"size"`
-		Error   string `json:"error"`
-	}
-
-	if err := json.Unmarshal(body, &cacheResponse); err != nil {
-		return "", fmt.Errorf("failed to parse cache response: %w", err)
-	}
-
-	if !cacheResponse.Success {
-		return "", fmt.Errorf("cache service error: %s", cacheResponse.Error)
-	}
-
-	return cacheResponse.RdpID, nil
-}
-
-func generateRDPBookmark(args map[string]interface{}) (string, error) {
-	virtID, ok := args["virt_id"].(string)
-	if !ok {
-		return "", fmt.Errorf("virt_id is required")
-	}
-
-	// Get VM details first
-	endpoint := fmt.Sprintf("/v1/restore/virt/%s", virtID)
-	data, err := makeAPIRequest("GET", endpoint, nil)
-	if err != nil {
-		return "", err
-	}
-
-	var vm VirtualMachine
-	if err := json.Unmarshal(data, &vm); err != nil {
-		return "", fmt.Errorf("failed to parse VM response: %w", err)
-	}
-
-	// Check if RDP endpoint is available
-	if vm.RDPEndpoint == nil || *vm.RDPEndpoint == "" {
-		return "", fmt.Errorf("RDP endpoint not available for this virtual machine")
-	}
-
-	// Generate RDP file content
-	rdpContent := fmt.Sprintf(`screen mode id:i:2
-use multimon:i:0
-desktopwidth:i:1920
-desktopheight:i:1080
-session bpp:i:32
-winposstr:s:0,3,0,0,800,600
-compression:i:1
-keyboardhook:i:2
-audiocapturemode:i:0
-videoplaybackmode:i:1
-connection type:i:7
-networkautodetect:i:1
-bandwidthautodetect:i:1
-displayconnectionbar:i:1
-enableworkspacereconnect:i:0
-disable wallpaper:i:0
-allow font smoothing:i:0
-allow desktop composition:i:0
-disable full window drag:i:1
-disable menu anims:i:1
-disable themes:i:0
-disable cursor setting:i:0
-bitmapcachepersistenable:i:1
-full address:s:%s
-audiomode:i:0
-redirectprinters:i:1
-redirectcomports:i:0
-redirectsmartcards:i:1
-redirectclipboard:i:1
-redirectposdevices:i:0
-autoreconnection enabled:i:1
-authentication level:i:2
-prompt for credentials:i:1
-negotiate security layer:i:1
-remoteapplicationmode:i:0
-alternate shell:s:
-shell working directory:s:
-gatewayhostname:s:
-gatewayusagemethod:i:4
-gatewaycredentialssource:i:4
-gatewayprofileusagemethod:i:0
-promptcredentialonce:i:0
-gatewaybrokeringtype:i:0
-use redirection server name:i:0
-rdgiskdcproxy:i:0
-kdcproxyname:s:
-`, *vm.RDPEndpoint)
-
-	// Store RDP content in cache service
-	rdpID, err := storeRDPInCache(rdpContent)
-	if err != nil {
-		// If cache storage fails, continue without it but log the error
-		fmt.Printf("Warning: Failed to store RDP in cache: %v\n", err)
-	}
-
-	// Create response with file content and metadata
-	result := map[string]interface{}{
-		"virt_id":      vm.VirtID,
-		"rdp_endpoint": *vm.RDPEndpoint,
-		"filename":     fmt.Sprintf("slide-vm-%s.rdp", vm.VirtID),
-		"content":      rdpContent,
-		"content_type": "application/rdp",
-		"_metadata": map[string]interface{}{
-			"usage_instructions": "Save the 'content' field to a file with the suggested 'filename', then double-click the .rdp file to connect to the virtual machine.",
-			"file_format":        "This generates a standard Windows Remote Desktop Connection (.rdp) file that can be used with any RDP client.",
-			"connection_info":    fmt.Sprintf("This bookmark will connect to: %s", *vm.RDPEndpoint),
-			"security_note":      "You will be prompted for credentials when connecting. Use the Windows account credentials from the original system.",
-		},
-	}
-
-	// Add download link if cache storage was successful
-	if rdpID != "" {
-		downloadURL := fmt.Sprintf("https://www.slide.recipes/mcpTools/rdpCache.php?rdpID=%s", rdpID)
-		result["download_url"] = downloadURL
-		result["rdp_cache_id"] = rdpID
-
-		// Update metadata with download instructions
-		metadata := result["_metadata"].(map[string]interface{})
-		metadata["download_instructions"] = fmt.Sprintf("You can download the RDP file directly from: %s (file expires in 1 hour)", downloadURL)
-		metadata["alternative_usage"] = "Either save the 'content' field manually or use the 'download_url' for direct download."
-	}
-
-	jsonData, err := json.MarshalIndent(result, "", "  ")
-	if err != nil {
-		return "", fmt.Errorf("failed to marshal result: %w", err)
-	}
-
-	return string(jsonData), nil
-}
-
 // User API functions
-func listUsers(args map[string]interface{}) (string, error) {
+func listUsers(ctx context.Context, args map[string]interface{}) (string, error) {
 	params := url.Values{}
 
 	if limit, ok := args["limit"]; ok {
@@ -2676,7 +2590,7 @@ func listUsers(args map[string]interface{}) (string, error) {
 		endpoint += "?" + params.Encode()
 	}
 
-	data, err := makeAPIRequest("GET", endpoint, nil)
+	data, err := makeAPIRequest(ctx, "GET", endpoint, nil)
 	if err != nil {
 		return "", err
 	}
@@ -2704,14 +2618,14 @@ func listUsers(args map[string]interface{}) (string, error) {
 	return string(jsonData), nil
 }
 
-func getUser(args map[string]interface{}) (string, error) {
+func getUser(ctx context.Context, args map[string]interface{}) (string, error) {
 	userID, ok := args["user_id"].(string)
 	if !ok {
 		return "", fmt.Errorf("user_id is required")
 	}
 
 	endpoint := fmt.Sprintf("/v1/user/%s", userID)
-	data, err := makeAPIRequest("GET", endpoint, nil)
+	data, err := makeAPIRequest(ctx, "GET", endpoint, nil)
 	if err != nil {
 		return "", err
 	}
@@ -2730,7 +2644,7 @@ func getUser(args map[string]interface{}) (string, error) {
 }
 
 // Alert API functions
-func listAlerts(args map[string]interface{}) (string, error) {
+func listAlerts(ctx context.Context, args map[string]interface{}) (string, error) {
 	params := url.Values{}
 
 	if limit, ok := args["limit"]; ok {
@@ -2776,7 +2690,7 @@ func listAlerts(args map[string]interface{}) (string, error) {
 		endpoint += "?" + params.Encode()
 	}
 
-	data, err := makeAPIRequest("GET", endpoint, nil)
+	data, err := makeAPIRequest(ctx, "GET", endpoint, nil)
 	if err != nil {
 		return "", err
 	}
@@ -2804,14 +2718,14 @@ func listAlerts(args map[string]interface{}) (string, error) {
 	return string(jsonData), nil
 }
 
-func getAlert(args map[string]interface{}) (string, error) {
+func getAlert(ctx context.Context, args map[string]interface{}) (string, error) {
 	alertID, ok := args["alert_id"].(string)
 	if !ok {
 		return "", fmt.Errorf("alert_id is required")
 	}
 
 	endpoint := fmt.Sprintf("/v1/alert/%s", alertID)
-	data, err := makeAPIRequest("GET", endpoint, nil)
+	data, err := makeAPIRequest(ctx, "GET", endpoint, nil)
 	if err != nil {
 		return "", err
 	}
@@ -2829,7 +2743,7 @@ func getAlert(args map[string]interface{}) (string, error) {
 	return string(jsonData), nil
 }
 
-func updateAlert(args map[string]interface{}) (string, error) {
+func updateAlert(ctx context.Context, args map[string]interface{}) (string, error) {
 	alertID, ok := args["alert_id"].(string)
 	if !ok {
 		return "", fmt.Errorf("alert_id is required")
@@ -2848,7 +2762,7 @@ func updateAlert(args map[string]interface{}) (string, error) {
 	}
 
 	endpoint := fmt.Sprintf("/v1/alert/%s", alertID)
-	data, err := makeAPIRequest("PATCH", endpoint, body)
+	data, err := makeAPIRequest(ctx, "PATCH", endpoint, body)
 	if err != nil {
 		return "", err
 	}
@@ -2867,7 +2781,7 @@ func updateAlert(args map[string]interface{}) (string, error) {
 }
 
 // Account API functions
-func listAccounts(args map[string]interface{}) (string, error) {
+func listAccounts(ctx context.Context, args map[string]interface{}) (string, error) {
 	params := url.Values{}
 
 	if limit, ok := args["limit"]; ok {
@@ -2898,7 +2812,7 @@ func listAccounts(args map[string]interface{}) (string, error) {
 		endpoint += "?" + params.Encode()
 	}
 
-	data, err := makeAPIRequest("GET", endpoint, nil)
+	data, err := makeAPIRequest(ctx, "GET", endpoint, nil)
 	if err != nil {
 		return "", err
 	}
@@ -2926,14 +2840,14 @@ func listAccounts(args map[string]interface{}) (string, error) {
 	return string(jsonData), nil
 }
 
-func getAccount(args map[string]interface{}) (string, error) {
+func getAccount(ctx context.Context, args map[string]interface{}) (string, error) {
 	accountID, ok := args["account_id"].(string)
 	if !ok {
 		return "", fmt.Errorf("account_id is required")
 	}
 
 	endpoint := fmt.Sprintf("/v1/account/%s", accountID)
-	data, err := makeAPIRequest("GET", endpoint, nil)
+	data, err := makeAPIRequest(ctx, "GET", endpoint, nil)
 	if err != nil {
 		return "", err
 	}
@@ -2951,7 +2865,7 @@ func getAccount(args map[string]interface{}) (string, error) {
 	return string(jsonData), nil
 }
 
-func updateAccount(args map[string]interface{}) (string, error) {
+func updateAccount(ctx context.Context, args map[string]interface{}) (string, error) {
 	accountID, ok := args["account_id"].(string)
 	if !ok {
 		return "", fmt.Errorf("account_id is required")
@@ -2980,7 +2894,7 @@ func updateAccount(args map[string]interface{}) (string, error) {
 	}
 
 	endpoint := fmt.Sprintf("/v1/account/%s", accountID)
-	data, err := makeAPIRequest("PATCH", endpoint, body)
+	data, err := makeAPIRequest(ctx, "PATCH", endpoint, body)
 	if err != nil {
 		return "", err
 	}
@@ -2999,7 +2913,7 @@ func updateAccount(args map[string]interface{}) (string, error) {
 }
 
 // Client API functions
-func listClients(args map[string]interface{}) (string, error) {
+func listClients(ctx context.Context, args map[string]interface{}) (string, error) {
 	params := url.Values{}
 
 	if limit, ok := args["limit"]; ok {
@@ -3030,7 +2944,7 @@ func listClients(args map[string]interface{}) (string, error) {
 		endpoint += "?" + params.Encode()
 	}
 
-	data, err := makeAPIRequest("GET", endpoint, nil)
+	data, err := makeAPIRequest(ctx, "GET", endpoint, nil)
 	if err != nil {
 		return "", err
 	}
@@ -3058,14 +2972,14 @@ func listClients(args map[string]interface{}) (string, error) {
 	return string(jsonData), nil
 }
 
-func getClient(args map[string]interface{}) (string, error) {
+func getClient(ctx context.Context, args map[string]interface{}) (string, error) {
 	clientID, ok := args["client_id"].(string)
 	if !ok {
 		return "", fmt.Errorf("client_id is required")
 	}
 
 	endpoint := fmt.Sprintf("/v1/client/%s", clientID)
-	data, err := makeAPIRequest("GET", endpoint, nil)
+	data, err := makeAPIRequest(ctx, "GET", endpoint, nil)
 	if err != nil {
 		return "", err
 	}
@@ -3083,7 +2997,7 @@ func getClient(args map[string]interface{}) (string, error) {
 	return string(jsonData), nil
 }
 
-func createClient(args map[string]interface{}) (string, error) {
+func createClient(ctx context.Context, args map[string]interface{}) (string, error) {
 	name, ok := args["name"].(string)
 	if !ok {
 		return "", fmt.Errorf("name is required")
@@ -3102,7 +3016,7 @@ func createClient(args map[string]interface{}) (string, error) {
 		return "", fmt.Errorf("failed to marshal request: %w", err)
 	}
 
-	data, err := makeAPIRequest("POST", "/v1/client", body)
+	data, err := makeAPIRequest(ctx, "POST", "/v1/client", body)
 	if err != nil {
 		return "", err
 	}
@@ -3120,7 +3034,7 @@ func createClient(args map[string]interface{}) (string, error) {
 	return string(jsonData), nil
 }
 
-func updateClient(args map[string]interface{}) (string, error) {
+func updateClient(ctx context.Context, args map[string]interface{}) (string, error) {
 	clientID, ok := args["client_id"].(string)
 	if !ok {
 		return "", fmt.Errorf("client_id is required")
@@ -3141,7 +3055,7 @@ func updateClient(args map[string]interface{}) (string, error) {
 	}
 
 	endpoint := fmt.Sprintf("/v1/client/%s", clientID)
-	data, err := makeAPIRequest("PATCH", endpoint, body)
+	data, err := makeAPIRequest(ctx, "PATCH", endpoint, body)
 	if err != nil {
 		return "", err
 	}
@@ -3159,14 +3073,14 @@ func updateClient(args map[string]interface{}) (string, error) {
 	return string(jsonData), nil
 }
 
-func deleteClient(args map[string]interface{}) (string, error) {
+func deleteClient(ctx context.Context, args map[string]interface{}) (string, error) {
 	clientID, ok := args["client_id"].(string)
 	if !ok {
 		return "", fmt.Errorf("client_id is required")
 	}
 
 	endpoint := fmt.Sprintf("/v1/client/%s", clientID)
-	_, err := makeAPIRequest("DELETE", endpoint, nil)
+	_, err := makeAPIRequest(ctx, "DELETE", endpoint, nil)
 	if err != nil {
 		return "", err
 	}
@@ -3175,7 +3089,7 @@ func deleteClient(args map[string]interface{}) (string, error) {
 }
 
 // Network API functions
-func listNetworks(args map[string]interface{}) (string, error) {
+func listNetworks(ctx context.Context, args map[string]interface{}) (string, error) {
 	params := url.Values{}
 
 	if limit, ok := args["limit"]; ok {
@@ -3206,7 +3120,7 @@ func listNetworks(args map[string]interface{}) (string, error) {
 		endpoint += "?" + params.Encode()
 	}
 
-	data, err := makeAPIRequest("GET", endpoint, nil)
+	data, err := makeAPIRequest(ctx, "GET", endpoint, nil)
 	if err != nil {
 		return "", err
 	}
@@ -3240,39 +3154,19 @@ func listNetworks(args map[string]interface{}) (string, error) {
 			"client_id":          network.ClientID,
 		}
 
-		// Process WireGuard peers to include config files
-		if len(network.WGPeers) > 0 {
-			enhancedWGPeers := make([]map[string]interface{}, len(network.WGPeers))
-
-			for j, peer := range network.WGPeers {
-				enhancedPeer := map[string]interface{}{
-					"wg_peer_id":        peer.WGPeerID,
-					"peer_name":         peer.PeerName,
-					"wg_public_key":     peer.WGPublicKey,
-					"wg_private_key":    peer.WGPrivateKey,
-					"wg_address":        peer.WGAddress,
-					"wg_endpoint":       peer.WGEndpoint,
-					"remote_networks":   peer.RemoteNetworks,
-					"_wireguard_config": generateWireGuardConfig(peer, network.WGPublicKey, network.WGPrefix),
-				}
-				enhancedWGPeers[j] = enhancedPeer
-			}
-			enhancedNetwork["wg_peers"] = enhancedWGPeers
-		} else {
-			enhancedNetwork["wg_peers"] = network.WGPeers
-		}
+		enhancedNetwork["wg_peers"] = wgPeerViews(network)
 
 		enhancedNetworks[i] = enhancedNetwork
 	}
 
 	enhancedResult := map[string]interface{}{
 		"pagination": result.Pagination,
-		"data":       enrichWithClientName(enhancedNetworks),
+		"data":       enrichWithClientName(ctx, enhancedNetworks),
 		"_metadata": map[string]interface{}{
 			"primary_identifier":     "name",
 			"presentation_guidance":  "Networks enable disaster recovery and isolated networking for virtual machines.",
 			"workflow_guidance":      "Networks can be standard (isolated) or bridge-lan (connected to device LAN). Virtual machines can be connected to networks.",
-			"wireguard_guidance":     "Networks with WireGuard enabled will have WG peers that include ready-to-use configuration files in the _wireguard_config field.",
+			"wireguard_guidance":     wgPeerGuidance,
 			"creation_guidance":      "When creating new networks with slide_create_network, ask users for clarification about configuration details rather than guessing. Key areas that often need clarification: network type (standard vs bridge-lan), bridge device selection, IP address ranges, DHCP configuration, internet access requirements, and WireGuard VPN needs.",
 			"clarification_guidance": "IMPORTANT: Network configuration errors can cause serious connectivity issues. Always ask users to specify their requirements clearly before creating networks. Don't assume default values for critical settings like network type, IP addressing, or bridge device selection.",
 			"client_id_matching":     "CRITICAL: When working with networks, agents and VMs assigned to the network MUST be part of the same client as the network. A network with client_id '' (empty string) can only be used with VMs that also have client_id ''. A network with a specific client_id can only be used with VMs that have the same client_id. Mismatched client IDs will cause network assignment failures.",
@@ -3287,14 +3181,14 @@ func listNetworks(args map[string]interface{}) (string, error) {
 	return string(jsonData), nil
 }
 
-func getNetwork(args map[string]interface{}) (string, error) {
+func getNetwork(ctx context.Context, args map[string]interface{}) (string, error) {
 	networkID, ok := args["network_id"].(string)
 	if !ok {
 		return "", fmt.Errorf("network_id is required")
 	}
 
 	endpoint := fmt.Sprintf("/v1/network/%s", networkID)
-	data, err := makeAPIRequest("GET", endpoint, nil)
+	data, err := makeAPIRequest(ctx, "GET", endpoint, nil)
 	if err != nil {
 		return "", err
 	}
@@ -3327,34 +3221,14 @@ func getNetwork(args map[string]interface{}) (string, error) {
 		"_metadata": map[string]interface{}{
 			"primary_identifier":    "network_id",
 			"presentation_guidance": "Network configuration with associated services and peers.",
-			"wireguard_guidance":    "If this network has WireGuard enabled, WG peers will include ready-to-use configuration files in the _wireguard_config field.",
+			"wireguard_guidance":    wgPeerGuidance,
 		},
 	}
 
-	// Process WireGuard peers to include config files
-	if len(result.WGPeers) > 0 {
-		enhancedWGPeers := make([]map[string]interface{}, len(result.WGPeers))
-
-		for i, peer := range result.WGPeers {
-			enhancedPeer := map[string]interface{}{
-				"wg_peer_id":        peer.WGPeerID,
-				"peer_name":         peer.PeerName,
-				"wg_public_key":     peer.WGPublicKey,
-				"wg_private_key":    peer.WGPrivateKey,
-				"wg_address":        peer.WGAddress,
-				"wg_endpoint":       peer.WGEndpoint,
-				"remote_networks":   peer.RemoteNetworks,
-				"_wireguard_config": generateWireGuardConfig(peer, result.WGPublicKey, result.WGPrefix),
-			}
-			enhancedWGPeers[i] = enhancedPeer
-		}
-		enhancedResult["wg_peers"] = enhancedWGPeers
-	} else {
-		enhancedResult["wg_peers"] = result.WGPeers
-	}
+	enhancedResult["wg_peers"] = wgPeerViews(result)
 
 	// Enrich with client name
-	enrichedWithClient := enrichWithClientName(enhancedResult)
+	enrichedWithClient := enrichWithClientName(ctx, enhancedResult)
 
 	jsonData, err := json.MarshalIndent(enrichedWithClient, "", "  ")
 	if err != nil {
@@ -3364,7 +3238,7 @@ func getNetwork(args map[string]interface{}) (string, error) {
 	return string(jsonData), nil
 }
 
-func createNetwork(args map[string]interface{}) (string, error) {
+func createNetwork(ctx context.Context, args map[string]interface{}) (string, error) {
 	name, ok := args["name"].(string)
 	if !ok {
 		return "", fmt.Errorf("name is required")
@@ -3447,7 +3321,7 @@ func createNetwork(args map[string]interface{}) (string, error) {
 		return "", fmt.Errorf("failed to marshal request: %w", err)
 	}
 
-	data, err := makeAPIRequest("POST", "/v1/network", body)
+	data, err := makeAPIRequest(ctx, "POST", "/v1/network", body)
 	if err != nil {
 		return "", err
 	}
@@ -3488,30 +3362,10 @@ func createNetwork(args map[string]interface{}) (string, error) {
 		},
 	}
 
-	// Process WireGuard peers to include config files (if any returned)
-	if len(result.WGPeers) > 0 {
-		enhancedWGPeers := make([]map[string]interface{}, len(result.WGPeers))
-
-		for i, peer := range result.WGPeers {
-			enhancedPeer := map[string]interface{}{
-				"wg_peer_id":        peer.WGPeerID,
-				"peer_name":         peer.PeerName,
-				"wg_public_key":     peer.WGPublicKey,
-				"wg_private_key":    peer.WGPrivateKey,
-				"wg_address":        peer.WGAddress,
-				"wg_endpoint":       peer.WGEndpoint,
-				"remote_networks":   peer.RemoteNetworks,
-				"_wireguard_config": generateWireGuardConfig(peer, result.WGPublicKey, result.WGPrefix),
-			}
-			enhancedWGPeers[i] = enhancedPeer
-		}
-		enhancedResult["wg_peers"] = enhancedWGPeers
-	} else {
-		enhancedResult["wg_peers"] = result.WGPeers
-	}
+	enhancedResult["wg_peers"] = wgPeerViews(result)
 
 	// Enrich with client name
-	enrichedWithClient := enrichWithClientName(enhancedResult)
+	enrichedWithClient := enrichWithClientName(ctx, enhancedResult)
 
 	jsonData, err := json.MarshalIndent(enrichedWithClient, "", "  ")
 	if err != nil {
@@ -3521,7 +3375,7 @@ func createNetwork(args map[string]interface{}) (string, error) {
 	return string(jsonData), nil
 }
 
-func updateNetwork(args map[string]interface{}) (string, error) {
+func updateNetwork(ctx context.Context, args map[string]interface{}) (string, error) {
 	networkID, ok := args["network_id"].(string)
 	if !ok {
 		return "", fmt.Errorf("network_id is required")
@@ -3601,7 +3455,7 @@ func updateNetwork(args map[string]interface{}) (string, error) {
 	}
 
 	endpoint := fmt.Sprintf("/v1/network/%s", networkID)
-	data, err := makeAPIRequest("PATCH", endpoint, body)
+	data, err := makeAPIRequest(ctx, "PATCH", endpoint, body)
 	if err != nil {
 		return "", err
 	}
@@ -3634,34 +3488,14 @@ func updateNetwork(args map[string]interface{}) (string, error) {
 		"_metadata": map[string]interface{}{
 			"primary_identifier":    "network_id",
 			"presentation_guidance": "Network updated successfully.",
-			"wireguard_guidance":    "If WireGuard is enabled, existing WG peers will include ready-to-use configuration files in the _wireguard_config field.",
+			"wireguard_guidance":    wgPeerGuidance,
 		},
 	}
 
-	// Process WireGuard peers to include config files (if any returned)
-	if len(result.WGPeers) > 0 {
-		enhancedWGPeers := make([]map[string]interface{}, len(result.WGPeers))
-
-		for i, peer := range result.WGPeers {
-			enhancedPeer := map[string]interface{}{
-				"wg_peer_id":        peer.WGPeerID,
-				"peer_name":         peer.PeerName,
-				"wg_public_key":     peer.WGPublicKey,
-				"wg_private_key":    peer.WGPrivateKey,
-				"wg_address":        peer.WGAddress,
-				"wg_endpoint":       peer.WGEndpoint,
-				"remote_networks":   peer.RemoteNetworks,
-				"_wireguard_config": generateWireGuardConfig(peer, result.WGPublicKey, result.WGPrefix),
-			}
-			enhancedWGPeers[i] = enhancedPeer
-		}
-		enhancedResult["wg_peers"] = enhancedWGPeers
-	} else {
-		enhancedResult["wg_peers"] = result.WGPeers
-	}
+	enhancedResult["wg_peers"] = wgPeerViews(result)
 
 	// Enrich with client name
-	enrichedWithClient := enrichWithClientName(enhancedResult)
+	enrichedWithClient := enrichWithClientName(ctx, enhancedResult)
 
 	jsonData, err := json.MarshalIndent(enrichedWithClient, "", "  ")
 	if err != nil {
@@ -3671,14 +3505,14 @@ func updateNetwork(args map[string]interface{}) (string, error) {
 	return string(jsonData), nil
 }
 
-func deleteNetwork(args map[string]interface{}) (string, error) {
+func deleteNetwork(ctx context.Context, args map[string]interface{}) (string, error) {
 	networkID, ok := args["network_id"].(string)
 	if !ok {
 		return "", fmt.Errorf("network_id is required")
 	}
 
 	endpoint := fmt.Sprintf("/v1/network/%s", networkID)
-	_, err := makeAPIRequest("DELETE", endpoint, nil)
+	_, err := makeAPIRequest(ctx, "DELETE", endpoint, nil)
 	if err != nil {
 		return "", err
 	}
@@ -3687,7 +3521,7 @@ func deleteNetwork(args map[string]interface{}) (string, error) {
 }
 
 // Network IPsec Connection functions
-func createNetworkIPSecConn(args map[string]interface{}) (string, error) {
+func createNetworkIPSecConn(ctx context.Context, args map[string]interface{}) (string, error) {
 	networkID, ok := args["network_id"].(string)
 	if !ok {
 		return "", fmt.Errorf("network_id is required")
@@ -3736,7 +3570,7 @@ func createNetworkIPSecConn(args map[string]interface{}) (string, error) {
 	}
 
 	endpoint := fmt.Sprintf("/v1/network/%s/ipsec", networkID)
-	data, err := makeAPIRequest("POST", endpoint, body)
+	data, err := makeAPIRequest(ctx, "POST", endpoint, body)
 	if err != nil {
 		return "", err
 	}
@@ -3754,7 +3588,7 @@ func createNetworkIPSecConn(args map[string]interface{}) (string, error) {
 	return string(jsonData), nil
 }
 
-func updateNetworkIPSecConn(args map[string]interface{}) (string, error) {
+func updateNetworkIPSecConn(ctx context.Context, args map[string]interface{}) (string, error) {
 	networkID, ok := args["network_id"].(string)
 	if !ok {
 		return "", fmt.Errorf("network_id is required")
@@ -3804,7 +3638,7 @@ func updateNetworkIPSecConn(args map[string]interface{}) (string, error) {
 	}
 
 	endpoint := fmt.Sprintf("/v1/network/%s/ipsec/%s", networkID, ipsecID)
-	data, err := makeAPIRequest("PATCH", endpoint, body)
+	data, err := makeAPIRequest(ctx, "PATCH", endpoint, body)
 	if err != nil {
 		return "", err
 	}
@@ -3822,7 +3656,7 @@ func updateNetworkIPSecConn(args map[string]interface{}) (string, error) {
 	return string(jsonData), nil
 }
 
-func deleteNetworkIPSecConn(args map[string]interface{}) (string, error) {
+func deleteNetworkIPSecConn(ctx context.Context, args map[string]interface{}) (string, error) {
 	networkID, ok := args["network_id"].(string)
 	if !ok {
 		return "", fmt.Errorf("network_id is required")
@@ -3833,7 +3667,7 @@ func deleteNetworkIPSecConn(args map[string]interface{}) (string, error) {
 	}
 
 	endpoint := fmt.Sprintf("/v1/network/%s/ipsec/%s", networkID, ipsecID)
-	_, err := makeAPIRequest("DELETE", endpoint, nil)
+	_, err := makeAPIRequest(ctx, "DELETE", endpoint, nil)
 	if err != nil {
 		return "", err
 	}
@@ -3842,7 +3676,7 @@ func deleteNetworkIPSecConn(args map[string]interface{}) (string, error) {
 }
 
 // Network Port Forward functions
-func createNetworkPortForward(args map[string]interface{}) (string, error) {
+func createNetworkPortForward(ctx context.Context, args map[string]interface{}) (string, error) {
 	networkID, ok := args["network_id"].(string)
 	if !ok {
 		return "", fmt.Errorf("network_id is required")
@@ -3867,7 +3701,7 @@ func createNetworkPortForward(args map[string]interface{}) (string, error) {
 	}
 
 	endpoint := fmt.Sprintf("/v1/network/%s/port-forward", networkID)
-	data, err := makeAPIRequest("POST", endpoint, body)
+	data, err := makeAPIRequest(ctx, "POST", endpoint, body)
 	if err != nil {
 		return "", err
 	}
@@ -3885,7 +3719,7 @@ func createNetworkPortForward(args map[string]interface{}) (string, error) {
 	return string(jsonData), nil
 }
 
-func updateNetworkPortForward(args map[string]interface{}) (string, error) {
+func updateNetworkPortForward(ctx context.Context, args map[string]interface{}) (string, error) {
 	networkID, ok := args["network_id"].(string)
 	if !ok {
 		return "", fmt.Errorf("network_id is required")
@@ -3910,7 +3744,7 @@ func updateNetworkPortForward(args map[string]interface{}) (string, error) {
 	}
 
 	endpoint := fmt.Sprintf("/v1/network/%s/port-forward/%s", networkID, portForwardID)
-	data, err := makeAPIRequest("PATCH", endpoint, body)
+	data, err := makeAPIRequest(ctx, "PATCH", endpoint, body)
 	if err != nil {
 		return "", err
 	}
@@ -3928,7 +3762,7 @@ func updateNetworkPortForward(args map[string]interface{}) (string, error) {
 	return string(jsonData), nil
 }
 
-func deleteNetworkPortForward(args map[string]interface{}) (string, error) {
+func deleteNetworkPortForward(ctx context.Context, args map[string]interface{}) (string, error) {
 	networkID, ok := args["network_id"].(string)
 	if !ok {
 		return "", fmt.Errorf("network_id is required")
@@ -3939,7 +3773,7 @@ func deleteNetworkPortForward(args map[string]interface{}) (string, error) {
 	}
 
 	endpoint := fmt.Sprintf("/v1/network/%s/port-forward/%s", networkID, portForwardID)
-	_, err := makeAPIRequest("DELETE", endpoint, nil)
+	_, err := makeAPIRequest(ctx, "DELETE", endpoint, nil)
 	if err != nil {
 		return "", err
 	}
@@ -3948,7 +3782,7 @@ func deleteNetworkPortForward(args map[string]interface{}) (string, error) {
 }
 
 // Network WireGuard Peer functions
-func createNetworkWGPeer(args map[string]interface{}) (string, error) {
+func createNetworkWGPeer(ctx context.Context, args map[string]interface{}) (string, error) {
 	networkID, ok := args["network_id"].(string)
 	if !ok {
 		return "", fmt.Errorf("network_id is required")
@@ -3983,7 +3817,7 @@ func createNetworkWGPeer(args map[string]interface{}) (string, error) {
 	}
 
 	endpoint := fmt.Sprintf("/v1/network/%s/wg-peer", networkID)
-	data, err := makeAPIRequest("POST", endpoint, body)
+	data, err := makeAPIRequest(ctx, "POST", endpoint, body)
 	if err != nil {
 		return "", err
 	}
@@ -3993,39 +3827,50 @@ func createNetworkWGPeer(args map[string]interface{}) (string, error) {
 		return "", fmt.Errorf("failed to parse response: %w", err)
 	}
 
-	// Enhanced result with WireGuard config
+	// This is the only response that carries the private key; see
+	// wireguard.go. The peer exists now, so from here on failures become
+	// warnings rather than errors that would lose the key.
+	var warnings []string
+	serverPublicKey, networkPrefix := wgServerKeyPlaceholder, ""
+	if network, err := fetchNetwork(ctx, networkID); err != nil {
+		warnings = append(warnings, fmt.Sprintf("The peer was created, but the network lookup failed (%v), so the config's [Peer] PublicKey is a placeholder. Copy the server key from slide_recovery operation=get_network network_id=%s into it.", err, networkID))
+	} else {
+		serverPublicKey, networkPrefix = network.WGPublicKey, network.WGPrefix
+	}
+	wireguardConfig := generateWireGuardConfig(result, serverPublicKey, networkPrefix)
+	uri := wgConfigResourceURI(networkID, result.WGPeerID)
+	qrText, err := attachWireGuardConfig(ctx, uri, wireguardConfig)
+	if err != nil {
+		warnings = append(warnings, fmt.Sprintf("The config could not be attached as a QR code (%v); save _wireguard_config as a .conf file instead.", err))
+	}
+
 	enhancedResult := map[string]interface{}{
-		"wg_peer_id":      result.WGPeerID,
-		"peer_name":       result.PeerName,
-		"wg_public_key":   result.WGPublicKey,
-		"wg_private_key":  result.WGPrivateKey,
-		"wg_address":      result.WGAddress,
-		"wg_endpoint":     result.WGEndpoint,
-		"remote_networks": result.RemoteNetworks,
+		"wg_peer_id":          result.WGPeerID,
+		"peer_name":           result.PeerName,
+		"wg_public_key":       result.WGPublicKey,
+		"wg_private_key":      result.WGPrivateKey,
+		"wg_address":          result.WGAddress,
+		"wg_endpoint":         result.WGEndpoint,
+		"remote_networks":     result.RemoteNetworks,
+		"_wireguard_config":   wireguardConfig,
+		"_wireguard_qr":       qrText,
+		"filename":            wgConfigFilename(result.WGPeerID),
+		"config_resource_uri": uri,
 		"_metadata": map[string]interface{}{
 			"primary_identifier":    "wg_peer_id",
-			"presentation_guidance": "WireGuard peer configuration for VPN access to the network.",
-			"config_file_guidance":  "Use the _wireguard_config field to get a ready-to-use WireGuard configuration file. Save this as a .conf file and import it into your WireGuard client.",
-			"usage_instructions":    "1. Save the _wireguard_config as a .conf file, 2. Import into WireGuard client, 3. Connect to access the specified remote networks.",
+			"presentation_guidance": "WireGuard peer configuration for VPN access to the network. Show the user _wireguard_qr as-is in a code block so they can scan it with the WireGuard phone app.",
+			"config_file_guidance":  "Use the _wireguard_config field to get a ready-to-use WireGuard configuration file. Save this as a .conf file with the suggested 'filename' and import it into your WireGuard client. The same config is attached to this result, with a PNG QR code.",
+			"usage_instructions":    "1. Save the _wireguard_config as a .conf file or scan the QR code, 2. Import into WireGuard client, 3. Connect to access the specified remote networks.",
+			"private_key_notice":    "The private key is shown only in this response. Slide does not return it again and this server does not keep or log it; config_resource_uri and later network views show a placeholder. Save the config or scan the QR code now. If the key is lost, delete this peer and create a new one.",
 		},
 	}
-
-	// Get network details to retrieve server public key
-	networkEndpoint := fmt.Sprintf("/v1/network/%s", networkID)
-	networkData, err := makeAPIRequest("GET", networkEndpoint, nil)
-	if err != nil {
-		return "", fmt.Errorf("failed to get network details: %w", err)
+	if qrText == "" {
+		delete(enhancedResult, "_wireguard_qr")
 	}
-
-	var network Network
-	if err := json.Unmarshal(networkData, &network); err != nil {
-		return "", fmt.Errorf("failed to parse network response: %w", err)
+	if len(warnings) > 0 {
+		enhancedResult["warning"] = strings.Join(warnings, " ")
 	}
 
-	// Generate WireGuard config with actual server public key
-	wireguardConfig := generateWireGuardConfig(result, network.WGPublicKey, network.WGPrefix)
-	enhancedResult["_wireguard_config"] = wireguardConfig
-
 	jsonData, err := json.MarshalIndent(enhancedResult, "", "  ")
 	if err != nil {
 		return "", fmt.Errorf("failed to marshal result: %w", err)
@@ -4034,7 +3879,7 @@ func createNetworkWGPeer(args map[string]interface{}) (string, error) {
 	return string(jsonData), nil
 }
 
-func updateNetworkWGPeer(args map[string]interface{}) (string, error) {
+func updateNetworkWGPeer(ctx context.Context, args map[string]interface{}) (string, error) {
 	networkID, ok := args["network_id"].(string)
 	if !ok {
 		return "", fmt.Errorf("network_id is required")
@@ -4070,7 +3915,7 @@ func updateNetworkWGPeer(args map[string]interface{}) (string, error) {
 	}
 
 	endpoint := fmt.Sprintf("/v1/network/%s/wg-peer/%s", networkID, wgPeerID)
-	data, err := makeAPIRequest("PATCH", endpoint, body)
+	data, err := makeAPIRequest(ctx, "PATCH", endpoint, body)
 	if err != nil {
 		return "", err
 	}
@@ -4080,39 +3925,19 @@ func updateNetworkWGPeer(args map[string]interface{}) (string, error) {
 		return "", fmt.Errorf("failed to parse response: %w", err)
 	}
 
-	// Enhanced result with WireGuard config
-	enhancedResult := map[string]interface{}{
-		"wg_peer_id":      result.WGPeerID,
-		"peer_name":       result.PeerName,
-		"wg_public_key":   result.WGPublicKey,
-		"wg_private_key":  result.WGPrivateKey,
-		"wg_address":      result.WGAddress,
-		"wg_endpoint":     result.WGEndpoint,
-		"remote_networks": result.RemoteNetworks,
-		"_metadata": map[string]interface{}{
-			"primary_identifier":    "wg_peer_id",
-			"presentation_guidance": "Updated WireGuard peer configuration for VPN access to the network.",
-			"config_file_guidance":  "Use the _wireguard_config field to get a ready-to-use WireGuard configuration file. Save this as a .conf file and import it into your WireGuard client.",
-			"usage_instructions":    "1. Save the _wireguard_config as a .conf file, 2. Import into WireGuard client, 3. Connect to access the specified remote networks.",
-		},
-	}
-
-	// Get network details to retrieve server public key
-	networkEndpoint := fmt.Sprintf("/v1/network/%s", networkID)
-	networkData, err := makeAPIRequest("GET", networkEndpoint, nil)
+	// The server public key lives on the network.
+	network, err := fetchNetwork(ctx, networkID)
 	if err != nil {
-		return "", fmt.Errorf("failed to get network details: %w", err)
+		return "", err
 	}
 
-	var network Network
-	if err := json.Unmarshal(networkData, &network); err != nil {
-		return "", fmt.Errorf("failed to parse network response: %w", err)
+	enhancedResult := wgPeerView(result, *network)
+	enhancedResult["_metadata"] = map[string]interface{}{
+		"primary_identifier":    "wg_peer_id",
+		"presentation_guidance": "Updated WireGuard peer configuration for VPN access to the network.",
+		"config_file_guidance":  "The _wireguard_config field has the updated configuration with a placeholder for the private key, which is shown only once, when the peer is created. Re-import it into the WireGuard client with that key, or delete and recreate the peer for a fresh config and QR code.",
 	}
 
-	// Generate WireGuard config with actual server public key
-	wireguardConfig := generateWireGuardConfig(result, network.WGPublicKey, network.WGPrefix)
-	enhancedResult["_wireguard_config"] = wireguardConfig
-
 	jsonData, err := json.MarshalIndent(enhancedResult, "", "  ")
 	if err != nil {
 		return "", fmt.Errorf("failed to marshal result: %w", err)
@@ -4121,7 +3946,7 @@ func updateNetworkWGPeer(args map[string]interface{}) (string, error) {
 	return string(jsonData), nil
 }
 
-func deleteNetworkWGPeer(args map[string]interface{}) (string, error) {
+func deleteNetworkWGPeer(ctx context.Context, args map[string]interface{}) (string, error) {
 	networkID, ok := args["network_id"].(string)
 	if !ok {
 		return "", fmt.Errorf("network_id is required")
@@ -4132,7 +3957,7 @@ func deleteNetworkWGPeer(args map[string]interface{}) (string, error) {
 	}
 
 	endpoint := fmt.Sprintf("/v1/network/%s/wg_peer/%s", networkID, wgPeerID)
-	_, err := makeAPIRequest("DELETE", endpoint, nil)
+	_, err := makeAPIRequest(ctx, "DELETE", endpoint, nil)
 	if err != nil {
 		return "", err
 	}
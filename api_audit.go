@@ -4,6 +4,7 @@ package main
 // AuditResources). Critical for compliance + "what just changed" questions.
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/url"
@@ -38,7 +39,7 @@ type AuditResourceType struct {
 }
 
 // listAudits wraps GET /v1/audit.
-func listAudits(opts auditQueryOpts) (*PaginatedResponse[Audit], error) {
+func listAudits(ctx context.Context, opts auditQueryOpts) (*PaginatedResponse[Audit], error) {
 	params := url.Values{}
 	if opts.Limit > 0 {
 		params.Set("limit", strconv.Itoa(opts.Limit))
@@ -68,7 +69,7 @@ func listAudits(opts auditQueryOpts) (*PaginatedResponse[Audit], error) {
 	if len(params) > 0 {
 		endpoint += "?" + params.Encode()
 	}
-	body, err := makeAPIRequest("GET", endpoint, nil)
+	body, err := makeAPIRequest(ctx, "GET", endpoint, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -91,11 +92,11 @@ type auditQueryOpts struct {
 }
 
 // getAudit wraps GET /v1/audit/{audit_id}.
-func getAudit(auditID string) (*Audit, error) {
+func getAudit(ctx context.Context, auditID string) (*Audit, error) {
 	if auditID == "" {
 		return nil, fmt.Errorf("audit_id is required")
 	}
-	body, err := makeAPIRequest("GET", fmt.Sprintf("/v1/audit/%s", url.PathEscape(auditID)), nil)
+	body, err := makeAPIRequest(ctx, "GET", fmt.Sprintf("/v1/audit/%s", url.PathEscape(auditID)), nil)
 	if err != nil {
 		return nil, err
 	}
@@ -107,7 +108,7 @@ func getAudit(auditID string) (*Audit, error) {
 }
 
 // listAuditActions wraps GET /v1/audit/action.
-func listAuditActions(limit, offset int) (*PaginatedResponse[AuditAction], error) {
+func listAuditActions(ctx context.Context, limit, offset int) (*PaginatedResponse[AuditAction], error) {
 	params := url.Values{}
 	if limit > 0 {
 		params.Set("limit", strconv.Itoa(limit))
@@ -119,7 +120,7 @@ func listAuditActions(limit, offset int) (*PaginatedResponse[AuditAction], error
 	if len(params) > 0 {
 		endpoint += "?" + params.Encode()
 	}
-	body, err := makeAPIRequest("GET", endpoint, nil)
+	body, err := makeAPIRequest(ctx, "GET", endpoint, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -131,7 +132,7 @@ func listAuditActions(limit, offset int) (*PaginatedResponse[AuditAction], error
 }
 
 // listAuditResourceTypes wraps GET /v1/audit/resource.
-func listAuditResourceTypes(limit, offset int) (*PaginatedResponse[AuditResourceType], error) {
+func listAuditResourceTypes(ctx context.Context, limit, offset int) (*PaginatedResponse[AuditResourceType], error) {
 	params := url.Values{}
 	if limit > 0 {
 		params.Set("limit", strconv.Itoa(limit))
@@ -143,7 +144,7 @@ func listAuditResourceTypes(limit, offset int) (*PaginatedResponse[AuditResource
 	if len(params) > 0 {
 		endpoint += "?" + params.Encode()
 	}
-	body, err := makeAPIRequest("GET", endpoint, nil)
+	body, err := makeAPIRequest(ctx, "GET", endpoint, nil)
 	if err != nil {
 		return nil, err
 	}
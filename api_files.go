@@ -7,6 +7,7 @@ package main
 // + path tuple ready for restore.
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/url"
@@ -30,7 +31,7 @@ type PathVersion struct {
 }
 
 // searchAgentFiles wraps GET /v1/agent/{agent_id}/file-search.
-func searchAgentFiles(agentID, searchTerm string, limit, offset int, sortBy string, sortAsc *bool) (*PaginatedResponse[FileIndexSearch], error) {
+func searchAgentFiles(ctx context.Context, agentID, searchTerm string, limit, offset int, sortBy string, sortAsc *bool) (*PaginatedResponse[FileIndexSearch], error) {
 	if agentID == "" {
 		return nil, fmt.Errorf("agent_id is required")
 	}
@@ -52,7 +53,7 @@ func searchAgentFiles(agentID, searchTerm string, limit, offset int, sortBy stri
 		params.Set("sort_asc", strconv.FormatBool(*sortAsc))
 	}
 	endpoint := fmt.Sprintf("/v1/agent/%s/file-search?%s", url.PathEscape(agentID), params.Encode())
-	body, err := makeAPIRequest("GET", endpoint, nil)
+	body, err := makeAPIRequest(ctx, "GET", endpoint, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -64,7 +65,7 @@ func searchAgentFiles(agentID, searchTerm string, limit, offset int, sortBy stri
 }
 
 // listPathVersions wraps GET /v1/agent/{agent_id}/file-search/version.
-func listPathVersions(agentID, path string, limit, offset int, sortBy string, sortAsc *bool) (*PaginatedResponse[PathVersion], error) {
+func listPathVersions(ctx context.Context, agentID, path string, limit, offset int, sortBy string, sortAsc *bool) (*PaginatedResponse[PathVersion], error) {
 	if agentID == "" {
 		return nil, fmt.Errorf("agent_id is required")
 	}
@@ -86,7 +87,7 @@ func listPathVersions(agentID, path string, limit, offset int, sortBy string, so
 		params.Set("sort_asc", strconv.FormatBool(*sortAsc))
 	}
 	endpoint := fmt.Sprintf("/v1/agent/%s/file-search/version?%s", url.PathEscape(agentID), params.Encode())
-	body, err := makeAPIRequest("GET", endpoint, nil)
+	body, err := makeAPIRequest(ctx, "GET", endpoint, nil)
 	if err != nil {
 		return nil, err
 	}
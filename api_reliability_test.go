@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -21,8 +22,10 @@ func useTestHTTPServer(t *testing.T, srv *httptest.Server) {
 	httpClient = srv.Client()
 	apiKey = "tk_test_reliability"
 	retrySleep = func(context.Context, time.Duration) error { return nil }
+	processSession.rateLimit = &rateLimitGate{}
 	t.Cleanup(func() {
 		APIBaseURL, httpClient, retrySleep, apiKey = oldBaseURL, oldClient, oldSleep, oldKey
+		processSession.rateLimit = &rateLimitGate{}
 	})
 }
 
@@ -46,13 +49,13 @@ func TestAPIRequestRetriesOnlyIdempotentMethods(t *testing.T) {
 	defer srv.Close()
 	useTestHTTPServer(t, srv)
 
-	if _, err := makeAPIRequest(http.MethodGet, "/read", nil); err != nil {
+	if _, err := makeAPIRequest(context.Background(), http.MethodGet, "/read", nil); err != nil {
 		t.Fatalf("GET should recover after one transient response: %v", err)
 	}
 	if got := getCalls.Load(); got != 2 {
 		t.Fatalf("GET calls = %d, want 2", got)
 	}
-	if _, err := makeAPIRequest(http.MethodPost, "/write", []byte(`{}`)); err == nil {
+	if _, err := makeAPIRequest(context.Background(), http.MethodPost, "/write", []byte(`{}`)); err == nil {
 		t.Fatal("POST should return the first transient error")
 	}
 	if got := postCalls.Load(); got != 1 {
@@ -78,7 +81,7 @@ func TestAPIRequestCapsRetryAfter(t *testing.T) {
 		waited = d
 		return nil
 	}
-	if _, err := makeAPIRequest(http.MethodGet, "/rate-limited", nil); err != nil {
+	if _, err := makeAPIRequest(context.Background(), http.MethodGet, "/rate-limited", nil); err != nil {
 		t.Fatalf("request failed: %v", err)
 	}
 	if waited != maxRetryAfter {
@@ -114,7 +117,7 @@ func TestAPIRequestRejectsOversizedResponse(t *testing.T) {
 	defer srv.Close()
 	useTestHTTPServer(t, srv)
 
-	_, err := makeAPIRequest(http.MethodGet, "/oversized", nil)
+	_, err := makeAPIRequest(context.Background(), http.MethodGet, "/oversized", nil)
 	if err == nil || !strings.Contains(err.Error(), "response exceeded") {
 		t.Fatalf("expected bounded-response error, got %v", err)
 	}
@@ -152,7 +155,7 @@ func TestFetchAllPaginated(t *testing.T) {
 	defer srv.Close()
 	useTestHTTPServer(t, srv)
 
-	clients, err := fetchAllPaginated[Client]("/v1/client?limit=1")
+	clients, err := fetchAllPaginated[Client](context.Background(), "/v1/client?limit=1")
 	if err != nil {
 		t.Fatalf("fetchAllPaginated: %v", err)
 	}
@@ -168,7 +171,7 @@ func TestFetchAllPaginatedRejectsLoop(t *testing.T) {
 	defer srv.Close()
 	useTestHTTPServer(t, srv)
 
-	_, err := fetchAllPaginated[Client]("/v1/client")
+	_, err := fetchAllPaginated[Client](context.Background(), "/v1/client")
 	if err == nil || !strings.Contains(err.Error(), "non-advancing") {
 		t.Fatalf("expected pagination-loop error, got %v", err)
 	}
@@ -196,7 +199,7 @@ func TestInventoryUsesThreeBulkReadsAndPreservesOrphans(t *testing.T) {
 	defer srv.Close()
 	useTestHTTPServer(t, srv)
 
-	out, err := listAllClientsDevicesAndAgents(nil)
+	out, err := listAllClientsDevicesAndAgents(context.Background(), nil)
 	if err != nil {
 		t.Fatalf("inventory: %v", err)
 	}
@@ -262,3 +265,110 @@ func ExampleAPIError() {
 	fmt.Println(strings.Contains(err.Error(), "status.slide.tech"))
 	// Output: true
 }
+
+func TestFanOutPreservesOrderAndBound(t *testing.T) {
+	setupTestEnv(t, ToolsSafe)
+	currentConfig().Concurrency = 4
+	items := make([]int, 40)
+	for i := range items {
+		items[i] = i
+	}
+	var inFlight, peak atomic.Int32
+	results, err := fanOut(context.Background(), items, func(_ context.Context, n int) string {
+		cur := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			old := peak.Load()
+			if cur <= old || peak.CompareAndSwap(old, cur) {
+				break
+			}
+		}
+		// Later items finish first, so completion order differs from input order.
+		time.Sleep(time.Duration(40-n) * 100 * time.Microsecond)
+		return fmt.Sprintf("item-%d", n)
+	})
+	if err != nil {
+		t.Fatalf("fanOut: %v", err)
+	}
+	for i, got := range results {
+		if want := fmt.Sprintf("item-%d", i); got != want {
+			t.Fatalf("results[%d] = %q, want %q", i, got, want)
+		}
+	}
+	if got := peak.Load(); got > 4 || got < 2 {
+		t.Fatalf("peak concurrency = %d, want between 2 and the configured 4", got)
+	}
+}
+
+func TestFanOutStopsAtDeadline(t *testing.T) {
+	setupTestEnv(t, ToolsSafe)
+	currentConfig().Concurrency = 3
+	ctx, cancel := context.WithTimeout(context.Background(), 25*time.Millisecond)
+	defer cancel()
+	var started atomic.Int32
+	began := time.Now()
+	results, err := fanOut(ctx, make([]struct{}, 100), func(ctx context.Context, _ struct{}) bool {
+		started.Add(1)
+		<-ctx.Done()
+		return true
+	})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err = %v, want deadline exceeded", err)
+	}
+	if elapsed := time.Since(began); elapsed > time.Second {
+		t.Fatalf("fanOut took %v to honour the deadline", elapsed)
+	}
+	if got := started.Load(); got > 3 {
+		t.Fatalf("%d items started after the deadline; only the 3 in flight should run", got)
+	}
+	if len(results) != 100 || results[99] {
+		t.Fatal("unstarted items should keep their zero value")
+	}
+}
+
+func TestRateLimitSharedAcrossRequests(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/limited" && calls.Add(1) == 1 {
+			w.Header().Set("Retry-After", "2")
+			http.Error(w, "slow down", http.StatusTooManyRequests)
+			return
+		}
+		io.WriteString(w, `{"ok":true}`)
+	}))
+	defer srv.Close()
+	useTestHTTPServer(t, srv)
+
+	var mu sync.Mutex
+	var waits []time.Duration
+	retrySleep = func(_ context.Context, d time.Duration) error {
+		mu.Lock()
+		waits = append(waits, d)
+		mu.Unlock()
+		return nil
+	}
+	if _, err := makeAPIRequest(context.Background(), http.MethodGet, "/limited", nil); err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	if len(waits) != 1 || waits[0] != 2*time.Second {
+		t.Fatalf("429 request waits = %v, want one 2s Retry-After sleep", waits)
+	}
+
+	// The stubbed sleep returns at once, so the hold is still in force and
+	// an unrelated request on the same identity must wait it out too.
+	if _, err := makeAPIRequest(context.Background(), http.MethodGet, "/other", nil); err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	if len(waits) != 2 || waits[1] <= time.Second || waits[1] > 2*time.Second {
+		t.Fatalf("second request waits = %v, want it held by the shared Retry-After", waits)
+	}
+
+	// A different tenant has its own gate.
+	tenant := context.WithValue(context.Background(), apiSessionContextKey{}, newAPISession("tk_other"))
+	if _, err := makeAPIRequest(tenant, http.MethodGet, "/other", nil); err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	if len(waits) != 2 {
+		t.Fatalf("another identity was held by this one's rate limit: %v", waits)
+	}
+}
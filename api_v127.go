@@ -5,6 +5,7 @@ package main
 // exists only to keep the diff readable when extending coverage.
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"strings"
@@ -26,11 +27,11 @@ type agentServicesPatchResponse struct {
 	Data []AgentServiceUpdateItem `json:"data"`
 }
 
-func listAgentServices(agentID string) ([]AgentService, error) {
+func listAgentServices(ctx context.Context, agentID string) ([]AgentService, error) {
 	if agentID == "" {
 		return nil, fmt.Errorf("agent_id is required")
 	}
-	body, err := makeAPIRequest("GET", fmt.Sprintf("/v1/agent/%s/service", agentID), nil)
+	body, err := makeAPIRequest(ctx, "GET", fmt.Sprintf("/v1/agent/%s/service", agentID), nil)
 	if err != nil {
 		return nil, err
 	}
@@ -41,7 +42,7 @@ func listAgentServices(agentID string) ([]AgentService, error) {
 	return resp.Data, nil
 }
 
-func updateAgentServices(agentID string, items []AgentServiceUpdateItem) ([]AgentServiceUpdateItem, error) {
+func updateAgentServices(ctx context.Context, agentID string, items []AgentServiceUpdateItem) ([]AgentServiceUpdateItem, error) {
 	if agentID == "" {
 		return nil, fmt.Errorf("agent_id is required")
 	}
@@ -52,7 +53,7 @@ func updateAgentServices(agentID string, items []AgentServiceUpdateItem) ([]Agen
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal services payload: %w", err)
 	}
-	body, err := makeAPIRequest("PATCH", fmt.Sprintf("/v1/agent/%s/service", agentID), payload)
+	body, err := makeAPIRequest(ctx, "PATCH", fmt.Sprintf("/v1/agent/%s/service", agentID), payload)
 	if err != nil {
 		return nil, err
 	}
@@ -72,11 +73,11 @@ type SnapshotServiceVerification struct {
 	Services []ServiceVerificationResult `json:"services"`
 }
 
-func getSnapshotServiceVerification(snapshotID string) (*SnapshotServiceVerification, error) {
+func getSnapshotServiceVerification(ctx context.Context, snapshotID string) (*SnapshotServiceVerification, error) {
 	if snapshotID == "" {
 		return nil, fmt.Errorf("snapshot_id is required")
 	}
-	body, err := makeAPIRequest("GET", fmt.Sprintf("/v1/snapshot/%s/service-verification", snapshotID), nil)
+	body, err := makeAPIRequest(ctx, "GET", fmt.Sprintf("/v1/snapshot/%s/service-verification", snapshotID), nil)
 	if err != nil {
 		return nil, err
 	}
@@ -91,11 +92,11 @@ func getSnapshotServiceVerification(snapshotID string) (*SnapshotServiceVerifica
 // User avatar (GET /v1/user/{user_id}/avatar)
 // -------------------------------------------------------------------------
 
-func getUserAvatar(userID string) (*Avatar, error) {
+func getUserAvatar(ctx context.Context, userID string) (*Avatar, error) {
 	if userID == "" {
 		return nil, fmt.Errorf("user_id is required")
 	}
-	body, err := makeAPIRequest("GET", fmt.Sprintf("/v1/user/%s/avatar", userID), nil)
+	body, err := makeAPIRequest(ctx, "GET", fmt.Sprintf("/v1/user/%s/avatar", userID), nil)
 	if err != nil {
 		return nil, err
 	}
@@ -110,11 +111,11 @@ func getUserAvatar(userID string) (*Avatar, error) {
 // Device network (GET/PATCH /v1/device/{device_id}/network)
 // -------------------------------------------------------------------------
 
-func getDeviceNetwork(deviceID string) (*DeviceNetwork, error) {
+func getDeviceNetwork(ctx context.Context, deviceID string) (*DeviceNetwork, error) {
 	if deviceID == "" {
 		return nil, fmt.Errorf("device_id is required")
 	}
-	body, err := makeAPIRequest("GET", fmt.Sprintf("/v1/device/%s/network", deviceID), nil)
+	body, err := makeAPIRequest(ctx, "GET", fmt.Sprintf("/v1/device/%s/network", deviceID), nil)
 	if err != nil {
 		return nil, err
 	}
@@ -125,7 +126,7 @@ func getDeviceNetwork(deviceID string) (*DeviceNetwork, error) {
 	return &resp, nil
 }
 
-func updateDeviceNetwork(deviceID string, payload map[string]interface{}) (*DeviceNetwork, error) {
+func updateDeviceNetwork(ctx context.Context, deviceID string, payload map[string]interface{}) (*DeviceNetwork, error) {
 	if deviceID == "" {
 		return nil, fmt.Errorf("device_id is required")
 	}
@@ -133,7 +134,7 @@ func updateDeviceNetwork(deviceID string, payload map[string]interface{}) (*Devi
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal device network update: %w", err)
 	}
-	resp, err := makeAPIRequest("PATCH", fmt.Sprintf("/v1/device/%s/network", deviceID), body)
+	resp, err := makeAPIRequest(ctx, "PATCH", fmt.Sprintf("/v1/device/%s/network", deviceID), body)
 	if err != nil {
 		return nil, err
 	}
@@ -152,11 +153,11 @@ type deviceVLANListResponse struct {
 	Data []DeviceVLAN `json:"data"`
 }
 
-func listDeviceVLANs(deviceID string) ([]DeviceVLAN, error) {
+func listDeviceVLANs(ctx context.Context, deviceID string) ([]DeviceVLAN, error) {
 	if deviceID == "" {
 		return nil, fmt.Errorf("device_id is required")
 	}
-	body, err := makeAPIRequest("GET", fmt.Sprintf("/v1/device/%s/vlan", deviceID), nil)
+	body, err := makeAPIRequest(ctx, "GET", fmt.Sprintf("/v1/device/%s/vlan", deviceID), nil)
 	if err != nil {
 		return nil, err
 	}
@@ -167,11 +168,11 @@ func listDeviceVLANs(deviceID string) ([]DeviceVLAN, error) {
 	return resp.Data, nil
 }
 
-func getDeviceVLAN(deviceID, vlanID string) (*DeviceVLAN, error) {
+func getDeviceVLAN(ctx context.Context, deviceID, vlanID string) (*DeviceVLAN, error) {
 	if deviceID == "" || vlanID == "" {
 		return nil, fmt.Errorf("device_id and vlan_id are required")
 	}
-	body, err := makeAPIRequest("GET", fmt.Sprintf("/v1/device/%s/vlan/%s", deviceID, vlanID), nil)
+	body, err := makeAPIRequest(ctx, "GET", fmt.Sprintf("/v1/device/%s/vlan/%s", deviceID, vlanID), nil)
 	if err != nil {
 		return nil, err
 	}
@@ -182,7 +183,7 @@ func getDeviceVLAN(deviceID, vlanID string) (*DeviceVLAN, error) {
 	return &resp, nil
 }
 
-func createDeviceVLAN(deviceID string, payload map[string]interface{}) (*DeviceVLAN, error) {
+func createDeviceVLAN(ctx context.Context, deviceID string, payload map[string]interface{}) (*DeviceVLAN, error) {
 	if deviceID == "" {
 		return nil, fmt.Errorf("device_id is required")
 	}
@@ -190,7 +191,7 @@ func createDeviceVLAN(deviceID string, payload map[string]interface{}) (*DeviceV
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal VLAN create payload: %w", err)
 	}
-	resp, err := makeAPIRequest("POST", fmt.Sprintf("/v1/device/%s/vlan", deviceID), body)
+	resp, err := makeAPIRequest(ctx, "POST", fmt.Sprintf("/v1/device/%s/vlan", deviceID), body)
 	if err != nil {
 		return nil, err
 	}
@@ -201,7 +202,7 @@ func createDeviceVLAN(deviceID string, payload map[string]interface{}) (*DeviceV
 	return &out, nil
 }
 
-func updateDeviceVLAN(deviceID, vlanID string, payload map[string]interface{}) (*DeviceVLAN, error) {
+func updateDeviceVLAN(ctx context.Context, deviceID, vlanID string, payload map[string]interface{}) (*DeviceVLAN, error) {
 	if deviceID == "" || vlanID == "" {
 		return nil, fmt.Errorf("device_id and vlan_id are required")
 	}
@@ -209,7 +210,7 @@ func updateDeviceVLAN(deviceID, vlanID string, payload map[string]interface{}) (
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal VLAN update payload: %w", err)
 	}
-	resp, err := makeAPIRequest("PATCH", fmt.Sprintf("/v1/device/%s/vlan/%s", deviceID, vlanID), body)
+	resp, err := makeAPIRequest(ctx, "PATCH", fmt.Sprintf("/v1/device/%s/vlan/%s", deviceID, vlanID), body)
 	if err != nil {
 		return nil, err
 	}
@@ -220,11 +221,11 @@ func updateDeviceVLAN(deviceID, vlanID string, payload map[string]interface{}) (
 	return &out, nil
 }
 
-func deleteDeviceVLAN(deviceID, vlanID string) error {
+func deleteDeviceVLAN(ctx context.Context, deviceID, vlanID string) error {
 	if deviceID == "" || vlanID == "" {
 		return fmt.Errorf("device_id and vlan_id are required")
 	}
-	if _, err := makeAPIRequest("DELETE", fmt.Sprintf("/v1/device/%s/vlan/%s", deviceID, vlanID), nil); err != nil {
+	if _, err := makeAPIRequest(ctx, "DELETE", fmt.Sprintf("/v1/device/%s/vlan/%s", deviceID, vlanID), nil); err != nil {
 		return err
 	}
 	return nil
@@ -237,7 +238,7 @@ func deleteDeviceVLAN(deviceID, vlanID string) error {
 // patchAgent issues PATCH /v1/agent/{id} with the provided fields and returns
 // the resulting Agent. Centralizing this avoids each tools_agents.go operation
 // re-marshaling and re-parsing.
-func patchAgent(agentID string, payload map[string]interface{}) (*Agent, error) {
+func patchAgent(ctx context.Context, agentID string, payload map[string]interface{}) (*Agent, error) {
 	if agentID == "" {
 		return nil, fmt.Errorf("agent_id is required")
 	}
@@ -248,7 +249,7 @@ func patchAgent(agentID string, payload map[string]interface{}) (*Agent, error)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal agent patch: %w", err)
 	}
-	resp, err := makeAPIRequest("PATCH", fmt.Sprintf("/v1/agent/%s", agentID), body)
+	resp, err := makeAPIRequest(ctx, "PATCH", fmt.Sprintf("/v1/agent/%s", agentID), body)
 	if err != nil {
 		return nil, err
 	}
@@ -261,8 +262,8 @@ func patchAgent(agentID string, payload map[string]interface{}) (*Agent, error)
 
 // patchAgentJSON is a convenience that returns the full Agent as a pretty
 // JSON string, suitable for direct use as a tool result body.
-func patchAgentJSON(agentID string, payload map[string]interface{}) (string, error) {
-	agent, err := patchAgent(agentID, payload)
+func patchAgentJSON(ctx context.Context, agentID string, payload map[string]interface{}) (string, error) {
+	agent, err := patchAgent(ctx, agentID, payload)
 	if err != nil {
 		return "", err
 	}
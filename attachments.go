@@ -0,0 +1,49 @@
+package main
+
+// Extra content blocks for tool results.
+//
+// Handlers return a JSON string, which adaptToolHandler turns into the
+// text content and structuredContent of the result. A handler that also
+// has a file to hand over (an .rdp bookmark, a QR code) calls
+// attachContent, and adaptToolHandler appends those blocks after the
+// text. Outside an MCP call (--tool) there is no collector and attached
+// content is dropped; the JSON body must stand on its own.
+
+import (
+	"context"
+	"sync"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+type attachmentCollector struct {
+	mu      sync.Mutex
+	content []mcp.Content
+}
+
+type attachmentsContextKey struct{}
+
+// withAttachments returns a context whose handlers can attach content,
+// and the collector to read it back from.
+func withAttachments(ctx context.Context) (context.Context, *attachmentCollector) {
+	c := &attachmentCollector{}
+	return context.WithValue(ctx, attachmentsContextKey{}, c), c
+}
+
+// attachContent adds a block to the current tool result, if any.
+func attachContent(ctx context.Context, content mcp.Content) {
+	c, ok := ctx.Value(attachmentsContextKey{}).(*attachmentCollector)
+	if !ok {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.content = append(c.content, content)
+}
+
+// blocks returns the attached content in attachment order.
+func (c *attachmentCollector) blocks() []mcp.Content {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.content
+}
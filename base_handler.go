@@ -1,13 +1,14 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"sort"
 	"strings"
 )
 
 // OperationHandler represents a function that handles a specific operation
-type OperationHandler func(map[string]interface{}) (string, error)
+type OperationHandler func(context.Context, map[string]interface{}) (string, error)
 
 // ToolOperations maps operation names to their handler functions
 type ToolOperations map[string]OperationHandler
@@ -27,18 +28,18 @@ type BaseToolConfig struct {
 // HandleToolWithOperations provides a shared base handler that eliminates boilerplate
 // across all tool files by handling:
 // 1. Operation parameter extraction and validation
-// 2. Permission checking via config.IsOperationAllowed()
+// 2. Permission checking via currentConfig().IsOperationAllowed()
 // 3. Optional name_hint -> *_id resolution before dispatch
 // 4. Operation dispatch to specific handlers
 // 5. Standardized error handling
-func HandleToolWithOperations(toolConfig BaseToolConfig, args map[string]interface{}) (string, error) {
+func HandleToolWithOperations(ctx context.Context, toolConfig BaseToolConfig, args map[string]interface{}) (string, error) {
 	operation, ok := args["operation"].(string)
 	if !ok {
 		return "", fmt.Errorf("operation parameter is required")
 	}
 
-	if !config.IsOperationAllowed(toolConfig.ToolName, operation) {
-		return "", fmt.Errorf("operation '%s' not available for %s in '%s' mode", operation, toolConfig.ToolName, config.ToolsMode)
+	if cfg := currentConfig(); !cfg.IsOperationAllowed(toolConfig.ToolName, operation) {
+		return "", fmt.Errorf("operation '%s' not available for %s in '%s' mode", operation, toolConfig.ToolName, cfg.ToolsMode)
 	}
 
 	handler, exists := toolConfig.Operations[operation]
@@ -47,7 +48,7 @@ func HandleToolWithOperations(toolConfig BaseToolConfig, args map[string]interfa
 	}
 
 	if spec, ok := toolConfig.Resolutions[operation]; ok {
-		hintResp, err := resolveNameHint(args, spec)
+		hintResp, err := resolveNameHint(ctx, args, spec)
 		if err != nil {
 			return "", err
 		}
@@ -63,7 +64,7 @@ func HandleToolWithOperations(toolConfig BaseToolConfig, args map[string]interfa
 	// without each handler having to thread it through.
 	args["_tool"] = toolConfig.ToolName
 
-	return handler(args)
+	return handler(ctx, args)
 }
 
 // CreateToolConfig is a helper function to create tool configurations more easily
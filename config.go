@@ -23,6 +23,15 @@ const (
 	ToolsFull     = "full"      // everything, including delete/poweroff/reboot
 )
 
+// Transports. stdio is the default for desktop hosts that spawn the binary;
+// http serves Streamable HTTP (with SSE fallback) for a shared instance.
+const (
+	TransportStdio = "stdio"
+	TransportHTTP  = "http"
+
+	defaultListenAddr = "127.0.0.1:8080"
+)
+
 // legacy mode aliases (silently mapped to the new names)
 var legacyToolsModes = map[string]string{
 	"reporting": ToolsReadOnly,
@@ -38,6 +47,18 @@ type ServerConfig struct {
 	BaseURL       string
 	ToolsMode     string
 	DisabledTools []string
+	Transport     string
+	ListenAddr    string
+	HTTPAuthToken string // shared secret tokenless HTTP callers must send to use APIKey
+	Profile       string // named profile the identity came from, if any
+	APIKeySource  string // where APIKey came from, for diagnostics only
+	Concurrency   int    // max parallel requests per fan-out
+	RDPDir        string // where get_rdp_bookmark saves .rdp files, if set
+	RDPCache      bool   // upload .rdp files to the external slide.recipes cache
+	DownloadDir   string // where download/download_image save files; unset disables them
+	DownloadMaxMB int    // largest file download/download_image will fetch
+	ReportDir     string // where Markdown/HTML reports are saved, if set
+	JobsFile      string // where background jobs are saved across restarts, if set
 }
 
 // NewServerConfig creates a new configuration with defaults.
@@ -46,6 +67,10 @@ func NewServerConfig() *ServerConfig {
 		BaseURL:       "https://api.slide.tech",
 		ToolsMode:     ToolsSafe,
 		DisabledTools: []string{},
+		Transport:     TransportStdio,
+		ListenAddr:    defaultListenAddr,
+		Concurrency:   defaultConcurrency,
+		DownloadMaxMB: defaultDownloadMaxMB,
 	}
 }
 
@@ -92,21 +117,85 @@ func (c *ServerConfig) ValidateBaseURL() error {
 	if u.User != nil || u.RawQuery != "" || u.Fragment != "" {
 		return fmt.Errorf("Slide API base URL must not contain credentials, query parameters, or a fragment")
 	}
-	host := u.Hostname()
-	loopback := strings.EqualFold(host, "localhost")
-	if ip := net.ParseIP(host); ip != nil && ip.IsLoopback() {
-		loopback = true
-	}
-	if u.Scheme != "https" && !(u.Scheme == "http" && loopback) {
+	if u.Scheme != "https" && !(u.Scheme == "http" && isLoopbackHost(u.Hostname())) {
 		return fmt.Errorf("Slide API base URL must use HTTPS (HTTP is allowed only for localhost tests)")
 	}
 	return nil
 }
 
+// isLoopbackHost reports whether host names the local machine only. An
+// empty host (":8080") listens on every interface.
+func isLoopbackHost(host string) bool {
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// ValidateTransport normalizes the transport name and checks that the HTTP
+// listen address is a usable host:port pair. A server with its own token
+// must not hand it to anyone who can reach a non-loopback listener, so
+// that combination requires HTTPAuthToken.
+func (c *ServerConfig) ValidateTransport() error {
+	c.Transport = strings.ToLower(strings.TrimSpace(c.Transport))
+	switch c.Transport {
+	case "":
+		c.Transport = TransportStdio
+		return nil
+	case TransportStdio:
+		return nil
+	case TransportHTTP:
+		if c.ListenAddr == "" {
+			c.ListenAddr = defaultListenAddr
+		}
+		host, _, err := net.SplitHostPort(c.ListenAddr)
+		if err != nil {
+			return fmt.Errorf("invalid listen address %q: %v (expected host:port, e.g. :8080)", c.ListenAddr, err)
+		}
+		if c.APIKey != "" && c.HTTPAuthToken == "" && !isLoopbackHost(host) {
+			return fmt.Errorf("refusing to serve the server's Slide token on non-loopback address %q without --http-auth-token / SLIDE_HTTP_AUTH_TOKEN (or drop the server token so every caller brings their own)", c.ListenAddr)
+		}
+		return nil
+	}
+	return fmt.Errorf("invalid transport '%s'. Valid options: stdio, http", c.Transport)
+}
+
+// ValidateConcurrency keeps the fan-out width between 1 and maxConcurrency.
+func (c *ServerConfig) ValidateConcurrency() error {
+	if c.Concurrency == 0 {
+		c.Concurrency = defaultConcurrency
+	}
+	if c.Concurrency < 1 || c.Concurrency > maxConcurrency {
+		return fmt.Errorf("invalid concurrency %d. Valid range: 1-%d", c.Concurrency, maxConcurrency)
+	}
+	return nil
+}
+
+// ValidateDownloadMaxMB applies the default download size limit.
+func (c *ServerConfig) ValidateDownloadMaxMB() error {
+	if c.DownloadMaxMB == 0 {
+		c.DownloadMaxMB = defaultDownloadMaxMB
+	}
+	if c.DownloadMaxMB < 1 {
+		return fmt.Errorf("invalid download size limit %d MB: must be positive", c.DownloadMaxMB)
+	}
+	return nil
+}
+
 func (c *ServerConfig) Validate() error {
 	if err := c.ValidateToolsMode(); err != nil {
 		return err
 	}
+	if err := c.ValidateTransport(); err != nil {
+		return err
+	}
+	if err := c.ValidateConcurrency(); err != nil {
+		return err
+	}
+	if err := c.ValidateDownloadMaxMB(); err != nil {
+		return err
+	}
 	return c.ValidateBaseURL()
 }
 
@@ -167,7 +256,8 @@ func isReadOnlyTool(toolName string) bool {
 	// level; per-op gating filters writes via isReadOperation.
 	switch toolName {
 	case "slide_files", "slide_recovery", "slide_clients", "slide_admin",
-		"slide_devices", "slide_agents", "slide_snapshots", "slide_backups", "slide_alerts":
+		"slide_devices", "slide_agents", "slide_snapshots", "slide_backups", "slide_alerts",
+		"slide_jobs":
 		return true
 	}
 	return false
@@ -183,21 +273,21 @@ func isReadOperation(toolName, op string) bool {
 		return true
 	}
 	switch op {
-	case "list", "get", "browse", "search", "versions",
+	case "list", "get", "browse", "tree", "search", "search_client", "versions",
 		"recent", "actions", "resources",
 		"list_users", "get_user", "get_user_avatar",
-		"list_accounts", "get_account",
+		"list_accounts", "get_account", "switch_profile",
 		"list_clients", "get_client",
 		"list_services", "list_vlans", "get_vlan",
 		"get_network", "list_networks",
 		"get_service_verification",
-		"recent_for_agent", "status_for_client", "status_for_device",
+		"recent_for_agent", "status_for_client", "status_for_device", "status_all", "rpo_report",
 		"inventory", "health", "for_client", "for_device",
 		"list_restores", "get_restore", "list_pushes", "get_push_status",
 		"list_vms", "get_vm", "get_rdp_bookmark",
-		"list_images", "get_image", "browse_image",
+		"list_images", "get_image", "browse_image", "tree_image",
 		"list_deleted",
-		"triage",
+		"triage", "calendar", "verification_report",
 		// slide_help operations
 		"getting_started", "examples", "glossary", "troubleshoot",
 		"list_prompts", "list_resources", "what_can_you_do", "debug":
@@ -75,26 +75,26 @@ func TestOperationPermissionContract(t *testing.T) {
 		}
 	}
 
-	config.ToolsMode = ToolsReadOnly
+	currentConfig().ToolsMode = ToolsReadOnly
 	for _, info := range allToolInfos() {
 		for _, operation := range operationsFromInfo(t, info) {
 			if isReadOperation(info.Name, operation) {
 				continue
 			}
-			_, err := toolRegistry[info.Name](map[string]interface{}{"operation": operation})
+			_, err := toolRegistry[info.Name](context.Background(), map[string]interface{}{"operation": operation})
 			if err == nil || !strings.Contains(err.Error(), "not available") {
 				t.Errorf("read-only mode did not reject %s/%s before dispatch: %v", info.Name, operation, err)
 			}
 		}
 	}
 
-	config.ToolsMode = ToolsSafe
+	currentConfig().ToolsMode = ToolsSafe
 	for _, info := range allToolInfos() {
 		for _, operation := range operationsFromInfo(t, info) {
 			if !isDestructiveOperation(info.Name, operation) {
 				continue
 			}
-			_, err := toolRegistry[info.Name](map[string]interface{}{"operation": operation})
+			_, err := toolRegistry[info.Name](context.Background(), map[string]interface{}{"operation": operation})
 			if err == nil || !strings.Contains(err.Error(), "not available") {
 				t.Errorf("safe mode did not reject destructive %s/%s before dispatch: %v", info.Name, operation, err)
 			}
@@ -119,14 +119,34 @@ func (plainLogger) Printf(format string, args ...interface{}) {
 // maskedAPIKey returns a safe-to-paste rendering of the active token.
 // Shape: <first4>...<last2> (len=<n>), or "<empty>" if no token is set.
 func maskedAPIKey() string {
-	if apiKey == "" {
+	return maskToken(processSession.token())
+}
+
+// apiKeySourceLabel says where a session's token came from. Request-scoped
+// sessions always got theirs from the Authorization header.
+func apiKeySourceLabel(s *apiSession) string {
+	if s.apiKey != "" {
+		return "Authorization header"
+	}
+	if apiKeySourceErr != nil {
+		return "error: " + apiKeySourceErr.Error()
+	}
+	cfg := currentConfig()
+	if cfg == nil || cfg.APIKeySource == "" {
+		return "none"
+	}
+	return cfg.APIKeySource
+}
+
+func maskToken(token string) string {
+	if token == "" {
 		return "<empty>"
 	}
-	n := len(apiKey)
+	n := len(token)
 	if n <= 6 {
 		return fmt.Sprintf("<short> (len=%d)", n)
 	}
-	return fmt.Sprintf("%s...%s (len=%d)", apiKey[:4], apiKey[n-2:], n)
+	return fmt.Sprintf("%s...%s (len=%d)", token[:4], token[n-2:], n)
 }
 
 // envSource describes how a config value was sourced (CLI flag, env, default).
@@ -151,16 +171,26 @@ func envSnapshot() map[string]interface{} {
 	return map[string]interface{}{
 		"SLIDE_API_KEY":         mask(os.Getenv("SLIDE_API_KEY")),
 		"SLIDE_API_KEY_set":     os.Getenv("SLIDE_API_KEY") != "",
+		"SLIDE_API_KEY_FILE":    os.Getenv("SLIDE_API_KEY_FILE"),
+		"SLIDE_API_KEY_COMMAND": commandName(os.Getenv("SLIDE_API_KEY_COMMAND")),
+		"SLIDE_API_KEY_KEYRING": os.Getenv("SLIDE_API_KEY_KEYRING"),
 		"SLIDE_BASE_URL":        os.Getenv("SLIDE_BASE_URL"),
 		"SLIDE_TOOLS":           os.Getenv("SLIDE_TOOLS"),
 		"SLIDE_DISABLED_TOOLS":  os.Getenv("SLIDE_DISABLED_TOOLS"),
+		"SLIDE_TRANSPORT":       os.Getenv("SLIDE_TRANSPORT"),
+		"SLIDE_LISTEN":          os.Getenv("SLIDE_LISTEN"),
+		"SLIDE_CONCURRENCY":     os.Getenv("SLIDE_CONCURRENCY"),
+		"SLIDE_RDP_DIR":         os.Getenv("SLIDE_RDP_DIR"),
+		"SLIDE_RDP_CACHE":       os.Getenv("SLIDE_RDP_CACHE"),
+		"SLIDE_DOWNLOAD_DIR":    os.Getenv("SLIDE_DOWNLOAD_DIR"),
+		"SLIDE_DOWNLOAD_MAX_MB": os.Getenv("SLIDE_DOWNLOAD_MAX_MB"),
 	}
 }
 
 // dnsProbe resolves api.slide.tech (or whatever host base_url points at)
 // and reports the addresses. Useful to confirm DNS isn't being hijacked.
 func dnsProbe() map[string]interface{} {
-	host := APIBaseURL
+	host := processSession.baseURL()
 	host = strings.TrimPrefix(host, "https://")
 	host = strings.TrimPrefix(host, "http://")
 	if i := strings.IndexByte(host, '/'); i >= 0 {
@@ -188,12 +218,13 @@ func tlsProbe() map[string]interface{} {
 		TLSClientConfig: &tls.Config{InsecureSkipVerify: false},
 	}
 	cli := &http.Client{Timeout: 10 * time.Second, Transport: tr}
+	baseURL := processSession.baseURL()
 	start := time.Now()
-	req, _ := http.NewRequestWithContext(context.Background(), "GET", APIBaseURL+"/", nil)
+	req, _ := http.NewRequestWithContext(context.Background(), "GET", baseURL+"/", nil)
 	resp, err := cli.Do(req)
 	latency := time.Since(start).Milliseconds()
 	out := map[string]interface{}{
-		"url":        APIBaseURL + "/",
+		"url":        baseURL + "/",
 		"latency_ms": latency,
 	}
 	if err != nil {
@@ -215,13 +246,13 @@ func tlsProbe() map[string]interface{} {
 // endpointProbe makes one authenticated GET against an endpoint, capturing
 // status, latency, body excerpt, and error so the operator can see EXACTLY
 // what the Slide API is returning right now (no guessing from the LLM).
-func endpointProbe(method, endpoint string) map[string]interface{} {
+func endpointProbe(ctx context.Context, method, endpoint string) map[string]interface{} {
 	out := map[string]interface{}{
 		"method":   method,
 		"endpoint": endpoint,
 	}
 	start := time.Now()
-	body, err := makeAPIRequest(method, endpoint, nil)
+	body, err := makeAPIRequest(ctx, method, endpoint, nil)
 	latency := time.Since(start).Milliseconds()
 	out["latency_ms"] = latency
 
@@ -249,11 +280,12 @@ func endpointProbe(method, endpoint string) map[string]interface{} {
 }
 
 // nameResolverCacheSnapshot returns a redacted view of the cache for debug.
-func nameResolverCacheSnapshot() map[string]interface{} {
-	nameCacheMu.RLock()
-	defer nameCacheMu.RUnlock()
+func nameResolverCacheSnapshot(ctx context.Context) map[string]interface{} {
+	c := sessionFromContext(ctx).names
+	c.mu.RLock()
+	defer c.mu.RUnlock()
 	out := map[string]interface{}{}
-	for kind, entry := range nameCache {
+	for kind, entry := range c.entries {
 		out[kind] = map[string]interface{}{
 			"count":      len(entry.candidates),
 			"fetched_at": entry.fetchedAt.Format(time.RFC3339),
@@ -266,18 +298,34 @@ func nameResolverCacheSnapshot() map[string]interface{} {
 // gatherDebugInfo is the workhorse: builds a single structured map with
 // every diagnostic worth pasting back into a support thread. Safe to call
 // from a chat (no secrets emitted) and from --debug on the CLI.
-func gatherDebugInfo() map[string]interface{} {
+func gatherDebugInfo(ctx context.Context) map[string]interface{} {
+	session := sessionFromContext(ctx)
 	exe, _ := os.Executable()
 	host, _ := os.Hostname()
 	memStats := runtime.MemStats{}
 	runtime.ReadMemStats(&memStats)
 
+	conf := currentConfig()
 	cfg := map[string]interface{}{
-		"tools_mode":     config.ToolsMode,
-		"base_url":       config.BaseURL,
-		"disabled_tools": config.DisabledTools,
-		"api_key":        maskedAPIKey(),
-		"api_key_set":    apiKey != "",
+		"tools_mode":      conf.ToolsMode,
+		"base_url":        conf.BaseURL,
+		"disabled_tools":  conf.DisabledTools,
+		"api_key":         maskToken(session.token()),
+		"api_key_set":     session.token() != "",
+		"api_key_scope":   apiKeyScope(session),
+		"api_key_source":  apiKeySourceLabel(session),
+		"transport":       conf.Transport,
+		"listen_addr":     conf.ListenAddr,
+		"http_auth_token": conf.HTTPAuthToken != "",
+		"concurrency":     conf.Concurrency,
+		"rdp_dir":         conf.RDPDir,
+		"rdp_cache":       conf.RDPCache,
+		"download_dir":    conf.DownloadDir,
+		"download_max_mb": conf.DownloadMaxMB,
+		"report_dir":      conf.ReportDir,
+		"jobs_file":       conf.JobsFile,
+		"profile":         conf.Profile,
+		"config_file":     profileRuntime.path,
 	}
 
 	server := map[string]interface{}{
@@ -292,19 +340,19 @@ func gatherDebugInfo() map[string]interface{} {
 	}
 
 	runtimeInfo := map[string]interface{}{
-		"uptime_sec":  int64(time.Since(startedAt).Seconds()),
-		"goroutines":  runtime.NumGoroutine(),
-		"cpus":        runtime.NumCPU(),
-		"heap_mb":     memStats.HeapAlloc / 1024 / 1024,
-		"sys_mb":      memStats.Sys / 1024 / 1024,
+		"uptime_sec": int64(time.Since(startedAt).Seconds()),
+		"goroutines": runtime.NumGoroutine(),
+		"cpus":       runtime.NumCPU(),
+		"heap_mb":    memStats.HeapAlloc / 1024 / 1024,
+		"sys_mb":     memStats.Sys / 1024 / 1024,
 	}
 
 	probes := map[string]interface{}{}
-	if apiKey != "" {
-		probes["account"] = endpointProbe("GET", "/v1/account?limit=1")
-		probes["client"] = endpointProbe("GET", "/v1/client?limit=1")
-		probes["device"] = endpointProbe("GET", "/v1/device?limit=1")
-		probes["agent"] = endpointProbe("GET", "/v1/agent?limit=1")
+	if session.token() != "" {
+		probes["account"] = endpointProbe(ctx, "GET", "/v1/account?limit=1")
+		probes["client"] = endpointProbe(ctx, "GET", "/v1/client?limit=1")
+		probes["device"] = endpointProbe(ctx, "GET", "/v1/device?limit=1")
+		probes["agent"] = endpointProbe(ctx, "GET", "/v1/agent?limit=1")
 	} else {
 		probes["note"] = "No API token configured; skipping authenticated probes."
 	}
@@ -317,7 +365,7 @@ func gatherDebugInfo() map[string]interface{} {
 		"dns":                 dnsProbe(),
 		"tls":                 tlsProbe(),
 		"api_probes":          probes,
-		"name_resolver_cache": nameResolverCacheSnapshot(),
+		"name_resolver_cache": nameResolverCacheSnapshot(ctx),
 		"recent_logs":         logCapture.Snapshot(),
 		"generated_at":        time.Now().UTC().Format(time.RFC3339),
 		"hint":                "Paste this whole payload into a support thread if you're seeing errors. The api_key field is masked; no other secrets are emitted.",
@@ -327,7 +375,7 @@ func gatherDebugInfo() map[string]interface{} {
 // runDebug is the --debug CLI subcommand. Pretty-prints the debug bundle
 // to stdout as JSON and exits 0.
 func runDebug() {
-	info := gatherDebugInfo()
+	info := gatherDebugInfo(context.Background())
 	out, err := json.MarshalIndent(info, "", "  ")
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "failed to marshal debug info: %v\n", err)
@@ -18,6 +18,7 @@ package main
 //   2. Per-endpoint sample reads: /v1/client, /v1/device, /v1/agent.
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -32,8 +33,8 @@ import (
 // either (account_count, nil) on 200 or an APIError on non-2xx.
 // The count comes from the response data length, not pagination.total,
 // because the Slide API omits pagination.total for single-item responses.
-func probeAccount() (int, error) {
-	body, err := makeAPIRequest("GET", "/v1/account?limit=1", nil)
+func probeAccount(ctx context.Context) (int, error) {
+	body, err := makeAPIRequest(ctx, "GET", "/v1/account?limit=1", nil)
 	if err != nil {
 		return 0, err
 	}
@@ -49,8 +50,8 @@ func probeAccount() (int, error) {
 
 // accountSummary returns a short "as <name>" string for the startup log
 // from the same /v1/account probe. Empty string if no account is visible.
-func accountSummary() string {
-	body, err := makeAPIRequest("GET", "/v1/account?limit=1", nil)
+func accountSummary(ctx context.Context) string {
+	body, err := makeAPIRequest(ctx, "GET", "/v1/account?limit=1", nil)
 	if err != nil {
 		return ""
 	}
@@ -70,8 +71,8 @@ func accountSummary() string {
 
 // probeCount runs `GET <endpoint>?limit=1` and returns the total count
 // from the pagination envelope, or an error.
-func probeCount(endpoint string) (int, error) {
-	body, err := makeAPIRequest("GET", endpoint+"?limit=1", nil)
+func probeCount(ctx context.Context, endpoint string) (int, error) {
+	body, err := makeAPIRequest(ctx, "GET", endpoint+"?limit=1", nil)
 	if err != nil {
 		return 0, err
 	}
@@ -99,7 +100,7 @@ func authErrorHint(err error) string {
 		return ""
 	}
 	return strings.TrimSpace(`
-Your Slide API token was rejected by api.slide.tech (HTTP ` + fmt.Sprintf("%d", apiErr.StatusCode) + `).
+Your Slide API token was rejected by api.slide.tech (HTTP `+fmt.Sprintf("%d", apiErr.StatusCode)+`).
 
 To fix:
   1. Open https://console.slide.tech and sign in.
@@ -156,9 +157,10 @@ You can verify connectivity with:
 // the per-status APIError hints, and slide_help operation=troubleshoot
 // remains callable so the LLM can guide the user to a fix.
 func runStartupValidation() {
-	total, err := probeAccount()
+	ctx := context.Background()
+	total, err := probeAccount(ctx)
 	if err == nil {
-		summary := accountSummary()
+		summary := accountSummary(ctx)
 		if summary != "" {
 			stderrLogger.Printf("slide-mcp-server: connected to Slide as %s (%d account(s) visible)\n", summary, total)
 		} else {
@@ -200,9 +202,13 @@ type doctorCheck struct {
 // matters, prints a clean checklist, exits non-zero on any FAIL.
 // Reusable from CI (--doctor --json would be a future addition).
 func runDoctor() {
+	ctx := context.Background()
 	fmt.Printf("slide-mcp-server v%s -- doctor\n", Version)
 	fmt.Printf("Base URL: %s\n", APIBaseURL)
-	fmt.Printf("Tools mode: %s\n", config.ToolsMode)
+	fmt.Printf("Tools mode: %s\n", currentConfig().ToolsMode)
+	if currentConfig().Profile != "" {
+		fmt.Printf("Profile: %s (%s)\n", currentConfig().Profile, profileRuntime.path)
+	}
 	fmt.Println(strings.Repeat("-", 60))
 
 	checks := []doctorCheck{}
@@ -210,14 +216,13 @@ func runDoctor() {
 		checks = append(checks, doctorCheck{Name: name, Status: status, Detail: detail})
 	}
 
-	if apiKey == "" {
-		addCheck("API token configured", "FAIL", "no token provided via --api-key or SLIDE_API_KEY")
-	} else {
-		shown := apiKey
-		if len(shown) > 8 {
-			shown = shown[:4] + "..." + shown[len(shown)-2:]
-		}
-		addCheck("API token configured", "OK", fmt.Sprintf("token=%s", shown))
+	switch {
+	case apiKeySourceErr != nil:
+		addCheck("API token configured", "FAIL", apiKeySourceErr.Error())
+	case apiKey == "":
+		addCheck("API token configured", "FAIL", "no token provided via --api-key, SLIDE_API_KEY, SLIDE_API_KEY_FILE, SLIDE_API_KEY_COMMAND, SLIDE_API_KEY_KEYRING, or a profile")
+	default:
+		addCheck("API token configured", "OK", fmt.Sprintf("token=%s source=%s", maskedAPIKey(), currentConfig().APIKeySource))
 	}
 
 	// Connectivity probe (DNS + TLS to api.slide.tech). Cheap GET with
@@ -239,7 +244,7 @@ func runDoctor() {
 
 	// Auth probe (only run if token + connectivity look OK).
 	if apiKey != "" && netErr == nil {
-		_, err := probeAccount()
+		_, err := probeAccount(ctx)
 		switch {
 		case err == nil:
 			addCheck("Authentication (/v1/account)", "OK", "200 - token accepted")
@@ -261,7 +266,7 @@ func runDoctor() {
 			{"Devices endpoint", "/v1/device"},
 			{"Agents endpoint", "/v1/agent"},
 		} {
-			total, err := probeCount(ep.path)
+			total, err := probeCount(ctx, ep.path)
 			if err != nil {
 				addCheck(ep.name, "FAIL", err.Error())
 			} else {
@@ -0,0 +1,403 @@
+package main
+
+// Downloads of restored files and exported disk images to this machine.
+//
+// File restore browse entries and image export entries carry
+// download_uris, but until now the LLM could only show those links.
+// slide_files download and slide_recovery download_image fetch them into
+// --download-dir / SLIDE_DOWNLOAD_DIR for the stdio user running the
+// server on their own workstation. Both are off until that directory is
+// set, refuse over the HTTP transport (the file would land on the
+// server's disk, not the caller's), and refuse anything larger than
+// --download-max-mb.
+//
+// A download streams into <name>.part and is renamed when complete, so an
+// interrupted one resumes with a Range request on the next call. The
+// SHA-256 of the finished file is reported for the tech to check against
+// the copy they hand on.
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultDownloadMaxMB     = 10240
+	downloadProgressInterval = time.Second
+	downloadBufferSize       = 256 << 10
+)
+
+// downloadResult describes a file saved under DownloadDir.
+type downloadResult struct {
+	FilePath        string `json:"file_path"`
+	Bytes           int64  `json:"bytes"`
+	SHA256          string `json:"sha256"`
+	ResumedFrom     int64  `json:"resumed_from,omitempty"`
+	AlreadyComplete bool   `json:"already_complete,omitempty"`
+	ElapsedSeconds  int    `json:"elapsed_seconds"`
+}
+
+// downloadDir is the configured directory, or why downloads are off.
+func downloadDir() (string, error) {
+	cfg := currentConfig()
+	if cfg.Transport == TransportHTTP {
+		return "", fmt.Errorf("downloads save to the server's own disk, so they are only available over the stdio transport; use the download_uris from browse instead")
+	}
+	if cfg.DownloadDir == "" {
+		return "", fmt.Errorf("downloads are off: start the server with --download-dir <dir> (or SLIDE_DOWNLOAD_DIR) to save files locally; until then use the download_uris from browse")
+	}
+	return cfg.DownloadDir, nil
+}
+
+// downloadMaxBytes is the configured size limit.
+func downloadMaxBytes() int64 {
+	mb := currentConfig().DownloadMaxMB
+	if mb <= 0 {
+		mb = defaultDownloadMaxMB
+	}
+	return int64(mb) << 20
+}
+
+// pickDownloadURI returns the first HTTP(S) download link.
+func pickDownloadURI(uris []DownloadURI) (string, error) {
+	for _, u := range uris {
+		if parsed, err := url.Parse(u.URI); err == nil && (parsed.Scheme == "https" || parsed.Scheme == "http") {
+			return u.URI, nil
+		}
+	}
+	return "", fmt.Errorf("no HTTP download link for this entry")
+}
+
+// downloadName is name reduced to a single safe path element.
+func downloadName(name string) (string, error) {
+	base := path.Base(toSlashPath(name))
+	if base == "" || base == "." || base == ".." || base == "/" {
+		return "", fmt.Errorf("cannot derive a file name from %q", name)
+	}
+	return base, nil
+}
+
+// downloadSubdir checks that sub, a caller-supplied restore or export ID,
+// names a single directory under the download directory. Unlike a file
+// name it is not trimmed to its last element: anything else is refused.
+func downloadSubdir(sub string) error {
+	if sub == "" || sub == "." || sub == ".." || strings.ContainsAny(sub, "/\\:\x00") {
+		return fmt.Errorf("invalid ID %q for a download folder: expected a single path element", sub)
+	}
+	return nil
+}
+
+// contentRangeStart returns the first byte of a 206 response's
+// Content-Range, or -1 if the header is missing or malformed.
+func contentRangeStart(resp *http.Response) int64 {
+	rest, ok := strings.CutPrefix(resp.Header.Get("Content-Range"), "bytes ")
+	if !ok {
+		return -1
+	}
+	first, _, ok := strings.Cut(rest, "-")
+	if !ok {
+		return -1
+	}
+	n, err := strconv.ParseInt(first, 10, 64)
+	if err != nil {
+		return -1
+	}
+	return n
+}
+
+// downloadFile fetches uri into dir/sub/name, resuming a previous partial
+// download, and reports progress against size (0 if unknown).
+func downloadFile(ctx context.Context, uri, sub, name string, size int64) (*downloadResult, error) {
+	dir, err := downloadDir()
+	if err != nil {
+		return nil, err
+	}
+	limit := downloadMaxBytes()
+	if size > limit {
+		return nil, fmt.Errorf("%s is %d MB, over the %d MB download limit (--download-max-mb)", name, size>>20, limit>>20)
+	}
+	base, err := downloadName(name)
+	if err != nil {
+		return nil, err
+	}
+	if err := downloadSubdir(sub); err != nil {
+		return nil, err
+	}
+	dir = filepath.Join(dir, sub)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("create download directory: %w", err)
+	}
+	dest := filepath.Join(dir, base)
+	start := time.Now()
+
+	if info, err := os.Stat(dest); err == nil && size > 0 && info.Size() == size {
+		sum, err := sha256File(dest)
+		if err != nil {
+			return nil, err
+		}
+		return &downloadResult{FilePath: dest, Bytes: size, SHA256: sum, AlreadyComplete: true}, nil
+	}
+
+	part := dest + ".part"
+	var offset int64
+	if info, err := os.Stat(part); err == nil && (size == 0 || info.Size() < size) {
+		offset = info.Size()
+	}
+
+	resp, err := openDownload(ctx, uri, offset)
+	if err != nil {
+		return nil, fmt.Errorf("download %s: %w", base, err)
+	}
+	if resp.StatusCode == http.StatusPartialContent && contentRangeStart(resp) != offset {
+		// Appending a range we did not ask for would corrupt the file;
+		// fetch it whole instead.
+		resp.Body.Close()
+		offset = 0
+		resp, err = openDownload(ctx, uri, 0)
+		if err != nil {
+			return nil, fmt.Errorf("download %s: %w", base, err)
+		}
+	}
+	defer resp.Body.Close()
+
+	flags := os.O_CREATE | os.O_WRONLY
+	switch {
+	case resp.StatusCode == http.StatusPartialContent && offset > 0:
+		flags |= os.O_APPEND
+	case resp.StatusCode == http.StatusOK || resp.StatusCode == http.StatusPartialContent:
+		// No range support, or nothing to resume: start over.
+		flags |= os.O_TRUNC
+		offset = 0
+	case resp.StatusCode == http.StatusRequestedRangeNotSatisfiable:
+		if offset > 0 && offset == size {
+			break
+		}
+		return nil, fmt.Errorf("download %s: server rejected resuming at byte %d; delete %s to start over", base, offset, part)
+	default:
+		return nil, fmt.Errorf("download %s: server returned status %d", base, resp.StatusCode)
+	}
+
+	f, err := os.OpenFile(part, flags, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("open %s: %w", part, err)
+	}
+	hash := sha256.New()
+	if offset > 0 {
+		if err := hashPrefix(hash, part, offset); err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+	written := offset
+	if resp.StatusCode != http.StatusRequestedRangeNotSatisfiable {
+		written, err = copyWithProgress(ctx, io.MultiWriter(f, hash), resp.Body, offset, size, limit, base)
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		// The .part file stays so the next call can resume.
+		return nil, fmt.Errorf("download %s stopped at byte %d (call again to resume): %w", base, written, err)
+	}
+	if size > 0 && written != size {
+		return nil, fmt.Errorf("download %s ended at byte %d of %d (call again to resume)", base, written, size)
+	}
+	if err := os.Rename(part, dest); err != nil {
+		return nil, fmt.Errorf("finish download: %w", err)
+	}
+	return &downloadResult{
+		FilePath:       dest,
+		Bytes:          written,
+		SHA256:         hex.EncodeToString(hash.Sum(nil)),
+		ResumedFrom:    offset,
+		ElapsedSeconds: int(time.Since(start).Seconds()),
+	}, nil
+}
+
+// openDownload starts a GET of uri from byte offset. The Slide token is
+// sent only when uri is on the API host.
+func openDownload(ctx context.Context, uri string, offset int64) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", uri, nil)
+	if err != nil {
+		return nil, fmt.Errorf("build download request: %w", err)
+	}
+	req.Header.Set("User-Agent", ServerName+"/"+Version)
+	if sameHost(uri, sessionFromContext(ctx).baseURL()) {
+		req.Header.Set("Authorization", "Bearer "+sessionFromContext(ctx).token())
+	}
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+	// Large images outlive the API client's 20s timeout; the context
+	// still bounds the transfer.
+	client := &http.Client{Transport: httpClient.Transport}
+	return client.Do(req)
+}
+
+// copyWithProgress copies src to dst starting from offset, failing past
+// limit bytes in total, and sends a progress notification at most once a
+// second. It returns the total bytes now on disk.
+func copyWithProgress(ctx context.Context, dst io.Writer, src io.Reader, offset, size, limit int64, name string) (int64, error) {
+	buf := make([]byte, downloadBufferSize)
+	total := offset
+	last := time.Time{}
+	for {
+		n, rerr := src.Read(buf)
+		if n > 0 {
+			if total+int64(n) > limit {
+				return total, fmt.Errorf("%s is over the %d MB download limit (--download-max-mb)", name, limit>>20)
+			}
+			if _, err := dst.Write(buf[:n]); err != nil {
+				return total, err
+			}
+			total += int64(n)
+			if time.Since(last) >= downloadProgressInterval {
+				last = time.Now()
+				progressTo(ctx, float64(total), float64(size), "downloading %s: %d of %d MB", name, total>>20, size>>20)
+			}
+		}
+		if errors.Is(rerr, io.EOF) {
+			progressTo(ctx, float64(total), float64(size), "downloaded %s", name)
+			return total, nil
+		}
+		if rerr != nil {
+			return total, rerr
+		}
+	}
+}
+
+// hashPrefix feeds the first n bytes of file into h.
+func hashPrefix(h io.Writer, file string, n int64) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return fmt.Errorf("resume %s: %w", file, err)
+	}
+	defer f.Close()
+	if _, err := io.CopyN(h, f, n); err != nil {
+		return fmt.Errorf("resume %s: %w", file, err)
+	}
+	return nil
+}
+
+// sha256File is the hex SHA-256 of a file.
+func sha256File(file string) (string, error) {
+	h := sha256.New()
+	f, err := os.Open(file)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", fmt.Errorf("hash %s: %w", file, err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// sameHost reports whether a and b name the same host, so the Slide token
+// is only sent to Slide.
+func sameHost(a, b string) bool {
+	ua, err1 := url.Parse(a)
+	ub, err2 := url.Parse(b)
+	return err1 == nil && err2 == nil && ua.Host != "" && strings.EqualFold(ua.Host, ub.Host)
+}
+
+// handleFilesDownload saves one file from a file restore.
+func handleFilesDownload(ctx context.Context, args map[string]interface{}) (string, error) {
+	fileRestoreID, err := requireString(args, "file_restore_id")
+	if err != nil {
+		return "", err
+	}
+	source, err := requireString(args, "source_file_path")
+	if err != nil {
+		return "", err
+	}
+	if _, err := downloadDir(); err != nil {
+		return "", err
+	}
+	entry, err := findRestoreEntry(ctx, fileRestoreID, source)
+	if err != nil {
+		return "", err
+	}
+	uri, err := pickDownloadURI(entry.DownloadURIs)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w (is it a folder?)", entry.Path, err)
+	}
+	res, err := downloadFile(ctx, uri, fileRestoreID, entry.Name, entry.Size)
+	if err != nil {
+		return "", err
+	}
+	return formatSingle(map[string]interface{}{
+		"file_restore_id":  fileRestoreID,
+		"source_file_path": entry.Path,
+		"modified_at":      entry.ModifiedAt,
+		"download":         res,
+	}, args, formatCompact)
+}
+
+// handleRecoveryDownloadImage saves one disk image from an image export.
+func handleRecoveryDownloadImage(ctx context.Context, args map[string]interface{}) (string, error) {
+	imageExportID, err := requireString(args, "image_export_id")
+	if err != nil {
+		return "", err
+	}
+	if _, err := downloadDir(); err != nil {
+		return "", err
+	}
+	entries, err := fetchAllPaginated[ImageExportEntry](ctx, fmt.Sprintf("/v1/restore/image/%s/browse", imageExportID))
+	if err != nil {
+		return "", err
+	}
+	entry, err := pickImageEntry(entries, args)
+	if err != nil {
+		return "", err
+	}
+	uri, err := pickDownloadURI(entry.DownloadURIs)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", entry.Name, err)
+	}
+	res, err := downloadFile(ctx, uri, imageExportID, entry.Name, entry.Size)
+	if err != nil {
+		return "", err
+	}
+	return formatSingle(map[string]interface{}{
+		"image_export_id": imageExportID,
+		"disk_id":         entry.DiskID,
+		"name":            entry.Name,
+		"download":        res,
+	}, args, formatCompact)
+}
+
+// pickImageEntry selects the disk named by disk_id (or file name), or the
+// only disk when the export has one.
+func pickImageEntry(entries []ImageExportEntry, args map[string]interface{}) (*ImageExportEntry, error) {
+	diskID, _ := optionalString(args, "disk_id")
+	if diskID == "" && len(entries) == 1 {
+		return &entries[0], nil
+	}
+	var names []string
+	for i, e := range entries {
+		if diskID != "" && (e.DiskID == diskID || e.Name == diskID) {
+			return &entries[i], nil
+		}
+		names = append(names, fmt.Sprintf("%s (%s, %d MB)", e.DiskID, e.Name, e.Size>>20))
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("image export has no files yet; check slide_recovery operation=get_image")
+	}
+	if diskID == "" {
+		return nil, fmt.Errorf("image export has %d disks; pass disk_id, one of: %s", len(entries), strings.Join(names, ", "))
+	}
+	return nil, fmt.Errorf("no disk %q in this image export; choose one of: %s", diskID, strings.Join(names, ", "))
+}
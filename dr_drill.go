@@ -0,0 +1,472 @@
+package main
+
+// slide_recovery dr_drill: a DR test in one call.
+//
+// A quarterly DR test is boot_vm, get_vm until it is running, a look at
+// the remote access details and the snapshot's verification results, and
+// delete_vm. dr_drill runs that sequence for one agent or every agent of
+// a client: it boots each agent's latest restorable snapshot (or the
+// chosen one) on an isolated network, polls until the VM runs, records
+// how long that took as the observed RTO, checks the result, and always
+// deletes the VM it booted. The report is meant to be filed as evidence,
+// so every check says what it looked at.
+//
+// Booting VMs is heavy work for a device, so drills run `parallel` at a
+// time (default 2) rather than at the server's fan-out concurrency.
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	drillDefaultNetwork  = "network-isolated"
+	drillDefaultTimeout  = 600 // seconds to reach running
+	drillMaxTimeout      = 1800
+	drillDefaultParallel = 2
+	drillMaxParallel     = 8
+)
+
+// Drill check and agent outcomes.
+const (
+	drillPassed  = "passed"
+	drillFailed  = "failed"
+	drillWarning = "warning"
+	drillSkipped = "skipped"
+)
+
+// drillCheck is one line of an agent's drill result.
+type drillCheck struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Detail string `json:"detail"`
+}
+
+// drillAgent is one agent's drill.
+type drillAgent struct {
+	AgentID         string                      `json:"agent_id"`
+	AgentName       string                      `json:"agent_name"`
+	ClientID        *string                     `json:"client_id,omitempty"`
+	Outcome         string                      `json:"outcome"`
+	Reason          string                      `json:"reason,omitempty"`
+	SnapshotID      string                      `json:"snapshot_id,omitempty"`
+	SnapshotTime    string                      `json:"snapshot_time,omitempty"`
+	DeviceID        string                      `json:"device_id,omitempty"`
+	Location        string                      `json:"location,omitempty"`
+	VirtID          string                      `json:"virt_id,omitempty"`
+	BootRequestedAt string                      `json:"boot_requested_at,omitempty"`
+	RunningAt       string                      `json:"running_at,omitempty"`
+	RTOSeconds      *float64                    `json:"rto_seconds,omitempty"`
+	FinalState      string                      `json:"final_state,omitempty"`
+	TornDownAt      string                      `json:"torn_down_at,omitempty"`
+	ScreenshotURL   *string                     `json:"screenshot_url,omitempty"`
+	FailingServices []ServiceVerificationResult `json:"failing_services,omitempty"`
+	Checks          []drillCheck                `json:"checks"`
+}
+
+// newDrillAgent is an agent's result before anything has run.
+func newDrillAgent(a Agent) *drillAgent {
+	return &drillAgent{AgentID: a.AgentID, AgentName: bestAgentName(a), ClientID: a.ClientID, Checks: []drillCheck{}}
+}
+
+// canceledDrill is the result for an agent the drill never reached.
+func canceledDrill(a Agent) *drillAgent {
+	r := newDrillAgent(a)
+	r.Outcome, r.Reason = drillSkipped, "drill canceled before this agent started"
+	return r
+}
+
+func (a *drillAgent) check(name, status, format string, args ...interface{}) {
+	a.Checks = append(a.Checks, drillCheck{Name: name, Status: status, Detail: fmt.Sprintf(format, args...)})
+}
+
+// drillRTO summarises the observed RTOs of the VMs that reached running.
+type drillRTO struct {
+	MinSeconds    float64 `json:"min_seconds"`
+	MedianSeconds float64 `json:"median_seconds"`
+	MaxSeconds    float64 `json:"max_seconds"`
+	Slowest       string  `json:"slowest"`
+}
+
+// drillReport is the whole drill, in the shape it is filed.
+type drillReport struct {
+	Drill struct {
+		StartedAt       string  `json:"started_at"`
+		FinishedAt      string  `json:"finished_at"`
+		DurationSeconds float64 `json:"duration_seconds"`
+		Scope           string  `json:"scope"`
+		NetworkType     string  `json:"network_type"`
+		NetworkSource   string  `json:"network_source,omitempty"`
+		BootTimeout     int     `json:"boot_timeout_seconds"`
+		Parallel        int     `json:"parallel"`
+	} `json:"drill"`
+	Result  string `json:"result"`
+	Summary struct {
+		Agents  int       `json:"agents"`
+		Passed  int       `json:"passed"`
+		Failed  int       `json:"failed"`
+		Skipped int       `json:"skipped"`
+		RTO     *drillRTO `json:"rto,omitempty"`
+	} `json:"summary"`
+	Agents []*drillAgent          `json:"agents"`
+	Notes  []string               `json:"notes,omitempty"`
+	Report map[string]interface{} `json:"report,omitempty"`
+}
+
+// drillOptions are the per-VM settings shared by every agent in a drill.
+type drillOptions struct {
+	snapshotID    string
+	networkType   string
+	networkSource string
+	timeout       time.Duration
+}
+
+// handleRecoveryDRDrill boots, checks, and tears down a VM per agent.
+func handleRecoveryDRDrill(ctx context.Context, args map[string]interface{}) (string, error) {
+	agentID, _ := optionalString(args, "agent_id")
+	clientID, _ := optionalString(args, "client_id")
+	if agentID == "" && clientID == "" {
+		return "", fmt.Errorf("agent_id (or name_hint) or client_id is required")
+	}
+	opts := drillOptions{networkType: drillDefaultNetwork}
+	opts.snapshotID, _ = optionalString(args, "snapshot_id")
+	if opts.snapshotID != "" && agentID == "" {
+		return "", fmt.Errorf("snapshot_id picks one agent's snapshot; pass agent_id with it, or drop it to drill each agent's latest snapshot")
+	}
+	if nt, _ := optionalString(args, "network_type"); nt != "" {
+		opts.networkType = nt
+	}
+	opts.networkSource, _ = optionalString(args, "network_source")
+	if opts.networkType == "network-id" && opts.networkSource == "" {
+		return "", fmt.Errorf("network_type=network-id needs network_source (a DR network ID)")
+	}
+	timeout, ok := optionalInt(args, "boot_timeout_seconds")
+	if !ok || timeout <= 0 {
+		timeout = drillDefaultTimeout
+	}
+	timeout = min(timeout, drillMaxTimeout)
+	opts.timeout = time.Duration(timeout) * time.Second
+	parallel, ok := optionalInt(args, "parallel")
+	if !ok || parallel <= 0 {
+		parallel = drillDefaultParallel
+	}
+	parallel = min(parallel, drillMaxParallel)
+	if _, err := reportDocFormats(args); err != nil {
+		return "", err
+	}
+
+	started := time.Now().UTC()
+	agents, scope, err := fetchScopedAgents(ctx, map[string]interface{}{"agent_id": agentID, "client_id": clientID})
+	if err != nil {
+		return "", err
+	}
+	if len(agents) == 0 {
+		return "", fmt.Errorf("no agents in %s", scope)
+	}
+
+	slots := make(chan struct{}, parallel)
+	results, err := fanOut(ctx, agents, func(ctx context.Context, a Agent) *drillAgent {
+		select {
+		case slots <- struct{}{}:
+			defer func() { <-slots }()
+		case <-ctx.Done():
+			return canceledDrill(a)
+		}
+		r := runDrill(ctx, a, opts)
+		progressStep(ctx, -1, "drilled %s: %s", r.AgentName, r.Outcome)
+		return r
+	})
+	// A canceled drill still reports, and has torn down, what it booted.
+	for i, r := range results {
+		if r == nil {
+			results[i] = canceledDrill(agents[i])
+		}
+	}
+
+	rep := &drillReport{Agents: results}
+	finished := time.Now().UTC()
+	rep.Drill.StartedAt = started.Format(time.RFC3339)
+	rep.Drill.FinishedAt = finished.Format(time.RFC3339)
+	rep.Drill.DurationSeconds = roundSeconds(finished.Sub(started))
+	rep.Drill.Scope = scope
+	rep.Drill.NetworkType = opts.networkType
+	rep.Drill.NetworkSource = opts.networkSource
+	rep.Drill.BootTimeout = timeout
+	rep.Drill.Parallel = parallel
+	summarizeDrill(rep)
+	if err != nil {
+		rep.Notes = append(rep.Notes, "The drill was canceled; agents that had not started are marked skipped. VMs already booted were deleted.")
+	}
+	if opts.networkType != drillDefaultNetwork {
+		rep.Notes = append(rep.Notes, fmt.Sprintf("Drill VMs were attached with network_type=%s, not an isolated network; they could reach (and be reached from) other systems while running.", opts.networkType))
+	}
+	if rep.Report, err = attachReportDocs(ctx, args, drillReportDoc(rep), "dr-drill"); err != nil {
+		// The VMs are already gone; losing the document must not lose the result.
+		rep.Notes = append(rep.Notes, "The report document could not be saved: "+err.Error())
+	}
+	return formatSingle(rep, args, formatCompact)
+}
+
+// runDrill drills one agent. It never returns an error: everything that
+// goes wrong is a failed check, and a booted VM is always deleted.
+func runDrill(ctx context.Context, a Agent, opts drillOptions) *drillAgent {
+	r := newDrillAgent(a)
+	loc := agentLocation(&a)
+
+	snap, reason, err := drillSnapshot(ctx, a.AgentID, opts.snapshotID)
+	if err != nil {
+		r.Outcome = drillFailed
+		r.check("snapshot", drillFailed, "could not pick a snapshot: %v", err)
+		return r
+	}
+	if snap == nil {
+		r.Outcome, r.Reason = drillSkipped, reason
+		return r
+	}
+	r.SnapshotID = snap.SnapshotID
+	if t, err := time.Parse(time.RFC3339, snap.BackupStartedAt); err == nil {
+		r.SnapshotTime = t.In(loc).Format(time.RFC3339)
+	}
+	r.DeviceID, r.Location = restoreDevice(snap)
+
+	payload := map[string]interface{}{"snapshot_id": snap.SnapshotID, "device_id": r.DeviceID, "network_type": opts.networkType}
+	if opts.networkSource != "" {
+		payload["network_source"] = opts.networkSource
+	}
+	body, _ := json.Marshal(payload)
+	requested := time.Now()
+	r.BootRequestedAt = requested.UTC().Format(time.RFC3339)
+	progressStep(ctx, -1, "%s: booting %s on %s", r.AgentName, snap.SnapshotID, r.DeviceID)
+	data, err := makeAPIRequest(ctx, "POST", "/v1/restore/virt", body)
+	var vm VirtualMachine
+	if err == nil {
+		err = json.Unmarshal(data, &vm)
+	}
+	if err != nil || vm.VirtID == "" {
+		if err == nil {
+			err = fmt.Errorf("no virt_id in response")
+		}
+		r.Outcome = drillFailed
+		r.check("boot", drillFailed, "boot_vm failed: %v", err)
+		return r
+	}
+	r.VirtID = vm.VirtID
+	defer teardownDrillVM(ctx, r)
+
+	_, res, err := waitFor(ctx, "VM "+vm.VirtID, requested, opts.timeout, pollDrillVM(&vm))
+	running := time.Now()
+	r.FinalState = vm.State
+	switch {
+	case err != nil:
+		r.check("boot", drillFailed, "%v (last state %q)", err, vm.State)
+	case res.Succeeded:
+		rto := roundSeconds(running.Sub(requested))
+		r.RunningAt = running.UTC().Format(time.RFC3339)
+		r.RTOSeconds = &rto
+		r.check("boot", drillPassed, "VM %s reached running %.0fs after boot_vm", vm.VirtID, rto)
+		checkDrillAccess(r, &vm)
+	case res.Terminal:
+		r.check("boot", drillFailed, "VM %s went to state %s", vm.VirtID, vm.State)
+	default:
+		r.check("boot", drillFailed, "VM %s was not running within the boot timeout (last state %q)", vm.VirtID, vm.State)
+	}
+	checkDrillVerification(ctx, r, snap)
+	return r
+}
+
+// drillSnapshot is the snapshot to boot: the chosen one, checked to be
+// the agent's and restorable, else the agent's newest restorable one. A
+// nil snapshot with a reason means there is nothing to drill.
+func drillSnapshot(ctx context.Context, agentID, snapshotID string) (*Snapshot, string, error) {
+	if snapshotID != "" {
+		s, err := fetchSnapshot(ctx, snapshotID)
+		if err != nil {
+			return nil, "", err
+		}
+		if s.AgentID != agentID {
+			return nil, "", fmt.Errorf("snapshot %s belongs to agent %s, not %s", snapshotID, s.AgentID, agentID)
+		}
+		if s.Deleted != nil {
+			return nil, "", fmt.Errorf("snapshot %s was deleted", snapshotID)
+		}
+		if deviceID, _ := restoreDevice(s); deviceID == "" {
+			return nil, "", fmt.Errorf("snapshot %s has no local or cloud copy to boot", snapshotID)
+		}
+		return s, "", nil
+	}
+	data, err := makeAPIRequest(ctx, "GET", fmt.Sprintf("/v1/snapshot?agent_id=%s&limit=50&sort_by=backup_start_time&sort_asc=false", agentID), nil)
+	if err != nil {
+		return nil, "", err
+	}
+	var p PaginatedResponse[Snapshot]
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, "", fmt.Errorf("parse snapshots: %w", err)
+	}
+	for i := range p.Data {
+		s := &p.Data[i]
+		if s.Deleted != nil {
+			continue
+		}
+		if deviceID, _ := restoreDevice(s); deviceID != "" {
+			return s, "", nil
+		}
+	}
+	return nil, "no restorable snapshot", nil
+}
+
+// pollDrillVM reads the drill VM into vm, for waitFor.
+func pollDrillVM(vm *VirtualMachine) waitPoll {
+	return func(ctx context.Context) (interface{}, string, waitOutcome, error) {
+		data, err := makeAPIRequest(ctx, "GET", "/v1/restore/virt/"+vm.VirtID, nil)
+		if err != nil {
+			return nil, "", waitPending, fmt.Errorf("get_vm %s: %w", vm.VirtID, err)
+		}
+		if err := json.Unmarshal(data, vm); err != nil {
+			return nil, "", waitPending, fmt.Errorf("parse VM %s: %w", vm.VirtID, err)
+		}
+		return vm, vm.State, stateOutcome(vm.State, "running"), nil
+	}
+}
+
+// checkDrillAccess records whether the running VM offered a way in. The
+// drill does not connect: on an isolated network only the console path
+// the device brokers is expected to work.
+func checkDrillAccess(r *drillAgent, vm *VirtualMachine) {
+	var ways []string
+	if vm.RDPEndpoint != nil && *vm.RDPEndpoint != "" {
+		ways = append(ways, "RDP "+*vm.RDPEndpoint)
+	}
+	for _, v := range vm.VNC {
+		switch {
+		case v.WebsocketURI != nil && *v.WebsocketURI != "":
+			ways = append(ways, "VNC websocket ("+v.Type+")")
+		case v.Host != nil && v.Port != nil:
+			ways = append(ways, fmt.Sprintf("VNC %s:%d (%s)", *v.Host, *v.Port, v.Type))
+		}
+	}
+	if len(ways) == 0 {
+		r.check("remote_access", drillFailed, "the running VM reported no RDP endpoint and no VNC console")
+	} else {
+		r.check("remote_access", drillPassed, "%s", strings.Join(ways, ", "))
+	}
+	if vm.IPAddress != nil && *vm.IPAddress != "" {
+		r.check("network", drillPassed, "guest address %s", *vm.IPAddress)
+	} else {
+		r.check("network", drillWarning, "no guest IP address reported yet")
+	}
+}
+
+// checkDrillVerification records the snapshot's own boot, filesystem, and
+// service verification, with the failing services when there are any.
+func checkDrillVerification(ctx context.Context, r *drillAgent, s *Snapshot) {
+	r.ScreenshotURL = s.VerifyBootScreenshotURL
+	for _, v := range []struct {
+		name   string
+		status *string
+	}{
+		{"boot_verification", s.VerifyBootStatus},
+		{"filesystem_verification", s.VerifyFsStatus},
+		{"service_verification", s.VerifyServiceStatus},
+	} {
+		if v.status == nil || verifyStatusOutcome(*v.status) == verifyUnverified {
+			r.check(v.name, drillSkipped, "the snapshot was not verified")
+			continue
+		}
+		status := map[string]string{
+			verifyPassed:  drillPassed,
+			verifyFailed:  drillFailed,
+			verifyWarning: drillWarning,
+			verifyPending: drillWarning,
+		}[verifyStatusOutcome(*v.status)]
+		detail := "verify status " + *v.status
+		if v.name == "boot_verification" && s.VerifyBootScreenshotURL != nil {
+			detail += "; screenshot " + *s.VerifyBootScreenshotURL
+		}
+		if v.name == "service_verification" && status != drillPassed {
+			if res, err := getSnapshotServiceVerification(ctx, s.SnapshotID); err == nil {
+				var names []string
+				for _, svc := range res.Services {
+					if serviceVerificationFailed(svc.State) {
+						r.FailingServices = append(r.FailingServices, svc)
+						names = append(names, svc.Name)
+					}
+				}
+				if len(names) > 0 {
+					detail += "; not running: " + strings.Join(names, ", ")
+				}
+			} else {
+				detail += "; per-service results unavailable"
+			}
+		}
+		r.check(v.name, status, "%s", detail)
+	}
+}
+
+// teardownDrillVM deletes the drill's VM even when the call was canceled,
+// and settles the agent's outcome: any failed check, teardown included,
+// fails the drill.
+func teardownDrillVM(ctx context.Context, r *drillAgent) {
+	if _, err := makeAPIRequest(context.WithoutCancel(ctx), "DELETE", "/v1/restore/virt/"+r.VirtID, nil); err != nil {
+		r.check("teardown", drillFailed, "could not delete VM %s: %v; delete it with slide_recovery operation=delete_vm", r.VirtID, err)
+	} else {
+		r.TornDownAt = time.Now().UTC().Format(time.RFC3339)
+		r.check("teardown", drillPassed, "VM %s deleted", r.VirtID)
+	}
+	r.Outcome = drillPassed
+	for _, c := range r.Checks {
+		if c.Status == drillFailed {
+			r.Outcome = drillFailed
+		}
+	}
+}
+
+// summarizeDrill fills in the report's counts, RTO spread, and result:
+// failed if any agent failed, skipped if none ran, else passed.
+func summarizeDrill(rep *drillReport) {
+	var rtos []float64
+	var slowest *drillAgent
+	for _, a := range rep.Agents {
+		switch a.Outcome {
+		case drillPassed:
+			rep.Summary.Passed++
+		case drillFailed:
+			rep.Summary.Failed++
+		default:
+			rep.Summary.Skipped++
+		}
+		if a.RTOSeconds != nil {
+			rtos = append(rtos, *a.RTOSeconds)
+			if slowest == nil || *a.RTOSeconds > *slowest.RTOSeconds {
+				slowest = a
+			}
+		}
+	}
+	rep.Summary.Agents = len(rep.Agents)
+	if len(rtos) > 0 {
+		sort.Float64s(rtos)
+		rep.Summary.RTO = &drillRTO{
+			MinSeconds:    rtos[0],
+			MedianSeconds: rtos[len(rtos)/2],
+			MaxSeconds:    rtos[len(rtos)-1],
+			Slowest:       slowest.AgentName,
+		}
+	}
+	switch {
+	case rep.Summary.Failed > 0:
+		rep.Result = drillFailed
+	case rep.Summary.Passed == 0:
+		rep.Result = drillSkipped
+	default:
+		rep.Result = drillPassed
+	}
+}
+
+// roundSeconds is d in seconds to one decimal place.
+func roundSeconds(d time.Duration) float64 {
+	return math.Round(d.Seconds()*10) / 10
+}
@@ -0,0 +1,69 @@
+package main
+
+// Bounded-concurrency fan-out for per-entity Slide API calls.
+//
+// Account-wide questions ("did every agent under this client back up last
+// night?") turn into one request per agent. fanOut runs them on at most
+// the configured concurrency in workers and returns the results in input order, so
+// the output is exactly what the old serial loops produced, just sooner.
+// Rate limiting is shared: a 429 seen by any worker holds back every
+// request on the same Slide identity (see rateLimitGate in api.go).
+
+import (
+	"context"
+	"sync"
+)
+
+const (
+	defaultConcurrency = 8
+	maxConcurrency     = 32
+)
+
+// fanOutWorkers is the worker count for n items: the configured
+// concurrency, never more than there are items.
+func fanOutWorkers(n int) int {
+	workers := defaultConcurrency
+	if cfg := currentConfig(); cfg != nil && cfg.Concurrency > 0 {
+		workers = cfg.Concurrency
+	}
+	if workers > n {
+		workers = n
+	}
+	return workers
+}
+
+// fanOut calls fn for every item with bounded concurrency; results[i] is
+// fn's result for items[i]. Once ctx is done no further items start and
+// ctx.Err() is returned alongside whatever finished, so callers can tell
+// a canceled sweep from a complete one. fn must be safe to run
+// concurrently and should report per-item failures in its result.
+func fanOut[T, R any](ctx context.Context, items []T, fn func(context.Context, T) R) ([]R, error) {
+	results := make([]R, len(items))
+	if len(items) == 0 {
+		return results, ctx.Err()
+	}
+
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < fanOutWorkers(len(items)); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				results[i] = fn(ctx, items[i])
+			}
+		}()
+	}
+
+feed:
+	for i := range items {
+		select {
+		case next <- i:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(next)
+	wg.Wait()
+	return results, ctx.Err()
+}
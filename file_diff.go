@@ -0,0 +1,368 @@
+package main
+
+// slide_files diff_versions: what changed in a file between two
+// snapshots.
+//
+// versions says which snapshots hold a path; this answers "what did
+// Tuesday's copy say that today's doesn't". It restores both snapshots
+// (reusing a file restore that is already open for either), fetches the
+// file from each through its download link without saving it, and
+// returns a unified diff when both copies are text, or size, SHA-256 and
+// modified time side by side when they are not. File restores it had to
+// create are deleted before it returns, whether or not the diff worked.
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	diffTextMaxBytes   = 2 << 20 // larger files are compared as binaries
+	diffMaxOutputLines = 400
+)
+
+// diffSide is one of the two copies being compared.
+type diffSide struct {
+	SnapshotID    string `json:"snapshot_id"`
+	SnapshotTime  string `json:"snapshot_time,omitempty"`
+	Size          int64  `json:"size"`
+	ModifiedAt    string `json:"modified_at,omitempty"`
+	SHA256        string `json:"sha256,omitempty"`
+	FileRestoreID string `json:"file_restore_id"`
+	Restore       string `json:"restore"` // "reused" or "created"
+
+	content []byte // nil unless small enough to diff as text
+}
+
+// handleFilesDiffVersions compares path between two snapshots of an
+// agent: from_snapshot_id and to_snapshot_id, defaulting to the newest
+// version and the newest older one that differs from it.
+func handleFilesDiffVersions(ctx context.Context, args map[string]interface{}) (string, error) {
+	agentID, err := requireString(args, "agent_id")
+	if err != nil {
+		return "", err
+	}
+	filePath, err := requireString(args, "path")
+	if err != nil {
+		return "", err
+	}
+	fromID, _ := optionalString(args, "from_snapshot_id")
+	toID, _ := optionalString(args, "to_snapshot_id")
+	if fromID != "" && fromID == toID {
+		return "", fmt.Errorf("from_snapshot_id and to_snapshot_id are the same snapshot")
+	}
+
+	agent, err := fetchAgent(ctx, agentID)
+	if err != nil {
+		return "", err
+	}
+	progressStep(ctx, 4, "listing versions of %s", filePath)
+	versions, err := fetchRecoverVersions(ctx, agentID, filePath, agentLocation(agent))
+	if err != nil {
+		return "", err
+	}
+	from, to, candidates, reason := pickDiffVersions(versions, fromID, toID)
+	if to == nil {
+		if len(candidates) == 0 {
+			return "", fmt.Errorf("%s", reason)
+		}
+		return recoverChoice(args, "snapshot_id", candidates, reason)
+	}
+	result := map[string]interface{}{
+		"agent_id":   agentID,
+		"agent_name": bestAgentName(*agent),
+		"path":       filePath,
+	}
+	if from == nil {
+		result["status"] = "no_change"
+		result["note"] = reason
+		result["versions"] = len(versions)
+		return formatSingle(result, args, formatCompact)
+	}
+
+	pool, err := newRestorePool(ctx, agentID)
+	if err != nil {
+		return "", err
+	}
+	progressStep(ctx, 3, "opening restores of %s and %s", from.SnapshotID, to.SnapshotID)
+	sides, err := fanOut(ctx, []*recoverVersion{from, to}, func(ctx context.Context, v *recoverVersion) diffFetch {
+		side, err := fetchDiffSide(ctx, pool, v, filePath)
+		return diffFetch{side, err}
+	})
+	for _, s := range sides {
+		if err == nil {
+			err = s.err
+		}
+	}
+	cleanup, cleanupErr := pool.release(ctx)
+	if err != nil {
+		if cleanupErr != nil {
+			err = fmt.Errorf("%w (%v)", err, cleanupErr)
+		}
+		return "", err
+	}
+	if cleanup != nil {
+		result["cleanup"] = cleanup
+	}
+	a, b := sides[0].side, sides[1].side
+	result["from"] = a
+	result["to"] = b
+
+	progressStep(ctx, 0, "comparing")
+	switch {
+	case a.SHA256 != "" && a.SHA256 == b.SHA256:
+		result["status"] = "identical"
+		result["note"] = "Both snapshots hold byte-identical copies."
+	case a.content != nil && b.content != nil && isText(a.content) && isText(b.content):
+		ops, ok := diffLines(splitLines(textOf(a.content)), splitLines(textOf(b.content)))
+		result["status"] = "different"
+		result["kind"] = "text"
+		if !ok {
+			result["note"] = fmt.Sprintf("The two copies differ by more than %d lines; compare them side by side instead.", diffMaxEdits)
+			break
+		}
+		text, stats, truncated := unifiedDiff(diffLabel(filePath, a), diffLabel(filePath, b), ops, diffMaxOutputLines)
+		result["diff_stats"] = stats
+		if stats.Hunks == 0 {
+			result["note"] = "The text is the same; only line endings or a byte-order mark differ."
+			break
+		}
+		result["diff"] = text
+		if truncated {
+			result["diff_truncated"] = true
+			result["note"] = fmt.Sprintf("The diff was cut at %d lines; diff_stats counts all of it.", diffMaxOutputLines)
+		}
+	default:
+		result["status"] = "different"
+		result["kind"] = "binary"
+		result["comparison"] = map[string]interface{}{
+			"size_delta":       b.Size - a.Size,
+			"same_sha256":      a.SHA256 != "" && a.SHA256 == b.SHA256,
+			"modified_changed": a.ModifiedAt != b.ModifiedAt,
+		}
+		if a.SHA256 == "" || b.SHA256 == "" {
+			result["note"] = fmt.Sprintf("Files over the %d MB download limit are compared by size and modified time only.", downloadMaxBytes()>>20)
+		}
+	}
+	return formatSingle(result, args, formatCompact)
+}
+
+type diffFetch struct {
+	side *diffSide
+	err  error
+}
+
+// pickDiffVersions chooses the two versions to compare from versions
+// (newest first). With from == nil and to != nil, the file never changed
+// and reason says so. With to == nil, candidates and reason say what to
+// ask the user.
+func pickDiffVersions(versions []recoverVersion, fromID, toID string) (from, to *recoverVersion, candidates []recoverVersion, reason string) {
+	if len(versions) == 0 {
+		return nil, nil, nil, "no restorable snapshot contains this file"
+	}
+	find := func(id string) *recoverVersion {
+		for i := range versions {
+			if versions[i].SnapshotID == id {
+				return &versions[i]
+			}
+		}
+		return nil
+	}
+	for _, id := range []string{fromID, toID} {
+		if id != "" && find(id) == nil {
+			return nil, nil, versions, fmt.Sprintf("snapshot %s does not contain this file. Ask the user to pick versions, then re-call with from_snapshot_id/to_snapshot_id from candidates.", id)
+		}
+	}
+	to = &versions[0]
+	if toID != "" {
+		to = find(toID)
+	}
+	if fromID != "" {
+		return find(fromID), to, nil, ""
+	}
+	older := false
+	for i := range versions {
+		v := &versions[i]
+		if v == to {
+			older = true
+			continue
+		}
+		if older && (v.Size != to.Size || v.ModifiedTime != to.ModifiedTime) {
+			return v, to, nil, ""
+		}
+	}
+	if len(versions) == 1 {
+		return nil, nil, nil, "only one snapshot contains this file, so there is nothing to compare it with"
+	}
+	return nil, to, nil, "size and modified time are the same in every older snapshot holding this file; pass from_snapshot_id to compare with a specific one anyway."
+}
+
+// fetchDiffSide opens a restore for v and reads filePath from it: hashed
+// in full when under the download limit, and kept for diffing when under
+// diffTextMaxBytes.
+func fetchDiffSide(ctx context.Context, pool *restorePool, v *recoverVersion, filePath string) (*diffSide, error) {
+	restore, reused, err := pool.get(ctx, v.SnapshotID, v.DeviceID)
+	if err != nil {
+		return nil, fmt.Errorf("restore snapshot %s: %w", v.SnapshotID, err)
+	}
+	side := &diffSide{SnapshotID: v.SnapshotID, SnapshotTime: v.SnapshotTime, FileRestoreID: restore.FileRestoreID, Restore: "created"}
+	if reused {
+		side.Restore = "reused"
+	}
+	entry, err := findRestoreEntry(ctx, restore.FileRestoreID, filePath)
+	if err != nil {
+		return nil, fmt.Errorf("snapshot %s: %w", v.SnapshotID, err)
+	}
+	side.Size, side.ModifiedAt = entry.Size, entry.ModifiedAt
+	if entry.Size > downloadMaxBytes() {
+		return side, nil
+	}
+	uri, err := pickDownloadURI(entry.DownloadURIs)
+	if err != nil {
+		return nil, fmt.Errorf("snapshot %s: %s: %w", v.SnapshotID, entry.Path, err)
+	}
+	resp, err := openDownload(ctx, uri, 0)
+	if err != nil {
+		return nil, fmt.Errorf("snapshot %s: download %s: %w", v.SnapshotID, entry.Name, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("snapshot %s: download %s: server returned status %d", v.SnapshotID, entry.Name, resp.StatusCode)
+	}
+	hash := sha256.New()
+	var keep bytes.Buffer
+	dst := io.Writer(hash)
+	if entry.Size <= diffTextMaxBytes {
+		dst = io.MultiWriter(hash, &keep)
+	}
+	n, err := copyWithProgress(ctx, dst, resp.Body, 0, entry.Size, downloadMaxBytes(), entry.Name)
+	if err != nil {
+		return nil, fmt.Errorf("snapshot %s: download %s: %w", v.SnapshotID, entry.Name, err)
+	}
+	side.Size = n
+	side.SHA256 = hex.EncodeToString(hash.Sum(nil))
+	if entry.Size <= diffTextMaxBytes && n <= diffTextMaxBytes {
+		side.content = keep.Bytes()
+	}
+	return side, nil
+}
+
+// isText reports whether b looks like UTF-8 text: valid and free of NULs.
+func isText(b []byte) bool {
+	return utf8.Valid(b) && bytes.IndexByte(b, 0) < 0
+}
+
+// textOf is b as a string without a UTF-8 byte-order mark.
+func textOf(b []byte) string {
+	return strings.TrimPrefix(string(b), "\ufeff")
+}
+
+// diffLabel is the ---/+++ header for one side.
+func diffLabel(filePath string, s *diffSide) string {
+	when := s.SnapshotTime
+	if when == "" {
+		when = s.SnapshotID
+	}
+	return fmt.Sprintf("%s\t(snapshot %s)", toSlashPath(filePath), when)
+}
+
+// restorePool hands out one file restore per snapshot of an agent. It
+// reuses restores that are already open and deletes the ones it created
+// when released.
+type restorePool struct {
+	mu       sync.Mutex
+	existing []FileRestore
+	created  []string
+}
+
+// newRestorePool notes the agent's open file restores.
+func newRestorePool(ctx context.Context, agentID string) (*restorePool, error) {
+	restores, err := fetchAllPaginated[FileRestore](ctx, "/v1/restore/file")
+	if err != nil {
+		return nil, fmt.Errorf("list file restores: %w", err)
+	}
+	p := &restorePool{}
+	now := time.Now()
+	for _, r := range restores {
+		if r.AgentID != agentID {
+			continue
+		}
+		if r.ExpiresAt != nil {
+			if t, err := time.Parse(time.RFC3339, *r.ExpiresAt); err == nil && t.Before(now.Add(time.Minute)) {
+				continue
+			}
+		}
+		p.existing = append(p.existing, r)
+	}
+	return p, nil
+}
+
+// get returns a restore of snapshotID, creating one on deviceID when none
+// is open. reused reports which.
+func (p *restorePool) get(ctx context.Context, snapshotID, deviceID string) (r *FileRestore, reused bool, err error) {
+	p.mu.Lock()
+	for i := range p.existing {
+		if p.existing[i].SnapshotID == snapshotID {
+			r = &p.existing[i]
+			break
+		}
+	}
+	p.mu.Unlock()
+	if r != nil {
+		return r, true, nil
+	}
+	r, err = startFileRestore(ctx, snapshotID, deviceID)
+	if err != nil {
+		return nil, false, err
+	}
+	p.mu.Lock()
+	p.created = append(p.created, r.FileRestoreID)
+	p.existing = append(p.existing, *r)
+	p.mu.Unlock()
+	return r, false, nil
+}
+
+// release deletes the restores get created, even if ctx was canceled.
+// It reports what it did (nil when it created none), and an error naming
+// any restore it could not delete.
+func (p *restorePool) release(ctx context.Context) (map[string]interface{}, error) {
+	p.mu.Lock()
+	created := p.created
+	p.created = nil
+	p.mu.Unlock()
+	if len(created) == 0 {
+		return nil, nil
+	}
+	ctx = context.WithoutCancel(ctx)
+	deleted := []string{}
+	failed := map[string]string{}
+	for _, id := range created {
+		if _, err := makeAPIRequest(ctx, "DELETE", fmt.Sprintf("/v1/restore/file/%s", id), nil); err != nil {
+			failed[id] = err.Error()
+			continue
+		}
+		deleted = append(deleted, id)
+	}
+	out := map[string]interface{}{"deleted_restores": deleted}
+	if len(failed) == 0 {
+		return out, nil
+	}
+	out["failed"] = failed
+	out["note"] = "Delete these with slide_files operation=delete_restore, or they expire on their own."
+	ids := make([]string, 0, len(failed))
+	for id := range failed {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return out, fmt.Errorf("could not delete file restore(s) %s; delete them with slide_files operation=delete_restore", strings.Join(ids, ", "))
+}
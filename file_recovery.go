@@ -0,0 +1,386 @@
+package main
+
+// slide_files recover_file: the README's "find Q4-budget.xlsx on Bob's
+// laptop and restore Tuesday's version" in one call.
+//
+// The steps are the ones a tech would take by hand - search, versions,
+// create_restore, browse, create_push - with the choices made for them
+// when there is only one sensible answer. When there is more than one
+// (several files match, or the requested day has several different
+// versions) the call stops before creating anything and returns the
+// candidates; the caller re-calls with `path` or `snapshot_id` set.
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	recoverDefaultDestination = `C:\SlideRestore`
+	recoverMaxCandidates      = 20
+)
+
+// recoverVersion is a PathVersion joined with its snapshot.
+type recoverVersion struct {
+	SnapshotID   string `json:"snapshot_id"`
+	SnapshotTime string `json:"snapshot_time,omitempty"`
+	Size         int64  `json:"size"`
+	ModifiedTime string `json:"modified_time"`
+	DeviceID     string `json:"device_id,omitempty"`
+	Location     string `json:"location,omitempty"`
+
+	taken time.Time
+}
+
+// handleFilesRecoverFile finds one file on one agent, picks the version
+// to restore, creates the restore, and pushes the file to the protected
+// system's SlideRestore folder.
+func handleFilesRecoverFile(ctx context.Context, args map[string]interface{}) (string, error) {
+	agentID, err := requireString(args, "agent_id")
+	if err != nil {
+		return "", err
+	}
+	filePath, _ := optionalString(args, "path")
+	searchTerm, _ := optionalString(args, "search_term")
+	if filePath == "" && searchTerm == "" {
+		return "", fmt.Errorf("search_term or path is required")
+	}
+	snapshotID, _ := optionalString(args, "snapshot_id")
+	destination, _ := optionalString(args, "destination_folder")
+	if destination == "" {
+		destination = recoverDefaultDestination
+	}
+	if err := validateSlideRestoreFolder(destination); err != nil {
+		return "", err
+	}
+
+	agent, err := fetchAgent(ctx, agentID)
+	if err != nil {
+		return "", err
+	}
+	loc := agentLocation(agent)
+	date, before, err := parseRecoverTarget(args, loc)
+	if err != nil {
+		return "", err
+	}
+
+	if filePath == "" {
+		progressStep(ctx, 4, "searching %s for %q", bestAgentName(*agent), searchTerm)
+		var candidates []FileIndexSearch
+		filePath, candidates, err = pickRecoverPath(ctx, agentID, searchTerm)
+		if err != nil {
+			return "", err
+		}
+		if filePath == "" {
+			return recoverChoice(args, "path", candidates,
+				fmt.Sprintf("%d files match %q. Ask the user which one, then re-call recover_file with path=<path from candidates>.", len(candidates), searchTerm))
+		}
+	}
+
+	progressStep(ctx, 3, "listing versions of %s", filePath)
+	versions, err := fetchRecoverVersions(ctx, agentID, filePath, loc)
+	if err != nil {
+		return "", err
+	}
+	version, candidates, reason := pickRecoverVersion(versions, snapshotID, date, before, loc)
+	if version == nil {
+		if len(candidates) == 0 {
+			return "", fmt.Errorf("%s", reason)
+		}
+		return recoverChoice(args, "snapshot_id", candidates, reason)
+	}
+
+	progressStep(ctx, 2, "creating file restore from snapshot %s", version.SnapshotID)
+	restore, err := startFileRestore(ctx, version.SnapshotID, version.DeviceID)
+	if err != nil {
+		return "", err
+	}
+	result := map[string]interface{}{
+		"agent_id":        agentID,
+		"agent_name":      bestAgentName(*agent),
+		"path":            filePath,
+		"version":         version,
+		"file_restore_id": restore.FileRestoreID,
+		"expires_at":      restore.ExpiresAt,
+	}
+
+	progressStep(ctx, 1, "locating %s in the restore", filePath)
+	source, err := findRestoredFile(ctx, restore.FileRestoreID, filePath)
+	if err != nil {
+		// The restore is usable by hand; hand it over rather than failing.
+		result["status"] = "restore_ready"
+		result["note"] = fmt.Sprintf("The restore was created but the file could not be located in it automatically (%v).", err)
+		result["next_step"] = fmt.Sprintf("slide_files operation=browse file_restore_id=%s browse_path=%s, then create_push with the file's path.", restore.FileRestoreID, restoreBrowsePath(path.Dir(toSlashPath(filePath))))
+		return formatSingle(result, args, formatCompact)
+	}
+
+	progressStep(ctx, 0, "pushing %s to %s", source, destination)
+	push, err := startFileRestorePush(ctx, restore.FileRestoreID, source, destination)
+	if err != nil {
+		return "", fmt.Errorf("file restore %s created, but the push failed: %w", restore.FileRestoreID, err)
+	}
+	result["status"] = "push_started"
+	result["source_file_path"] = source
+	result["destination_folder"] = destination
+	result["file_restore_push_id"] = push.FileRestorePushID
+	result["state"] = push.State
+	result["next_step"] = fmt.Sprintf("slide_files operation=get_push_status file_restore_id=%s file_restore_push_id=%s", restore.FileRestoreID, push.FileRestorePushID)
+	return formatSingle(result, args, formatCompact)
+}
+
+// parseRecoverTarget reads `date` (a day in the agent's timezone) and
+// `before` (a YYYY-MM-DD day, meaning before it starts, or an RFC 3339
+// time). At most one may be set.
+func parseRecoverTarget(args map[string]interface{}, loc *time.Location) (date string, before time.Time, err error) {
+	date, _ = optionalString(args, "date")
+	b, _ := optionalString(args, "before")
+	if date != "" && b != "" {
+		return "", time.Time{}, fmt.Errorf("pass date or before, not both")
+	}
+	if date != "" {
+		if _, err := time.ParseInLocation(time.DateOnly, date, loc); err != nil {
+			return "", time.Time{}, fmt.Errorf("date must be YYYY-MM-DD (got %q)", date)
+		}
+	}
+	if b != "" {
+		if t, err := time.ParseInLocation(time.DateOnly, b, loc); err == nil {
+			before = t
+		} else if t, err := time.Parse(time.RFC3339, b); err == nil {
+			before = t
+		} else {
+			return "", time.Time{}, fmt.Errorf("before must be YYYY-MM-DD or an RFC 3339 time (got %q)", b)
+		}
+	}
+	return date, before, nil
+}
+
+// pickRecoverPath searches the agent's file index. It returns the path
+// when exactly one distinct file matches, or when exactly one match is
+// named search_term exactly; otherwise it returns the candidates.
+func pickRecoverPath(ctx context.Context, agentID, searchTerm string) (string, []FileIndexSearch, error) {
+	resp, err := searchAgentFiles(ctx, agentID, searchTerm, 50, 0, "path", nil)
+	if err != nil {
+		return "", nil, err
+	}
+	seen := map[string]bool{}
+	var unique, exact []FileIndexSearch
+	for _, f := range resp.Data {
+		if seen[f.Path] {
+			continue
+		}
+		seen[f.Path] = true
+		unique = append(unique, f)
+		if strings.EqualFold(path.Base(toSlashPath(f.Path)), searchTerm) {
+			exact = append(exact, f)
+		}
+	}
+	switch {
+	case len(unique) == 0:
+		return "", nil, fmt.Errorf("no file matching %q in the agent's file index; check the spelling or try slide_files operation=search with a shorter term", searchTerm)
+	case len(unique) == 1:
+		return unique[0].Path, nil, nil
+	case len(exact) == 1:
+		return exact[0].Path, nil, nil
+	}
+	return "", unique, nil
+}
+
+// fetchRecoverVersions lists the snapshots holding filePath, newest
+// first, with each snapshot's time and the device to restore it on.
+// Deleted snapshots are dropped.
+func fetchRecoverVersions(ctx context.Context, agentID, filePath string, loc *time.Location) ([]recoverVersion, error) {
+	sortAsc := false
+	resp, err := listPathVersions(ctx, agentID, filePath, 50, 0, "created_time", &sortAsc)
+	if err != nil {
+		return nil, err
+	}
+	type fetched struct {
+		v   recoverVersion
+		err error
+	}
+	rows, err := fanOut(ctx, resp.Data, func(ctx context.Context, pv PathVersion) fetched {
+		v := recoverVersion{SnapshotID: pv.SnapshotID, Size: pv.Size, ModifiedTime: pv.ModifiedTime}
+		s, err := fetchSnapshot(ctx, pv.SnapshotID)
+		if err != nil {
+			return fetched{v, err}
+		}
+		if s.Deleted != nil {
+			return fetched{}
+		}
+		if t, err := time.Parse(time.RFC3339, s.BackupStartedAt); err == nil {
+			v.taken = t
+			v.SnapshotTime = t.In(loc).Format(time.RFC3339)
+		}
+		v.DeviceID, v.Location = restoreDevice(s)
+		return fetched{v: v}
+	})
+	if err != nil {
+		return nil, err
+	}
+	versions := make([]recoverVersion, 0, len(rows))
+	for _, r := range rows {
+		if r.err != nil {
+			return nil, fmt.Errorf("look up snapshot %s: %w", r.v.SnapshotID, r.err)
+		}
+		if r.v.SnapshotID != "" && r.v.DeviceID != "" {
+			versions = append(versions, r.v)
+		}
+	}
+	sort.SliceStable(versions, func(i, j int) bool { return versions[i].taken.After(versions[j].taken) })
+	return versions, nil
+}
+
+// restoreDevice is where to restore s from: its local copy if it has one,
+// else its cloud copy.
+func restoreDevice(s *Snapshot) (deviceID, location string) {
+	for _, want := range []string{"local", "cloud"} {
+		for _, l := range s.Locations {
+			if l.Type == want && l.DeviceID != "" {
+				return l.DeviceID, want
+			}
+		}
+	}
+	return "", ""
+}
+
+// pickRecoverVersion chooses among versions (newest first). snapshotID
+// picks exactly; date picks that day's newest snapshot, unless the file
+// differs between that day's snapshots; before picks the newest snapshot
+// taken earlier; otherwise the newest overall. With no pick it returns
+// candidates and the reason.
+func pickRecoverVersion(versions []recoverVersion, snapshotID, date string, before time.Time, loc *time.Location) (*recoverVersion, []recoverVersion, string) {
+	if len(versions) == 0 {
+		return nil, nil, "no restorable snapshot contains this file"
+	}
+	switch {
+	case snapshotID != "":
+		for i := range versions {
+			if versions[i].SnapshotID == snapshotID {
+				return &versions[i], nil, ""
+			}
+		}
+		return nil, versions, fmt.Sprintf("snapshot %s does not contain this file. Ask the user to pick a version, then re-call with snapshot_id=<id from candidates>.", snapshotID)
+	case date != "":
+		var day []recoverVersion
+		for _, v := range versions {
+			if !v.taken.IsZero() && v.taken.In(loc).Format(time.DateOnly) == date {
+				day = append(day, v)
+			}
+		}
+		if len(day) == 0 {
+			return nil, versions, fmt.Sprintf("no snapshot from %s contains this file. Ask the user to pick a nearby version, then re-call with snapshot_id=<id from candidates>.", date)
+		}
+		for _, v := range day[1:] {
+			if v.Size != day[0].Size || v.ModifiedTime != day[0].ModifiedTime {
+				return nil, day, fmt.Sprintf("the file changed during %s, so that day has %d different versions. Ask the user which one, then re-call with snapshot_id=<id from candidates>.", date, len(day))
+			}
+		}
+		return &day[0], nil, ""
+	case !before.IsZero():
+		for i := range versions {
+			if !versions[i].taken.IsZero() && versions[i].taken.Before(before) {
+				return &versions[i], nil, ""
+			}
+		}
+		return nil, versions, fmt.Sprintf("no snapshot before %s contains this file. Ask the user to pick a version, then re-call with snapshot_id=<id from candidates>.", before.In(loc).Format(time.RFC3339))
+	}
+	return &versions[0], nil, ""
+}
+
+// recoverChoice is the stop-and-ask response.
+func recoverChoice[T any](args map[string]interface{}, choice string, candidates []T, hint string) (string, error) {
+	shown := candidates
+	if len(shown) > recoverMaxCandidates {
+		shown = shown[:recoverMaxCandidates]
+	}
+	return formatSingle(map[string]interface{}{
+		"status":           "needs_choice",
+		"choice":           choice,
+		"candidates":       shown,
+		"total_candidates": len(candidates),
+		"hint":             hint,
+	}, args, formatCompact)
+}
+
+// startFileRestore creates a file restore for snapshotID on deviceID.
+func startFileRestore(ctx context.Context, snapshotID, deviceID string) (*FileRestore, error) {
+	body, err := json.Marshal(map[string]interface{}{"snapshot_id": snapshotID, "device_id": deviceID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+	data, err := makeAPIRequest(ctx, "POST", "/v1/restore/file", body)
+	if err != nil {
+		return nil, err
+	}
+	var r FileRestore
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+	return &r, nil
+}
+
+// startFileRestorePush pushes source from a restore to destination.
+func startFileRestorePush(ctx context.Context, fileRestoreID, source, destination string) (*FileRestorePush, error) {
+	body, err := json.Marshal(map[string]interface{}{"source_file_path": source, "destination_folder": destination})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+	data, err := makeAPIRequest(ctx, "POST", fmt.Sprintf("/v1/restore/file/%s/push", fileRestoreID), body)
+	if err != nil {
+		return nil, err
+	}
+	var p FileRestorePush
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+	return &p, nil
+}
+
+// findRestoredFile browses the folder that should hold filePath and
+// returns the restore's own path for it.
+func findRestoredFile(ctx context.Context, fileRestoreID, filePath string) (string, error) {
+	e, err := findRestoreEntry(ctx, fileRestoreID, filePath)
+	if err != nil {
+		return "", err
+	}
+	return e.Path, nil
+}
+
+// findRestoreEntry browses the folder that should hold filePath (a
+// file-index or restore path) and returns its entry.
+func findRestoreEntry(ctx context.Context, fileRestoreID, filePath string) (*FileRestoreEntry, error) {
+	slashPath := toSlashPath(filePath)
+	dir, name := restoreBrowsePath(path.Dir(slashPath)), path.Base(slashPath)
+	entries, err := fetchAllPaginated[FileRestoreEntry](ctx, fmt.Sprintf("/v1/restore/file/%s/browse?path=%s", fileRestoreID, url.QueryEscape(dir)))
+	if err != nil {
+		return nil, err
+	}
+	for i := range entries {
+		if strings.EqualFold(entries[i].Name, name) {
+			return &entries[i], nil
+		}
+	}
+	return nil, fmt.Errorf("%s not found in %s", name, dir)
+}
+
+// toSlashPath turns a file-index path (C:\Users\bob\x.xlsx) into a
+// forward-slash one (C:/Users/bob/x.xlsx).
+func toSlashPath(p string) string {
+	return strings.ReplaceAll(p, `\`, "/")
+}
+
+// restoreBrowsePath is p as the browse API spells it: rooted, with the
+// drive letter as the first segment (/C:/Users).
+func restoreBrowsePath(p string) string {
+	p = toSlashPath(p)
+	if !strings.HasPrefix(p, "/") {
+		p = "/" + p
+	}
+	return p
+}
@@ -0,0 +1,225 @@
+package main
+
+// slide_files search_client: "somebody at ACME had the contract PDF".
+//
+// The file index is per agent, so the search fans out over every agent of
+// a client (or device) and merges the hits, best match first, each
+// attributed to its agent. Agents with file indexing turned off cannot be
+// searched and are listed as skipped rather than silently left out. The
+// whole sweep shares one deadline: when it passes, the hits gathered so
+// far are returned and the agents still outstanding are reported as
+// timed out.
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	searchClientDefaultTimeout = 30 * time.Second
+	searchClientMaxTimeout     = 120 * time.Second
+	searchClientDefaultLimit   = 50
+	searchClientMaxLimit       = 500
+	searchClientPerAgent       = 50 // the API's page cap
+)
+
+// Reasons an agent contributed no results.
+const (
+	searchSkipIndexDisabled = "file_index_disabled"
+	searchSkipError         = "error"
+	searchSkipTimedOut      = "timed_out"
+)
+
+// clientFileHit is one search result with its agent.
+type clientFileHit struct {
+	AgentID      string `json:"agent_id"`
+	AgentName    string `json:"agent_name"`
+	Path         string `json:"path"`
+	Size         int64  `json:"size"`
+	ModifiedTime string `json:"modified_time"`
+	Match        string `json:"match"`
+
+	rank int
+}
+
+// searchSkip is an agent that was not (fully) searched.
+type searchSkip struct {
+	AgentID   string `json:"agent_id"`
+	AgentName string `json:"agent_name"`
+	Reason    string `json:"reason"`
+	Note      string `json:"note,omitempty"`
+}
+
+// agentSearch is one agent's outcome.
+type agentSearch struct {
+	hits      []clientFileHit
+	skip      *searchSkip
+	truncated bool
+}
+
+// handleFilesSearchClient searches every file-indexed agent of a client
+// or device for search_term.
+func handleFilesSearchClient(ctx context.Context, args map[string]interface{}) (string, error) {
+	searchTerm, err := requireString(args, "search_term")
+	if err != nil {
+		return "", err
+	}
+	clientID, _ := optionalString(args, "client_id")
+	deviceID, _ := optionalString(args, "device_id")
+	if clientID == "" && deviceID == "" {
+		return "", fmt.Errorf("client_id or device_id is required (alternative: pass name_hint for the client)")
+	}
+	limit, _ := optionalInt(args, "limit")
+	if limit <= 0 {
+		limit = searchClientDefaultLimit
+	}
+	if limit > searchClientMaxLimit {
+		limit = searchClientMaxLimit
+	}
+	timeout := searchClientDefaultTimeout
+	if secs, ok := optionalInt(args, "timeout_seconds"); ok && secs > 0 {
+		timeout = min(time.Duration(secs)*time.Second, searchClientMaxTimeout)
+	}
+
+	start := time.Now()
+	agents, scope, err := fetchScopedAgents(ctx, map[string]interface{}{"client_id": clientID, "device_id": deviceID})
+	if err != nil {
+		return "", err
+	}
+
+	var searchable []Agent
+	var skipped []searchSkip
+	for _, a := range agents {
+		if a.FileIndexEnabled != nil && !*a.FileIndexEnabled {
+			skipped = append(skipped, searchSkip{AgentID: a.AgentID, AgentName: bestAgentName(a), Reason: searchSkipIndexDisabled,
+				Note: fmt.Sprintf("enable with slide_agents operation=set_file_index_enabled agent_id=%s", a.AgentID)})
+			continue
+		}
+		searchable = append(searchable, a)
+	}
+
+	searchCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	var started atomic.Int32
+	outcomes, err := fanOut(searchCtx, searchable, func(ctx context.Context, a Agent) *agentSearch {
+		n := int(started.Add(1))
+		progressStep(ctx, len(searchable)-n, "searching agent %d/%d (%s)", n, len(searchable), bestAgentName(a))
+		return searchOneAgent(ctx, a, searchTerm)
+	})
+	if err != nil && ctx.Err() != nil {
+		// The caller canceled; a passed deadline is reported below instead.
+		return "", ctx.Err()
+	}
+
+	var hits []clientFileHit
+	matched, agentsTruncated := 0, 0
+	for i, o := range outcomes {
+		a := searchable[i]
+		switch {
+		case o == nil:
+			skipped = append(skipped, searchSkip{AgentID: a.AgentID, AgentName: bestAgentName(a), Reason: searchSkipTimedOut,
+				Note: "not started before the deadline"})
+		case o.skip != nil:
+			skipped = append(skipped, *o.skip)
+		default:
+			if len(o.hits) > 0 {
+				matched++
+			}
+			if o.truncated {
+				agentsTruncated++
+			}
+			hits = append(hits, o.hits...)
+		}
+	}
+	sort.SliceStable(hits, func(i, j int) bool {
+		if hits[i].rank != hits[j].rank {
+			return hits[i].rank > hits[j].rank
+		}
+		if hits[i].ModifiedTime != hits[j].ModifiedTime {
+			return hits[i].ModifiedTime > hits[j].ModifiedTime
+		}
+		if hits[i].AgentName != hits[j].AgentName {
+			return hits[i].AgentName < hits[j].AgentName
+		}
+		return hits[i].Path < hits[j].Path
+	})
+	total := len(hits)
+	if total > limit {
+		hits = hits[:limit]
+	}
+	if hits == nil {
+		hits = []clientFileHit{}
+	}
+
+	bySkip := map[string]int{}
+	for _, s := range skipped {
+		bySkip[s.Reason]++
+	}
+	result := map[string]interface{}{
+		"scope":       scope,
+		"search_term": searchTerm,
+		"summary": map[string]interface{}{
+			"agents":          len(agents),
+			"searched":        len(searchable) - bySkip[searchSkipError] - bySkip[searchSkipTimedOut],
+			"matched_agents":  matched,
+			"results":         total,
+			"returned":        len(hits),
+			"skipped":         bySkip,
+			"timed_out":       bySkip[searchSkipTimedOut] > 0,
+			"elapsed_seconds": time.Since(start).Round(100 * time.Millisecond).Seconds(),
+		},
+		"results": hits,
+	}
+	if len(skipped) > 0 {
+		result["skipped_agents"] = skipped
+	}
+	if agentsTruncated > 0 {
+		result["note"] = fmt.Sprintf("%d agent(s) had more than %d matches; only their first %d are included. Narrow search_term or use slide_files operation=search on that agent.",
+			agentsTruncated, searchClientPerAgent, searchClientPerAgent)
+	}
+	return formatSingle(result, args, formatCompact)
+}
+
+// searchOneAgent runs the file search on one agent.
+func searchOneAgent(ctx context.Context, a Agent, searchTerm string) *agentSearch {
+	resp, err := searchAgentFiles(ctx, a.AgentID, searchTerm, searchClientPerAgent, 0, "", nil)
+	if err != nil {
+		reason := searchSkipError
+		if errors.Is(err, context.DeadlineExceeded) || ctx.Err() != nil {
+			reason = searchSkipTimedOut
+		}
+		return &agentSearch{skip: &searchSkip{AgentID: a.AgentID, AgentName: bestAgentName(a), Reason: reason, Note: err.Error()}}
+	}
+	out := &agentSearch{truncated: resp.Pagination.NextOffset != nil}
+	for _, f := range resp.Data {
+		match, rank := fileMatchRank(f.Path, searchTerm)
+		out.hits = append(out.hits, clientFileHit{
+			AgentID: a.AgentID, AgentName: bestAgentName(a),
+			Path: f.Path, Size: f.Size, ModifiedTime: f.ModifiedTime,
+			Match: match, rank: rank,
+		})
+	}
+	return out
+}
+
+// fileMatchRank grades how well p's file name matches term: the whole
+// name, its start, anywhere in it, or only elsewhere in the path.
+func fileMatchRank(p, term string) (string, int) {
+	name := strings.ToLower(path.Base(toSlashPath(p)))
+	term = strings.ToLower(term)
+	switch {
+	case name == term:
+		return "exact", 3
+	case strings.HasPrefix(name, term):
+		return "prefix", 2
+	case strings.Contains(name, term):
+		return "name", 1
+	}
+	return "path", 0
+}
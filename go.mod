@@ -2,7 +2,12 @@ module slide-mcp-server
 
 go 1.25.5
 
-require github.com/mark3labs/mcp-go v0.56.0
+require (
+	github.com/godbus/dbus/v5 v5.2.2
+	github.com/mark3labs/mcp-go v0.56.0
+	github.com/skip2/go-qrcode v0.0.0-20200617195104-da1b6568686e
+	gopkg.in/yaml.v3 v3.0.1
+)
 
 require (
 	github.com/google/jsonschema-go v0.4.2 // indirect
@@ -10,5 +15,6 @@ require (
 	github.com/santhosh-tekuri/jsonschema/v6 v6.0.2 // indirect
 	github.com/spf13/cast v1.7.1 // indirect
 	github.com/yosida95/uritemplate/v3 v3.0.2 // indirect
+	golang.org/x/sys v0.27.0 // indirect
 	golang.org/x/text v0.14.0 // indirect
 )
@@ -48,6 +48,15 @@ func nextStepsFor(tool, op string, args map[string]interface{}) []string {
 		v, _ := args[k].(string)
 		return v
 	}
+	// With wait=true the response is already the finished object; only
+	// a timed-out wait still needs polling.
+	waited, _ := optionalBool(args, "wait")
+	if background, _ := optionalBool(args, "background"); background {
+		switch op {
+		case "start", "boot_vm", "export_image", "create_restore", "create_push":
+			return []string{"Call slide_jobs operation=get job_id=<_job.job_id> later to see whether it has finished."}
+		}
+	}
 	switch tool {
 	case "slide_overview":
 		switch op {
@@ -78,41 +87,96 @@ func nextStepsFor(tool, op string, args map[string]interface{}) []string {
 			return []string{
 				"Pick a path from the results and call slide_files operation=versions agent_id=" + get("agent_id") + " path=<chosen path> to list every snapshot that contains it.",
 			}
+		case "search_client":
+			return []string{
+				"Pick a result and call slide_files operation=versions agent_id=<its agent_id> path=<its path>, or recover_file with the same.",
+				"Agents under skipped_agents were not searched; file_index_disabled ones need slide_agents operation=set_file_index_enabled first.",
+			}
 		case "versions":
 			return []string{
 				"Pick a snapshot_id and call slide_files operation=create_restore snapshot_id=<id> device_id=<id> to start a restore session.",
 			}
 		case "create_restore":
 			return []string{
-				"Call slide_files operation=browse file_restore_id=<id> browse_path=/ to walk the restored filesystem.",
+				"Call slide_files operation=tree file_restore_id=<id> to see the restored filesystem in one call (browse for a single folder).",
 				"Or slide_files operation=create_push file_restore_id=<id> source_file_path=<path> destination_folder=C:\\SlideRestore to push a file back to the protected system.",
 			}
 		case "create_push":
+			if waited {
+				return []string{"If _wait.timed_out is true, keep checking with slide_files operation=get_push_status file_restore_id=<id> file_restore_push_id=<id>."}
+			}
 			return []string{
 				"Call slide_files operation=get_push_status file_restore_id=<id> file_restore_push_id=<id> to monitor the push.",
+				"Or pass wait=true to create_push next time to get the finished push back in one call.",
 			}
 		case "browse":
 			return []string{
 				"Once you pick a file, call slide_files operation=create_push to push it back to the protected system.",
+				"Or slide_files operation=download file_restore_id=<id> source_file_path=<path> to save it on this computer (needs --download-dir).",
+			}
+		case "diff_versions":
+			return []string{
+				"If status is needs_choice, show the candidates and re-call with from_snapshot_id/to_snapshot_id.",
+				"To get a copy back, slide_files operation=recover_file with the snapshot_id of the version the user wants.",
+			}
+		case "diff_snapshots":
+			return []string{
+				"If verdict is suspicious, compare earlier snapshots the same way to find the last clean one before restoring.",
+				"slide_files operation=diff_versions on a flagged file shows exactly how it changed.",
+			}
+		case "tree":
+			return []string{
+				"For a folder marked '…', re-call tree with browse_path set to it, or raise max_depth/max_entries.",
+				"Once you pick a file, call slide_files operation=create_push or operation=download with its path.",
+			}
+		case "recover_file":
+			return []string{
+				"If status is needs_choice, show the candidates and re-call recover_file with the chosen path or snapshot_id.",
+				"Otherwise call slide_files operation=get_push_status with the returned IDs until the push completes.",
 			}
 		}
 	case "slide_recovery":
 		switch op {
 		case "boot_vm":
+			if waited {
+				return []string{
+					"slide_recovery operation=get_rdp_bookmark virt_id=<id> for an RDP file to access the VM.",
+					"If _wait.timed_out is true, keep checking with slide_recovery operation=get_vm virt_id=<id>.",
+				}
+			}
 			return []string{
-				"Call slide_recovery operation=get_vm virt_id=<from response> to confirm the VM is running.",
+				"Call slide_recovery operation=get_vm virt_id=<from response> to confirm the VM is running (or pass wait=true to boot_vm).",
 				"slide_recovery operation=get_rdp_bookmark virt_id=<id> for an RDP file to access the VM.",
 			}
+		case "dr_drill":
+			return []string{
+				"For a failed agent, the checks say which step failed; re-run dr_drill agent_id=<id> after fixing it.",
+				"If a teardown check failed, delete the VM with slide_recovery operation=delete_vm virt_id=<id>.",
+			}
 		case "export_image":
+			if waited {
+				return []string{
+					"slide_recovery operation=browse_image image_export_id=<id> lists the disks and their download links.",
+					"If _wait.timed_out is true, keep checking with slide_recovery operation=get_image image_export_id=<id>.",
+				}
+			}
 			return []string{
-				"Call slide_recovery operation=get_image image_export_id=<from response> to monitor progress.",
+				"Call slide_recovery operation=get_image image_export_id=<from response> to monitor progress (or pass wait=true to export_image).",
 				"slide_recovery operation=browse_image image_export_id=<id> once the export completes.",
 			}
+		case "browse_image", "tree_image":
+			return []string{
+				"slide_recovery operation=download_image image_export_id=<id> disk_id=<disk> saves a disk on this computer (needs --download-dir).",
+			}
 		case "create_network":
 			return []string{
 				"Add a port forward with slide_recovery operation=create_port_forward network_id=<from response>.",
 				"Or add a WireGuard peer with slide_recovery operation=create_wg_peer network_id=<id>.",
 			}
+		case "create_wg_peer":
+			return []string{
+				"Show the user _wireguard_qr to scan, or have them save _wireguard_config; the private key is not shown again.",
+			}
 		}
 	case "slide_alerts":
 		switch op {
@@ -122,15 +186,35 @@ func nextStepsFor(tool, op string, args map[string]interface{}) []string {
 				"Mark an alert resolved with slide_alerts operation=update alert_id=<id> resolved=true (requires safe or full mode).",
 			}
 		}
+	case "slide_jobs":
+		switch op {
+		case "list":
+			return []string{"Call slide_jobs operation=get job_id=<id> for a job's last object seen."}
+		case "get":
+			return []string{"Still running? Check back later, or slide_jobs operation=cancel job_id=" + get("job_id") + " to stop following it."}
+		}
 	case "slide_backups":
 		switch op {
+		case "status_all":
+			return []string{
+				"For any failed or missing agent, call slide_backups operation=recent_for_agent agent_id=<id> hours=24 to inspect specific runs.",
+				"Drill into one client with slide_backups operation=status_for_client client_id=<id>.",
+			}
+		case "rpo_report":
+			return []string{
+				"For each violation, call slide_backups operation=recent_for_agent agent_id=<id> hours=24 to see why backups are not landing.",
+				"Review or change an agent's schedule with slide_agents operation=set_schedule agent_id=<id> (requires full mode).",
+			}
 		case "status_for_client", "status_for_device":
 			return []string{
 				"For any agent with failures, call slide_backups operation=recent_for_agent agent_id=<id> hours=24 to inspect specific runs.",
 			}
 		case "start":
+			if waited {
+				return []string{"If _wait.timed_out is true, keep checking with slide_backups operation=get backup_id=<id>."}
+			}
 			return []string{
-				"Backup launched. Call slide_backups operation=recent_for_agent agent_id=" + get("agent_id") + " hours=2 to monitor.",
+				"Backup launched. Call slide_backups operation=recent_for_agent agent_id=" + get("agent_id") + " hours=2 to monitor, or pass wait=true to start to wait for it.",
 			}
 		}
 	case "slide_snapshots":
@@ -140,6 +224,22 @@ func nextStepsFor(tool, op string, args map[string]interface{}) []string {
 				"Pick a snapshot_id and call slide_recovery operation=boot_vm to spin up a recovery VM.",
 				"Or slide_files operation=versions agent_id=" + get("agent_id") + " path=<path> if the user wants a specific file.",
 			}
+		case "calendar":
+			return []string{
+				"For gap days, call slide_backups operation=recent_for_agent agent_id=" + get("agent_id") + " hours=<span> to see what the backups did.",
+				"Check the backup schedule with slide_agents operation=get agent_id=" + get("agent_id") + " if gaps line up with unscheduled hours.",
+			}
+		case "verification_report":
+			return []string{
+				"Open a failing agent's latest_verified.verify_boot_screenshot_url to see what the verification boot showed.",
+				"Call slide_snapshots operation=get_service_verification snapshot_id=<latest_verified.snapshot_id> for every service result.",
+				"Re-run with report=markdown, html, or both for a document to hand to the client.",
+			}
+		case "change_anomalies":
+			return []string{
+				"If verdict is suspicious, run the returned next_step to see every change in the first suspicious step.",
+				"Restore from last_clean: slide_recovery operation=boot_vm for a server, or slide_files operation=recover_file snapshot_id=<last_clean> for files.",
+			}
 		}
 	case "slide_audit":
 		switch op {
@@ -0,0 +1,458 @@
+package main
+
+// Background jobs: tracked polling for work that outlasts a tool call.
+//
+// wait=true holds the call open until the object is done, which suits a
+// VM boot but not an image export or a large push that runs for an hour.
+// background=true on the same operations returns at once with a `_job`
+// block; a goroutine keeps polling the object with waitFor's backoff, and
+// slide_jobs (list / get / cancel) and slide://jobs/{job_id} report where
+// it stands.
+//
+// Jobs belong to the API session that started them: over HTTP each MCP
+// session sees only its own, whether or not it brought a token, and they
+// stop when the session ends. stdio jobs are saved to --jobs-file when it
+// is set, and a restarted server resumes following the ones still
+// running. The file holds ids and states only - never the polled objects
+// (a VM's carries its VNC password) and never tokens, which is why HTTP
+// jobs are not saved.
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	jobTimeout   = 24 * time.Hour // a job still running after this is timed_out
+	jobRetention = 24 * time.Hour // finished jobs are forgotten after this
+	jobMaxKept   = 500            // finished jobs beyond this go oldest first
+)
+
+// Job statuses. Only running ever changes.
+const (
+	jobRunning   = "running"
+	jobSucceeded = "succeeded"
+	jobFailed    = "failed"
+	jobTimedOut  = "timed_out"
+	jobCanceled  = "canceled"
+	jobError     = "error"
+)
+
+var jobStatuses = []string{jobRunning, jobSucceeded, jobFailed, jobTimedOut, jobCanceled, jobError}
+
+const resourceURITplJob = "slide://jobs/{job_id}"
+
+// job is one tracked object. The exported fields are what slide_jobs
+// reports and what --jobs-file saves.
+type job struct {
+	JobID      string            `json:"job_id"`
+	URI        string            `json:"uri"`
+	Kind       string            `json:"kind"`
+	Tool       string            `json:"tool,omitempty"`
+	Operation  string            `json:"operation,omitempty"`
+	Label      string            `json:"label"`
+	Target     map[string]string `json:"target"`
+	Status     string            `json:"status"`
+	State      string            `json:"state,omitempty"`
+	Polls      int               `json:"polls"`
+	StartedAt  string            `json:"started_at"`
+	UpdatedAt  string            `json:"updated_at"`
+	FinishedAt string            `json:"finished_at,omitempty"`
+	Error      string            `json:"error,omitempty"`
+	Note       string            `json:"note,omitempty"`
+
+	owner  string      // apiSession key; "" is the process session
+	object interface{} // last object seen; kept in memory only
+	cancel context.CancelFunc
+}
+
+// jobRegistry holds every job in the process.
+type jobRegistry struct {
+	mu   sync.Mutex
+	jobs map[string]*job
+	path string // --jobs-file, once opened
+
+	saveMu    sync.Mutex     // orders writes to path
+	followers sync.WaitGroup // one per follow goroutine still running
+}
+
+var backgroundJobs = newJobRegistry()
+
+func newJobRegistry() *jobRegistry {
+	return &jobRegistry{jobs: map[string]*job{}}
+}
+
+func newJobID() string {
+	b := make([]byte, 6)
+	_, _ = rand.Read(b)
+	return "job_" + hex.EncodeToString(b)
+}
+
+func jobNow() string {
+	return time.Now().UTC().Format(time.RFC3339)
+}
+
+// start registers j for the caller's session, follows it with poll, and
+// returns a copy of the new job.
+func (r *jobRegistry) start(ctx context.Context, j *job, started time.Time, poll waitPoll) job {
+	s := sessionFromContext(ctx)
+	j.JobID = newJobID()
+	j.URI = "slide://jobs/" + j.JobID
+	j.Status = jobRunning
+	j.StartedAt = started.UTC().Format(time.RFC3339)
+	j.UpdatedAt = j.StartedAt
+	j.owner = s.key
+	r.mu.Lock()
+	r.prune(time.Now())
+	r.jobs[j.JobID] = j
+	cp := *j
+	r.mu.Unlock()
+	r.follow(s, j, started, poll)
+	r.save()
+	return cp
+}
+
+// follow polls j in the background under session s. The goroutine gets a
+// fresh context so the job outlives the call that started it and sends
+// that call no progress.
+func (r *jobRegistry) follow(s *apiSession, j *job, started time.Time, poll waitPoll) {
+	ctx, cancel := detachedContext(s)
+	r.mu.Lock()
+	j.cancel = cancel
+	r.mu.Unlock()
+	r.followers.Add(1)
+	go func() {
+		defer r.followers.Done()
+		defer cancel()
+		_, res, err := waitFor(ctx, j.Label, started, jobTimeout, func(ctx context.Context) (interface{}, string, waitOutcome, error) {
+			obj, state, outcome, err := poll(ctx)
+			if err == nil {
+				r.mu.Lock()
+				changed := j.State != state
+				j.object, j.State = obj, state
+				j.Polls++
+				j.UpdatedAt = jobNow()
+				r.mu.Unlock()
+				if changed {
+					r.save()
+				}
+			}
+			return obj, state, outcome, err
+		})
+		r.mu.Lock()
+		if j.Status == jobRunning {
+			switch {
+			case err != nil:
+				j.Status, j.Error = jobError, err.Error()
+			case res.TimedOut:
+				j.Status = jobTimedOut
+				j.Note = fmt.Sprintf("Still %s after %s; no longer followed.", j.State, jobTimeout)
+			case res.Succeeded:
+				j.Status = jobSucceeded
+			default:
+				j.Status = jobFailed
+			}
+			j.FinishedAt = jobNow()
+			j.UpdatedAt = j.FinishedAt
+		}
+		r.mu.Unlock()
+		r.save()
+	}()
+}
+
+// lookup finds a job the caller may see. Callers hold r.mu. Another
+// session's job reads as missing.
+func (r *jobRegistry) lookup(ctx context.Context, id string) (*job, error) {
+	j, ok := r.jobs[id]
+	if !ok || j.owner != sessionFromContext(ctx).key {
+		return nil, fmt.Errorf("no job %s in this session; slide_jobs operation=list shows the ones there are", id)
+	}
+	return j, nil
+}
+
+// get returns a copy of a job and the last object it saw.
+func (r *jobRegistry) get(ctx context.Context, id string) (job, interface{}, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	j, err := r.lookup(ctx, id)
+	if err != nil {
+		return job{}, nil, err
+	}
+	return *j, j.object, nil
+}
+
+// list returns copies of the caller's jobs, newest first, optionally only
+// those with the given status.
+func (r *jobRegistry) list(ctx context.Context, status string) []job {
+	owner := sessionFromContext(ctx).key
+	r.mu.Lock()
+	r.prune(time.Now())
+	out := make([]job, 0, len(r.jobs))
+	for _, j := range r.jobs {
+		if j.owner == owner && (status == "" || j.Status == status) {
+			out = append(out, *j)
+		}
+	}
+	r.mu.Unlock()
+	sort.Slice(out, func(i, k int) bool {
+		if out[i].StartedAt != out[k].StartedAt {
+			return out[i].StartedAt > out[k].StartedAt
+		}
+		return out[i].JobID < out[k].JobID
+	})
+	return out
+}
+
+// cancel stops following a running job and returns a copy of it. The
+// Slide-side work carries on.
+func (r *jobRegistry) cancel(ctx context.Context, id string) (job, error) {
+	r.mu.Lock()
+	j, err := r.lookup(ctx, id)
+	if err != nil {
+		r.mu.Unlock()
+		return job{}, err
+	}
+	wasRunning := j.Status == jobRunning
+	if wasRunning {
+		j.stop(jobCanceled, "")
+	}
+	cp := *j
+	r.mu.Unlock()
+	if wasRunning {
+		r.save()
+	}
+	return cp, nil
+}
+
+// stop ends a running job with status. Callers hold the registry lock.
+func (j *job) stop(status, note string) {
+	j.Status = status
+	if note != "" {
+		j.Note = note
+	}
+	j.FinishedAt = jobNow()
+	j.UpdatedAt = j.FinishedAt
+	if j.cancel != nil {
+		j.cancel()
+	}
+}
+
+// stopOwned cancels every running job whose owner matches, for a session
+// that has ended or an identity that has changed. drop also forgets them.
+func (r *jobRegistry) stopOwned(match func(owner string) bool, note string, drop bool) {
+	r.mu.Lock()
+	n := 0
+	for id, j := range r.jobs {
+		if !match(j.owner) {
+			continue
+		}
+		if j.Status == jobRunning {
+			j.stop(jobCanceled, note)
+			n++
+		}
+		if drop {
+			delete(r.jobs, id)
+		}
+	}
+	r.mu.Unlock()
+	if n > 0 {
+		r.save()
+	}
+}
+
+// prune forgets finished jobs past jobRetention, then the oldest finished
+// ones beyond jobMaxKept. Running jobs are kept. Callers hold r.mu.
+func (r *jobRegistry) prune(now time.Time) {
+	cutoff := now.Add(-jobRetention).UTC().Format(time.RFC3339)
+	var finished []*job
+	for id, j := range r.jobs {
+		if j.Status == jobRunning {
+			continue
+		}
+		if j.FinishedAt < cutoff {
+			delete(r.jobs, id)
+			continue
+		}
+		finished = append(finished, j)
+	}
+	if len(finished) <= jobMaxKept {
+		return
+	}
+	sort.Slice(finished, func(i, k int) bool { return finished[i].FinishedAt < finished[k].FinishedAt })
+	for _, j := range finished[:len(finished)-jobMaxKept] {
+		delete(r.jobs, j.JobID)
+	}
+}
+
+// open loads path and resumes following the jobs a previous run left
+// running. A missing file is an empty one. On error nothing is saved.
+func (r *jobRegistry) open(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	var saved struct {
+		Jobs []*job `json:"jobs"`
+	}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &saved); err != nil {
+			return fmt.Errorf("parse %s: %w", path, err)
+		}
+	}
+	var resume []*job
+	r.mu.Lock()
+	r.path = path
+	for _, j := range saved.Jobs {
+		if j.JobID == "" {
+			continue
+		}
+		r.jobs[j.JobID] = j
+		if j.Status == jobRunning {
+			resume = append(resume, j)
+		}
+	}
+	r.prune(time.Now())
+	r.mu.Unlock()
+
+	for _, j := range resume {
+		kind, ok := waitKinds[j.Kind]
+		created := make(map[string]interface{}, len(j.Target))
+		for k, v := range j.Target {
+			created[k] = v
+		}
+		var poll waitPoll
+		if ok {
+			_, poll, err = kind.target(created)
+		} else {
+			err = fmt.Errorf("unknown job kind %q", j.Kind)
+		}
+		if err != nil {
+			r.mu.Lock()
+			j.stop(jobError, "")
+			j.Error = "cannot resume: " + err.Error()
+			r.mu.Unlock()
+			continue
+		}
+		started, err := time.Parse(time.RFC3339, j.StartedAt)
+		if err != nil {
+			started = time.Now()
+		}
+		r.follow(processSession, j, started, poll)
+	}
+	r.save()
+	return nil
+}
+
+// save writes the process session's jobs to path, if one is open.
+// Failures are logged: a job must not fail because its record could not
+// be written.
+func (r *jobRegistry) save() {
+	r.saveMu.Lock()
+	defer r.saveMu.Unlock()
+	r.mu.Lock()
+	path := r.path
+	saved := []job{}
+	for _, j := range r.jobs {
+		if j.owner == "" {
+			saved = append(saved, *j)
+		}
+	}
+	r.mu.Unlock()
+	if path == "" {
+		return
+	}
+	sort.Slice(saved, func(i, k int) bool { return saved[i].StartedAt < saved[k].StartedAt })
+	data, err := json.MarshalIndent(map[string]interface{}{"jobs": saved}, "", "  ")
+	if err == nil {
+		err = writeFileAtomic(path, data)
+	}
+	if err != nil {
+		log.Printf("Warning: could not save jobs to %s: %v", path, err)
+	}
+}
+
+// writeFileAtomic replaces path with data, readable by the owner only.
+func writeFileAtomic(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(0o600); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// jobFollowUp is the get call that shows a job's object directly.
+func jobFollowUp(j job) string {
+	t := j.Target
+	switch j.Kind {
+	case "backup":
+		return "slide_backups operation=get backup_id=" + t["backup_id"]
+	case "vm":
+		return "slide_recovery operation=get_vm virt_id=" + t["virt_id"]
+	case "image_export":
+		return "slide_recovery operation=get_image image_export_id=" + t["image_export_id"]
+	case "file_restore":
+		return "slide_files operation=get_restore file_restore_id=" + t["file_restore_id"]
+	case "push":
+		return "slide_files operation=get_push_status file_restore_id=" + t["file_restore_id"] + " file_restore_push_id=" + t["file_restore_push_id"]
+	}
+	return ""
+}
+
+// jobView is a job with the last object it saw, as get and the resource
+// return it.
+func jobView(j job, obj interface{}) map[string]interface{} {
+	raw, _ := json.Marshal(j)
+	var out map[string]interface{}
+	_ = json.Unmarshal(raw, &out)
+	if obj != nil {
+		out["object"] = obj
+	} else if j.Polls > 0 || j.Status != jobRunning {
+		out["object_note"] = "The last object seen is not kept across a server restart; " + jobFollowUp(j) + " fetches it."
+	}
+	return out
+}
+
+func handleResourceJob(ctx context.Context, uri string) ([]byte, error) {
+	id, err := extractIDFromURI(uri, "slide://jobs/")
+	if err != nil {
+		return nil, err
+	}
+	j, obj, err := backgroundJobs.get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(jobView(j, obj))
+}
+
+// sessionJobOwner matches the jobs of every tenant on one MCP session.
+func sessionJobOwner(mcpSessionID string) func(string) bool {
+	prefix := mcpSessionID + "|"
+	return func(owner string) bool { return strings.HasPrefix(owner, prefix) }
+}
@@ -0,0 +1,72 @@
+//go:build linux
+
+package main
+
+// Linux Secret Service (GNOME Keyring, KWallet, KeePassXC) lookup over the
+// session D-Bus. Store the token once with:
+//
+//	secret-tool store --label="Slide API token" service slide-mcp-server account default
+//
+// and start the server with SLIDE_API_KEY_KEYRING=default.
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+)
+
+const (
+	secretServiceName    = "org.freedesktop.secrets"
+	secretServicePath    = dbus.ObjectPath("/org/freedesktop/secrets")
+	secretServiceTimeout = 10 * time.Second
+)
+
+// secretServiceSecret mirrors the (oayays) Secret struct of the spec.
+type secretServiceSecret struct {
+	Session     dbus.ObjectPath
+	Parameters  []byte
+	Value       []byte
+	ContentType string
+}
+
+// readKeyringToken fetches the secret stored under service=slide-mcp-server
+// account=<account>. A locked item is reported rather than unlocked: the
+// unlock prompt would pop up on a desktop the MCP host may not be showing.
+func readKeyringToken(account string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), secretServiceTimeout)
+	defer cancel()
+
+	conn, err := dbus.ConnectSessionBus(dbus.WithContext(ctx))
+	if err != nil {
+		return "", fmt.Errorf("connect to session D-Bus: %w", err)
+	}
+	defer conn.Close()
+	svc := conn.Object(secretServiceName, secretServicePath)
+
+	attrs := map[string]string{"service": keyringService, "account": account}
+	var unlocked, locked []dbus.ObjectPath
+	if err := svc.CallWithContext(ctx, "org.freedesktop.Secret.Service.SearchItems", 0, attrs).Store(&unlocked, &locked); err != nil {
+		return "", fmt.Errorf("search Secret Service: %w", err)
+	}
+	if len(unlocked) == 0 {
+		if len(locked) > 0 {
+			return "", fmt.Errorf("keyring item service=%s account=%s is locked; unlock the keyring and retry", keyringService, account)
+		}
+		return "", fmt.Errorf("no keyring item with service=%s account=%s", keyringService, account)
+	}
+
+	var output dbus.Variant
+	var session dbus.ObjectPath
+	if err := svc.CallWithContext(ctx, "org.freedesktop.Secret.Service.OpenSession", 0, "plain", dbus.MakeVariant("")).Store(&output, &session); err != nil {
+		return "", fmt.Errorf("open Secret Service session: %w", err)
+	}
+	defer conn.Object(secretServiceName, session).CallWithContext(ctx, "org.freedesktop.Secret.Session.Close", 0)
+
+	var secret secretServiceSecret
+	if err := conn.Object(secretServiceName, unlocked[0]).CallWithContext(ctx, "org.freedesktop.Secret.Item.GetSecret", 0, session).Store(&secret); err != nil {
+		return "", fmt.Errorf("read keyring item: %w", err)
+	}
+	return firstLineToken(string(secret.Value), "keyring secret")
+}
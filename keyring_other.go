@@ -0,0 +1,10 @@
+//go:build !linux
+
+package main
+
+import "fmt"
+
+// readKeyringToken is Linux-only: the Secret Service API lives on D-Bus.
+func readKeyringToken(account string) (string, error) {
+	return "", fmt.Errorf("the Secret Service keyring is only available on Linux; use SLIDE_API_KEY_FILE or SLIDE_API_KEY_COMMAND")
+}
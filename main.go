@@ -6,13 +6,33 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
 )
 
 // Global configuration instance. Plumbed through tools_*.go via package
-// scope rather than DI because slide-mcp-server is a single-process,
-// single-tenant binary; the indirection cost outweighs the cleanliness
-// benefit and would touch every handler signature.
-var config *ServerConfig
+// scope rather than DI: permission tier and disabled tools are the same for
+// every caller. The only per-caller state, the Slide identity over HTTP,
+// rides on the handler context instead (see session.go).
+//
+// switch_profile replaces it while other tool calls are running, so it is
+// read with currentConfig and replaced with setConfig, never modified once
+// published. Take one snapshot per decision: two reads may straddle a
+// switch.
+var processConfig atomic.Pointer[ServerConfig]
+
+// currentConfig returns the configuration in effect, or nil before
+// startup has resolved one.
+func currentConfig() *ServerConfig {
+	return processConfig.Load()
+}
+
+// setConfig publishes c as the configuration in effect.
+func setConfig(c *ServerConfig) {
+	processConfig.Store(c)
+}
 
 func main() {
 	var (
@@ -23,6 +43,18 @@ func main() {
 		showVersion      = flag.Bool("version", false, "Show version information and exit")
 		runDoctorFlag    = flag.Bool("doctor", false, "Run self-diagnostic checks (token, network, sample reads) and exit. Idempotent and CI-friendly.")
 		runDebugFlag     = flag.Bool("debug", false, "Dump a full diagnostic bundle (version, runtime, config, env, DNS, TLS, live API probes, recent logs) as JSON and exit. Safe to paste into a support thread; API token is masked.")
+		cliTransport     = flag.String("transport", "", "Transport: stdio (default) or http (overrides SLIDE_TRANSPORT environment variable)")
+		cliListen        = flag.String("listen", "", "Listen address for --transport http, e.g. :8080 (overrides SLIDE_LISTEN environment variable; default 127.0.0.1:8080)")
+		cliHTTPAuthToken = flag.String("http-auth-token", "", "Shared secret HTTP callers without their own Slide token must send as Authorization: Bearer (overrides SLIDE_HTTP_AUTH_TOKEN environment variable)")
+		cliConfigFile    = flag.String("config", "", "Path to the profiles file (overrides SLIDE_CONFIG environment variable; default ~/.config/slide-mcp/cfg.yaml)")
+		cliConcurrency   = flag.Int("concurrency", 0, fmt.Sprintf("Max parallel Slide API requests per fan-out, 1-%d (overrides SLIDE_CONCURRENCY environment variable; default %d)", maxConcurrency, defaultConcurrency))
+		cliRDPDir        = flag.String("rdp-dir", "", "Directory where get_rdp_bookmark saves .rdp files (overrides SLIDE_RDP_DIR environment variable)")
+		cliRDPCache      = flag.Bool("rdp-cache", false, "Also upload .rdp bookmarks to the external slide.recipes cache for a download link (or set SLIDE_RDP_CACHE=true)")
+		cliDownloadDir   = flag.String("download-dir", "", "Directory where slide_files download and slide_recovery download_image save files; unset disables them (overrides SLIDE_DOWNLOAD_DIR environment variable)")
+		cliDownloadMaxMB = flag.Int("download-max-mb", 0, fmt.Sprintf("Largest file download/download_image will fetch, in MB (overrides SLIDE_DOWNLOAD_MAX_MB environment variable; default %d)", defaultDownloadMaxMB))
+		cliReportDir     = flag.String("report-dir", "", "Directory where dr_drill and verification_report save Markdown/HTML reports (overrides SLIDE_REPORT_DIR environment variable)")
+		cliJobsFile      = flag.String("jobs-file", "", "File where background jobs are saved so a restart resumes them (overrides SLIDE_JOBS_FILE environment variable)")
+		cliProfile       = flag.String("profile", "", "Named profile from the profiles file (overrides SLIDE_PROFILE environment variable and default_profile)")
 		skipValidation   = flag.Bool("skip-startup-validation", false, "Skip the startup probe of /v1/account. Useful when launching offline.")
 
 		// One-shot tool execution flags
@@ -36,44 +68,166 @@ func main() {
 		os.Exit(0)
 	}
 
-	config = NewServerConfig()
+	// Flags and env vars outrank the profile file, which outranks defaults.
+	var overrides configOverrides
 
 	if *cliTools != "" {
-		config.ToolsMode = *cliTools
+		overrides.ToolsMode = *cliTools
 	} else if envTools := os.Getenv("SLIDE_TOOLS"); envTools != "" {
-		config.ToolsMode = envTools
+		overrides.ToolsMode = envTools
 	}
 
-	var disabledToolsStr string
 	if *cliDisabledTools != "" {
-		disabledToolsStr = *cliDisabledTools
+		overrides.DisabledTools = *cliDisabledTools
 	} else if envDisabledTools := os.Getenv("SLIDE_DISABLED_TOOLS"); envDisabledTools != "" {
-		disabledToolsStr = envDisabledTools
+		overrides.DisabledTools = envDisabledTools
 	}
-	config.SetDisabledTools(disabledToolsStr)
 
 	if *cliBaseURL != "" {
-		config.BaseURL = *cliBaseURL
+		overrides.BaseURL = *cliBaseURL
 	} else if envBaseURL := os.Getenv("SLIDE_BASE_URL"); envBaseURL != "" {
-		config.BaseURL = envBaseURL
+		overrides.BaseURL = envBaseURL
 	}
 
 	if *cliAPIKey != "" {
-		config.APIKey = *cliAPIKey
+		overrides.APIKey, overrides.APIKeySource = *cliAPIKey, "--api-key"
+	} else if envAPIKey := os.Getenv("SLIDE_API_KEY"); envAPIKey != "" {
+		overrides.APIKey, overrides.APIKeySource = envAPIKey, "SLIDE_API_KEY"
 	} else {
-		config.APIKey = os.Getenv("SLIDE_API_KEY")
+		sources := envTokenSources()
+		token, kind, err := sources.resolve()
+		envName := "SLIDE_API_KEY_" + strings.ToUpper(kind)
+		switch {
+		case err != nil && (*runDoctorFlag || *runDebugFlag):
+			// Let the diagnostics report the broken source instead of dying.
+			apiKeySourceErr = fmt.Errorf("%s: %w", envName, err)
+		case err != nil:
+			log.Fatalf("%s: %v", envName, err)
+		case token != "":
+			overrides.APIKey = token
+			overrides.APIKeySource = fmt.Sprintf("%s (%s)", envName, sources.detail(kind))
+		}
+	}
+
+	profilesPath, explicitProfilesPath := defaultProfilesPath(), false
+	if *cliConfigFile != "" {
+		profilesPath, explicitProfilesPath = *cliConfigFile, true
+	} else if envConfig := os.Getenv("SLIDE_CONFIG"); envConfig != "" {
+		profilesPath, explicitProfilesPath = envConfig, true
+	}
+	profiles, err := loadProfiles(profilesPath, explicitProfilesPath)
+	if err != nil {
+		log.Fatal(err)
 	}
 
-	if err := config.Validate(); err != nil {
+	profileName := profiles.DefaultProfile
+	if *cliProfile != "" {
+		profileName = *cliProfile
+	} else if envProfile := os.Getenv("SLIDE_PROFILE"); envProfile != "" {
+		profileName = envProfile
+	}
+
+	cfg, err := profiles.resolveConfig(profileName, overrides)
+	if err != nil {
 		log.Fatal(err)
 	}
 
-	if config.APIKey == "" && !*runDoctorFlag && !*runDebugFlag {
+	if *cliTransport != "" {
+		cfg.Transport = *cliTransport
+	} else if envTransport := os.Getenv("SLIDE_TRANSPORT"); envTransport != "" {
+		cfg.Transport = envTransport
+	}
+
+	if *cliListen != "" {
+		cfg.ListenAddr = *cliListen
+	} else if envListen := os.Getenv("SLIDE_LISTEN"); envListen != "" {
+		cfg.ListenAddr = envListen
+	}
+
+	if *cliHTTPAuthToken != "" {
+		cfg.HTTPAuthToken = *cliHTTPAuthToken
+	} else if envHTTPAuthToken := os.Getenv("SLIDE_HTTP_AUTH_TOKEN"); envHTTPAuthToken != "" {
+		cfg.HTTPAuthToken = envHTTPAuthToken
+	}
+
+	if *cliConcurrency != 0 {
+		cfg.Concurrency = *cliConcurrency
+	} else if envConcurrency := os.Getenv("SLIDE_CONCURRENCY"); envConcurrency != "" {
+		n, err := strconv.Atoi(envConcurrency)
+		if err != nil {
+			log.Fatalf("invalid SLIDE_CONCURRENCY %q: expected an integer", envConcurrency)
+		}
+		cfg.Concurrency = n
+	}
+
+	if *cliRDPDir != "" {
+		cfg.RDPDir = *cliRDPDir
+	} else if envRDPDir := os.Getenv("SLIDE_RDP_DIR"); envRDPDir != "" {
+		cfg.RDPDir = envRDPDir
+	}
+
+	if *cliRDPCache {
+		cfg.RDPCache = true
+	} else if envRDPCache := os.Getenv("SLIDE_RDP_CACHE"); envRDPCache != "" {
+		enabled, err := strconv.ParseBool(envRDPCache)
+		if err != nil {
+			log.Fatalf("invalid SLIDE_RDP_CACHE %q: expected true or false", envRDPCache)
+		}
+		cfg.RDPCache = enabled
+	}
+
+	if *cliDownloadDir != "" {
+		cfg.DownloadDir = *cliDownloadDir
+	} else if envDownloadDir := os.Getenv("SLIDE_DOWNLOAD_DIR"); envDownloadDir != "" {
+		cfg.DownloadDir = envDownloadDir
+	}
+
+	if *cliDownloadMaxMB != 0 {
+		cfg.DownloadMaxMB = *cliDownloadMaxMB
+	} else if envDownloadMaxMB := os.Getenv("SLIDE_DOWNLOAD_MAX_MB"); envDownloadMaxMB != "" {
+		n, err := strconv.Atoi(envDownloadMaxMB)
+		if err != nil {
+			log.Fatalf("invalid SLIDE_DOWNLOAD_MAX_MB %q: expected an integer", envDownloadMaxMB)
+		}
+		cfg.DownloadMaxMB = n
+	}
+
+	if *cliReportDir != "" {
+		cfg.ReportDir = *cliReportDir
+	} else if envReportDir := os.Getenv("SLIDE_REPORT_DIR"); envReportDir != "" {
+		cfg.ReportDir = envReportDir
+	}
+
+	if *cliJobsFile != "" {
+		cfg.JobsFile = *cliJobsFile
+	} else if envJobsFile := os.Getenv("SLIDE_JOBS_FILE"); envJobsFile != "" {
+		cfg.JobsFile = envJobsFile
+	}
+
+	if err := cfg.Validate(); err != nil {
+		log.Fatal(err)
+	}
+	setConfig(cfg)
+
+	profileRuntime.path = profilesPath
+	profileRuntime.file = profiles
+	profileRuntime.overrides = overrides
+	profileRuntime.ceiling = cfg.ToolsMode
+	profileRuntime.disabled = slices.Clone(cfg.DisabledTools)
+
+	// Over HTTP each request may bring its own token, so a server-wide
+	// token is optional there.
+	multiTenant := cfg.Transport == TransportHTTP && *cliOneShotTool == ""
+	if cfg.APIKey == "" && !*runDoctorFlag && !*runDebugFlag && !multiTenant {
 		log.Fatalf(`Error: Slide API token not provided.
 
 Use one of:
   - Pass --api-key <token>
   - Set the SLIDE_API_KEY environment variable
+  - Point SLIDE_API_KEY_FILE at a file only you can read (chmod 600)
+  - Set SLIDE_API_KEY_COMMAND to a password-manager command, e.g. op read op://...
+  - On Linux, set SLIDE_API_KEY_KEYRING to a Secret Service account name
+  - Select a profile from ~/.config/slide-mcp/cfg.yaml with --profile <name>
   - Configure the token in Claude Desktop's Slide Backup extension settings
 
 Generate a token at https://console.slide.tech under My Settings -> API Tokens.
@@ -81,11 +235,15 @@ Generate a token at https://console.slide.tech under My Settings -> API Tokens.
 To diagnose without a token: slide-mcp-server --debug`)
 	}
 
-	APIBaseURL = config.BaseURL
-	apiKey = config.APIKey
+	APIBaseURL = cfg.BaseURL
+	apiKey = cfg.APIKey
+
+	if cfg.Profile != "" {
+		log.Printf("Using profile %q from %s", cfg.Profile, profilesPath)
+	}
 
-	if len(config.DisabledTools) > 0 {
-		log.Printf("Disabled tools: %v", config.DisabledTools)
+	if len(cfg.DisabledTools) > 0 {
+		log.Printf("Disabled tools: %v", cfg.DisabledTools)
 	}
 
 	if *runDoctorFlag {
@@ -98,6 +256,14 @@ To diagnose without a token: slide-mcp-server --debug`)
 		return
 	}
 
+	// A bad jobs file must not keep the server from starting; it is left
+	// alone and jobs live in memory only.
+	if cfg.JobsFile != "" {
+		if err := backgroundJobs.open(cfg.JobsFile); err != nil {
+			log.Printf("Warning: background jobs will not be saved: %v", err)
+		}
+	}
+
 	if *cliOneShotTool != "" {
 		argsMap := map[string]interface{}{}
 		if *cliToolArgs != "" {
@@ -111,17 +277,24 @@ To diagnose without a token: slide-mcp-server --debug`)
 		return
 	}
 
-	log.Printf("Slide MCP Server v%s starting (mode=%s)...", Version, config.ToolsMode)
+	log.Printf("Slide MCP Server v%s starting (mode=%s)...", Version, cfg.ToolsMode)
 
 	// Validate the API token in the background so we never block the MCP
 	// initialize handshake on a network round-trip to Slide. The goroutine
 	// only logs to stderr - it can NEVER kill the process. If the token
 	// is bad, each tool call surfaces the same friendly auth error via
 	// APIError, and the warning lands in Claude Desktop's extension log.
-	if !*skipValidation {
+	if !*skipValidation && cfg.APIKey != "" {
 		go runStartupValidation()
 	}
 
+	if cfg.Transport == TransportHTTP {
+		if err := runHTTPServer(cfg.ListenAddr); err != nil {
+			log.Fatalf("Server error: %v", err)
+		}
+		return
+	}
+
 	if err := runStdioServer(); err != nil {
 		log.Fatalf("Server error: %v", err)
 	}
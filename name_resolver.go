@@ -24,6 +24,7 @@ package main
 // back to the user, then re-call with an explicit *_id.
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"regexp"
@@ -58,17 +59,29 @@ type nameCacheEntry struct {
 	fetchedAt  time.Time
 }
 
-var (
-	nameCacheMu sync.RWMutex
-	nameCache   = map[string]nameCacheEntry{}
-)
+// nameCacheStore is one apiSession's resolver cache, keyed by kind.
+type nameCacheStore struct {
+	mu      sync.RWMutex
+	entries map[string]nameCacheEntry
+}
+
+func newNameCacheStore() *nameCacheStore {
+	return &nameCacheStore{entries: map[string]nameCacheEntry{}}
+}
+
+func (c *nameCacheStore) reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = map[string]nameCacheEntry{}
+}
 
 // nameCacheGet returns a non-empty, non-stale cache entry for kind, or
 // the zero value if the cache should be refreshed.
-func nameCacheGet(kind string) (nameCacheEntry, bool) {
-	nameCacheMu.RLock()
-	defer nameCacheMu.RUnlock()
-	e, ok := nameCache[kind]
+func nameCacheGet(ctx context.Context, kind string) (nameCacheEntry, bool) {
+	c := sessionFromContext(ctx).names
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	e, ok := c.entries[kind]
 	if !ok {
 		return nameCacheEntry{}, false
 	}
@@ -78,27 +91,26 @@ func nameCacheGet(kind string) (nameCacheEntry, bool) {
 	return e, true
 }
 
-func nameCachePut(kind string, candidates []nameCandidate) {
-	nameCacheMu.Lock()
-	defer nameCacheMu.Unlock()
-	nameCache[kind] = nameCacheEntry{candidates: candidates, fetchedAt: time.Now()}
+func nameCachePut(ctx context.Context, kind string, candidates []nameCandidate) {
+	c := sessionFromContext(ctx).names
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[kind] = nameCacheEntry{candidates: candidates, fetchedAt: time.Now()}
 }
 
 // resetNameCache is used by tests to force a refresh between scenarios.
 func resetNameCache() {
-	nameCacheMu.Lock()
-	defer nameCacheMu.Unlock()
-	nameCache = map[string]nameCacheEntry{}
+	processSession.names.reset()
 }
 
 // fetchCandidates pulls the live list of agents / devices / clients and
 // maps each one into one or more nameCandidate rows. Agents and devices
 // publish both display_name and hostname; we emit both as candidates so
 // either lookup style works.
-func fetchCandidates(kind string) ([]nameCandidate, error) {
+func fetchCandidates(ctx context.Context, kind string) ([]nameCandidate, error) {
 	switch kind {
 	case "agent":
-		body, err := makeAPIRequest("GET", "/v1/agent?limit=50", nil)
+		body, err := makeAPIRequest(ctx, "GET", "/v1/agent?limit=50", nil)
 		if err != nil {
 			return nil, err
 		}
@@ -120,7 +132,7 @@ func fetchCandidates(kind string) ([]nameCandidate, error) {
 		}
 		return out, nil
 	case "device":
-		body, err := makeAPIRequest("GET", "/v1/device?limit=50", nil)
+		body, err := makeAPIRequest(ctx, "GET", "/v1/device?limit=50", nil)
 		if err != nil {
 			return nil, err
 		}
@@ -140,7 +152,7 @@ func fetchCandidates(kind string) ([]nameCandidate, error) {
 		}
 		return out, nil
 	case "client":
-		body, err := makeAPIRequest("GET", "/v1/client?limit=50", nil)
+		body, err := makeAPIRequest(ctx, "GET", "/v1/client?limit=50", nil)
 		if err != nil {
 			return nil, err
 		}
@@ -158,15 +170,15 @@ func fetchCandidates(kind string) ([]nameCandidate, error) {
 }
 
 // ensureCandidates returns the cached list for kind, fetching if stale.
-func ensureCandidates(kind string) ([]nameCandidate, error) {
-	if e, ok := nameCacheGet(kind); ok {
+func ensureCandidates(ctx context.Context, kind string) ([]nameCandidate, error) {
+	if e, ok := nameCacheGet(ctx, kind); ok {
 		return e.candidates, nil
 	}
-	candidates, err := fetchCandidates(kind)
+	candidates, err := fetchCandidates(ctx, kind)
 	if err != nil {
 		return nil, err
 	}
-	nameCachePut(kind, candidates)
+	nameCachePut(ctx, kind, candidates)
 	return candidates, nil
 }
 
@@ -225,13 +237,13 @@ type ResolutionSpec struct {
 //     (no resolution attempted - the existing requireString-in-handler
 //     path will use that value).
 //   - Else if args["name_hint"] is a non-empty string, fuzzy-match and:
-//     * 0 matches  -> hintResp is a JSON payload describing the miss.
-//     * 1 match    -> args[idKey] gets the resolved ID written into it,
-//                     return ("", "", nil) so the handler proceeds.
-//     * 2+ matches -> hintResp is a JSON payload describing the candidates.
+//   - 0 matches  -> hintResp is a JSON payload describing the miss.
+//   - 1 match    -> args[idKey] gets the resolved ID written into it,
+//     return ("", "", nil) so the handler proceeds.
+//   - 2+ matches -> hintResp is a JSON payload describing the candidates.
 //   - Else: return ("", "", nil) - the handler's own missing-id error
 //     fires when it does its requireString check.
-func resolveNameHint(args map[string]interface{}, spec ResolutionSpec) (hintResp string, err error) {
+func resolveNameHint(ctx context.Context, args map[string]interface{}, spec ResolutionSpec) (hintResp string, err error) {
 	if cur, ok := args[spec.IDKey].(string); ok && strings.TrimSpace(cur) != "" {
 		return "", nil
 	}
@@ -248,7 +260,7 @@ func resolveNameHint(args map[string]interface{}, spec ResolutionSpec) (hintResp
 		return "", nil
 	}
 
-	candidates, ferr := ensureCandidates(spec.Kind)
+	candidates, ferr := ensureCandidates(ctx, spec.Kind)
 	if ferr != nil {
 		return "", fmt.Errorf("name_hint resolution: %w", ferr)
 	}
@@ -259,7 +271,7 @@ func resolveNameHint(args map[string]interface{}, spec ResolutionSpec) (hintResp
 		// Force-refresh once on a miss in case the cache is stale -
 		// new agents/devices/clients show up frequently in MSP usage.
 		resetNameCache()
-		candidates, ferr = ensureCandidates(spec.Kind)
+		candidates, ferr = ensureCandidates(ctx, spec.Kind)
 		if ferr == nil {
 			matches = matchByName(candidates, hint)
 		}
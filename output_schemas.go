@@ -48,7 +48,7 @@ func outputSchemaForTool(name string) json.RawMessage {
 	case "slide_recovery", "slide_alerts", "slide_backups":
 		// Mix of lists and rollups.
 		return json.RawMessage(sharedRollupSchema)
-	case "list_all_clients_devices_and_agents":
+	case "list_all_clients_devices_and_agents", "slide_jobs":
 		return json.RawMessage(sharedSingleObjectSchema)
 	}
 	return nil
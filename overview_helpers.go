@@ -6,6 +6,7 @@ package main
 // reads rather than one API request per client and device.
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/url"
@@ -18,7 +19,14 @@ import (
 // fetchAllPaginated follows Slide's next_offset cursor and refuses malformed
 // pagination loops. The API caps limit at 50, so account-wide operations must
 // not silently treat the first page as the whole account.
-func fetchAllPaginated[T any](endpoint string) ([]T, error) {
+func fetchAllPaginated[T any](ctx context.Context, endpoint string) ([]T, error) {
+	return fetchPaginatedWhile[T](ctx, endpoint, nil)
+}
+
+// fetchPaginatedWhile is fetchAllPaginated for sorted listings that only
+// need a prefix: paging stops after the first page whose last item fails
+// keep. That page is returned whole, so callers still filter by keep.
+func fetchPaginatedWhile[T any](ctx context.Context, endpoint string, keep func(T) bool) ([]T, error) {
 	u, err := url.Parse(endpoint)
 	if err != nil {
 		return nil, fmt.Errorf("parse paginated endpoint %q: %w", endpoint, err)
@@ -36,9 +44,12 @@ func fetchAllPaginated[T any](endpoint string) ([]T, error) {
 
 	items := make([]T, 0)
 	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
 		query.Set("offset", strconv.Itoa(offset))
 		u.RawQuery = query.Encode()
-		body, requestErr := makeAPIRequest("GET", u.String(), nil)
+		body, requestErr := makeAPIRequest(ctx, "GET", u.String(), nil)
 		if requestErr != nil {
 			return nil, requestErr
 		}
@@ -50,9 +61,17 @@ func fetchAllPaginated[T any](endpoint string) ([]T, error) {
 		if len(items) > maxPaginatedEntities {
 			return nil, fmt.Errorf("%s returned more than the safety limit of %d entities", u.Path, maxPaginatedEntities)
 		}
+		if page.Pagination.Total > 0 {
+			progressStep(ctx, -1, "fetched %d of %d from %s", len(items), page.Pagination.Total, u.Path)
+		} else {
+			progressStep(ctx, -1, "fetched %d from %s", len(items), u.Path)
+		}
 		if page.Pagination.NextOffset == nil {
 			return items, nil
 		}
+		if keep != nil && len(page.Data) > 0 && !keep(page.Data[len(page.Data)-1]) {
+			return items, nil
+		}
 		next := *page.Pagination.NextOffset
 		if next <= offset {
 			return nil, fmt.Errorf("%s returned non-advancing next_offset %d after %d", u.Path, next, offset)
@@ -61,7 +80,31 @@ func fetchAllPaginated[T any](endpoint string) ([]T, error) {
 	}
 }
 
-func fetchInventoryEntities() ([]Client, []Device, []Agent, error) {
+// fetchScopedAgents returns the agents a report covers, narrowest scope
+// first: agent_id, device_id, client_id, else the whole account. scope is
+// a label for the response ("client c_...", "account").
+func fetchScopedAgents(ctx context.Context, args map[string]interface{}) ([]Agent, string, error) {
+	if agentID, _ := optionalString(args, "agent_id"); agentID != "" {
+		a, err := fetchAgent(ctx, agentID)
+		if err != nil {
+			return nil, "", err
+		}
+		return []Agent{*a}, "agent " + agentID, nil
+	}
+	endpoint, scope := "/v1/agent", "account"
+	if deviceID, _ := optionalString(args, "device_id"); deviceID != "" {
+		endpoint, scope = "/v1/agent?device_id="+url.QueryEscape(deviceID), "device "+deviceID
+	} else if clientID, _ := optionalString(args, "client_id"); clientID != "" {
+		endpoint, scope = "/v1/agent?client_id="+url.QueryEscape(clientID), "client "+clientID
+	}
+	agents, err := fetchAllPaginated[Agent](ctx, endpoint)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to get agents: %w", err)
+	}
+	return agents, scope, nil
+}
+
+func fetchInventoryEntities(ctx context.Context) ([]Client, []Device, []Agent, error) {
 	var (
 		clients []Client
 		devices []Device
@@ -72,15 +115,15 @@ func fetchInventoryEntities() ([]Client, []Device, []Agent, error) {
 	wg.Add(3)
 	go func() {
 		defer wg.Done()
-		clients, errs[0] = fetchAllPaginated[Client]("/v1/client")
+		clients, errs[0] = fetchAllPaginated[Client](ctx, "/v1/client")
 	}()
 	go func() {
 		defer wg.Done()
-		devices, errs[1] = fetchAllPaginated[Device]("/v1/device")
+		devices, errs[1] = fetchAllPaginated[Device](ctx, "/v1/device")
 	}()
 	go func() {
 		defer wg.Done()
-		agents, errs[2] = fetchAllPaginated[Agent]("/v1/agent")
+		agents, errs[2] = fetchAllPaginated[Agent](ctx, "/v1/agent")
 	}()
 	wg.Wait()
 
@@ -93,8 +136,8 @@ func fetchInventoryEntities() ([]Client, []Device, []Agent, error) {
 	return clients, devices, agents, nil
 }
 
-func listAllClientsDevicesAndAgents(_ map[string]interface{}) (string, error) {
-	clients, devices, agents, err := fetchInventoryEntities()
+func listAllClientsDevicesAndAgents(ctx context.Context, _ map[string]interface{}) (string, error) {
+	clients, devices, agents, err := fetchInventoryEntities(ctx)
 	if err != nil {
 		return "", err
 	}
@@ -0,0 +1,266 @@
+package main
+
+// Named account profiles.
+//
+// MSPs that manage several Slide accounts (their own plus partner
+// sub-accounts) keep one profile per account in
+// ~/.config/slide-mcp/config.yaml:
+//
+//	default_profile: ours
+//	profiles:
+//	  ours:
+//	    api_key_env: SLIDE_OURS_KEY
+//	    tools: full
+//	  partner:
+//	    api_key_command: op read op://msp/slide-partner/token
+//	    base_url: https://api.slide.tech
+//	    tools: read-only
+//	    disabled_tools: [slide_admin]
+//
+// Precedence is defaults < profile < environment < flags, so existing
+// setups keep working unchanged. `--profile` / SLIDE_PROFILE picks the
+// startup profile; `slide_admin operation=switch_profile` hops between
+// them mid-conversation (stdio only, and never above the startup tier).
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Profile is one named account in the config file.
+type Profile struct {
+	APIKey        string   `yaml:"api_key"`
+	APIKeyEnv     string   `yaml:"api_key_env"`
+	APIKeyFile    string   `yaml:"api_key_file"`
+	APIKeyCommand string   `yaml:"api_key_command"`
+	APIKeyKeyring string   `yaml:"api_key_keyring"`
+	BaseURL       string   `yaml:"base_url"`
+	Tools         string   `yaml:"tools"`
+	DisabledTools []string `yaml:"disabled_tools"`
+}
+
+// profileFile is the on-disk shape of config.yaml.
+type profileFile struct {
+	DefaultProfile string             `yaml:"default_profile"`
+	Profiles       map[string]Profile `yaml:"profiles"`
+}
+
+// configOverrides are the flag / environment values that outrank the
+// profile file. Empty fields leave the profile (or default) in place.
+type configOverrides struct {
+	APIKey        string
+	APIKeySource  string
+	BaseURL       string
+	ToolsMode     string
+	DisabledTools string
+}
+
+func (o configOverrides) apply(c *ServerConfig) {
+	if o.APIKey != "" {
+		c.APIKey = o.APIKey
+		c.APIKeySource = o.APIKeySource
+	}
+	if o.BaseURL != "" {
+		c.BaseURL = o.BaseURL
+	}
+	if o.ToolsMode != "" {
+		c.ToolsMode = o.ToolsMode
+	}
+	if o.DisabledTools != "" {
+		c.SetDisabledTools(o.DisabledTools)
+	}
+}
+
+// profileRuntime remembers what switch_profile needs to rebuild the
+// config the same way main did at startup.
+var profileRuntime struct {
+	path      string
+	file      *profileFile
+	overrides configOverrides
+	ceiling   string   // startup tools mode; switching never exceeds it
+	disabled  []string // startup disabled tools; switching never re-enables them
+}
+
+// defaultProfilesPath honours XDG_CONFIG_HOME, falling back to
+// ~/.config/slide-mcp/config.yaml on every platform.
+func defaultProfilesPath() string {
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		return filepath.Join(dir, "slide-mcp", "config.yaml")
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".config", "slide-mcp", "config.yaml")
+}
+
+// loadProfiles reads the profile file. A missing file at the default
+// location is not an error; an explicitly requested one is.
+func loadProfiles(path string, explicit bool) (*profileFile, error) {
+	pf := &profileFile{Profiles: map[string]Profile{}}
+	if path == "" {
+		return pf, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) && !explicit {
+			return pf, nil
+		}
+		return nil, fmt.Errorf("read config file %s: %w", path, err)
+	}
+	if err := yaml.Unmarshal(data, pf); err != nil {
+		return nil, fmt.Errorf("parse config file %s: %w", path, err)
+	}
+	if pf.Profiles == nil {
+		pf.Profiles = map[string]Profile{}
+	}
+	if pf.DefaultProfile != "" {
+		if _, ok := pf.Profiles[pf.DefaultProfile]; !ok {
+			return nil, fmt.Errorf("config file %s: default_profile %q is not defined", path, pf.DefaultProfile)
+		}
+	}
+	return pf, nil
+}
+
+// names returns the profile names in stable order.
+func (pf *profileFile) names() []string {
+	out := make([]string, 0, len(pf.Profiles))
+	for name := range pf.Profiles {
+		out = append(out, name)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// token resolves the profile's Slide API token and says where it came
+// from. Inline api_key wins, then api_key_env, then the token sources.
+func (p Profile) token() (token, source string, err error) {
+	if p.APIKey != "" {
+		return p.APIKey, "api_key", nil
+	}
+	if p.APIKeyEnv != "" {
+		v := os.Getenv(p.APIKeyEnv)
+		if v == "" {
+			return "", "", fmt.Errorf("api_key_env %s is not set", p.APIKeyEnv)
+		}
+		return v, "api_key_env " + p.APIKeyEnv, nil
+	}
+	sources := tokenSources{File: p.APIKeyFile, Command: p.APIKeyCommand, Keyring: p.APIKeyKeyring}
+	token, kind, err := sources.resolve()
+	if err != nil {
+		return "", "", fmt.Errorf("api_key_%s: %w", kind, err)
+	}
+	if kind == "" {
+		return "", "", nil
+	}
+	return token, "api_key_" + kind + " " + sources.detail(kind), nil
+}
+
+// resolveConfig builds a ServerConfig from defaults, the named profile
+// (empty name = no profile), and the flag/env overrides, in that order.
+func (pf *profileFile) resolveConfig(name string, o configOverrides) (*ServerConfig, error) {
+	c := NewServerConfig()
+	if name != "" {
+		p, ok := pf.Profiles[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown profile %q (available: %s)", name, strings.Join(pf.names(), ", "))
+		}
+		// A pinned token makes the profile's token source irrelevant, so
+		// don't fail on an unset api_key_env in that case.
+		if o.APIKey == "" {
+			token, source, err := p.token()
+			if err != nil {
+				return nil, fmt.Errorf("profile %q: %w", name, err)
+			}
+			c.APIKey = token
+			if token != "" {
+				c.APIKeySource = fmt.Sprintf("profile %s (%s)", name, source)
+			}
+		}
+		if p.BaseURL != "" {
+			c.BaseURL = p.BaseURL
+		}
+		if p.Tools != "" {
+			c.ToolsMode = p.Tools
+		}
+		if len(p.DisabledTools) > 0 {
+			c.SetDisabledTools(strings.Join(p.DisabledTools, ","))
+		}
+		c.Profile = name
+	}
+	o.apply(c)
+	return c, nil
+}
+
+// toolsModeRank orders the permission tiers from least to most capable.
+func toolsModeRank(mode string) int {
+	switch mode {
+	case ToolsReadOnly:
+		return 0
+	case ToolsSafe:
+		return 1
+	case ToolsFull:
+		return 2
+	}
+	return -1
+}
+
+// switchProfile swaps the process identity to the named profile. Caches
+// tied to the previous identity are dropped, and the permission tier is
+// capped at the one the server started with so a conversation can't use
+// a profile hop to escalate. capped reports whether that cap applied.
+func switchProfile(name string) (next *ServerConfig, capped bool, err error) {
+	pf := profileRuntime.file
+	if pf == nil || len(pf.Profiles) == 0 {
+		return nil, false, fmt.Errorf("no profiles configured; add them to %s", profileRuntime.path)
+	}
+	if o := profileRuntime.overrides; o.APIKey != "" {
+		return nil, false, fmt.Errorf("the API token is pinned by %s; unset it to switch between profile tokens", o.APIKeySource)
+	}
+	next, err = pf.resolveConfig(name, profileRuntime.overrides)
+	if err != nil {
+		return nil, false, err
+	}
+	if next.APIKey == "" {
+		return nil, false, fmt.Errorf("profile %q has no token source (api_key, api_key_env, api_key_file, api_key_command, or api_key_keyring)", name)
+	}
+	prev := currentConfig()
+	next.Transport = prev.Transport
+	next.ListenAddr = prev.ListenAddr
+	next.Concurrency = prev.Concurrency
+	next.RDPDir = prev.RDPDir
+	next.RDPCache = prev.RDPCache
+	next.DownloadDir = prev.DownloadDir
+	next.DownloadMaxMB = prev.DownloadMaxMB
+	next.ReportDir = prev.ReportDir
+	next.JobsFile = prev.JobsFile
+	if err := next.Validate(); err != nil {
+		return nil, false, fmt.Errorf("profile %q: %w", name, err)
+	}
+	if toolsModeRank(next.ToolsMode) > toolsModeRank(profileRuntime.ceiling) {
+		next.ToolsMode = profileRuntime.ceiling
+		capped = true
+	}
+	for _, tool := range profileRuntime.disabled {
+		if !slices.Contains(next.DisabledTools, tool) {
+			next.DisabledTools = append(next.DisabledTools, tool)
+		}
+	}
+
+	processIdentityMu.Lock()
+	setConfig(next)
+	apiKey = next.APIKey
+	APIBaseURL = next.BaseURL
+	processIdentityMu.Unlock()
+	processSession.resetCaches()
+	backgroundJobs.stopOwned(func(owner string) bool { return owner == "" }, "Stopped by switch_profile: the job belongs to the previous profile's account.", false)
+	return next, capped, nil
+}
@@ -0,0 +1,93 @@
+package main
+
+// MCP progress notifications.
+//
+// When a tools/call carries `_meta.progressToken`, adaptToolHandler puts a
+// progressReporter on the context and the long fan-outs (paginated
+// listings, per-agent backup rollups, the health sweep) call progressStep
+// as they go. Hosts render those notifications/progress messages as a
+// live progress bar instead of a call that looks hung. Without a token
+// every progressStep is a no-op.
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// progressReporter serializes the notifications for one tool call so the
+// progress value keeps increasing even when several paginators run
+// concurrently under the same call.
+type progressReporter struct {
+	token mcp.ProgressToken
+	srv   *server.MCPServer
+
+	mu       sync.Mutex
+	progress float64
+}
+
+type progressContextKey struct{}
+
+// withProgress attaches a reporter to ctx when the caller asked for
+// progress and the call came through an MCP server.
+func withProgress(ctx context.Context, meta *mcp.Meta) context.Context {
+	if meta == nil || meta.ProgressToken == nil {
+		return ctx
+	}
+	srv := server.ServerFromContext(ctx)
+	if srv == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, progressContextKey{}, &progressReporter{token: meta.ProgressToken, srv: srv})
+}
+
+// progressStep advances the caller's progress by one unit. remaining is
+// how many more units are expected after this one, or -1 when unknown,
+// so fixed-size fan-outs get a determinate bar. Delivery failures are
+// ignored: progress is advisory and must never fail the call.
+func progressStep(ctx context.Context, remaining int, format string, args ...interface{}) {
+	r, ok := ctx.Value(progressContextKey{}).(*progressReporter)
+	if !ok {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.progress++
+	params := map[string]any{
+		"progressToken": r.token,
+		"progress":      r.progress,
+		"message":       fmt.Sprintf(format, args...),
+	}
+	if remaining >= 0 {
+		params["total"] = r.progress + float64(remaining)
+	}
+	_ = r.srv.SendNotificationToClient(ctx, string(mcp.MethodNotificationProgress), params)
+}
+
+// progressTo reports absolute progress, such as bytes downloaded out of
+// total, for calls that measure in something other than steps. A total
+// of 0 means unknown.
+func progressTo(ctx context.Context, progress, total float64, format string, args ...interface{}) {
+	r, ok := ctx.Value(progressContextKey{}).(*progressReporter)
+	if !ok {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if progress <= r.progress {
+		return
+	}
+	r.progress = progress
+	params := map[string]any{
+		"progressToken": r.token,
+		"progress":      r.progress,
+		"message":       fmt.Sprintf(format, args...),
+	}
+	if total > 0 {
+		params["total"] = total
+	}
+	_ = r.srv.SendNotificationToClient(ctx, string(mcp.MethodNotificationProgress), params)
+}
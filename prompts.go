@@ -42,8 +42,8 @@ func promptWelcome() mcp.Prompt {
 
 func handlePromptWelcome(_ context.Context, _ mcp.GetPromptRequest) (*mcp.GetPromptResult, error) {
 	mode := ""
-	if config != nil {
-		mode = config.ToolsMode
+	if cfg := currentConfig(); cfg != nil {
+		mode = cfg.ToolsMode
 	}
 	body := `You are connected to slide-mcp-server, the MCP extension for the Slide BCDR / backup platform. ` +
 		`Greet the operator with a concise, friendly intro that covers:
@@ -98,7 +98,7 @@ Steps:
 1. Call slide_overview operation=health to get current health.
 2. If a client was named, resolve it via slide_clients list (match the name) then call slide_overview for_client.
 3. Call slide_alerts triage to get unresolved alerts grouped by severity.
-4. Call slide_backups status_for_client (or status_for_device for each device) for the time window.
+4. Call slide_backups status_for_client for a named client, otherwise slide_backups status_all, for the time window.
 
 Then write a concise summary in this exact structure:
 
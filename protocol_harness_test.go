@@ -1,15 +1,26 @@
 package main
 
 import (
+	"bufio"
 	"bytes"
 	"context"
+	"encoding/base64"
 	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"runtime"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
+
+	"github.com/mark3labs/mcp-go/server"
 )
 
 // These profiles cover the protocol revisions used by current Anthropic and
@@ -209,3 +220,804 @@ func withoutEnvironmentKey(env []string, key string) []string {
 	}
 	return filtered
 }
+
+// httpMCPSession is a minimal Streamable HTTP client for the harness.
+type httpMCPSession struct {
+	t         *testing.T
+	url       string
+	token     string
+	sessionID string
+}
+
+func (c *httpMCPSession) post(body string) map[string]interface{} {
+	c.t.Helper()
+	resp := c.do(body)
+	defer resp.Body.Close()
+	if id := resp.Header.Get("Mcp-Session-Id"); id != "" {
+		c.sessionID = id
+	}
+	raw, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode == http.StatusAccepted {
+		return nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		c.t.Fatalf("POST status %d: %s", resp.StatusCode, raw)
+	}
+	var message map[string]interface{}
+	if err := json.Unmarshal(raw, &message); err != nil {
+		c.t.Fatalf("invalid JSON-RPC response %q: %v", raw, err)
+	}
+	return message
+}
+
+func (c *httpMCPSession) do(body string) *http.Response {
+	c.t.Helper()
+	req, _ := http.NewRequest(http.MethodPost, c.url+httpStreamablePath, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json, text/event-stream")
+	if c.sessionID != "" {
+		req.Header.Set("Mcp-Session-Id", c.sessionID)
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		c.t.Fatalf("POST %s: %v", httpStreamablePath, err)
+	}
+	return resp
+}
+
+// stream posts body and returns every JSON-RPC message the server sent,
+// whether it answered with plain JSON or upgraded to an SSE stream.
+func (c *httpMCPSession) stream(body string) []map[string]interface{} {
+	c.t.Helper()
+	resp := c.do(body)
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		raw, _ := io.ReadAll(resp.Body)
+		c.t.Fatalf("POST status %d: %s", resp.StatusCode, raw)
+	}
+	var messages []map[string]interface{}
+	decode := func(raw string) {
+		var message map[string]interface{}
+		if err := json.Unmarshal([]byte(raw), &message); err != nil {
+			c.t.Fatalf("invalid JSON-RPC message %q: %v", raw, err)
+		}
+		messages = append(messages, message)
+	}
+	if !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+		raw, _ := io.ReadAll(resp.Body)
+		decode(string(raw))
+		return messages
+	}
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		if data, ok := strings.CutPrefix(scanner.Text(), "data: "); ok {
+			decode(data)
+		}
+	}
+	return messages
+}
+
+func (c *httpMCPSession) initialize() {
+	c.t.Helper()
+	c.post(`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-11-25","capabilities":{},"clientInfo":{"name":"http-harness","version":"1"}}}`)
+	if c.sessionID == "" {
+		c.t.Fatal("initialize did not assign an Mcp-Session-Id")
+	}
+	c.post(`{"jsonrpc":"2.0","method":"notifications/initialized"}`)
+}
+
+// TestStreamableHTTPTransport drives the HTTP transport end-to-end and
+// checks the tier filter and operation gating survive the transport swap.
+func TestStreamableHTTPTransport(t *testing.T) {
+	setupTestEnv(t, ToolsReadOnly)
+	srv, err := buildMCPServer()
+	if err != nil {
+		t.Fatalf("buildMCPServer: %v", err)
+	}
+	ts := httptest.NewServer(newHTTPTransport(srv))
+	defer ts.Close()
+
+	client := &httpMCPSession{t: t, url: ts.URL}
+	client.initialize()
+
+	toolsResult := requireObject(t, client.post(`{"jsonrpc":"2.0","id":2,"method":"tools/list"}`)["result"], "tools/list.result")
+	tools, _ := toolsResult["tools"].([]interface{})
+	if len(tools) == 0 {
+		t.Fatal("tools/list over HTTP returned no tools")
+	}
+	for _, rawTool := range tools {
+		name, _ := requireObject(t, rawTool, "tool")["name"].(string)
+		if !currentConfig().IsToolAllowed(name) {
+			t.Fatalf("tool %s leaked through the read-only filter", name)
+		}
+	}
+
+	callResult := requireObject(t, client.post(`{"jsonrpc":"2.0","id":3,"method":"tools/call","params":{"name":"slide_backups","arguments":{"operation":"start","agent_id":"a_test"}}}`)["result"], "tools/call.result")
+	if callResult["isError"] != true {
+		t.Fatalf("read-only tier allowed slide_backups start over HTTP: %v", callResult)
+	}
+
+	// The legacy SSE endpoint announces its message endpoint first.
+	sseReq, _ := http.NewRequest(http.MethodGet, ts.URL+httpSSEPath, nil)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	sseResp, err := http.DefaultClient.Do(sseReq.WithContext(ctx))
+	if err != nil {
+		t.Fatalf("GET %s: %v", httpSSEPath, err)
+	}
+	defer sseResp.Body.Close()
+	line, err := bufio.NewReader(sseResp.Body).ReadString('\n')
+	if err != nil || !strings.HasPrefix(line, "event: endpoint") {
+		t.Fatalf("SSE stream did not start with an endpoint event: %q (%v)", line, err)
+	}
+}
+
+// TestHTTPTransportPerRequestTokens runs two MCP sessions with different
+// Slide tokens against one multi-tenant server and checks that API calls
+// and the client-name cache stay scoped to each caller.
+func TestHTTPTransportPerRequestTokens(t *testing.T) {
+	setupTestEnv(t, ToolsSafe)
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/v1/client":
+			_, _ = io.WriteString(w, `{"pagination":{"total":1},"data":[{"client_id":"c_shared","name":"client-of-`+token+`"}]}`)
+		case "/v1/device":
+			_, _ = io.WriteString(w, `{"pagination":{"total":1},"data":[{"device_id":"d_1","display_name":"box","client_id":"c_shared"}]}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer api.Close()
+	useTestHTTPServer(t, api)
+	apiKey = ""
+	processSession.clients = newClientCacheStore()
+
+	srv, err := buildMCPServer()
+	if err != nil {
+		t.Fatalf("buildMCPServer: %v", err)
+	}
+	ts := httptest.NewServer(newHTTPTransport(srv))
+	defer ts.Close()
+
+	anonymous := &httpMCPSession{t: t, url: ts.URL}
+	resp := anonymous.do(`{"jsonrpc":"2.0","id":1,"method":"ping"}`)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("tokenless request to multi-tenant server returned %d, want 401", resp.StatusCode)
+	}
+
+	for _, token := range []string{"tk_tenant_one", "tk_tenant_two"} {
+		client := &httpMCPSession{t: t, url: ts.URL, token: token}
+		client.initialize()
+		result := requireObject(t, client.post(`{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"slide_devices","arguments":{"operation":"list"}}}`)["result"], "tools/call.result")
+		if result["isError"] == true {
+			t.Fatalf("%s: slide_devices list failed: %v", token, result)
+		}
+		body, _ := json.Marshal(result["structuredContent"])
+		if !strings.Contains(string(body), "client-of-"+token) {
+			t.Fatalf("%s: response not scoped to the caller's token: %s", token, body)
+		}
+	}
+	processSession.clients.RLock()
+	defer processSession.clients.RUnlock()
+	if len(processSession.clients.clients) != 0 {
+		t.Fatalf("per-request lookups leaked into the process cache: %v", processSession.clients.clients)
+	}
+}
+
+// TestHTTPSharedTokenRequiresAuthToken checks that a server with its own
+// Slide token refuses a non-loopback listener without --http-auth-token,
+// and that with one set a caller must present it (or a Slide token of
+// its own) before running on the server's token.
+func TestHTTPSharedTokenRequiresAuthToken(t *testing.T) {
+	setupTestEnv(t, ToolsSafe)
+	var mu sync.Mutex
+	var seen []string
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		seen = append(seen, strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "))
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, `{"pagination":{"total":0},"data":[]}`)
+	}))
+	defer api.Close()
+	useTestHTTPServer(t, api)
+
+	cfg := *currentConfig()
+	cfg.Transport, cfg.ListenAddr = TransportHTTP, ":8080"
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "--http-auth-token") {
+		t.Fatalf("shared token on %s without an auth token validated: %v", cfg.ListenAddr, err)
+	}
+	cfg.ListenAddr = "127.0.0.1:8080"
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("loopback listener rejected: %v", err)
+	}
+	cfg.ListenAddr, cfg.HTTPAuthToken = "0.0.0.0:8080", "s3cret"
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("non-loopback listener with an auth token rejected: %v", err)
+	}
+	setConfig(&cfg)
+
+	srv, err := buildMCPServer()
+	if err != nil {
+		t.Fatalf("buildMCPServer: %v", err)
+	}
+	ts := httptest.NewServer(newHTTPTransport(srv))
+	defer ts.Close()
+
+	anonymous := &httpMCPSession{t: t, url: ts.URL}
+	resp := anonymous.do(`{"jsonrpc":"2.0","id":1,"method":"ping"}`)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("unauthenticated request to a shared-token server returned %d, want 401", resp.StatusCode)
+	}
+
+	for token, want := range map[string]string{"s3cret": processSession.token(), "tk_mine": "tk_mine"} {
+		client := &httpMCPSession{t: t, url: ts.URL, token: token}
+		client.initialize()
+		result := requireObject(t, client.post(`{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"slide_devices","arguments":{"operation":"list"}}}`)["result"], "tools/call.result")
+		if result["isError"] == true {
+			t.Fatalf("%s: slide_devices list failed: %v", token, result)
+		}
+		mu.Lock()
+		got := seen
+		seen = nil
+		mu.Unlock()
+		if len(got) == 0 || got[len(got)-1] != want {
+			t.Errorf("bearer %s reached Slide as %v, want %s", token, got, want)
+		}
+	}
+}
+
+// TestHTTPSessionsOnServerTokenKeepJobsApart runs two tokenless MCP
+// sessions against a server with its own token and checks that neither
+// can see or cancel the other's background job.
+func TestHTTPSessionsOnServerTokenKeepJobsApart(t *testing.T) {
+	setupTestEnv(t, ToolsSafe)
+	defer func(a, b time.Duration) { waitInitialInterval, waitMaxInterval = a, b }(waitInitialInterval, waitMaxInterval)
+	waitInitialInterval, waitMaxInterval = time.Millisecond, time.Millisecond
+	defer func(r *jobRegistry) { backgroundJobs = r }(backgroundJobs)
+	backgroundJobs = newJobRegistry()
+	defer backgroundJobs.followers.Wait()
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.Method == "POST" && r.URL.Path == "/v1/backup":
+			_, _ = io.WriteString(w, `{"backup_id":"b_1"}`)
+		case r.URL.Path == "/v1/backup/b_1":
+			_, _ = io.WriteString(w, `{"backup_id":"b_1","agent_id":"a_1","status":"started"}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer api.Close()
+	useTestHTTPServer(t, api)
+
+	srv, err := buildMCPServer()
+	if err != nil {
+		t.Fatalf("buildMCPServer: %v", err)
+	}
+	ts := httptest.NewServer(newHTTPTransport(srv))
+	defer ts.Close()
+	owner := &httpMCPSession{t: t, url: ts.URL}
+	owner.initialize()
+	other := &httpMCPSession{t: t, url: ts.URL}
+	other.initialize()
+	call := func(c *httpMCPSession, tool, args string) map[string]interface{} {
+		t.Helper()
+		return requireObject(t, c.post(`{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"` + tool + `","arguments":` + args + `}}`)["result"], "tools/call.result")
+	}
+
+	started := call(owner, "slide_backups", `{"operation":"start","agent_id":"a_1","background":true}`)
+	job := requireObject(t, requireObject(t, started["structuredContent"], "structuredContent")["_job"], "_job")
+	jobID, _ := job["job_id"].(string)
+	if jobID == "" {
+		t.Fatalf("start returned no job: %v", started)
+	}
+
+	listed := requireObject(t, call(other, "slide_jobs", `{"operation":"list"}`)["structuredContent"], "structuredContent")
+	if listed["count"] != float64(0) {
+		t.Errorf("other session lists %v jobs, want none: %v", listed["count"], listed)
+	}
+	for _, op := range []string{"get", "cancel"} {
+		if result := call(other, "slide_jobs", `{"operation":"`+op+`","job_id":"`+jobID+`"}`); result["isError"] != true {
+			t.Errorf("other session could %s the job: %v", op, result)
+		}
+	}
+
+	listed = requireObject(t, call(owner, "slide_jobs", `{"operation":"list"}`)["structuredContent"], "structuredContent")
+	if listed["count"] != float64(1) {
+		t.Errorf("owner lists %v jobs, want its own: %v", listed["count"], listed)
+	}
+	canceled := requireObject(t, call(owner, "slide_jobs", `{"operation":"cancel","job_id":"`+jobID+`"}`)["structuredContent"], "structuredContent")
+	if canceled["status"] != jobCanceled {
+		t.Errorf("owner cancel = %v", canceled)
+	}
+}
+
+// TestToolCallCancellation sends notifications/cancelled for an in-flight
+// tools/call and checks that the upstream Slide request is aborted and
+// the per-agent fan-out stops instead of walking the remaining agents.
+func TestToolCallCancellation(t *testing.T) {
+	setupTestEnv(t, ToolsSafe)
+	currentConfig().Concurrency = 2
+	var backupCalls atomic.Int32
+	var cancelOnce sync.Once
+	upstreamStarted := make(chan struct{}, 1)
+	upstreamCanceled := make(chan struct{})
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/agent":
+			agents := make([]Agent, 5)
+			for i := range agents {
+				agents[i] = Agent{AgentID: fmt.Sprintf("a_%d", i), DeviceID: "d_1", Hostname: fmt.Sprintf("host-%d", i)}
+			}
+			_ = json.NewEncoder(w).Encode(PaginatedResponse[Agent]{Data: agents})
+		case "/v1/backup":
+			backupCalls.Add(1)
+			select {
+			case upstreamStarted <- struct{}{}:
+			default:
+			}
+			<-r.Context().Done()
+			cancelOnce.Do(func() { close(upstreamCanceled) })
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer api.Close()
+	useTestHTTPServer(t, api)
+
+	srv, err := buildMCPServer()
+	if err != nil {
+		t.Fatalf("buildMCPServer: %v", err)
+	}
+
+	call := []byte(`{"jsonrpc":"2.0","id":42,"method":"tools/call","params":{"name":"slide_backups","arguments":{"operation":"status_for_device","device_id":"d_1"}}}`)
+	done := make(chan map[string]interface{}, 1)
+	go func() {
+		done <- marshalRPCResponse(t, srv.HandleMessage(context.Background(), call))
+	}()
+
+	select {
+	case <-upstreamStarted:
+	case <-time.After(5 * time.Second):
+		t.Fatal("tool call never reached the Slide API")
+	}
+	cancelled := []byte(`{"jsonrpc":"2.0","method":"notifications/cancelled","params":{"requestId":42,"reason":"user aborted"}}`)
+	if response := srv.HandleMessage(context.Background(), cancelled); response != nil {
+		t.Fatalf("cancellation notification unexpectedly returned %v", response)
+	}
+
+	select {
+	case <-upstreamCanceled:
+	case <-time.After(5 * time.Second):
+		t.Fatal("upstream request kept running after notifications/cancelled")
+	}
+	select {
+	case response := <-done:
+		result := requireObject(t, response["result"], "tools/call.result")
+		if result["isError"] != true {
+			t.Fatalf("canceled call should report an error result, got %v", result)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("tools/call did not return after cancellation")
+	}
+	if got := backupCalls.Load(); got > int32(currentConfig().Concurrency) {
+		t.Fatalf("fan-out issued %d backup requests, want at most the %d in flight at cancellation", got, currentConfig().Concurrency)
+	}
+}
+
+// TestToolCallProgressNotifications checks that a tools/call carrying a
+// progressToken gets one notifications/progress per agent, with rising
+// progress and a determinate total, before the final result.
+func TestToolCallProgressNotifications(t *testing.T) {
+	setupTestEnv(t, ToolsSafe)
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/v1/agent":
+			agents := []Agent{{AgentID: "a_1", Hostname: "one"}, {AgentID: "a_2", Hostname: "two"}, {AgentID: "a_3", Hostname: "three"}}
+			_ = json.NewEncoder(w).Encode(PaginatedResponse[Agent]{Data: agents})
+		case "/v1/backup":
+			_, _ = io.WriteString(w, `{"pagination":{"total":0},"data":[]}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer api.Close()
+	useTestHTTPServer(t, api)
+
+	srv, err := buildMCPServer()
+	if err != nil {
+		t.Fatalf("buildMCPServer: %v", err)
+	}
+	ts := httptest.NewServer(newHTTPTransport(srv))
+	defer ts.Close()
+	client := &httpMCPSession{t: t, url: ts.URL}
+	client.initialize()
+
+	messages := client.stream(`{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"_meta":{"progressToken":"bar-1"},"name":"slide_backups","arguments":{"operation":"status_for_device","device_id":"d_1"}}}`)
+	if len(messages) == 0 || messages[len(messages)-1]["id"] == nil {
+		t.Fatalf("final message should be the tools/call response: %v", messages)
+	}
+	var progress []map[string]interface{}
+	for _, message := range messages[:len(messages)-1] {
+		if message["method"] == "notifications/progress" {
+			progress = append(progress, requireObject(t, message["params"], "progress.params"))
+		}
+	}
+	if len(progress) != 3 {
+		t.Fatalf("got %d progress notifications, want one per agent: %v", len(progress), messages)
+	}
+	for i, params := range progress {
+		if params["progressToken"] != "bar-1" {
+			t.Errorf("progress token = %v, want bar-1", params["progressToken"])
+		}
+		if params["progress"] != float64(i+1) || params["total"] != float64(3) {
+			t.Errorf("notification %d: progress=%v total=%v", i, params["progress"], params["total"])
+		}
+	}
+
+	// Without a token the call stays quiet.
+	for _, message := range client.stream(`{"jsonrpc":"2.0","id":3,"method":"tools/call","params":{"name":"slide_backups","arguments":{"operation":"status_for_device","device_id":"d_1"}}}`) {
+		if message["method"] == "notifications/progress" {
+			t.Fatalf("progress sent without a progressToken: %v", message)
+		}
+	}
+}
+
+// TestRDPBookmarkStaysLocal checks that get_rdp_bookmark returns the .rdp
+// file inline, as a resource, and in --rdp-dir without contacting the
+// external cache unless --rdp-cache is on.
+func TestRDPBookmarkStaysLocal(t *testing.T) {
+	setupTestEnv(t, ToolsReadOnly)
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/restore/virt/v_rdp" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte(`{"virt_id":"v_rdp","rdp_endpoint":"203.0.113.7:3389"}`))
+	}))
+	defer api.Close()
+	useTestHTTPServer(t, api)
+
+	var cacheHits atomic.Int32
+	cache := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cacheHits.Add(1)
+		_, _ = w.Write([]byte(`{"success":true,"rdpID":"r_1"}`))
+	}))
+	defer cache.Close()
+	previousCacheURL := rdpCacheURL
+	rdpCacheURL = cache.URL
+	t.Cleanup(func() { rdpCacheURL = previousCacheURL })
+	currentConfig().RDPDir = filepath.Join(t.TempDir(), "rdp")
+
+	srv, err := buildMCPServer()
+	if err != nil {
+		t.Fatalf("buildMCPServer: %v", err)
+	}
+	call := func() map[string]interface{} {
+		t.Helper()
+		response := marshalRPCResponse(t, srv.HandleMessage(context.Background(), []byte(`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"slide_recovery","arguments":{"operation":"get_rdp_bookmark","virt_id":"v_rdp"}}}`)))
+		return requireObject(t, response["result"], "tools/call.result")
+	}
+
+	result := call()
+	if cacheHits.Load() != 0 {
+		t.Fatal("get_rdp_bookmark contacted the external cache without --rdp-cache")
+	}
+	content, _ := result["content"].([]interface{})
+	if len(content) != 2 {
+		t.Fatalf("content = %v, want the JSON text plus the embedded .rdp", content)
+	}
+	embedded := requireObject(t, content[1], "content[1]")
+	resource := requireObject(t, embedded["resource"], "content[1].resource")
+	if embedded["type"] != "resource" || resource["uri"] != "slide://vm/v_rdp/rdp" || resource["mimeType"] != rdpMIMEType ||
+		!strings.Contains(resource["text"].(string), "full address:s:203.0.113.7:3389") {
+		t.Errorf("embedded resource = %v", embedded)
+	}
+	structured := requireObject(t, result["structuredContent"], "structuredContent")
+	path, _ := structured["file_path"].(string)
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("rdp file %q: %v", path, err)
+	}
+	if runtime.GOOS != "windows" && info.Mode().Perm() != 0o600 {
+		t.Errorf("rdp file mode = %04o, want 0600", info.Mode().Perm())
+	}
+	if _, ok := structured["download_url"]; ok {
+		t.Error("download_url present without --rdp-cache")
+	}
+
+	read := marshalRPCResponse(t, srv.HandleMessage(context.Background(), []byte(`{"jsonrpc":"2.0","id":2,"method":"resources/read","params":{"uri":"slide://vm/v_rdp/rdp"}}`)))
+	contents, _ := requireObject(t, read["result"], "resources/read.result")["contents"].([]interface{})
+	if len(contents) != 1 || !strings.Contains(requireObject(t, contents[0], "contents[0]")["text"].(string), "203.0.113.7:3389") {
+		t.Errorf("resources/read = %v", read)
+	}
+
+	currentConfig().RDPCache = true
+	structured = requireObject(t, call()["structuredContent"], "structuredContent")
+	if cacheHits.Load() != 1 || structured["rdp_cache_id"] != "r_1" {
+		t.Errorf("with --rdp-cache: hits=%d result=%v", cacheHits.Load(), structured)
+	}
+}
+
+func TestWireGuardPrivateKeyShownOnce(t *testing.T) {
+	setupTestEnv(t, ToolsFull)
+	const privateKey = "cHJpdmF0ZS1rZXktc2hvd24tb25jZQ=="
+	peer := `{"wg_peer_id":"wg_1","peer_name":"tech phone","wg_public_key":"peer-pub","wg_address":"10.99.0.2/32","wg_endpoint":"203.0.113.9:51820","remote_networks":["192.168.10.0/24"]`
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == "POST" && r.URL.Path == "/v1/network/net_1/wg-peer":
+			_, _ = w.Write([]byte(peer + `,"wg_private_key":"` + privateKey + `"}`))
+		case r.Method == "GET" && r.URL.Path == "/v1/network/net_1":
+			// Even if Slide echoes the key back, it must not be shown again.
+			_, _ = w.Write([]byte(`{"network_id":"net_1","wg":true,"wg_prefix":"10.99.0.0/24","wg_public_key":"server-pub","wg_peers":[` + peer + `,"wg_private_key":"` + privateKey + `"}]}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer api.Close()
+	useTestHTTPServer(t, api)
+
+	srv, err := buildMCPServer()
+	if err != nil {
+		t.Fatalf("buildMCPServer: %v", err)
+	}
+	rpc := func(body string) map[string]interface{} {
+		t.Helper()
+		response := marshalRPCResponse(t, srv.HandleMessage(context.Background(), []byte(body)))
+		return requireObject(t, response["result"], "result")
+	}
+
+	created := rpc(`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"slide_recovery","arguments":{"operation":"create_wg_peer","network_id":"net_1","peer_name":"tech phone"}}}`)
+	content, _ := created["content"].([]interface{})
+	if len(content) != 3 {
+		t.Fatalf("content = %v, want the JSON text, the embedded config, and the QR PNG", content)
+	}
+	resource := requireObject(t, requireObject(t, content[1], "content[1]")["resource"], "content[1].resource")
+	if resource["uri"] != "slide://network/net_1/wg-peer/wg_1/config" || resource["mimeType"] != "text/plain" ||
+		!strings.Contains(resource["text"].(string), "PrivateKey = "+privateKey) {
+		t.Errorf("embedded config = %v", resource)
+	}
+	image := requireObject(t, content[2], "content[2]")
+	png, err := base64.StdEncoding.DecodeString(image["data"].(string))
+	if image["type"] != "image" || image["mimeType"] != "image/png" || err != nil || !bytes.HasPrefix(png, []byte("\x89PNG")) {
+		t.Errorf("QR image = type %v mime %v (decode err %v)", image["type"], image["mimeType"], err)
+	}
+	structured := requireObject(t, created["structuredContent"], "structuredContent")
+	if qr, _ := structured["_wireguard_qr"].(string); !strings.Contains(qr, "█") {
+		t.Errorf("_wireguard_qr = %q, want a block-character QR code", qr)
+	}
+
+	later := []string{
+		`{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"slide_recovery","arguments":{"operation":"get_network","network_id":"net_1"}}}`,
+		`{"jsonrpc":"2.0","id":3,"method":"resources/read","params":{"uri":"slide://network/net_1/wg-peer/wg_1/config"}}`,
+	}
+	for _, body := range later {
+		raw, _ := json.Marshal(rpc(body))
+		if strings.Contains(string(raw), privateKey) {
+			t.Errorf("private key shown again by %s", body)
+		}
+		if !strings.Contains(string(raw), "PublicKey = server-pub") || !strings.Contains(string(raw), "shown only once") {
+			t.Errorf("%s: config with placeholder missing: %s", body, raw)
+		}
+	}
+	for _, line := range logCapture.Snapshot() {
+		if strings.Contains(line, privateKey) {
+			t.Errorf("private key logged: %s", line)
+		}
+	}
+}
+
+// TestResourceSubscriptions subscribes to an agent resource over an
+// in-process stdio session and checks that agent heartbeats stay quiet, a
+// real change sends notifications/resources/updated, and unsubscribing
+// stops the poller.
+func TestResourceSubscriptions(t *testing.T) {
+	setupTestEnv(t, ToolsSafe)
+	var hostname atomic.Value
+	hostname.Store("fs-01")
+	var fetches atomic.Int32
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/v1/agent/a_2":
+			_ = json.NewEncoder(w).Encode(Agent{AgentID: "a_2", Hostname: "fs-02"})
+		case "/v1/agent/a_1":
+			fetches.Add(1)
+			// last_seen_at moves on every fetch, like a checking-in agent.
+			_ = json.NewEncoder(w).Encode(Agent{AgentID: "a_1", Hostname: hostname.Load().(string), LastSeenAt: time.Now().UTC().Format(time.RFC3339Nano)})
+		case "/v1/snapshot", "/v1/alert":
+			_, _ = io.WriteString(w, `{"pagination":{"total":0},"data":[]}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer api.Close()
+	useTestHTTPServer(t, api)
+	oldInterval := resourcePollInterval
+	resourcePollInterval = 5 * time.Millisecond
+	defer func() { resourcePollInterval = oldInterval }()
+
+	srv, err := buildMCPServer()
+	if err != nil {
+		t.Fatalf("buildMCPServer: %v", err)
+	}
+	stdinR, stdinW := io.Pipe()
+	stdoutR, stdoutW := io.Pipe()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_ = server.NewStdioServer(srv).Listen(ctx, stdinR, stdoutW)
+	}()
+	defer func() {
+		cancel()
+		stdinW.Close()
+		<-done
+		stdoutW.Close()
+		resourceWatches.running.Wait()
+	}()
+	messages := make(chan map[string]interface{}, 64)
+	go func() {
+		scanner := bufio.NewScanner(stdoutR)
+		for scanner.Scan() {
+			var message map[string]interface{}
+			if json.Unmarshal(scanner.Bytes(), &message) == nil {
+				messages <- message
+			}
+		}
+	}()
+	send := func(body string) {
+		if _, err := io.WriteString(stdinW, body+"\n"); err != nil {
+			t.Fatalf("write %s: %v", body, err)
+		}
+	}
+	await := func(what string, match func(map[string]interface{}) bool) map[string]interface{} {
+		t.Helper()
+		timeout := time.After(5 * time.Second)
+		for {
+			select {
+			case message := <-messages:
+				if match(message) {
+					return message
+				}
+			case <-timeout:
+				t.Fatalf("timed out waiting for %s", what)
+			}
+		}
+	}
+	reply := func(id float64) func(map[string]interface{}) bool {
+		return func(m map[string]interface{}) bool { return m["id"] == id }
+	}
+	updated := func(m map[string]interface{}) bool { return m["method"] == "notifications/resources/updated" }
+	waitForFetches := func(n int32) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for fetches.Load() < n {
+			if time.Now().After(deadline) {
+				t.Fatalf("resource fetched %d times, want at least %d", fetches.Load(), n)
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	send(`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-11-25","capabilities":{},"clientInfo":{"name":"subscribe-harness","version":"1"}}}`)
+	initResult := requireObject(t, await("initialize", reply(1))["result"], "initialize.result")
+	resourcesCap := requireObject(t, requireObject(t, initResult["capabilities"], "capabilities")["resources"], "capabilities.resources")
+	if resourcesCap["subscribe"] != true {
+		t.Fatalf("resources capability = %v, want subscribe=true", resourcesCap)
+	}
+	send(`{"jsonrpc":"2.0","method":"notifications/initialized"}`)
+
+	// A URI with nothing to poll is acknowledged but not watched.
+	send(`{"jsonrpc":"2.0","id":2,"method":"resources/subscribe","params":{"uri":"slide://welcome"}}`)
+	await("subscribe welcome", reply(2))
+	send(`{"jsonrpc":"2.0","id":3,"method":"resources/subscribe","params":{"uri":"slide://agent/a_1"}}`)
+	await("subscribe agent", reply(3))
+	resourceWatches.mu.Lock()
+	watched := len(resourceWatches.watches)
+	resourceWatches.mu.Unlock()
+	if watched != 1 {
+		t.Fatalf("%d resources watched, want only slide://agent/a_1", watched)
+	}
+
+	// Heartbeats alone are not a change.
+	waitForFetches(4)
+	time.Sleep(20 * time.Millisecond)
+	for len(messages) > 0 {
+		if message := <-messages; updated(message) {
+			t.Fatalf("notified on an unchanged resource: %v", message)
+		}
+	}
+
+	hostname.Store("fs-01-renamed")
+	params := requireObject(t, await("resources/updated", updated)["params"], "resources/updated.params")
+	if params["uri"] != "slide://agent/a_1" {
+		t.Fatalf("resources/updated uri = %v", params["uri"])
+	}
+
+	// A subscription past the per-session cap, and one to an agent the API
+	// does not know, are acknowledged but not watched.
+	defer func(n int) { maxWatchesPerSession = n }(maxWatchesPerSession)
+	maxWatchesPerSession = 1
+	send(`{"jsonrpc":"2.0","id":5,"method":"resources/subscribe","params":{"uri":"slide://agent/a_2"}}`)
+	await("subscribe past the cap", reply(5))
+	maxWatchesPerSession = 2
+	send(`{"jsonrpc":"2.0","id":6,"method":"resources/subscribe","params":{"uri":"slide://agent/a_missing"}}`)
+	await("subscribe missing agent", reply(6))
+	resourceWatches.mu.Lock()
+	watched = len(resourceWatches.watches)
+	resourceWatches.mu.Unlock()
+	if watched != 1 {
+		t.Fatalf("%d resources watched, want the one past the cap and the missing agent skipped", watched)
+	}
+	send(`{"jsonrpc":"2.0","id":7,"method":"resources/subscribe","params":{"uri":"slide://agent/a_2"}}`)
+	await("subscribe under the cap", reply(7))
+	resourceWatches.mu.Lock()
+	watched = len(resourceWatches.watches)
+	resourceWatches.mu.Unlock()
+	if watched != 2 {
+		t.Fatalf("%d resources watched, want slide://agent/a_2 added under the cap", watched)
+	}
+
+	for id, uri := range []string{"slide://agent/a_1", "slide://agent/a_2"} {
+		send(fmt.Sprintf(`{"jsonrpc":"2.0","id":%d,"method":"resources/unsubscribe","params":{"uri":%q}}`, 8+id, uri))
+		await("unsubscribe", reply(float64(8+id)))
+	}
+	resourceWatches.running.Wait()
+	resourceWatches.mu.Lock()
+	watched = len(resourceWatches.watches)
+	resourceWatches.mu.Unlock()
+	if watched != 0 {
+		t.Fatalf("%d resources still watched after unsubscribe", watched)
+	}
+}
+
+// TestWireGuardPeerKeyKeptWhenNetworkLookupFails checks that a peer whose
+// network cannot be read back after the create still comes back with its
+// private key, a placeholder server key, and a warning.
+func TestWireGuardPeerKeyKeptWhenNetworkLookupFails(t *testing.T) {
+	setupTestEnv(t, ToolsFull)
+	const privateKey = "a2V5LWFmdGVyLWZhaWxlZC1sb29rdXA="
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == "POST" && r.URL.Path == "/v1/network/net_1/wg-peer":
+			_, _ = w.Write([]byte(`{"wg_peer_id":"wg_1","peer_name":"tech phone","wg_address":"10.99.0.2/32","wg_endpoint":"203.0.113.9:51820","wg_private_key":"` + privateKey + `"}`))
+		case r.Method == "GET" && r.URL.Path == "/v1/network/net_1":
+			http.Error(w, `{"message":"forbidden"}`, http.StatusForbidden)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer api.Close()
+	useTestHTTPServer(t, api)
+
+	srv, err := buildMCPServer()
+	if err != nil {
+		t.Fatalf("buildMCPServer: %v", err)
+	}
+	response := marshalRPCResponse(t, srv.HandleMessage(context.Background(), []byte(`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"slide_recovery","arguments":{"operation":"create_wg_peer","network_id":"net_1","peer_name":"tech phone"}}}`)))
+	result := requireObject(t, response["result"], "result")
+	if result["isError"] == true {
+		t.Fatalf("create_wg_peer failed after the peer was created: %v", result)
+	}
+	structured := requireObject(t, result["structuredContent"], "structuredContent")
+	if structured["wg_private_key"] != privateKey {
+		t.Errorf("wg_private_key = %v, want the key from the create response", structured["wg_private_key"])
+	}
+	conf, _ := structured["_wireguard_config"].(string)
+	if !strings.Contains(conf, "PrivateKey = "+privateKey) || !strings.Contains(conf, "PublicKey = "+wgServerKeyPlaceholder) {
+		t.Errorf("_wireguard_config = %q, want the private key and the server key placeholder", conf)
+	}
+	if warning, _ := structured["warning"].(string); !strings.Contains(warning, "get_network network_id=net_1") {
+		t.Errorf("warning = %q, want a pointer to get_network", warning)
+	}
+}
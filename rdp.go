@@ -0,0 +1,230 @@
+package main
+
+// RDP bookmarks for recovery VMs.
+//
+// get_rdp_bookmark builds a standard .rdp file for a VM's rdp_endpoint and
+// hands it over without leaving the machine: inline in the tool result as
+// an embedded resource, as the slide://vm/{virt_id}/rdp resource, and, when
+// --rdp-dir / SLIDE_RDP_DIR is set, as a file in that directory. Uploading
+// the file to the external slide.recipes cache for a download link is
+// opt-in (--rdp-cache / SLIDE_RDP_CACHE) because it sends the VM's
+// endpoint to a third party.
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+const (
+	rdpMIMEType         = "application/x-rdp"
+	resourceURITplVMRDP = "slide://vm/{virt_id}/rdp"
+)
+
+// rdpCacheURL is the external cache used when RDPCache is enabled.
+var rdpCacheURL = "https://www.slide.recipes/mcpTools/rdpCache.php"
+
+// rdpFileTemplate is the .rdp body; %s is the VM's host:port.
+const rdpFileTemplate = `screen mode id:i:2
+use multimon:i:0
+desktopwidth:i:1920
+desktopheight:i:1080
+session bpp:i:32
+winposstr:s:0,3,0,0,800,600
+compression:i:1
+keyboardhook:i:2
+audiocapturemode:i:0
+videoplaybackmode:i:1
+connection type:i:7
+networkautodetect:i:1
+bandwidthautodetect:i:1
+displayconnectionbar:i:1
+enableworkspacereconnect:i:0
+disable wallpaper:i:0
+allow font smoothing:i:0
+allow desktop composition:i:0
+disable full window drag:i:1
+disable menu anims:i:1
+disable themes:i:0
+disable cursor setting:i:0
+bitmapcachepersistenable:i:1
+full address:s:%s
+audiomode:i:0
+redirectprinters:i:1
+redirectcomports:i:0
+redirectsmartcards:i:1
+redirectclipboard:i:1
+redirectposdevices:i:0
+autoreconnection enabled:i:1
+authentication level:i:2
+prompt for credentials:i:1
+negotiate security layer:i:1
+remoteapplicationmode:i:0
+alternate shell:s:
+shell working directory:s:
+gatewayhostname:s:
+gatewayusagemethod:i:4
+gatewaycredentialssource:i:4
+gatewayprofileusagemethod:i:0
+promptcredentialonce:i:0
+gatewaybrokeringtype:i:0
+use redirection server name:i:0
+rdgiskdcproxy:i:0
+kdcproxyname:s:
+`
+
+// rdpResourceURI is the slide://vm/{virt_id}/rdp URI for a VM.
+func rdpResourceURI(virtID string) string {
+	return "slide://vm/" + virtID + "/rdp"
+}
+
+// rdpFilename is the suggested name for a VM's bookmark.
+func rdpFilename(virtID string) string {
+	return fmt.Sprintf("slide-vm-%s.rdp", virtID)
+}
+
+// fetchVMRDP returns the VM and its .rdp file content.
+func fetchVMRDP(ctx context.Context, virtID string) (*VirtualMachine, string, error) {
+	data, err := makeAPIRequest(ctx, "GET", fmt.Sprintf("/v1/restore/virt/%s", virtID), nil)
+	if err != nil {
+		return nil, "", err
+	}
+	var vm VirtualMachine
+	if err := json.Unmarshal(data, &vm); err != nil {
+		return nil, "", fmt.Errorf("failed to parse VM response: %w", err)
+	}
+	if vm.RDPEndpoint == nil || *vm.RDPEndpoint == "" {
+		return nil, "", fmt.Errorf("RDP endpoint not available for this virtual machine")
+	}
+	return &vm, fmt.Sprintf(rdpFileTemplate, *vm.RDPEndpoint), nil
+}
+
+// writeRDPFile saves content as dir/slide-vm-<id>.rdp, readable only by
+// the current user, and returns the path.
+func writeRDPFile(dir, virtID, content string) (string, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", fmt.Errorf("create RDP directory: %w", err)
+	}
+	path := filepath.Join(dir, rdpFilename(virtID))
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		return "", fmt.Errorf("write RDP file: %w", err)
+	}
+	return path, nil
+}
+
+// storeRDPInCache uploads content to the external cache and returns its
+// rdpID. Only called when RDPCache is enabled.
+func storeRDPInCache(ctx context.Context, content string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", rdpCacheURL, strings.NewReader(content))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "text/plain")
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to store RDP in cache: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("cache service returned status: %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxAPIResponseBytes))
+	if err != nil {
+		return "", fmt.Errorf("failed to read cache response: %w", err)
+	}
+
+	var cacheResponse struct {
+		Success bool   `json:"success"`
+		RdpID   string `json:"rdpID"`
+		Error   string `json:"error"`
+	}
+	if err := json.Unmarshal(body, &cacheResponse); err != nil {
+		return "", fmt.Errorf("failed to parse cache response: %w", err)
+	}
+	if !cacheResponse.Success {
+		return "", fmt.Errorf("cache service error: %s", cacheResponse.Error)
+	}
+	return cacheResponse.RdpID, nil
+}
+
+func generateRDPBookmark(ctx context.Context, args map[string]interface{}) (string, error) {
+	virtID, err := requireString(args, "virt_id")
+	if err != nil {
+		return "", err
+	}
+	vm, content, err := fetchVMRDP(ctx, virtID)
+	if err != nil {
+		return "", err
+	}
+
+	uri := rdpResourceURI(vm.VirtID)
+	attachContent(ctx, mcp.NewEmbeddedResource(mcp.TextResourceContents{
+		URI:      uri,
+		MIMEType: rdpMIMEType,
+		Text:     content,
+	}))
+
+	metadata := map[string]interface{}{
+		"usage_instructions": "Save the 'content' field to a file with the suggested 'filename', then double-click the .rdp file to connect to the virtual machine. The same file is attached to this result and readable as the 'resource_uri' resource.",
+		"file_format":        "This generates a standard Windows Remote Desktop Connection (.rdp) file that can be used with any RDP client.",
+		"connection_info":    fmt.Sprintf("This bookmark will connect to: %s", *vm.RDPEndpoint),
+		"security_note":      "You will be prompted for credentials when connecting. Use the Windows account credentials from the original system.",
+	}
+	result := map[string]interface{}{
+		"virt_id":      vm.VirtID,
+		"rdp_endpoint": *vm.RDPEndpoint,
+		"filename":     rdpFilename(vm.VirtID),
+		"content":      content,
+		"content_type": rdpMIMEType,
+		"resource_uri": uri,
+		"_metadata":    metadata,
+	}
+
+	cfg := currentConfig()
+	if cfg.RDPDir != "" {
+		path, err := writeRDPFile(cfg.RDPDir, vm.VirtID, content)
+		if err != nil {
+			return "", err
+		}
+		result["file_path"] = path
+		metadata["file_instructions"] = fmt.Sprintf("The bookmark was saved to %s; open it to connect.", path)
+	}
+
+	if cfg.RDPCache {
+		rdpID, err := storeRDPInCache(ctx, content)
+		if err != nil {
+			// The bookmark is still usable inline; the link is a convenience.
+			log.Printf("Warning: failed to store RDP in cache: %v", err)
+		} else {
+			downloadURL := fmt.Sprintf("%s?rdpID=%s", rdpCacheURL, rdpID)
+			result["download_url"] = downloadURL
+			result["rdp_cache_id"] = rdpID
+			metadata["download_instructions"] = fmt.Sprintf("You can download the RDP file directly from: %s (file expires in 1 hour)", downloadURL)
+		}
+	}
+
+	return toJSONString(result)
+}
+
+// handleResourceVMRDP serves slide://vm/{virt_id}/rdp as the raw .rdp file.
+func handleResourceVMRDP(ctx context.Context, uri string) ([]byte, error) {
+	id, err := extractIDFromURI(uri, "slide://vm/")
+	if err != nil {
+		return nil, err
+	}
+	_, content, err := fetchVMRDP(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(content), nil
+}
@@ -12,7 +12,7 @@ import (
 
 // ToolHandler is the in-process handler signature shared by every
 // tools_*.go file. It returns a JSON string body plus an error.
-type ToolHandler func(map[string]interface{}) (string, error)
+type ToolHandler func(context.Context, map[string]interface{}) (string, error)
 
 // toolRegistry maps tool names to their in-process handlers. The SDK server
 // calls these via adaptToolHandler(); the one-shot --tool CLI mode also
@@ -41,9 +41,12 @@ var toolRegistry = map[string]ToolHandler{
 	"slide_backups":   handleBackupsTool,
 	"slide_alerts":    handleAlertsTool,
 
+	// Background jobs started with background=true
+	"slide_jobs": handleJobsTool,
+
 	// Backward-compat shim (delegates to slide_overview inventory)
-	"list_all_clients_devices_and_agents": func(args map[string]interface{}) (string, error) {
-		return listAllClientsDevicesAndAgents(args)
+	"list_all_clients_devices_and_agents": func(ctx context.Context, args map[string]interface{}) (string, error) {
+		return listAllClientsDevicesAndAgents(ctx, args)
 	},
 }
 
@@ -63,6 +66,7 @@ func allToolInfos() []ToolInfo {
 		getSnapshotsToolInfo(),
 		getBackupsToolInfo(),
 		getAlertsToolInfo(),
+		getJobsToolInfo(),
 		{
 			Name: "list_all_clients_devices_and_agents",
 			Description: "Slide MCP - backward-compat alias. " +
@@ -88,7 +92,7 @@ func toolInfoToSDKTool(info ToolInfo) (mcp.Tool, error) {
 		return mcp.Tool{}, fmt.Errorf("marshal schema for %s: %w", info.Name, err)
 	}
 	desc := info.Description
-	if config != nil && config.ToolsMode == ToolsReadOnly {
+	if cfg := currentConfig(); cfg != nil && cfg.ToolsMode == ToolsReadOnly {
 		desc += " (Read-only mode: only list/get/search operations available.)"
 	}
 	t := mcp.NewToolWithRawSchema(info.Name, desc, schemaBytes)
@@ -116,22 +120,26 @@ func toolInfoToSDKTool(info ToolInfo) (mcp.Tool, error) {
 // happen for our handlers), we fall back to text-only - still valid per
 // spec, just won't satisfy outputSchema clients.
 func adaptToolHandler(name string, handler ToolHandler) server.ToolHandlerFunc {
-	return func(_ context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-		if config.IsToolDisabled(name) {
+	return func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		cfg := currentConfig()
+		if cfg.IsToolDisabled(name) {
 			return mcp.NewToolResultErrorf("tool '%s' is disabled", name), nil
 		}
-		if !config.IsToolAllowed(name) {
-			return mcp.NewToolResultErrorf("tool '%s' not available in '%s' mode", name, config.ToolsMode), nil
+		if !cfg.IsToolAllowed(name) {
+			return mcp.NewToolResultErrorf("tool '%s' not available in '%s' mode", name, cfg.ToolsMode), nil
 		}
 		args := req.GetArguments()
 		if args == nil {
 			args = map[string]any{}
 		}
-		text, err := handler(args)
+		ctx, attached := withAttachments(withProgress(bindAPISession(ctx), req.Params.Meta))
+		text, err := handler(ctx, args)
 		if err != nil {
 			return mcp.NewToolResultError(err.Error()), nil
 		}
-		return toolResultWithStructured(text), nil
+		result := toolResultWithStructured(text)
+		result.Content = append(result.Content, attached.blocks()...)
+		return result, nil
 	}
 }
 
@@ -173,9 +181,10 @@ func registerTools(s *server.MCPServer) error {
 // they do not appear in tools/list.
 func toolFilterForMode() server.ToolFilterFunc {
 	return func(_ context.Context, tools []mcp.Tool) []mcp.Tool {
+		cfg := currentConfig()
 		filtered := make([]mcp.Tool, 0, len(tools))
 		for _, t := range tools {
-			if config.IsToolAllowed(t.Name) {
+			if cfg.IsToolAllowed(t.Name) {
 				filtered = append(filtered, t)
 			}
 		}
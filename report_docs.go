@@ -0,0 +1,371 @@
+package main
+
+// Markdown and HTML documents for drill and verification reports.
+//
+// MSPs hand clients evidence of DR testing, and JSON is not something a
+// client signs off on. Passing report=markdown, html, or both to
+// slide_recovery dr_drill or slide_snapshots verification_report renders
+// the same result as a self-contained document: a header with the scope
+// and outcome, a per-agent table with timings and boot screenshot links,
+// and the individual checks. The documents are attached to the tool
+// result as embedded resources and, when --report-dir / SLIDE_REPORT_DIR
+// is set, saved there with owner-only permissions.
+//
+// Both renderers work from one reportDoc, so the Markdown and HTML
+// versions of a report always say the same thing.
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+const (
+	reportFormatMarkdown = "markdown"
+	reportFormatHTML     = "html"
+	reportFormatBoth     = "both"
+)
+
+var reportFormats = []string{reportFormatMarkdown, reportFormatHTML, reportFormatBoth}
+
+// reportDoc is a report laid out for people: facts up top, then tables.
+type reportDoc struct {
+	title    string
+	subtitle string
+	result   string // overall outcome, shown as a badge
+	facts    [][2]string
+	sections []reportSection
+	notes    []string
+}
+
+type reportSection struct {
+	heading string
+	columns []string
+	rows    [][]reportCell
+}
+
+// reportCell is one table cell. A status cell is styled by its value; a
+// link cell shows text linking to link.
+type reportCell struct {
+	text   string
+	link   string
+	status bool
+}
+
+func cell(text string) reportCell    { return reportCell{text: text} }
+func statusCell(s string) reportCell { return reportCell{text: s, status: true} }
+func optionalCell(text *string) reportCell {
+	if text == nil {
+		return cell("")
+	}
+	return cell(*text)
+}
+func linkCell(text string, link *string) reportCell {
+	if link == nil || *link == "" {
+		return cell("")
+	}
+	return reportCell{text: text, link: *link}
+}
+
+// attachReportDocs renders doc in the formats args["report"] asks for,
+// attaches them to the tool result, and saves them under the report
+// directory when one is configured. It returns nil when no report was
+// asked for.
+func attachReportDocs(ctx context.Context, args map[string]interface{}, doc reportDoc, base string) (map[string]interface{}, error) {
+	formats, err := reportDocFormats(args)
+	if err != nil || formats == nil {
+		return nil, err
+	}
+
+	name := base + "-" + time.Now().UTC().Format("20060102T150405Z")
+	var resources, files []string
+	for _, f := range formats {
+		filename, mimeType, content := name+".md", "text/markdown", renderReportMarkdown(doc)
+		if f == reportFormatHTML {
+			filename, mimeType, content = name+".html", "text/html", renderReportHTML(doc)
+		}
+		uri := "slide://report/" + filename
+		attachContent(ctx, mcp.NewEmbeddedResource(mcp.TextResourceContents{URI: uri, MIMEType: mimeType, Text: content}))
+		resources = append(resources, uri)
+		if dir := currentConfig().ReportDir; dir != "" {
+			path, err := writeReportFile(dir, filename, content)
+			if err != nil {
+				return nil, err
+			}
+			files = append(files, path)
+		}
+	}
+	out := map[string]interface{}{"formats": formats, "attached": resources}
+	if len(files) > 0 {
+		out["files"] = files
+	} else {
+		out["note"] = "The documents are attached to this result only. Start the server with --report-dir (or SLIDE_REPORT_DIR) to also save them."
+	}
+	return out, nil
+}
+
+// reportDocFormats is the document formats args["report"] asks for, nil
+// for none. Handlers that do expensive work call it first so a typo fails
+// before the work starts.
+func reportDocFormats(args map[string]interface{}) ([]string, error) {
+	switch format, _ := optionalString(args, "report"); format {
+	case "":
+		return nil, nil
+	case reportFormatMarkdown, reportFormatHTML:
+		return []string{format}, nil
+	case reportFormatBoth:
+		return []string{reportFormatMarkdown, reportFormatHTML}, nil
+	}
+	return nil, fmt.Errorf("report must be one of %s", strings.Join(reportFormats, ", "))
+}
+
+// writeReportFile saves content as dir/filename, readable only by the
+// current user, and returns the path.
+func writeReportFile(dir, filename, content string) (string, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", fmt.Errorf("create report directory: %w", err)
+	}
+	path := filepath.Join(dir, filename)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		return "", fmt.Errorf("write report: %w", err)
+	}
+	return path, nil
+}
+
+// safeLink is link if it is an http(s) URL, else "", so a report never
+// carries a javascript: or file: link.
+func safeLink(link string) string {
+	if u, err := url.Parse(link); err == nil && (u.Scheme == "https" || u.Scheme == "http") {
+		return link
+	}
+	return ""
+}
+
+// markdownText keeps text from being read as inline HTML, since
+// hostnames and API messages end up in the report verbatim.
+func markdownText(text string) string {
+	return strings.NewReplacer("<", "&lt;", ">", "&gt;").Replace(text)
+}
+
+// markdownCell escapes text for a Markdown table cell.
+func markdownCell(text string) string {
+	text = strings.ReplaceAll(markdownText(text), "|", `\|`)
+	return strings.Join(strings.Fields(text), " ")
+}
+
+func renderReportMarkdown(doc reportDoc) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "# %s\n\n", markdownText(doc.title))
+	if doc.subtitle != "" {
+		fmt.Fprintf(&sb, "%s\n\n", markdownText(doc.subtitle))
+	}
+	if doc.result != "" {
+		fmt.Fprintf(&sb, "**Result: %s**\n\n", strings.ToUpper(doc.result))
+	}
+	for _, f := range doc.facts {
+		fmt.Fprintf(&sb, "- **%s:** %s\n", f[0], markdownCell(f[1]))
+	}
+	for _, s := range doc.sections {
+		fmt.Fprintf(&sb, "\n## %s\n\n", s.heading)
+		sb.WriteString("| " + strings.Join(s.columns, " | ") + " |\n")
+		sb.WriteString("|" + strings.Repeat(" --- |", len(s.columns)) + "\n")
+		for _, row := range s.rows {
+			cells := make([]string, len(row))
+			for i, c := range row {
+				text := markdownCell(c.text)
+				switch {
+				case c.link != "" && safeLink(c.link) != "":
+					text = fmt.Sprintf("[%s](<%s>)", text, strings.ReplaceAll(c.link, ">", "%3E"))
+				case c.status && text != "":
+					text = "**" + text + "**"
+				}
+				cells[i] = text
+			}
+			sb.WriteString("| " + strings.Join(cells, " | ") + " |\n")
+		}
+	}
+	if len(doc.notes) > 0 {
+		sb.WriteString("\n## Notes\n\n")
+		for _, n := range doc.notes {
+			fmt.Fprintf(&sb, "- %s\n", markdownText(n))
+		}
+	}
+	fmt.Fprintf(&sb, "\n_Generated by slide-mcp-server %s at %s._\n", Version, time.Now().UTC().Format(time.RFC3339))
+	return sb.String()
+}
+
+// reportCSS keeps the HTML report readable on screen and in print with no
+// external assets.
+const reportCSS = `body{font-family:-apple-system,"Segoe UI",Helvetica,Arial,sans-serif;margin:2em;color:#222}
+h1{margin-bottom:.2em}h2{margin-top:1.6em;border-bottom:1px solid #ddd;padding-bottom:.2em}
+.subtitle{color:#555;margin-top:0}dl{display:grid;grid-template-columns:max-content auto;gap:.3em 1.2em}dt{font-weight:600}dd{margin:0}
+table{border-collapse:collapse;width:100%;font-size:.92em}th,td{border:1px solid #ddd;padding:.35em .6em;text-align:left;vertical-align:top}th{background:#f4f4f4}
+.status{font-weight:600;text-transform:uppercase;font-size:.85em}.badge{display:inline-block;padding:.2em .7em;border-radius:.3em;color:#fff;background:#666}
+.passed,.ok{color:#17693a}.failed,.failing,.never_passed,.error{color:#b3261e}.warning,.unverified,.pending{color:#9a6700}.skipped{color:#666}
+.badge.passed{background:#17693a;color:#fff}.badge.failed{background:#b3261e;color:#fff}
+footer{margin-top:2em;color:#777;font-size:.85em}@media print{body{margin:0}}`
+
+func renderReportHTML(doc reportDoc) string {
+	esc := html.EscapeString
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "<!DOCTYPE html>\n<html lang=\"en\">\n<head>\n<meta charset=\"utf-8\">\n<title>%s</title>\n<style>\n%s\n</style>\n</head>\n<body>\n", esc(doc.title), reportCSS)
+	fmt.Fprintf(&sb, "<h1>%s</h1>\n", esc(doc.title))
+	if doc.subtitle != "" {
+		fmt.Fprintf(&sb, "<p class=\"subtitle\">%s</p>\n", esc(doc.subtitle))
+	}
+	if doc.result != "" {
+		fmt.Fprintf(&sb, "<p><span class=\"badge %s\">%s</span></p>\n", esc(doc.result), esc(strings.ToUpper(doc.result)))
+	}
+	if len(doc.facts) > 0 {
+		sb.WriteString("<dl>\n")
+		for _, f := range doc.facts {
+			fmt.Fprintf(&sb, "<dt>%s</dt><dd>%s</dd>\n", esc(f[0]), esc(f[1]))
+		}
+		sb.WriteString("</dl>\n")
+	}
+	for _, s := range doc.sections {
+		fmt.Fprintf(&sb, "<h2>%s</h2>\n<table>\n<thead><tr>", esc(s.heading))
+		for _, c := range s.columns {
+			fmt.Fprintf(&sb, "<th>%s</th>", esc(c))
+		}
+		sb.WriteString("</tr></thead>\n<tbody>\n")
+		for _, row := range s.rows {
+			sb.WriteString("<tr>")
+			for _, c := range row {
+				switch {
+				case c.link != "" && safeLink(c.link) != "":
+					fmt.Fprintf(&sb, "<td><a href=\"%s\">%s</a></td>", esc(c.link), esc(c.text))
+				case c.status:
+					fmt.Fprintf(&sb, "<td class=\"status %s\">%s</td>", esc(c.text), esc(c.text))
+				default:
+					fmt.Fprintf(&sb, "<td>%s</td>", esc(c.text))
+				}
+			}
+			sb.WriteString("</tr>\n")
+		}
+		sb.WriteString("</tbody>\n</table>\n")
+	}
+	if len(doc.notes) > 0 {
+		sb.WriteString("<h2>Notes</h2>\n<ul>\n")
+		for _, n := range doc.notes {
+			fmt.Fprintf(&sb, "<li>%s</li>\n", esc(n))
+		}
+		sb.WriteString("</ul>\n")
+	}
+	fmt.Fprintf(&sb, "<footer>Generated by slide-mcp-server %s at %s.</footer>\n</body>\n</html>\n", esc(Version), time.Now().UTC().Format(time.RFC3339))
+	return sb.String()
+}
+
+// drillReportDoc lays out a dr_drill result.
+func drillReportDoc(rep *drillReport) reportDoc {
+	doc := reportDoc{
+		title:    "DR drill report",
+		subtitle: "Scope: " + rep.Drill.Scope,
+		result:   rep.Result,
+		notes:    append([]string(nil), rep.Notes...),
+	}
+	network := rep.Drill.NetworkType
+	if rep.Drill.NetworkSource != "" {
+		network += " (" + rep.Drill.NetworkSource + ")"
+	}
+	doc.facts = [][2]string{
+		{"Started", rep.Drill.StartedAt},
+		{"Finished", rep.Drill.FinishedAt},
+		{"Duration", fmt.Sprintf("%.0f s", rep.Drill.DurationSeconds)},
+		{"Network", network},
+		{"Boot timeout", fmt.Sprintf("%d s", rep.Drill.BootTimeout)},
+		{"Agents", fmt.Sprintf("%d (%d passed, %d failed, %d skipped)", rep.Summary.Agents, rep.Summary.Passed, rep.Summary.Failed, rep.Summary.Skipped)},
+	}
+	if rto := rep.Summary.RTO; rto != nil {
+		doc.facts = append(doc.facts, [2]string{"Observed RTO", fmt.Sprintf("min %.0f s, median %.0f s, max %.0f s (%s)", rto.MinSeconds, rto.MedianSeconds, rto.MaxSeconds, rto.Slowest)})
+	}
+
+	agents := reportSection{
+		heading: "Agents",
+		columns: []string{"Agent", "Outcome", "Snapshot", "Snapshot time", "Booted on", "Boot requested", "Running", "RTO", "Torn down", "Boot screenshot"},
+	}
+	for _, a := range rep.Agents {
+		rto := ""
+		if a.RTOSeconds != nil {
+			rto = fmt.Sprintf("%.0f s", *a.RTOSeconds)
+		}
+		booted := a.DeviceID
+		if a.Location != "" {
+			booted += " (" + a.Location + ")"
+		}
+		if a.Reason != "" {
+			doc.notes = append(doc.notes, a.AgentName+": "+a.Reason)
+		}
+		agents.rows = append(agents.rows, []reportCell{
+			cell(a.AgentName), statusCell(a.Outcome), cell(a.SnapshotID), cell(a.SnapshotTime), cell(booted),
+			cell(a.BootRequestedAt), cell(a.RunningAt), cell(rto), cell(a.TornDownAt), linkCell("screenshot", a.ScreenshotURL),
+		})
+	}
+	checks := reportSection{heading: "Checks", columns: []string{"Agent", "Check", "Status", "Detail"}}
+	for _, a := range rep.Agents {
+		for _, c := range a.Checks {
+			checks.rows = append(checks.rows, []reportCell{cell(a.AgentName), cell(c.Name), statusCell(c.Status), cell(c.Detail)})
+		}
+	}
+	doc.sections = []reportSection{agents, checks}
+	return doc
+}
+
+// verificationReportDoc lays out a verification_report result.
+func verificationReportDoc(scope string, days int, since time.Time, total int, byStatus map[string]int, agents []agentVerification) reportDoc {
+	problems := total - byStatus[verifyReportOK]
+	result := "passed"
+	if problems > 0 {
+		result = "failed"
+	}
+	var counts []string
+	for _, s := range []string{verifyReportOK, verifyReportFailing, verifyReportNeverPassed, verifyReportUnverified, verifyReportError} {
+		if byStatus[s] > 0 {
+			counts = append(counts, fmt.Sprintf("%d %s", byStatus[s], s))
+		}
+	}
+	doc := reportDoc{
+		title:    "Backup verification report",
+		subtitle: "Scope: " + scope,
+		result:   result,
+		facts: [][2]string{
+			{"Window", fmt.Sprintf("last %d days (since %s)", days, since.Format(time.RFC3339))},
+			{"Agents", fmt.Sprintf("%d (%s)", total, strings.Join(counts, ", "))},
+			{"Problems", fmt.Sprintf("%d", problems)},
+		},
+	}
+	table := reportSection{
+		heading: "Agents",
+		columns: []string{"Agent", "Status", "Latest verified snapshot", "Boot", "Filesystem", "Services", "Last boot pass", "Boot screenshot", "Failing services"},
+	}
+	for _, a := range agents {
+		row := []reportCell{cell(a.AgentName), statusCell(a.Status)}
+		if v := a.LatestVerified; v != nil {
+			row = append(row, cell(v.SnapshotID+" ("+v.StartedAt+")"), optionalCell(v.Boot), optionalCell(v.Filesystem), optionalCell(v.Service))
+		} else {
+			row = append(row, cell(""), cell(""), cell(""), cell(""))
+		}
+		var screenshot *string
+		if a.LatestVerified != nil {
+			screenshot = a.LatestVerified.ScreenshotURL
+		}
+		var failing []string
+		for _, svc := range a.FailingServices {
+			failing = append(failing, svc.Name+" ("+svc.State+")")
+		}
+		row = append(row, cell(a.LastBootPassAt), linkCell("screenshot", screenshot), cell(strings.Join(failing, ", ")))
+		table.rows = append(table.rows, row)
+		if a.Note != "" {
+			doc.notes = append(doc.notes, a.AgentName+": "+a.Note)
+		}
+	}
+	doc.sections = []reportSection{table}
+	return doc
+}
@@ -23,19 +23,19 @@ import (
 // keeps working as an alias for slide://overview/inventory so existing
 // Claude Desktop installs that cached the old context resource don't break.
 const (
-	resourceURIInventory       = "slide://overview/inventory"
-	resourceURIHealth          = "slide://overview/health"
-	resourceURIAlertsOpen      = "slide://alerts/unresolved"
-	resourceURIAuditRecent     = "slide://audit/recent"
-	resourceURIDocsOpenAPI     = "slide://docs/openapi"
-	resourceURIWelcome         = "slide://welcome"
-	resourceURIHelpGlossary    = "slide://help/glossary"
+	resourceURIInventory        = "slide://overview/inventory"
+	resourceURIHealth           = "slide://overview/health"
+	resourceURIAlertsOpen       = "slide://alerts/unresolved"
+	resourceURIAuditRecent      = "slide://audit/recent"
+	resourceURIDocsOpenAPI      = "slide://docs/openapi"
+	resourceURIWelcome          = "slide://welcome"
+	resourceURIHelpGlossary     = "slide://help/glossary"
 	resourceURIHelpTroubleshoot = "slide://help/troubleshoot"
-	resourceURITplClient       = "slide://client/{client_id}"
-	resourceURITplDevice       = "slide://device/{device_id}"
-	resourceURITplAgent        = "slide://agent/{agent_id}"
-	resourceURITplAgentRecents = "slide://agent/{agent_id}/snapshots/recent"
-	resourceURILegacyContext   = "slide://context/clients-devices-agents"
+	resourceURITplClient        = "slide://client/{client_id}"
+	resourceURITplDevice        = "slide://device/{device_id}"
+	resourceURITplAgent         = "slide://agent/{agent_id}"
+	resourceURITplAgentRecents  = "slide://agent/{agent_id}/snapshots/recent"
+	resourceURILegacyContext    = "slide://context/clients-devices-agents"
 )
 
 // registerResources wires every v5 resource onto the SDK server.
@@ -89,16 +89,25 @@ func registerResources(s *server.MCPServer) {
 	addTemplate(s, resourceURITplAgentRecents, "Slide agent recent snapshots",
 		"Last 14 days of snapshots for one agent. URI: slide://agent/{agent_id}/snapshots/recent.",
 		handleResourceAgentRecentSnapshots)
+	addTextTemplate(s, resourceURITplVMRDP, "Slide recovery VM RDP bookmark",
+		"Remote Desktop (.rdp) file for a running recovery VM, generated locally. URI: slide://vm/{virt_id}/rdp.",
+		rdpMIMEType, handleResourceVMRDP)
+	addTextTemplate(s, resourceURITplWGPeerConf, "Slide DR network WireGuard peer config",
+		"WireGuard client config (.conf) for a DR network peer. The private key is a placeholder: it is shown only once, by slide_recovery create_wg_peer. URI: slide://network/{network_id}/wg-peer/{wg_peer_id}/config.",
+		wgConfigMIMEType, handleResourceWGPeerConfig)
+	addTemplate(s, resourceURITplJob, "Slide background job",
+		"Status and last object seen for a job started with background=true, in this session. URI: slide://jobs/{job_id}.",
+		handleResourceJob)
 }
 
 // addStaticResource is a tiny wrapper for non-templated URIs.
-func addStaticResource(s *server.MCPServer, uri, name, desc string, fn func(string) ([]byte, error)) {
+func addStaticResource(s *server.MCPServer, uri, name, desc string, fn func(context.Context, string) ([]byte, error)) {
 	r := mcp.NewResource(uri, name,
 		mcp.WithResourceDescription(desc),
 		mcp.WithMIMEType("application/json"),
 	)
-	s.AddResource(r, func(_ context.Context, req mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
-		body, err := fn(req.Params.URI)
+	s.AddResource(r, func(ctx context.Context, req mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+		body, err := fn(bindAPISession(ctx), req.Params.URI)
 		if err != nil {
 			return nil, err
 		}
@@ -112,21 +121,26 @@ func addStaticResource(s *server.MCPServer, uri, name, desc string, fn func(stri
 	})
 }
 
-// addTemplate is a wrapper for URI-templated resources.
-func addTemplate(s *server.MCPServer, tpl, name, desc string, fn func(string) ([]byte, error)) {
+// addTemplate is a wrapper for URI-templated JSON resources.
+func addTemplate(s *server.MCPServer, tpl, name, desc string, fn func(context.Context, string) ([]byte, error)) {
+	addTextTemplate(s, tpl, name, desc, "application/json", fn)
+}
+
+// addTextTemplate registers a URI-templated resource with any text MIME type.
+func addTextTemplate(s *server.MCPServer, tpl, name, desc, mimeType string, fn func(context.Context, string) ([]byte, error)) {
 	rt := mcp.NewResourceTemplate(tpl, name,
 		mcp.WithTemplateDescription(desc),
-		mcp.WithTemplateMIMEType("application/json"),
+		mcp.WithTemplateMIMEType(mimeType),
 	)
-	s.AddResourceTemplate(rt, func(_ context.Context, req mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
-		body, err := fn(req.Params.URI)
+	s.AddResourceTemplate(rt, func(ctx context.Context, req mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+		body, err := fn(bindAPISession(ctx), req.Params.URI)
 		if err != nil {
 			return nil, err
 		}
 		return []mcp.ResourceContents{
 			mcp.TextResourceContents{
 				URI:      req.Params.URI,
-				MIMEType: "application/json",
+				MIMEType: mimeType,
 				Text:     string(body),
 			},
 		}, nil
@@ -140,53 +154,53 @@ func addTemplate(s *server.MCPServer, tpl, name, desc string, fn func(string) ([
 // application/json so clients that auto-attach resources don't expect a
 // markdown renderer, but the body is structured as a JSON object with a
 // `markdown` string field for readability.
-func handleResourceWelcome(_ string) ([]byte, error) {
+func handleResourceWelcome(_ context.Context, _ string) ([]byte, error) {
 	return json.Marshal(map[string]interface{}{
 		"format":   "markdown",
 		"markdown": helpWelcomeMD,
 	})
 }
 
-func handleResourceHelpGlossary(_ string) ([]byte, error) {
+func handleResourceHelpGlossary(_ context.Context, _ string) ([]byte, error) {
 	return json.Marshal(map[string]interface{}{
 		"format":   "markdown",
 		"markdown": helpGlossaryMD,
 	})
 }
 
-func handleResourceHelpTroubleshoot(_ string) ([]byte, error) {
+func handleResourceHelpTroubleshoot(_ context.Context, _ string) ([]byte, error) {
 	return json.Marshal(map[string]interface{}{
 		"format":   "markdown",
 		"markdown": helpTroubleshootMD,
 	})
 }
 
-func handleResourceInventory(_ string) ([]byte, error) {
-	body, err := listAllClientsDevicesAndAgents(map[string]interface{}{})
+func handleResourceInventory(ctx context.Context, _ string) ([]byte, error) {
+	body, err := listAllClientsDevicesAndAgents(ctx, map[string]interface{}{})
 	if err != nil {
 		return nil, err
 	}
 	return []byte(body), nil
 }
 
-func handleResourceHealth(_ string) ([]byte, error) {
-	body, err := handleOverviewHealth(map[string]interface{}{})
+func handleResourceHealth(ctx context.Context, _ string) ([]byte, error) {
+	body, err := handleOverviewHealth(ctx, map[string]interface{}{})
 	if err != nil {
 		return nil, err
 	}
 	return []byte(body), nil
 }
 
-func handleResourceAlertsOpen(_ string) ([]byte, error) {
-	body, err := handleAlertsTriage(map[string]interface{}{})
+func handleResourceAlertsOpen(ctx context.Context, _ string) ([]byte, error) {
+	body, err := handleAlertsTriage(ctx, map[string]interface{}{})
 	if err != nil {
 		return nil, err
 	}
 	return []byte(body), nil
 }
 
-func handleResourceAuditRecent(_ string) ([]byte, error) {
-	body, err := handleAuditRecent(map[string]interface{}{"hours": float64(24)})
+func handleResourceAuditRecent(ctx context.Context, _ string) ([]byte, error) {
+	body, err := handleAuditRecent(ctx, map[string]interface{}{"hours": float64(24)})
 	if err != nil {
 		return nil, err
 	}
@@ -203,7 +217,7 @@ var openapiCache = struct {
 
 const openapiCacheTTL = time.Hour
 
-func handleResourceOpenAPI(_ string) ([]byte, error) {
+func handleResourceOpenAPI(_ context.Context, _ string) ([]byte, error) {
 	openapiCache.Lock()
 	defer openapiCache.Unlock()
 	if openapiCache.body != nil && time.Since(openapiCache.fetched) < openapiCacheTTL {
@@ -248,37 +262,37 @@ func extractIDFromURI(uri, prefix string) (string, error) {
 	return rest, nil
 }
 
-func handleResourceClient(uri string) ([]byte, error) {
+func handleResourceClient(ctx context.Context, uri string) ([]byte, error) {
 	id, err := extractIDFromURI(uri, "slide://client/")
 	if err != nil {
 		return nil, err
 	}
-	body, err := handleOverviewForClient(map[string]interface{}{"client_id": id})
+	body, err := handleOverviewForClient(ctx, map[string]interface{}{"client_id": id})
 	if err != nil {
 		return nil, err
 	}
 	return []byte(body), nil
 }
 
-func handleResourceDevice(uri string) ([]byte, error) {
+func handleResourceDevice(ctx context.Context, uri string) ([]byte, error) {
 	id, err := extractIDFromURI(uri, "slide://device/")
 	if err != nil {
 		return nil, err
 	}
-	body, err := handleOverviewForDevice(map[string]interface{}{"device_id": id})
+	body, err := handleOverviewForDevice(ctx, map[string]interface{}{"device_id": id})
 	if err != nil {
 		return nil, err
 	}
 	return []byte(body), nil
 }
 
-func handleResourceAgent(uri string) ([]byte, error) {
+func handleResourceAgent(ctx context.Context, uri string) ([]byte, error) {
 	id, err := extractIDFromURI(uri, "slide://agent/")
 	if err != nil {
 		return nil, err
 	}
 	// Compose: agent detail + recent snapshots + open alerts.
-	agentData, err := makeAPIRequest("GET", fmt.Sprintf("/v1/agent/%s", id), nil)
+	agentData, err := makeAPIRequest(ctx, "GET", fmt.Sprintf("/v1/agent/%s", id), nil)
 	if err != nil {
 		return nil, err
 	}
@@ -286,10 +300,10 @@ func handleResourceAgent(uri string) ([]byte, error) {
 	if err := json.Unmarshal(agentData, &agent); err != nil {
 		return nil, err
 	}
-	snapshotsData, _ := makeAPIRequest("GET", fmt.Sprintf("/v1/snapshot?agent_id=%s&limit=20&sort_by=backup_start_time&sort_asc=false", id), nil)
+	snapshotsData, _ := makeAPIRequest(ctx, "GET", fmt.Sprintf("/v1/snapshot?agent_id=%s&limit=20&sort_by=backup_start_time&sort_asc=false", id), nil)
 	var snaps PaginatedResponse[Snapshot]
 	_ = json.Unmarshal(snapshotsData, &snaps)
-	alertsData, _ := makeAPIRequest("GET", fmt.Sprintf("/v1/alert?agent_id=%s&resolved=false&limit=50", id), nil)
+	alertsData, _ := makeAPIRequest(ctx, "GET", fmt.Sprintf("/v1/alert?agent_id=%s&resolved=false&limit=50", id), nil)
 	var alerts PaginatedResponse[Alert]
 	_ = json.Unmarshal(alertsData, &alerts)
 	out := map[string]interface{}{
@@ -300,12 +314,12 @@ func handleResourceAgent(uri string) ([]byte, error) {
 	return json.Marshal(out)
 }
 
-func handleResourceAgentRecentSnapshots(uri string) ([]byte, error) {
+func handleResourceAgentRecentSnapshots(ctx context.Context, uri string) ([]byte, error) {
 	id, err := extractIDFromURI(uri, "slide://agent/")
 	if err != nil {
 		return nil, err
 	}
-	body, err := handleSnapshotsRecentForAgent(map[string]interface{}{"agent_id": id})
+	body, err := handleSnapshotsRecentForAgent(ctx, map[string]interface{}{"agent_id": id})
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,355 @@
+package main
+
+// slide_files tree and slide_recovery tree_image: a whole restore in one
+// call.
+//
+// browse returns one directory level per call, so looking through a user
+// profile costs dozens of calls. tree walks the restore breadth-first,
+// browsing each level's directories with bounded concurrency, and renders
+// the result as a compact indented listing with sizes and modified times.
+// max_depth bounds how far it goes, include/exclude globs trim the
+// listing, and max_entries caps the total so a walk over C:/ cannot flood
+// the context. Image exports have no folders, so tree_image is the same
+// listing over the export's disks.
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+	"sync/atomic"
+)
+
+const (
+	treeDefaultDepth   = 3
+	treeMaxDepth       = 10
+	treeDefaultEntries = 500
+	treeMaxEntries     = 5000
+)
+
+// treeNode is one browsed entry and, for directories, what is under it.
+type treeNode struct {
+	entry    FileRestoreEntry
+	rel      string // path below the tree's root, slash-separated
+	children []*treeNode
+	expanded bool // browsed (not cut off by max_depth or max_entries)
+	matched  bool // a file passing include, or a directory holding one
+}
+
+// treeFilter holds the include/exclude globs. A pattern containing "/"
+// is matched against the path below the root, otherwise against the name.
+type treeFilter struct {
+	include, exclude []string
+}
+
+func (f treeFilter) match(patterns []string, n *treeNode) bool {
+	for _, p := range patterns {
+		target := n.entry.Name
+		if strings.Contains(p, "/") {
+			target = n.rel
+		}
+		if ok, _ := path.Match(strings.ToLower(p), strings.ToLower(target)); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (f treeFilter) excluded(n *treeNode) bool { return f.match(f.exclude, n) }
+
+func (f treeFilter) included(n *treeNode) bool {
+	return len(f.include) == 0 || f.match(f.include, n)
+}
+
+// isDirEntry reports whether a browse entry is a folder.
+func isDirEntry(e FileRestoreEntry) bool {
+	switch strings.ToLower(e.Type) {
+	case "dir", "directory", "folder":
+		return true
+	}
+	return false
+}
+
+// handleFilesTree walks a file restore from browse_path.
+func handleFilesTree(ctx context.Context, args map[string]interface{}) (string, error) {
+	fileRestoreID, err := requireString(args, "file_restore_id")
+	if err != nil {
+		return "", err
+	}
+	root, _ := optionalString(args, "browse_path")
+	if root == "" {
+		root = "/"
+	}
+	root = restoreBrowsePath(root)
+	maxDepth, maxEntries, filter, err := treeOptions(args, treeOpLimits)
+	if err != nil {
+		return "", err
+	}
+
+	rootNode := &treeNode{entry: FileRestoreEntry{Name: root, Path: root, Type: "dir"}}
+	entries, truncated, err := walkRestoreTree(ctx, fileRestoreID, rootNode, maxDepth, maxEntries, filter, nil)
+	if err != nil {
+		return "", err
+	}
+	markTreeMatches(rootNode, filter)
+
+	var lines []string
+	stats := treeStats{}
+	for _, c := range rootNode.children {
+		renderTree(c, 0, &lines, &stats)
+	}
+	result := map[string]interface{}{
+		"file_restore_id": fileRestoreID,
+		"root":            root,
+		"tree":            strings.Join(lines, "\n"),
+		"summary": map[string]interface{}{
+			"directories":     stats.dirs,
+			"files":           stats.files,
+			"total_bytes":     stats.bytes,
+			"total_size":      humanSize(stats.bytes),
+			"entries_browsed": entries,
+			"max_depth":       maxDepth,
+			"unexpanded_dirs": stats.unexpanded,
+			"truncated":       truncated,
+			"max_entries":     maxEntries,
+			"include":         filter.include,
+			"exclude":         filter.exclude,
+			"legend":          "Indented two spaces per level; folders end in '/'. '/ …' marks a folder that was not expanded (max_depth or max_entries).",
+		},
+	}
+	if truncated {
+		result["note"] = fmt.Sprintf("Stopped after %d entries. Raise max_entries, narrow with include/exclude, or start lower with browse_path.", maxEntries)
+	}
+	return formatSingle(result, args, formatCompact)
+}
+
+// treeLimits are an operation's max_depth and max_entries defaults and
+// ceilings.
+type treeLimits struct {
+	depth, entries, maxEntries int
+}
+
+var treeOpLimits = treeLimits{treeDefaultDepth, treeDefaultEntries, treeMaxEntries}
+
+// treeOptions reads max_depth, max_entries, include and exclude.
+func treeOptions(args map[string]interface{}, limits treeLimits) (int, int, treeFilter, error) {
+	maxDepth, ok := optionalInt(args, "max_depth")
+	if !ok || maxDepth <= 0 {
+		maxDepth = limits.depth
+	}
+	maxDepth = min(maxDepth, treeMaxDepth)
+	maxEntries, ok := optionalInt(args, "max_entries")
+	if !ok || maxEntries <= 0 {
+		maxEntries = limits.entries
+	}
+	maxEntries = min(maxEntries, limits.maxEntries)
+
+	var f treeFilter
+	var err error
+	if f.include, err = optionalStrings(args, "include"); err != nil {
+		return 0, 0, f, err
+	}
+	if f.exclude, err = optionalStrings(args, "exclude"); err != nil {
+		return 0, 0, f, err
+	}
+	for _, p := range append(append([]string{}, f.include...), f.exclude...) {
+		if _, err := path.Match(p, ""); err != nil {
+			return 0, 0, f, fmt.Errorf("bad glob %q: %w", p, err)
+		}
+	}
+	return maxDepth, maxEntries, f, nil
+}
+
+// callBudget caps the browse requests a walk may make. A nil budget is
+// unlimited.
+type callBudget struct {
+	left atomic.Int64
+}
+
+func newCallBudget(n int) *callBudget {
+	b := &callBudget{}
+	b.left.Store(int64(n))
+	return b
+}
+
+// take spends one request, reporting false once the budget is gone.
+func (b *callBudget) take() bool {
+	return b == nil || b.left.Add(-1) >= 0
+}
+
+// walkRestoreTree browses root breadth-first to maxDepth levels, keeping
+// at most maxEntries entries and making at most budget's browse requests
+// (one per page of a folder). It returns how many entries were kept and
+// whether the entry cap or the budget cut the walk short; folders it
+// could not list in full are left unexpanded.
+func walkRestoreTree(ctx context.Context, fileRestoreID string, root *treeNode, maxDepth, maxEntries int, filter treeFilter, budget *callBudget) (int, bool, error) {
+	type listing struct {
+		entries []FileRestoreEntry
+		partial bool // budget ran out: entries is missing pages, or empty
+		err     error
+	}
+	frontier := []*treeNode{root}
+	kept := 0
+	truncated := false
+	var browsed atomic.Int32
+	for depth := 0; depth < maxDepth && len(frontier) > 0; depth++ {
+		listings, err := fanOut(ctx, frontier, func(ctx context.Context, n *treeNode) listing {
+			if !budget.take() {
+				return listing{partial: true}
+			}
+			progressStep(ctx, -1, "browsed %d folders (%s)", browsed.Add(1), n.entry.Path)
+			partial := false
+			entries, err := fetchPaginatedWhile(ctx,
+				fmt.Sprintf("/v1/restore/file/%s/browse?path=%s", fileRestoreID, url.QueryEscape(n.entry.Path)),
+				func(FileRestoreEntry) bool {
+					if budget.take() {
+						return true
+					}
+					partial = true
+					return false
+				})
+			return listing{entries, partial, err}
+		})
+		if err != nil {
+			return kept, false, err
+		}
+		var next []*treeNode
+		for i, n := range frontier {
+			if listings[i].err != nil {
+				return kept, false, fmt.Errorf("browse %s: %w", n.entry.Path, listings[i].err)
+			}
+			n.expanded = !listings[i].partial
+			truncated = truncated || listings[i].partial
+			for _, e := range listings[i].entries {
+				child := &treeNode{entry: e, rel: strings.TrimPrefix(path.Join(n.rel, e.Name), "/")}
+				if filter.excluded(child) {
+					continue
+				}
+				if kept == maxEntries {
+					// Leave the rest of this level unexpanded.
+					n.expanded = false
+					return kept, true, nil
+				}
+				kept++
+				n.children = append(n.children, child)
+				if isDirEntry(e) {
+					next = append(next, child)
+				}
+			}
+		}
+		frontier = next
+	}
+	return kept, truncated, nil
+}
+
+// markTreeMatches sets matched bottom-up: files that pass include, and
+// directories with a matching file below them. Unexpanded directories
+// count as matching, since what is inside them is unknown.
+func markTreeMatches(n *treeNode, filter treeFilter) bool {
+	if !isDirEntry(n.entry) {
+		n.matched = filter.included(n)
+		return n.matched
+	}
+	n.matched = !n.expanded || len(filter.include) == 0
+	for _, c := range n.children {
+		if markTreeMatches(c, filter) {
+			n.matched = true
+		}
+	}
+	return n.matched
+}
+
+type treeStats struct {
+	dirs, files, unexpanded int
+	bytes                   int64
+}
+
+// renderTree appends n and its matching descendants to lines.
+func renderTree(n *treeNode, indent int, lines *[]string, stats *treeStats) {
+	if !n.matched {
+		return
+	}
+	pad := strings.Repeat("  ", indent)
+	if isDirEntry(n.entry) {
+		stats.dirs++
+		line := pad + n.entry.Name + "/"
+		if !n.expanded {
+			stats.unexpanded++
+			line += " …"
+		}
+		*lines = append(*lines, line)
+		for _, c := range n.children {
+			renderTree(c, indent+1, lines, stats)
+		}
+		return
+	}
+	stats.files++
+	stats.bytes += n.entry.Size
+	*lines = append(*lines, fmt.Sprintf("%s%s  %s  %s", pad, n.entry.Name, humanSize(n.entry.Size), shortTime(n.entry.ModifiedAt)))
+}
+
+// handleRecoveryTreeImage lists an image export's disks in the tree
+// format. Exports are flat, so there is one level.
+func handleRecoveryTreeImage(ctx context.Context, args map[string]interface{}) (string, error) {
+	imageExportID, err := requireString(args, "image_export_id")
+	if err != nil {
+		return "", err
+	}
+	_, maxEntries, filter, err := treeOptions(args, treeOpLimits)
+	if err != nil {
+		return "", err
+	}
+	entries, err := fetchAllPaginated[ImageExportEntry](ctx, fmt.Sprintf("/v1/restore/image/%s/browse", imageExportID))
+	if err != nil {
+		return "", err
+	}
+	var lines []string
+	var total int64
+	shown := 0
+	for _, e := range entries {
+		n := &treeNode{entry: FileRestoreEntry{Name: e.Name}, rel: e.Name}
+		if filter.excluded(n) || !filter.included(n) {
+			continue
+		}
+		if shown == maxEntries {
+			break
+		}
+		shown++
+		total += e.Size
+		lines = append(lines, fmt.Sprintf("%s  %s  disk_id=%s", e.Name, humanSize(e.Size), e.DiskID))
+	}
+	return formatSingle(map[string]interface{}{
+		"image_export_id": imageExportID,
+		"tree":            strings.Join(lines, "\n"),
+		"summary": map[string]interface{}{
+			"disks":       len(entries),
+			"shown":       shown,
+			"total_bytes": total,
+			"total_size":  humanSize(total),
+			"truncated":   shown < len(entries) && shown == maxEntries,
+		},
+	}, args, formatCompact)
+}
+
+// humanSize renders a byte count in binary units.
+func humanSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// shortTime trims an RFC 3339 time to minutes for the tree listing.
+func shortTime(s string) string {
+	if len(s) >= 16 && s[10] == 'T' {
+		return s[:10] + " " + s[11:16]
+	}
+	return s
+}
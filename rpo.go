@@ -0,0 +1,297 @@
+package main
+
+// slide_backups rpo_report: is every agent inside its recovery point
+// objective?
+//
+// An agent's RPO target is its backup schedule interval, or the caller's
+// rpo_minutes. Only time the schedule allows backups to run counts against
+// it: an agent scheduled 08:00-18:00 Monday to Friday is not out of policy
+// on Saturday morning because Friday's last backup finished at 17:55.
+// Schedules are evaluated in the agent's timezone. A paused agent is
+// reported as paused, and a pause that has since ended counts as
+// allowed-to-miss time up to its paused_until. An agent whose backup
+// schedule is turned off is reported as schedule_paused, not as a breach.
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+// RPO statuses, in report order.
+const (
+	rpoViolation      = "violation"       // more in-window time has passed than the target allows
+	rpoError          = "error"           // the agent's backups could not be fetched
+	rpoNoSchedule     = "no_schedule"     // no schedule and no rpo_minutes to judge against
+	rpoSchedulePaused = "schedule_paused" // the backup schedule is turned off
+	rpoPaused         = "paused"          // backups are paused right now
+	rpoOK             = "ok"
+)
+
+var rpoStatusRank = map[string]int{
+	rpoViolation:      0,
+	rpoError:          1,
+	rpoNoSchedule:     2,
+	rpoSchedulePaused: 3,
+	rpoPaused:         4,
+	rpoOK:             5,
+}
+
+// agentRPOStatus is one agent's line in the rpo_report.
+type agentRPOStatus struct {
+	AgentID             string          `json:"agent_id"`
+	AgentName           string          `json:"agent_name,omitempty"`
+	DeviceID            string          `json:"device_id,omitempty"`
+	ClientID            string          `json:"client_id,omitempty"`
+	Status              string          `json:"status"`
+	TargetMinutes       int             `json:"rpo_target_minutes,omitempty"`
+	TargetSource        string          `json:"rpo_target_source,omitempty"`
+	LastSuccessAt       string          `json:"last_success_at,omitempty"`
+	MinutesSinceSuccess *int            `json:"minutes_since_success,omitempty"`
+	InWindowMinutes     *int            `json:"in_window_minutes,omitempty"`
+	OverdueMinutes      *int            `json:"overdue_minutes,omitempty"`
+	BackupRunning       bool            `json:"backup_running,omitempty"`
+	PausedUntil         string          `json:"paused_until,omitempty"`
+	Schedule            *BackupSchedule `json:"schedule,omitempty"`
+	Timezone            string          `json:"timezone,omitempty"`
+	Note                string          `json:"note,omitempty"`
+}
+
+// handleBackupsRPOReport compares each agent's last successful backup with
+// its RPO target, optionally scoped to one agent, device, or client.
+func handleBackupsRPOReport(ctx context.Context, args map[string]interface{}) (string, error) {
+	override, _ := optionalInt(args, "rpo_minutes")
+	if override < 0 {
+		return "", fmt.Errorf("rpo_minutes must be positive (got %d)", override)
+	}
+	problemsOnly, _ := optionalBool(args, "problems_only")
+
+	agents, scope, err := fetchScopedAgents(ctx, args)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now().UTC()
+	var started atomic.Int32
+	statuses, err := fanOut(ctx, agents, func(ctx context.Context, a Agent) agentRPOStatus {
+		n := int(started.Add(1))
+		progressStep(ctx, len(agents)-n, "checking RPO for agent %d/%d (%s)", n, len(agents), bestAgentName(a))
+		return fetchAgentRPOStatus(ctx, a, override, now)
+	})
+	if err != nil {
+		return "", err
+	}
+
+	summary := map[string]int{}
+	worstOverdue := 0
+	report := make([]agentRPOStatus, 0, len(statuses))
+	for _, s := range statuses {
+		summary[s.Status]++
+		if s.OverdueMinutes != nil && *s.OverdueMinutes > worstOverdue {
+			worstOverdue = *s.OverdueMinutes
+		}
+		if problemsOnly && (s.Status == rpoOK || s.Status == rpoPaused || s.Status == rpoSchedulePaused) {
+			continue
+		}
+		report = append(report, s)
+	}
+	sort.SliceStable(report, func(i, j int) bool { return worseRPO(report[i], report[j]) })
+
+	result := map[string]interface{}{
+		"scope":         scope,
+		"problems_only": problemsOnly,
+		"summary": map[string]interface{}{
+			"agents":                len(statuses),
+			"violations":            summary[rpoViolation],
+			"by_status":             summary,
+			"worst_overdue_minutes": worstOverdue,
+			"as_of":                 now.Format(time.RFC3339),
+		},
+		"agents": report,
+	}
+	if override > 0 {
+		result["rpo_minutes"] = override
+	}
+	return formatSingle(result, args, formatCompact)
+}
+
+// fetchAgentRPOStatus looks up a's most recent successful backup and
+// judges it against the target.
+func fetchAgentRPOStatus(ctx context.Context, a Agent, override int, now time.Time) agentRPOStatus {
+	s := agentRPOStatus{AgentID: a.AgentID, AgentName: bestAgentName(a), DeviceID: a.DeviceID, Schedule: a.BackupSchedule}
+	if a.ClientID != nil {
+		s.ClientID = *a.ClientID
+	}
+	data, err := makeAPIRequest(ctx, "GET", fmt.Sprintf("/v1/backup?agent_id=%s&limit=50&sort_by=start_time", a.AgentID), nil)
+	if err != nil {
+		s.Status, s.Note = rpoError, backupFetchErrorStatus
+		return s
+	}
+	var p PaginatedResponse[Backup]
+	if err := json.Unmarshal(data, &p); err != nil {
+		s.Status, s.Note = rpoError, "unparseable backup list"
+		return s
+	}
+	return evaluateRPO(s, a, p.Data, override, now)
+}
+
+// evaluateRPO fills in the verdict for one agent given its recent backups.
+func evaluateRPO(s agentRPOStatus, a Agent, backups []Backup, override int, now time.Time) agentRPOStatus {
+	var lastSuccess time.Time
+	for _, b := range backups {
+		switch b.Status {
+		case "succeeded":
+		case "failed":
+			continue
+		default:
+			s.BackupRunning = true
+			continue
+		}
+		if b.EndedAt == nil {
+			continue
+		}
+		if t, err := time.Parse(time.RFC3339, *b.EndedAt); err == nil && t.After(lastSuccess) {
+			lastSuccess = t
+		}
+	}
+	if !lastSuccess.IsZero() {
+		s.LastSuccessAt = lastSuccess.Format(time.RFC3339)
+		since := int(now.Sub(lastSuccess).Minutes())
+		s.MinutesSinceSuccess = &since
+	}
+
+	switch {
+	case override > 0:
+		s.TargetMinutes, s.TargetSource = override, "rpo_minutes"
+	case a.BackupSchedule != nil && a.BackupSchedule.IntervalInMinutes > 0:
+		s.TargetMinutes, s.TargetSource = a.BackupSchedule.IntervalInMinutes, "schedule"
+	}
+
+	loc := time.UTC
+	if a.Timezone != nil && *a.Timezone != "" {
+		if l, err := time.LoadLocation(*a.Timezone); err == nil {
+			loc = l
+		} else {
+			s.Note = fmt.Sprintf("unknown timezone %q; schedule evaluated in UTC", *a.Timezone)
+		}
+	}
+	s.Timezone = loc.String()
+
+	// A pause that has ended excuses everything up to its end; a current
+	// one excuses everything.
+	from := lastSuccess
+	if a.BackupPausedIndefinite != nil && *a.BackupPausedIndefinite {
+		s.Status = rpoPaused
+		return s
+	}
+	if a.BackupPausedUntil != nil && *a.BackupPausedUntil != "" {
+		if until, err := time.Parse(time.RFC3339, *a.BackupPausedUntil); err == nil {
+			if until.After(now) {
+				s.Status, s.PausedUntil = rpoPaused, *a.BackupPausedUntil
+				return s
+			}
+			if !from.IsZero() && until.After(from) {
+				from = until
+			}
+		}
+	}
+	if a.BackupScheduleActive != nil && !*a.BackupScheduleActive {
+		s.Status = rpoSchedulePaused
+		return s
+	}
+
+	if s.TargetMinutes == 0 {
+		s.Status = rpoNoSchedule
+		if s.Note == "" {
+			s.Note = "no backup schedule; pass rpo_minutes to check this agent"
+		}
+		return s
+	}
+	if lastSuccess.IsZero() {
+		s.Status = rpoViolation
+		if s.Note == "" {
+			s.Note = fmt.Sprintf("no successful backup in the last %d runs", len(backups))
+		}
+		return s
+	}
+
+	elapsed := int(scheduleWindowMinutes(a.BackupSchedule, loc, from, now))
+	s.InWindowMinutes = &elapsed
+	s.Status = rpoOK
+	if overdue := elapsed - s.TargetMinutes; overdue > 0 {
+		s.Status, s.OverdueMinutes = rpoViolation, &overdue
+	}
+	return s
+}
+
+// scheduleWindowMinutes is the number of minutes in [from, to) that fall
+// inside sched's backup window, evaluated in loc. The window opens at
+// start_hour on each listed day and closes at end_hour, running past
+// midnight when end_hour <= start_hour (so equal hours mean all day). A
+// nil schedule or one with no days places no restriction.
+func scheduleWindowMinutes(sched *BackupSchedule, loc *time.Location, from, to time.Time) float64 {
+	if !to.After(from) {
+		return 0
+	}
+	if sched == nil || len(sched.Days) == 0 {
+		return to.Sub(from).Minutes()
+	}
+	days := make(map[time.Weekday]bool, len(sched.Days))
+	for _, d := range sched.Days {
+		days[time.Weekday(d)] = true
+	}
+
+	// Start a day early so a window that opened the evening before from
+	// and runs past midnight is counted.
+	f := from.In(loc)
+	var total time.Duration
+	for day := time.Date(f.Year(), f.Month(), f.Day()-1, 0, 0, 0, 0, loc); day.Before(to); day = day.AddDate(0, 0, 1) {
+		if !days[day.Weekday()] {
+			continue
+		}
+		open := time.Date(day.Year(), day.Month(), day.Day(), sched.StartHour, 0, 0, 0, loc)
+		closeDay := day.Day()
+		if sched.EndHour <= sched.StartHour {
+			closeDay++
+		}
+		closing := time.Date(day.Year(), day.Month(), closeDay, sched.EndHour, 0, 0, 0, loc)
+		if open.Before(from) {
+			open = from
+		}
+		if closing.After(to) {
+			closing = to
+		}
+		if closing.After(open) {
+			total += closing.Sub(open)
+		}
+	}
+	return total.Minutes()
+}
+
+// worseRPO orders report lines: violations first, most overdue (or never
+// backed up) at the top, then errors, unjudgeable, paused, and compliant
+// agents, each by name.
+func worseRPO(a, b agentRPOStatus) bool {
+	if rpoStatusRank[a.Status] != rpoStatusRank[b.Status] {
+		return rpoStatusRank[a.Status] < rpoStatusRank[b.Status]
+	}
+	if a.Status == rpoViolation {
+		oa, ob := -1, -1
+		if a.OverdueMinutes != nil {
+			oa = *a.OverdueMinutes
+		}
+		if b.OverdueMinutes != nil {
+			ob = *b.OverdueMinutes
+		}
+		if (oa < 0) != (ob < 0) {
+			return oa < 0
+		}
+		if oa != ob {
+			return oa > ob
+		}
+	}
+	return a.AgentName < b.AgentName
+}
@@ -1,9 +1,12 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"log"
+	"os"
+	"os/signal"
 
 	"github.com/mark3labs/mcp-go/server"
 )
@@ -22,6 +25,7 @@ func buildMCPServer() (*server.MCPServer, error) {
 		server.WithRecovery(),
 		server.WithInstructions(serverInstructions()),
 		server.WithToolFilter(toolFilterForMode()),
+		server.WithHooks(sessionHooks()),
 	)
 
 	if err := registerTools(srv); err != nil {
@@ -39,7 +43,7 @@ func runStdioServer() error {
 	if err != nil {
 		return err
 	}
-	log.Printf("%s %s ready on stdio (mode=%s)", ServerName, Version, config.ToolsMode)
+	log.Printf("%s %s ready on stdio (mode=%s)", ServerName, Version, currentConfig().ToolsMode)
 	return server.ServeStdio(srv)
 }
 
@@ -50,8 +54,8 @@ func runStdioServer() error {
 // loudly route the model toward this server on first-mention.
 func serverInstructions() string {
 	mode := ""
-	if config != nil {
-		mode = config.ToolsMode
+	if cfg := currentConfig(); cfg != nil {
+		mode = cfg.ToolsMode
 	}
 	modeLine := "Active permission tier: " + mode + "."
 	switch mode {
@@ -95,6 +99,7 @@ Tool families:
 - slide_audit    -> account audit log queries (compliance / "what changed?").
 - slide_clients / slide_admin                                -> client + user/account management.
 - slide_devices / slide_agents / slide_snapshots / slide_backups / slide_alerts -> lower-level CRUD with task-oriented additions (triage, status_for_client, recent_for_agent).
+- slide_jobs     -> background jobs: long backups, exports, and pushes started with background=true.
 
 Prompts (slash-command UI):
 - slide.welcome          -> one-message intro for first-time users.
@@ -107,9 +112,23 @@ Prompts (slash-command UI):
 Worked examples (user question -> first tool call you should make):
 - "Are all my Slide boxes healthy?"                        -> slide_overview operation=health
 - "Did backups run last night for ACME?"                   -> slide_backups operation=status_for_client client_id=...
+- "Did backups run last night across everything?"          -> slide_backups operation=status_all problems_only=true
+- "Is anything out of RPO policy?"                         -> slide_backups operation=rpo_report problems_only=true
+- "Do we have a restore point for every day this month?"   -> slide_snapshots operation=calendar name_hint=...
+- "Would our servers actually boot if we needed them?"      -> slide_snapshots operation=verification_report problems_only=true
 - "Find Q4-budget.xlsx on Bob's laptop"                    -> slide_files operation=search name_hint=Bob search_term=Q4-budget
+- "Somebody at ACME had the contract PDF"                  -> slide_files operation=search_client name_hint=ACME search_term=contract
 - "I need yesterday's version of <file>"                   -> slide_files operation=versions ...
+- "What's in Bob's Documents folder in that restore?"      -> slide_files operation=tree file_restore_id=... browse_path=/C:/Users/bob/Documents
+- "What changed in web.config since last week?"            -> slide_files operation=diff_versions name_hint=... path=...
+- "What changed on FS01 since last night? Ransomware?"     -> slide_files operation=diff_snapshots name_hint=FS01 from_snapshot_id=... to_snapshot_id=...
+- "Ransomware - which snapshot is the last clean one?"     -> slide_snapshots operation=change_anomalies name_hint=... browse_path=/D:/Shares
+- "Restore Tuesday's Q4-budget.xlsx to Bob's laptop"       -> slide_files operation=recover_file name_hint=Bob search_term=Q4-budget.xlsx date=YYYY-MM-DD
 - "Boot a recovery VM for <server>"                        -> slide_recovery operation=boot_vm ...
+- "Boot it and tell me when it's up"                       -> slide_recovery operation=boot_vm ... wait=true
+- "Export it as VHDX; I'll check back later"               -> slide_recovery operation=export_image ... background=true
+- "Is that export done yet?"                               -> slide_jobs operation=list
+- "Run a DR test for ACME's servers"                       -> slide_recovery operation=dr_drill client_id=...
 - "What unresolved alerts do I have?"                      -> slide_alerts operation=triage
 - "What changed in the last 24 hours?"                     -> slide_audit operation=recent
 
@@ -124,17 +143,22 @@ func runOneShotTool(name string, args map[string]interface{}) error {
 	if args == nil {
 		args = map[string]interface{}{}
 	}
-	if config.IsToolDisabled(name) {
+	cfg := currentConfig()
+	if cfg.IsToolDisabled(name) {
 		return fmt.Errorf("tool '%s' is disabled", name)
 	}
-	if !config.IsToolAllowed(name) {
-		return fmt.Errorf("tool '%s' not available in '%s' mode", name, config.ToolsMode)
+	if !cfg.IsToolAllowed(name) {
+		return fmt.Errorf("tool '%s' not available in '%s' mode", name, cfg.ToolsMode)
 	}
 	handler, ok := toolRegistry[name]
 	if !ok {
 		return fmt.Errorf("unknown tool: %s", name)
 	}
-	result := createToolResult(handler(args))
+	// Ctrl-C aborts the in-flight Slide requests instead of leaving them
+	// to the operation timeout.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	result := createToolResult(handler(ctx, args))
 	out, err := json.MarshalIndent(result, "", "  ")
 	if err != nil {
 		return fmt.Errorf("encode result: %w", err)
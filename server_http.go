@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// HTTP endpoint layout. Streamable HTTP is the primary transport; the
+// legacy SSE pair stays mounted alongside it for hosts that predate the
+// 2025-03-26 protocol revision.
+const (
+	httpStreamablePath = "/mcp"
+	httpSSEPath        = "/sse"
+	httpMessagePath    = "/message"
+
+	httpShutdownTimeout = 10 * time.Second
+)
+
+// httpTransport bundles the two SDK transports that share one MCPServer so
+// shutdown can close long-lived SSE streams before draining the listener.
+type httpTransport struct {
+	streamable *server.StreamableHTTPServer
+	sse        *server.SSEServer
+	mux        *http.ServeMux
+}
+
+// newHTTPTransport mounts the Streamable HTTP and SSE transports on a single
+// mux. Both wrap the same *server.MCPServer, so the tool filter, permission
+// tiers, and schema validation installed by buildMCPServer apply unchanged.
+func newHTTPTransport(srv *server.MCPServer) *httpTransport {
+	t := &httpTransport{
+		streamable: server.NewStreamableHTTPServer(srv,
+			server.WithEndpointPath(httpStreamablePath),
+			server.WithStateful(true),
+			server.WithSessionIdleTTL(apiSessionIdleTTL),
+			server.WithHTTPContextFunc(withRequestToken),
+		),
+		sse: server.NewSSEServer(srv,
+			server.WithSSEEndpoint(httpSSEPath),
+			server.WithMessageEndpoint(httpMessagePath),
+			server.WithUseFullURLForMessageEndpoint(false),
+			server.WithKeepAlive(true),
+			server.WithSSEContextFunc(withRequestToken),
+		),
+		mux: http.NewServeMux(),
+	}
+	t.mux.Handle(httpStreamablePath, t.streamable)
+	t.mux.Handle(httpSSEPath, t.sse.SSEHandler())
+	t.mux.Handle(httpMessagePath, t.sse.MessageHandler())
+	return t
+}
+
+// ServeHTTP rejects unauthenticated requests up front. When the server has
+// no token of its own (multi-tenant mode) every caller must bring its own
+// Slide token as `Authorization: Bearer <token>`. When it has one and
+// --http-auth-token is set, callers without a Slide token must send that
+// secret instead before they run on the server's token.
+func (t *httpTransport) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ownToken := bearerToken(r) != "" && !sharedSecretBearer(r)
+	switch {
+	case ownToken:
+	case processSession.token() == "":
+		httpUnauthorized(w, "Slide API token required: send Authorization: Bearer <token>")
+		return
+	case currentConfig().HTTPAuthToken != "" && !sharedSecretBearer(r):
+		httpUnauthorized(w, "authentication required: send Authorization: Bearer with the server's --http-auth-token or your own Slide token")
+		return
+	}
+	t.mux.ServeHTTP(w, r)
+}
+
+func httpUnauthorized(w http.ResponseWriter, msg string) {
+	w.Header().Set("WWW-Authenticate", `Bearer realm="slide-mcp-server"`)
+	http.Error(w, msg, http.StatusUnauthorized)
+}
+
+// runHTTPServer serves the MCP server over Streamable HTTP (with SSE
+// fallback) on addr. Blocks until the listener fails or the process
+// receives SIGINT/SIGTERM, then shuts down gracefully.
+func runHTTPServer(addr string) error {
+	srv, err := buildMCPServer()
+	if err != nil {
+		return err
+	}
+	transport := newHTTPTransport(srv)
+	httpSrv := &http.Server{
+		Addr:              addr,
+		Handler:           transport,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	errCh := make(chan error, 1)
+	go func() { errCh <- httpSrv.ListenAndServe() }()
+	tenancy := "shared token"
+	switch {
+	case processSession.token() == "":
+		tenancy = "per-request tokens"
+	case currentConfig().HTTPAuthToken == "":
+		tenancy = "shared token, no auth: loopback only"
+	}
+	log.Printf("%s %s ready on http://%s (streamable=%s sse=%s, mode=%s, %s)",
+		ServerName, Version, addr, httpStreamablePath, httpSSEPath, currentConfig().ToolsMode, tenancy)
+
+	select {
+	case err := <-errCh:
+		if errors.Is(err, http.ErrServerClosed) {
+			return nil
+		}
+		return err
+	case <-ctx.Done():
+	}
+
+	log.Printf("Shutting down HTTP transport...")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), httpShutdownTimeout)
+	defer cancel()
+	transport.sse.CloseSessions()
+	if err := transport.streamable.Shutdown(shutdownCtx); err != nil {
+		log.Printf("streamable shutdown: %v", err)
+	}
+	return httpSrv.Shutdown(shutdownCtx)
+}
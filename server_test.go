@@ -1,30 +1,42 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
 	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 // setupTestEnv configures a global config + apiKey suitable for tests that
 // don't need to hit the real API (or use httptest for the API).
 func setupTestEnv(t *testing.T, mode string) {
 	t.Helper()
-	config = NewServerConfig()
-	config.APIKey = "tk_test"
+	cfg := NewServerConfig()
+	cfg.APIKey = "tk_test"
 	if mode != "" {
-		config.ToolsMode = mode
+		cfg.ToolsMode = mode
 	}
-	if err := config.ValidateToolsMode(); err != nil {
+	if err := cfg.ValidateToolsMode(); err != nil {
 		t.Fatalf("validate mode: %v", err)
 	}
-	APIBaseURL = config.BaseURL
-	apiKey = config.APIKey
+	setConfig(cfg)
+	APIBaseURL = cfg.BaseURL
+	apiKey = cfg.APIKey
 }
 
 // TestToolsListContents asserts every v4 tool is registered and the
@@ -83,7 +95,7 @@ func TestToolsListContents(t *testing.T) {
 		"list_all_clients_devices_and_agents",
 		"slide_admin", "slide_agents", "slide_alerts", "slide_audit", "slide_backups",
 		"slide_clients", "slide_devices", "slide_files", "slide_help", "slide_overview",
-		"slide_recovery", "slide_snapshots",
+		"slide_jobs", "slide_recovery", "slide_snapshots",
 	}
 	for _, name := range expectedTools {
 		if _, ok := got[name]; !ok {
@@ -94,13 +106,14 @@ func TestToolsListContents(t *testing.T) {
 	// Spot-check the new task-oriented operations are reachable.
 	wantOps := map[string][]string{
 		"slide_help":      {"getting_started", "examples", "glossary", "troubleshoot", "what_can_you_do"},
-		"slide_files":     {"search", "versions", "create_restore", "browse", "create_push"},
+		"slide_files":     {"search", "search_client", "versions", "diff_versions", "diff_snapshots", "create_restore", "browse", "tree", "download", "create_push", "recover_file"},
 		"slide_audit":     {"list", "get", "actions", "resources", "recent"},
 		"slide_overview":  {"inventory", "health", "for_client", "for_device"},
-		"slide_recovery":  {"boot_vm", "export_image", "create_network", "create_wg_peer"},
-		"slide_backups":   {"status_for_client", "status_for_device", "recent_for_agent"},
+		"slide_recovery":  {"boot_vm", "dr_drill", "export_image", "tree_image", "download_image", "create_network", "create_wg_peer"},
+		"slide_backups":   {"status_for_client", "status_for_device", "status_all", "rpo_report", "recent_for_agent"},
 		"slide_alerts":    {"triage"},
-		"slide_snapshots": {"recent_for_agent", "get_service_verification"},
+		"slide_jobs":      {"list", "get", "cancel"},
+		"slide_snapshots": {"recent_for_agent", "get_service_verification", "calendar", "verification_report", "change_anomalies"},
 	}
 	for tool, ops := range wantOps {
 		enum := got[tool]
@@ -256,7 +269,7 @@ func TestSlideFilesSearchHTTP(t *testing.T) {
 	setupTestEnv(t, ToolsFull)
 	APIBaseURL = srv.URL
 
-	out, err := handleFilesSearch(map[string]interface{}{
+	out, err := handleFilesSearch(context.Background(), map[string]interface{}{
 		"agent_id":    "a_test",
 		"search_term": "budget",
 	})
@@ -286,7 +299,7 @@ func TestSlideAuditRecentHTTP(t *testing.T) {
 	setupTestEnv(t, ToolsFull)
 	APIBaseURL = srv.URL
 
-	out, err := handleAuditRecent(map[string]interface{}{"hours": float64(24)})
+	out, err := handleAuditRecent(context.Background(), map[string]interface{}{"hours": float64(24)})
 	if err != nil {
 		t.Fatalf("handleAuditRecent: %v", err)
 	}
@@ -324,7 +337,7 @@ func TestRetryAfterParsing(t *testing.T) {
 // TestOneShotPermissionDenied confirms tool-level disablement works.
 func TestOneShotPermissionDenied(t *testing.T) {
 	setupTestEnv(t, ToolsFull)
-	config.SetDisabledTools("slide_devices")
+	currentConfig().SetDisabledTools("slide_devices")
 
 	if err := runOneShotTool("slide_devices", map[string]interface{}{
 		"operation": "list",
@@ -356,7 +369,7 @@ func TestNextStepsHintsInjected(t *testing.T) {
 	resetNameCache()
 
 	t.Run("hints on by default", func(t *testing.T) {
-		out, err := handleFilesTool(map[string]interface{}{
+		out, err := handleFilesTool(context.Background(), map[string]interface{}{
 			"operation":   "search",
 			"agent_id":    "a_test",
 			"search_term": "anything",
@@ -373,7 +386,7 @@ func TestNextStepsHintsInjected(t *testing.T) {
 	})
 
 	t.Run("hints=off suppresses next_steps", func(t *testing.T) {
-		out, err := handleFilesTool(map[string]interface{}{
+		out, err := handleFilesTool(context.Background(), map[string]interface{}{
 			"operation":   "search",
 			"agent_id":    "a_test",
 			"search_term": "anything",
@@ -412,7 +425,7 @@ func TestResolvedBlockAppended(t *testing.T) {
 	APIBaseURL = srv.URL
 	resetNameCache()
 
-	out, err := handleFilesTool(map[string]interface{}{
+	out, err := handleFilesTool(context.Background(), map[string]interface{}{
 		"operation":   "search",
 		"name_hint":   "unique",
 		"search_term": "anything",
@@ -454,7 +467,7 @@ func TestSlideFilesSearchWithNameHintHTTP(t *testing.T) {
 	APIBaseURL = srv.URL
 	resetNameCache()
 
-	out, err := handleFilesTool(map[string]interface{}{
+	out, err := handleFilesTool(context.Background(), map[string]interface{}{
 		"operation":   "search",
 		"name_hint":   "bob",
 		"search_term": "budget",
@@ -489,7 +502,7 @@ func TestSlideFilesSearchAmbiguousNameHint(t *testing.T) {
 	APIBaseURL = srv.URL
 	resetNameCache()
 
-	out, err := handleFilesTool(map[string]interface{}{
+	out, err := handleFilesTool(context.Background(), map[string]interface{}{
 		"operation":   "search",
 		"name_hint":   "bob",
 		"search_term": "budget",
@@ -606,7 +619,7 @@ func TestNameResolverHTTP(t *testing.T) {
 	t.Run("pass-through agent_id", func(t *testing.T) {
 		resetNameCache()
 		args := map[string]interface{}{"agent_id": "a_zzzzzzzzzzzz"}
-		resp, err := resolveNameHint(args, ResolutionSpec{IDKey: "agent_id", Kind: "agent"})
+		resp, err := resolveNameHint(context.Background(), args, ResolutionSpec{IDKey: "agent_id", Kind: "agent"})
 		if err != nil {
 			t.Fatalf("err: %v", err)
 		}
@@ -621,7 +634,7 @@ func TestNameResolverHTTP(t *testing.T) {
 	t.Run("single match resolves and writes back", func(t *testing.T) {
 		resetNameCache()
 		args := map[string]interface{}{"name_hint": "File"}
-		resp, err := resolveNameHint(args, ResolutionSpec{IDKey: "agent_id", Kind: "agent"})
+		resp, err := resolveNameHint(context.Background(), args, ResolutionSpec{IDKey: "agent_id", Kind: "agent"})
 		if err != nil {
 			t.Fatalf("err: %v", err)
 		}
@@ -636,7 +649,7 @@ func TestNameResolverHTTP(t *testing.T) {
 	t.Run("ambiguous match returns candidates", func(t *testing.T) {
 		resetNameCache()
 		args := map[string]interface{}{"name_hint": "Bob"}
-		resp, err := resolveNameHint(args, ResolutionSpec{IDKey: "agent_id", Kind: "agent"})
+		resp, err := resolveNameHint(context.Background(), args, ResolutionSpec{IDKey: "agent_id", Kind: "agent"})
 		if err != nil {
 			t.Fatalf("err: %v", err)
 		}
@@ -658,7 +671,7 @@ func TestNameResolverHTTP(t *testing.T) {
 	t.Run("no match returns suggestion", func(t *testing.T) {
 		resetNameCache()
 		args := map[string]interface{}{"name_hint": "doesnotexist-xyz"}
-		resp, err := resolveNameHint(args, ResolutionSpec{IDKey: "agent_id", Kind: "agent"})
+		resp, err := resolveNameHint(context.Background(), args, ResolutionSpec{IDKey: "agent_id", Kind: "agent"})
 		if err != nil {
 			t.Fatalf("err: %v", err)
 		}
@@ -670,7 +683,7 @@ func TestNameResolverHTTP(t *testing.T) {
 	t.Run("looks-like-ID name_hint passes through", func(t *testing.T) {
 		resetNameCache()
 		args := map[string]interface{}{"name_hint": "a_zzzzzzzzzzzz"}
-		resp, err := resolveNameHint(args, ResolutionSpec{IDKey: "agent_id", Kind: "agent"})
+		resp, err := resolveNameHint(context.Background(), args, ResolutionSpec{IDKey: "agent_id", Kind: "agent"})
 		if err != nil {
 			t.Fatalf("err: %v", err)
 		}
@@ -685,7 +698,7 @@ func TestNameResolverHTTP(t *testing.T) {
 	t.Run("empty input returns nothing", func(t *testing.T) {
 		resetNameCache()
 		args := map[string]interface{}{}
-		resp, err := resolveNameHint(args, ResolutionSpec{IDKey: "agent_id", Kind: "agent"})
+		resp, err := resolveNameHint(context.Background(), args, ResolutionSpec{IDKey: "agent_id", Kind: "agent"})
 		if err != nil {
 			t.Fatalf("err: %v", err)
 		}
@@ -810,3 +823,1782 @@ func sortedKeys(m map[string][]string) []string {
 	sort.Strings(keys)
 	return keys
 }
+
+// TestProfileResolution covers defaults < profile < flag/env precedence
+// and the error paths of the profile file.
+func TestProfileResolution(t *testing.T) {
+	path := t.TempDir() + "/config.yaml"
+	yaml := `default_profile: ours
+profiles:
+  ours:
+    api_key_env: SLIDE_TEST_OURS_KEY
+    tools: full
+  partner:
+    api_key: tk_partner
+    base_url: https://partner.example.com
+    tools: reporting
+    disabled_tools: [slide_admin]
+`
+	if err := os.WriteFile(path, []byte(yaml), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("SLIDE_TEST_OURS_KEY", "tk_ours")
+
+	pf, err := loadProfiles(path, true)
+	if err != nil {
+		t.Fatalf("loadProfiles: %v", err)
+	}
+	if pf.DefaultProfile != "ours" || strings.Join(pf.names(), ",") != "ours,partner" {
+		t.Fatalf("unexpected profile file: %+v", pf)
+	}
+
+	c, err := pf.resolveConfig("ours", configOverrides{})
+	if err != nil {
+		t.Fatalf("resolve ours: %v", err)
+	}
+	if c.APIKey != "tk_ours" || c.ToolsMode != ToolsFull || c.Profile != "ours" {
+		t.Errorf("ours resolved to %+v", c)
+	}
+
+	c, err = pf.resolveConfig("partner", configOverrides{ToolsMode: "safe"})
+	if err != nil {
+		t.Fatalf("resolve partner: %v", err)
+	}
+	if c.APIKey != "tk_partner" || c.BaseURL != "https://partner.example.com" || c.ToolsMode != "safe" {
+		t.Errorf("override not applied over profile: %+v", c)
+	}
+	if !c.IsToolDisabled("slide_admin") {
+		t.Error("profile disabled_tools not applied")
+	}
+
+	if _, err := pf.resolveConfig("nope", configOverrides{}); err == nil || !strings.Contains(err.Error(), "ours, partner") {
+		t.Errorf("unknown profile should list available ones, got %v", err)
+	}
+
+	t.Setenv("SLIDE_TEST_OURS_KEY", "")
+	if _, err := pf.resolveConfig("ours", configOverrides{}); err == nil {
+		t.Error("unset api_key_env should fail")
+	}
+	if c, err := pf.resolveConfig("ours", configOverrides{APIKey: "tk_flag"}); err != nil || c.APIKey != "tk_flag" {
+		t.Errorf("pinned token should win without reading api_key_env: %v %+v", err, c)
+	}
+
+	if _, err := loadProfiles(t.TempDir()+"/missing.yaml", false); err != nil {
+		t.Errorf("missing default config file should be ignored: %v", err)
+	}
+	if _, err := loadProfiles(t.TempDir()+"/missing.yaml", true); err == nil {
+		t.Error("missing --config file should fail")
+	}
+}
+
+// TestSwitchProfileCapsTier checks that switch_profile swaps the identity,
+// drops cached names, and never raises the tier above the startup one or
+// re-enables a tool disabled at startup.
+func TestSwitchProfileCapsTier(t *testing.T) {
+	setupTestEnv(t, ToolsSafe)
+	saved := profileRuntime
+	t.Cleanup(func() { profileRuntime = saved })
+
+	profileRuntime.path = "test.yaml"
+	profileRuntime.file = &profileFile{Profiles: map[string]Profile{
+		"admin":  {APIKey: "tk_admin", Tools: "full", DisabledTools: []string{"slide_clients"}},
+		"viewer": {APIKey: "tk_viewer", Tools: "read-only"},
+	}}
+	profileRuntime.overrides = configOverrides{}
+	profileRuntime.ceiling = ToolsSafe
+	profileRuntime.disabled = []string{"slide_audit"}
+	processSession.clients.reset()
+	processSession.clients.clients["c_1"] = Client{ClientID: "c_1", Name: "Stale Client"}
+
+	next, capped, err := switchProfile("admin")
+	if err != nil {
+		t.Fatalf("switchProfile: %v", err)
+	}
+	if !capped || next.ToolsMode != ToolsSafe || currentConfig().ToolsMode != ToolsSafe {
+		t.Errorf("tier should be capped at safe, got %q capped=%v", currentConfig().ToolsMode, capped)
+	}
+	if apiKey != "tk_admin" || currentConfig().Profile != "admin" {
+		t.Errorf("identity not swapped: apiKey=%q profile=%q", apiKey, currentConfig().Profile)
+	}
+	if len(processSession.clients.clients) != 0 {
+		t.Error("client cache should be dropped on switch")
+	}
+	if !currentConfig().IsToolDisabled("slide_audit") || !currentConfig().IsToolDisabled("slide_clients") {
+		t.Errorf("disabled tools = %v, want the startup and profile sets combined", currentConfig().DisabledTools)
+	}
+
+	if _, capped, err := switchProfile("viewer"); err != nil || capped || currentConfig().ToolsMode != ToolsReadOnly {
+		t.Errorf("downgrade should apply as-is: err=%v capped=%v mode=%q", err, capped, currentConfig().ToolsMode)
+	}
+	if disabled := currentConfig().DisabledTools; len(disabled) != 1 || disabled[0] != "slide_audit" {
+		t.Errorf("disabled tools after switching to viewer = %v, want only the startup slide_audit", disabled)
+	}
+
+	profileRuntime.overrides.APIKey = "tk_pinned"
+	if _, _, err := switchProfile("admin"); err == nil {
+		t.Error("switching must be refused while the token is pinned")
+	}
+}
+
+// TestSwitchProfileDuringToolCalls runs switch_profile while other tool
+// calls are in flight; under -race it catches unsynchronised reads of the
+// process configuration.
+func TestSwitchProfileDuringToolCalls(t *testing.T) {
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"backup_id":"b_1"}`))
+	}))
+	defer api.Close()
+	setupTestEnv(t, ToolsSafe)
+	APIBaseURL = api.URL
+	saved := profileRuntime
+	t.Cleanup(func() { profileRuntime = saved })
+	profileRuntime.path = "test.yaml"
+	profileRuntime.file = &profileFile{Profiles: map[string]Profile{
+		"one": {APIKey: "tk_one", BaseURL: api.URL, Tools: "safe"},
+		"two": {APIKey: "tk_two", BaseURL: api.URL, Tools: "read-only"},
+	}}
+	profileRuntime.overrides = configOverrides{}
+	profileRuntime.ceiling = ToolsSafe
+
+	srv, err := buildMCPServer()
+	if err != nil {
+		t.Fatalf("buildMCPServer: %v", err)
+	}
+	call := func(id int, tool, args string) {
+		srv.HandleMessage(context.Background(), []byte(fmt.Sprintf(`{"jsonrpc":"2.0","id":%d,"method":"tools/call","params":{"name":%q,"arguments":%s}}`, id, tool, args)))
+	}
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 20; i++ {
+			switchProfile([]string{"one", "two"}[i%2])
+			call(i, "slide_admin", `{"operation":"switch_profile","profile":"one"}`)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 20; i++ {
+			call(100+i, "slide_help", `{"operation":"what_can_you_do"}`)
+			call(200+i, "slide_backups", `{"operation":"start","agent_id":"a_1"}`)
+		}
+	}()
+	wg.Wait()
+	if cfg := currentConfig(); cfg.Profile != "one" || cfg.ToolsMode != ToolsSafe {
+		t.Errorf("final profile = %q (%s), want one (safe)", cfg.Profile, cfg.ToolsMode)
+	}
+}
+
+// TestTokenSources covers SLIDE_API_KEY_FILE permission checks and
+// SLIDE_API_KEY_COMMAND, plus the same sources inside a profile.
+func TestTokenSources(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("mode-bit checks and sh are POSIX-only")
+	}
+	dir := t.TempDir()
+	path := dir + "/token"
+	if err := os.WriteFile(path, []byte("tk_from_file\nnotes: ignored\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := (tokenSources{File: path}).resolve(); err == nil || !strings.Contains(err.Error(), "chmod 600") {
+		t.Errorf("world-readable token file should be refused, got %v", err)
+	}
+	if err := os.Chmod(path, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	token, kind, err := (tokenSources{File: path, Command: "false"}).resolve()
+	if err != nil || token != "tk_from_file" || kind != "file" {
+		t.Errorf("file source: token=%q kind=%q err=%v", token, kind, err)
+	}
+
+	token, kind, err = (tokenSources{Command: "printf 'tk_from_cmd\\n'"}).resolve()
+	if err != nil || token != "tk_from_cmd" || kind != "command" {
+		t.Errorf("command source: token=%q kind=%q err=%v", token, kind, err)
+	}
+	if _, _, err := (tokenSources{Command: "echo tk_leak; exit 3"}).resolve(); err == nil || strings.Contains(err.Error(), "tk_leak") {
+		t.Errorf("failing command should error without echoing stdout, got %v", err)
+	}
+	if _, _, err := (tokenSources{Command: "true"}).resolve(); err == nil {
+		t.Error("empty command output should be rejected")
+	}
+
+	pf := &profileFile{Profiles: map[string]Profile{
+		"vault": {APIKeyCommand: "echo tk_vault"},
+	}}
+	c, err := pf.resolveConfig("vault", configOverrides{})
+	if err != nil || c.APIKey != "tk_vault" || c.APIKeySource != "profile vault (api_key_command echo)" {
+		t.Errorf("profile command source: %+v err=%v", c, err)
+	}
+}
+
+// TestBackupsStatusAllHTTP checks the account-wide rollup groups agents by
+// client and device, puts the worst first, keeps agents whose backups
+// could not be read, and honours problems_only.
+func TestBackupsStatusAllHTTP(t *testing.T) {
+	recent := time.Now().UTC().Add(-2 * time.Hour).Format(time.RFC3339)
+	older := time.Now().UTC().Add(-3 * time.Hour).Format(time.RFC3339)
+	stale := time.Now().UTC().Add(-72 * time.Hour).Format(time.RFC3339)
+	backups := map[string]string{
+		"a_ok":   `[{"backup_id":"b1","started_at":"` + recent + `","status":"succeeded"}]`,
+		"a_fail": `[{"backup_id":"b2","started_at":"` + recent + `","status":"failed","error_message":"VSS timeout"},{"backup_id":"b3","started_at":"` + older + `","status":"succeeded"}]`,
+		"a_none": `[]`,
+		"a_good": `[{"backup_id":"b4","started_at":"` + recent + `","status":"succeeded"}]`,
+		"a_lone": `[{"backup_id":"b5","started_at":"` + stale + `","status":"succeeded"}]`,
+	}
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/v1/client":
+			w.Write([]byte(`{"data":[{"client_id":"c_acme","name":"Acme"},{"client_id":"c_beta","name":"Beta"}],"pagination":{}}`))
+		case "/v1/device":
+			w.Write([]byte(`{"data":[{"device_id":"d_a","client_id":"c_acme","hostname":"acme-box"},{"device_id":"d_b","client_id":"c_beta","hostname":"beta-box"},{"device_id":"d_x","hostname":"spare-box"}],"pagination":{}}`))
+		case "/v1/agent":
+			w.Write([]byte(`{"data":[` +
+				`{"agent_id":"a_ok","device_id":"d_a","hostname":"acme-ok"},` +
+				`{"agent_id":"a_fail","device_id":"d_a","hostname":"acme-fail"},` +
+				`{"agent_id":"a_none","device_id":"d_b","hostname":"beta-none"},` +
+				`{"agent_id":"a_good","device_id":"d_b","hostname":"beta-good"},` +
+				`{"agent_id":"a_lone","device_id":"d_x","hostname":"spare-agent"},` +
+				`{"agent_id":"a_garbled","device_id":"d_x","hostname":"spare-garbled"}],"pagination":{}}`))
+		case "/v1/backup":
+			if r.URL.Query().Get("agent_id") == "a_garbled" {
+				w.Write([]byte(`<html>upstream hiccup</html>`))
+				return
+			}
+			w.Write([]byte(`{"data":` + backups[r.URL.Query().Get("agent_id")] + `,"pagination":{}}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer api.Close()
+	setupTestEnv(t, ToolsReadOnly)
+	APIBaseURL = api.URL
+
+	type rollup struct {
+		Summary struct {
+			Agents   int            `json:"agents"`
+			Problems int            `json:"problems"`
+			ByHealth map[string]int `json:"by_health"`
+		} `json:"summary"`
+		Clients []struct {
+			ClientName string `json:"client_name"`
+			Worst      string `json:"worst"`
+			Agents     int    `json:"agents"`
+			Devices    []struct {
+				DeviceName string `json:"device_name"`
+				Agents     []struct {
+					AgentID string `json:"agent_id"`
+					Health  string `json:"health"`
+				} `json:"agents_status"`
+			} `json:"devices"`
+		} `json:"clients"`
+	}
+	run := func(args map[string]interface{}) rollup {
+		t.Helper()
+		args["operation"] = "status_all"
+		args["format"] = "full"
+		out, err := handleBackupsTool(context.Background(), args)
+		if err != nil {
+			t.Fatalf("status_all: %v", err)
+		}
+		var r rollup
+		if err := json.Unmarshal([]byte(out), &r); err != nil {
+			t.Fatalf("parse status_all: %v\n%s", err, out)
+		}
+		return r
+	}
+
+	all := run(map[string]interface{}{})
+	if all.Summary.Agents != 6 || all.Summary.Problems != 4 {
+		t.Fatalf("summary = %+v, want 6 agents with 4 problems", all.Summary)
+	}
+	if h := all.Summary.ByHealth; h["failed"] != 1 || h["missing"] != 2 || h["error"] != 1 || h["ok"] != 2 {
+		t.Errorf("by_health = %v", h)
+	}
+	var order []string
+	for _, c := range all.Clients {
+		order = append(order, c.ClientName)
+	}
+	if strings.Join(order, ",") != "Acme,(no client),Beta" {
+		t.Errorf("client order = %v, want worst first then by name", order)
+	}
+	acme := all.Clients[0]
+	if acme.Worst != "failed" || acme.Agents != 2 || len(acme.Devices[0].Agents) != 2 || acme.Devices[0].Agents[0].AgentID != "a_fail" {
+		t.Errorf("acme group = %+v, want a_fail listed first", acme)
+	}
+
+	problems := run(map[string]interface{}{"problems_only": true})
+	if problems.Summary.Agents != 6 {
+		t.Errorf("problems_only should keep account-wide totals, got %+v", problems.Summary)
+	}
+	for _, c := range problems.Clients {
+		for _, d := range c.Devices {
+			for _, a := range d.Agents {
+				if a.Health == "ok" {
+					t.Errorf("problems_only returned healthy agent %s", a.AgentID)
+				}
+			}
+		}
+	}
+	if len(problems.Clients) != 3 || len(problems.Clients[0].Devices[0].Agents) != 1 {
+		t.Errorf("problems_only grouping = %+v", problems.Clients)
+	}
+}
+
+func TestScheduleWindowMinutes(t *testing.T) {
+	at := func(s string) time.Time {
+		t.Helper()
+		v, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+	weekdays := &BackupSchedule{IntervalInMinutes: 60, StartHour: 8, EndHour: 18, Days: []int{1, 2, 3, 4, 5}}
+	overnight := &BackupSchedule{IntervalInMinutes: 60, StartHour: 22, EndHour: 6, Days: []int{0, 1, 2, 3, 4, 5, 6}}
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("no tzdata: %v", err)
+	}
+
+	cases := []struct {
+		name     string
+		sched    *BackupSchedule
+		loc      *time.Location
+		from, to string
+		want     float64
+	}{
+		{"friday close to saturday", weekdays, time.UTC, "2026-10-16T17:55:00Z", "2026-10-17T10:00:00Z", 5},
+		{"friday close to monday open", weekdays, time.UTC, "2026-10-16T17:55:00Z", "2026-10-19T09:00:00Z", 65},
+		{"overnight window", overnight, time.UTC, "2026-10-19T12:00:00Z", "2026-10-20T12:00:00Z", 480},
+		{"inside overnight window", overnight, time.UTC, "2026-10-20T01:00:00Z", "2026-10-20T03:00:00Z", 120},
+		{"agent timezone", weekdays, newYork, "2026-10-19T08:00:00Z", "2026-10-19T14:00:00Z", 120},
+		{"no schedule", nil, time.UTC, "2026-10-17T00:00:00Z", "2026-10-17T05:00:00Z", 300},
+	}
+	for _, tc := range cases {
+		if got := scheduleWindowMinutes(tc.sched, tc.loc, at(tc.from), at(tc.to)); got != tc.want {
+			t.Errorf("%s: got %v minutes, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestBackupsRPOReportHTTP(t *testing.T) {
+	ago := func(d time.Duration) string { return time.Now().UTC().Add(-d).Format(time.RFC3339) }
+	backups := map[string]string{
+		"a_ok":     `[{"backup_id":"b1","started_at":"` + ago(40*time.Minute) + `","ended_at":"` + ago(30*time.Minute) + `","status":"succeeded"}]`,
+		"a_late":   `[{"backup_id":"b2","started_at":"` + ago(time.Hour) + `","status":"failed"},{"backup_id":"b3","started_at":"` + ago(6*time.Hour) + `","ended_at":"` + ago(5*time.Hour) + `","status":"succeeded"}]`,
+		"a_never":  `[{"backup_id":"b4","started_at":"` + ago(time.Hour) + `","status":"failed"}]`,
+		"a_paused": `[]`,
+		"a_off":    `[{"backup_id":"b6","started_at":"` + ago(49*time.Hour) + `","ended_at":"` + ago(48*time.Hour) + `","status":"succeeded"}]`,
+		"a_none":   `[{"backup_id":"b5","started_at":"` + ago(40*time.Minute) + `","ended_at":"` + ago(30*time.Minute) + `","status":"succeeded"}]`,
+	}
+	allDay := `"backup_schedule":{"interval_in_minutes":60,"start_hour":0,"end_hour":0,"days":[0,1,2,3,4,5,6]}`
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/v1/agent":
+			w.Write([]byte(`{"data":[` +
+				`{"agent_id":"a_ok","device_id":"d_a","hostname":"ok",` + allDay + `},` +
+				`{"agent_id":"a_late","device_id":"d_a","hostname":"late",` + allDay + `},` +
+				`{"agent_id":"a_never","device_id":"d_a","hostname":"never",` + allDay + `},` +
+				`{"agent_id":"a_paused","device_id":"d_a","hostname":"paused","backup_paused_indefinite":true,` + allDay + `},` +
+				`{"agent_id":"a_off","device_id":"d_a","hostname":"off","backup_schedule_active":false,` + allDay + `},` +
+				`{"agent_id":"a_none","device_id":"d_a","hostname":"unscheduled"}],"pagination":{}}`))
+		case "/v1/backup":
+			w.Write([]byte(`{"data":` + backups[r.URL.Query().Get("agent_id")] + `,"pagination":{}}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer api.Close()
+	setupTestEnv(t, ToolsReadOnly)
+	APIBaseURL = api.URL
+
+	type report struct {
+		Summary struct {
+			Agents     int            `json:"agents"`
+			Violations int            `json:"violations"`
+			ByStatus   map[string]int `json:"by_status"`
+		} `json:"summary"`
+		Agents []struct {
+			AgentID        string `json:"agent_id"`
+			Status         string `json:"status"`
+			TargetMinutes  int    `json:"rpo_target_minutes"`
+			OverdueMinutes *int   `json:"overdue_minutes"`
+		} `json:"agents"`
+	}
+	run := func(args map[string]interface{}) report {
+		t.Helper()
+		args["operation"] = "rpo_report"
+		args["format"] = "full"
+		out, err := handleBackupsTool(context.Background(), args)
+		if err != nil {
+			t.Fatalf("rpo_report: %v", err)
+		}
+		var r report
+		if err := json.Unmarshal([]byte(out), &r); err != nil {
+			t.Fatalf("parse rpo_report: %v\n%s", err, out)
+		}
+		return r
+	}
+
+	all := run(map[string]interface{}{})
+	if all.Summary.Agents != 6 || all.Summary.Violations != 2 {
+		t.Fatalf("summary = %+v, want 6 agents with 2 violations", all.Summary)
+	}
+	if s := all.Summary.ByStatus; s["ok"] != 1 || s["paused"] != 1 || s["no_schedule"] != 1 || s["schedule_paused"] != 1 {
+		t.Errorf("by_status = %v", s)
+	}
+	var order []string
+	for _, a := range all.Agents {
+		order = append(order, a.AgentID+"="+a.Status)
+	}
+	if strings.Join(order, ",") != "a_never=violation,a_late=violation,a_none=no_schedule,a_off=schedule_paused,a_paused=paused,a_ok=ok" {
+		t.Errorf("order = %v, want never-succeeded first, then most overdue", order)
+	}
+	if late := all.Agents[1]; late.OverdueMinutes == nil || *late.OverdueMinutes < 235 || *late.OverdueMinutes > 245 {
+		t.Errorf("a_late overdue = %v, want about 240 minutes past a 60 minute interval", late.OverdueMinutes)
+	}
+
+	relaxed := run(map[string]interface{}{"rpo_minutes": float64(600), "problems_only": true})
+	if relaxed.Summary.Violations != 1 || len(relaxed.Agents) != 1 || relaxed.Agents[0].AgentID != "a_never" {
+		t.Errorf("rpo_minutes=600 problems_only = %+v, want only a_never", relaxed)
+	}
+	if relaxed.Summary.ByStatus["ok"] != 3 {
+		t.Errorf("rpo_minutes should also judge unscheduled agents, by_status = %v", relaxed.Summary.ByStatus)
+	}
+}
+
+func TestSnapshotsCalendarHTTP(t *testing.T) {
+	ok := `"verify_boot_status":"success"`
+	// A year of hourly snapshots newer than February sits in front of the
+	// month; the calendar must skip over it, not page through it.
+	var snapshots []string
+	for at := time.Date(2027, 3, 1, 0, 0, 0, 0, time.UTC); at.After(time.Date(2026, 3, 1, 1, 0, 0, 0, time.UTC)); at = at.Add(-time.Hour) {
+		snapshots = append(snapshots, `{"snapshot_id":"s_later","backup_started_at":"`+at.Format(time.RFC3339)+`","locations":[{"type":"local"}]}`)
+	}
+	snapshots = append(snapshots,
+		`{"snapshot_id":"s_mar","backup_started_at":"2026-03-01T01:00:00Z","locations":[{"type":"local"}]}`,
+		`{"snapshot_id":"s_1","backup_started_at":"2026-02-02T09:00:00Z","locations":[{"type":"local"},{"type":"cloud"}],`+ok+`}`,
+		`{"snapshot_id":"s_2","backup_started_at":"2026-02-02T15:00:00Z","locations":[{"type":"local"},{"type":"cloud"}]}`,
+		`{"snapshot_id":"s_3","backup_started_at":"2026-02-03T09:00:00Z","locations":[{"type":"local"}],"verify_boot_status":"failed"}`,
+		`{"snapshot_id":"s_4","backup_started_at":"2026-02-04T09:00:00Z","locations":[],"deleted":"2026-02-20T00:00:00Z"}`,
+		`{"snapshot_id":"s_jan","backup_started_at":"2026-01-31T09:00:00Z","locations":[{"type":"cloud"}]}`)
+	for i := 0; i < 200; i++ {
+		snapshots = append(snapshots, `{"snapshot_id":"s_old","backup_started_at":"2025-12-01T09:00:00Z","locations":[{"type":"cloud"}]}`)
+	}
+	var snapshotRequests, snapshotPages int
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/v1/agent/a_cal":
+			w.Write([]byte(`{"agent_id":"a_cal","hostname":"cal","backup_schedule":{"interval_in_minutes":60,"start_hour":8,"end_hour":18,"days":[1,2,3,4,5]}}`))
+		case "/v1/snapshot":
+			snapshotRequests++
+			offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+			limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+			if limit > 1 {
+				snapshotPages++
+			}
+			end := min(offset+limit, len(snapshots))
+			next := "null"
+			if end < len(snapshots) {
+				next = strconv.Itoa(end)
+			}
+			w.Write([]byte(`{"data":[` + strings.Join(snapshots[min(offset, end):end], ",") + `],"pagination":{"total":` + strconv.Itoa(len(snapshots)) + `,"next_offset":` + next + `}}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer api.Close()
+	setupTestEnv(t, ToolsReadOnly)
+	APIBaseURL = api.URL
+
+	out, err := handleSnapshotsTool(context.Background(), map[string]interface{}{
+		"operation": "calendar", "agent_id": "a_cal", "month": "2026-02", "format": "full",
+	})
+	if err != nil {
+		t.Fatalf("calendar: %v", err)
+	}
+	var cal struct {
+		Month   string `json:"month"`
+		Summary struct {
+			DaysCovered  int            `json:"days_covered"`
+			GapDays      int            `json:"gap_days"`
+			Snapshots    int            `json:"snapshots"`
+			Local        int            `json:"local"`
+			Cloud        int            `json:"cloud"`
+			Deleted      int            `json:"deleted"`
+			Verification map[string]int `json:"verification"`
+		} `json:"summary"`
+		Gaps []calendarGap `json:"gaps"`
+		Grid []string      `json:"grid"`
+		Days []calendarDay `json:"days"`
+	}
+	if err := json.Unmarshal([]byte(out), &cal); err != nil {
+		t.Fatalf("parse calendar: %v\n%s", err, out)
+	}
+	if snapshotPages != 1 || snapshotRequests > 20 {
+		t.Errorf("fetched %d snapshot pages in %d requests, want the newer snapshots skipped and paging to stop once the month is passed", snapshotPages, snapshotRequests)
+	}
+	if len(cal.Days) != 28 || cal.Days[0].Weekday != "Sun" {
+		t.Fatalf("days = %d starting %q, want 28 starting Sun", len(cal.Days), cal.Days[0].Weekday)
+	}
+	s := cal.Summary
+	if s.DaysCovered != 2 || s.Snapshots != 4 || s.Local != 3 || s.Cloud != 2 || s.Deleted != 1 {
+		t.Errorf("summary = %+v", s)
+	}
+	if s.Verification["passed"] != 1 || s.Verification["failed"] != 1 || s.Verification["unverified"] != 1 {
+		t.Errorf("verification = %v", s.Verification)
+	}
+	// Weekdays from the 4th on have no surviving snapshot; weekends are off schedule.
+	if s.GapDays != 18 || len(cal.Gaps) != 1 || cal.Gaps[0].From != "2026-02-04" || cal.Gaps[0].To != "2026-02-27" {
+		t.Errorf("gaps = %+v (gap days %d)", cal.Gaps, s.GapDays)
+	}
+	if cal.Days[6].Gap || !cal.Days[3].Gap {
+		t.Errorf("Saturday must not be a gap and the deleted-only Wednesday must be")
+	}
+	if len(cal.Grid) != 5 || !strings.Contains(cal.Grid[1], "2✓") || !strings.Contains(cal.Grid[1], "3~") || !strings.Contains(cal.Grid[1], "4✗") {
+		t.Errorf("grid = %q", cal.Grid)
+	}
+}
+
+func TestSnapshotsVerificationReportHTTP(t *testing.T) {
+	ago := func(h int) string { return time.Now().UTC().Add(-time.Duration(h) * time.Hour).Format(time.RFC3339) }
+	snapshots := map[string]string{
+		"a_ok": `[{"snapshot_id":"s_ok","backup_started_at":"` + ago(3) + `","verify_boot_status":"success","verify_fs_status":"success"}]`,
+		"a_svc": `[{"snapshot_id":"s_svc2","backup_started_at":"` + ago(2) + `","verify_boot_status":"success","verify_service_status":"failed"},` +
+			`{"snapshot_id":"s_svc1","backup_started_at":"` + ago(30) + `","verify_boot_status":"success"}]`,
+		"a_never": `[{"snapshot_id":"s_n1","backup_started_at":"` + ago(5) + `","verify_boot_status":"failed","verify_boot_screenshot_url":"https://x/shot.png"},` +
+			`{"snapshot_id":"s_n0","backup_started_at":"` + ago(900) + `","verify_boot_status":"success"}]`,
+		"a_none": `[{"snapshot_id":"s_u","backup_started_at":"` + ago(1) + `"}]`,
+	}
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/v1/agent":
+			if r.URL.Query().Get("client_id") != "c_acme" {
+				t.Errorf("agent listing query = %q, want the client scope", r.URL.RawQuery)
+			}
+			w.Write([]byte(`{"data":[` +
+				`{"agent_id":"a_ok","hostname":"ok"},{"agent_id":"a_svc","hostname":"svc"},` +
+				`{"agent_id":"a_never","hostname":"never"},{"agent_id":"a_none","hostname":"none"}],"pagination":{}}`))
+		case "/v1/snapshot":
+			w.Write([]byte(`{"data":` + snapshots[r.URL.Query().Get("agent_id")] + `,"pagination":{}}`))
+		case "/v1/snapshot/s_svc2/service-verification":
+			w.Write([]byte(`{"snapshot_id":"s_svc2","services":[{"service_id":"1","name":"MSSQLSERVER","state":"stopped"},{"service_id":"2","name":"W32Time","state":"running"}]}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer api.Close()
+	setupTestEnv(t, ToolsReadOnly)
+	APIBaseURL = api.URL
+
+	out, err := handleSnapshotsTool(context.Background(), map[string]interface{}{
+		"operation": "verification_report", "client_id": "c_acme", "format": "full",
+	})
+	if err != nil {
+		t.Fatalf("verification_report: %v", err)
+	}
+	var report struct {
+		Scope   string `json:"scope"`
+		Summary struct {
+			Agents   int            `json:"agents"`
+			Problems int            `json:"problems"`
+			ByStatus map[string]int `json:"by_status"`
+		} `json:"summary"`
+		Agents []agentVerification `json:"agents"`
+	}
+	if err := json.Unmarshal([]byte(out), &report); err != nil {
+		t.Fatalf("parse verification_report: %v\n%s", err, out)
+	}
+	if report.Scope != "client c_acme" || report.Summary.Agents != 4 || report.Summary.Problems != 3 {
+		t.Fatalf("report = %+v", report)
+	}
+	byID := map[string]agentVerification{}
+	var order []string
+	for _, a := range report.Agents {
+		byID[a.AgentID] = a
+		order = append(order, a.Status)
+	}
+	// s_n0 is older than the 30-day window, so a_never has never passed.
+	if strings.Join(order, ",") != "never_passed,failing,unverified,ok" {
+		t.Errorf("status order = %v", order)
+	}
+	never := byID["a_never"]
+	if never.LatestVerified == nil || never.LatestVerified.ScreenshotURL == nil || never.HoursSinceBootPass != nil {
+		t.Errorf("a_never = %+v, want the failed snapshot with its screenshot and no boot pass", never)
+	}
+	svc := byID["a_svc"]
+	if len(svc.FailingServices) != 1 || svc.FailingServices[0].Name != "MSSQLSERVER" {
+		t.Errorf("a_svc failing services = %+v", svc.FailingServices)
+	}
+	if svc.HoursSinceBootPass == nil || *svc.HoursSinceBootPass != 2 {
+		t.Errorf("a_svc hours since boot pass = %v, want 2", svc.HoursSinceBootPass)
+	}
+
+	// report=both attaches a Markdown and an HTML document and saves them
+	// under --report-dir.
+	currentConfig().ReportDir = t.TempDir()
+	ctx, attached := withAttachments(context.Background())
+	out, err = handleSnapshotsTool(ctx, map[string]interface{}{
+		"operation": "verification_report", "client_id": "c_acme", "format": "full", "report": "both",
+	})
+	if err != nil {
+		t.Fatalf("verification_report report=both: %v", err)
+	}
+	var withDoc struct {
+		Report struct {
+			Files    []string `json:"files"`
+			Attached []string `json:"attached"`
+		} `json:"report"`
+	}
+	if err := json.Unmarshal([]byte(out), &withDoc); err != nil {
+		t.Fatalf("parse: %v\n%s", err, out)
+	}
+	if len(withDoc.Report.Files) != 2 || len(attached.blocks()) != 2 {
+		t.Fatalf("report = %+v, %d attachments", withDoc.Report, len(attached.blocks()))
+	}
+	for _, f := range withDoc.Report.Files {
+		info, err := os.Stat(f)
+		if err != nil || info.Mode().Perm() != 0o600 {
+			t.Errorf("%s: %v, mode %v", f, err, info)
+		}
+	}
+	md, _ := os.ReadFile(withDoc.Report.Files[0])
+	page, _ := os.ReadFile(withDoc.Report.Files[1])
+	if !strings.HasSuffix(withDoc.Report.Files[0], ".md") || !strings.Contains(string(md), "| never | **never_passed** |") || !strings.Contains(string(md), "[screenshot](<https://x/shot.png>)") {
+		t.Errorf("markdown report:\n%s", md)
+	}
+	if !strings.Contains(string(page), `<td class="status failing">failing</td>`) || !strings.Contains(string(page), "MSSQLSERVER (stopped)") {
+		t.Errorf("html report:\n%s", page)
+	}
+	if _, err := handleSnapshotsTool(context.Background(), map[string]interface{}{"operation": "verification_report", "client_id": "c_acme", "report": "pdf"}); err == nil {
+		t.Error("report=pdf: want error")
+	}
+}
+
+func TestReportDocEscaping(t *testing.T) {
+	evil := "javascript:alert(1)"
+	doc := reportDoc{
+		title: "T <1>",
+		sections: []reportSection{{
+			heading: "S",
+			columns: []string{"A", "B"},
+			rows:    [][]reportCell{{cell("x|y <b>"), linkCell("shot", &evil)}},
+		}},
+	}
+	page := renderReportHTML(doc)
+	if strings.Contains(page, "<b>") || strings.Contains(page, "javascript:") || !strings.Contains(page, "<title>T &lt;1&gt;</title>") {
+		t.Errorf("html not escaped:\n%s", page)
+	}
+	if md := renderReportMarkdown(doc); !strings.Contains(md, `| x\|y &lt;b&gt; | shot |`) {
+		t.Errorf("markdown cell:\n%s", md)
+	}
+}
+
+func TestFilesRecoverFileHTTP(t *testing.T) {
+	tuesdaySize := 100
+	var restores, pushes []map[string]interface{}
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		snap := func(id, at string) {
+			w.Write([]byte(`{"snapshot_id":"` + id + `","backup_started_at":"` + at + `","locations":[{"type":"cloud","device_id":"d_cloud"},{"type":"local","device_id":"d_local"}]}`))
+		}
+		switch {
+		case r.URL.Path == "/v1/agent/a_bob":
+			w.Write([]byte(`{"agent_id":"a_bob","hostname":"bob-laptop","device_id":"d_local","timezone":"America/New_York"}`))
+		case r.URL.Path == "/v1/agent/a_bob/file-search":
+			w.Write([]byte(`{"data":[{"path":"C:\\Users\\bob\\Documents\\Q4-budget.xlsx"},{"path":"C:\\Users\\bob\\Documents\\old\\Q4-budget-draft.xlsx"}],"pagination":{}}`))
+		case r.URL.Path == "/v1/agent/a_bob/file-search/version":
+			w.Write([]byte(fmt.Sprintf(`{"data":[`+
+				`{"snapshot_id":"s_wed","size":120,"modified_time":"2026-02-11T15:00:00Z"},`+
+				`{"snapshot_id":"s_tue2","size":%d,"modified_time":"2026-02-10T13:00:00Z"},`+
+				`{"snapshot_id":"s_tue1","size":100,"modified_time":"2026-02-10T13:00:00Z"},`+
+				`{"snapshot_id":"s_mon","size":90,"modified_time":"2026-02-09T13:00:00Z"}],"pagination":{}}`, tuesdaySize)))
+		case r.URL.Path == "/v1/snapshot/s_wed":
+			snap("s_wed", "2026-02-11T16:00:00Z")
+		case r.URL.Path == "/v1/snapshot/s_tue2":
+			// 00:30 Wednesday UTC is still Tuesday evening in New York.
+			snap("s_tue2", "2026-02-11T00:30:00Z")
+		case r.URL.Path == "/v1/snapshot/s_tue1":
+			snap("s_tue1", "2026-02-10T16:00:00Z")
+		case r.URL.Path == "/v1/snapshot/s_mon":
+			snap("s_mon", "2026-02-09T16:00:00Z")
+		case r.Method == "POST" && r.URL.Path == "/v1/restore/file":
+			var body map[string]interface{}
+			json.NewDecoder(r.Body).Decode(&body)
+			restores = append(restores, body)
+			w.Write([]byte(`{"file_restore_id":"fr_1","snapshot_id":"` + body["snapshot_id"].(string) + `"}`))
+		case r.URL.Path == "/v1/restore/file/fr_1/browse":
+			if got := r.URL.Query().Get("path"); got != "/C:/Users/bob/Documents" {
+				t.Errorf("browse path = %q", got)
+			}
+			w.Write([]byte(`{"data":[{"name":"old","path":"/C:/Users/bob/Documents/old","type":"dir"},{"name":"Q4-Budget.xlsx","path":"/C:/Users/bob/Documents/Q4-Budget.xlsx","type":"file"}],"pagination":{}}`))
+		case r.Method == "POST" && r.URL.Path == "/v1/restore/file/fr_1/push":
+			var body map[string]interface{}
+			json.NewDecoder(r.Body).Decode(&body)
+			pushes = append(pushes, body)
+			w.Write([]byte(`{"file_restore_push_id":"frp_1","file_restore_id":"fr_1","state":"created"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer api.Close()
+	setupTestEnv(t, ToolsSafe)
+	APIBaseURL = api.URL
+
+	call := func(extra map[string]interface{}) map[string]interface{} {
+		t.Helper()
+		args := map[string]interface{}{"operation": "recover_file", "agent_id": "a_bob", "format": "full"}
+		for k, v := range extra {
+			args[k] = v
+		}
+		out, err := handleFilesTool(context.Background(), args)
+		if err != nil {
+			t.Fatalf("recover_file: %v", err)
+		}
+		var got map[string]interface{}
+		if err := json.Unmarshal([]byte(out), &got); err != nil {
+			t.Fatalf("parse: %v\n%s", err, out)
+		}
+		return got
+	}
+
+	got := call(map[string]interface{}{"search_term": "budget"})
+	if got["status"] != "needs_choice" || got["choice"] != "path" || len(restores) != 0 {
+		t.Fatalf("ambiguous search: %v (restores %v)", got, restores)
+	}
+
+	got = call(map[string]interface{}{"search_term": "Q4-budget.xlsx", "date": "2026-02-10"})
+	if got["status"] != "push_started" || got["file_restore_push_id"] != "frp_1" {
+		t.Fatalf("recover: %v", got)
+	}
+	if len(restores) != 1 || restores[0]["snapshot_id"] != "s_tue2" || restores[0]["device_id"] != "d_local" {
+		t.Errorf("restore request = %v, want Tuesday's newest snapshot on the local device", restores)
+	}
+	if len(pushes) != 1 || pushes[0]["source_file_path"] != "/C:/Users/bob/Documents/Q4-Budget.xlsx" || pushes[0]["destination_folder"] != `C:\SlideRestore` {
+		t.Errorf("push request = %v", pushes)
+	}
+
+	got = call(map[string]interface{}{"path": `C:\Users\bob\Documents\Q4-budget.xlsx`, "before": "2026-02-10"})
+	if len(restores) != 2 || restores[1]["snapshot_id"] != "s_mon" {
+		t.Errorf("before=2026-02-10 restored %v, want s_mon (%v)", restores, got)
+	}
+
+	tuesdaySize = 110
+	got = call(map[string]interface{}{"search_term": "Q4-budget.xlsx", "date": "2026-02-10"})
+	if got["status"] != "needs_choice" || got["choice"] != "snapshot_id" || got["total_candidates"] != float64(2) || len(restores) != 2 {
+		t.Errorf("file changed during the day: %v (restores %d)", got, len(restores))
+	}
+}
+
+func TestFilesDiffVersionsHTTP(t *testing.T) {
+	content := map[string]string{
+		"s_old": "<config>\n  <debug>false</debug>\n</config>\n",
+		"s_new": "<config>\r\n  <debug>true</debug>\r\n</config>\r\n<extra/>\r\n",
+	}
+	var deleted []string
+	var apiURL string
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		snap := func(id, at string) {
+			w.Write([]byte(`{"snapshot_id":"` + id + `","backup_started_at":"` + at + `","locations":[{"type":"local","device_id":"d_1"}]}`))
+		}
+		restoreOf := map[string]string{"fr_open": "s_old", "fr_new": "s_new"}
+		switch {
+		case r.URL.Path == "/v1/agent/a_web":
+			w.Write([]byte(`{"agent_id":"a_web","hostname":"web01","device_id":"d_1","timezone":"UTC"}`))
+		case r.URL.Path == "/v1/agent/a_web/file-search/version":
+			w.Write([]byte(`{"data":[` +
+				`{"snapshot_id":"s_new","size":40,"modified_time":"2026-03-03T10:00:00Z"},` +
+				`{"snapshot_id":"s_mid","size":40,"modified_time":"2026-03-03T10:00:00Z"},` +
+				`{"snapshot_id":"s_old","size":30,"modified_time":"2026-03-01T10:00:00Z"}],"pagination":{}}`))
+		case r.URL.Path == "/v1/snapshot/s_new":
+			snap("s_new", "2026-03-04T01:00:00Z")
+		case r.URL.Path == "/v1/snapshot/s_mid":
+			snap("s_mid", "2026-03-03T01:00:00Z")
+		case r.URL.Path == "/v1/snapshot/s_old":
+			snap("s_old", "2026-03-02T01:00:00Z")
+		case r.Method == "GET" && r.URL.Path == "/v1/restore/file":
+			w.Write([]byte(`{"data":[{"file_restore_id":"fr_other","agent_id":"a_db","snapshot_id":"s_new"},{"file_restore_id":"fr_open","agent_id":"a_web","snapshot_id":"s_old"}],"pagination":{}}`))
+		case r.Method == "POST" && r.URL.Path == "/v1/restore/file":
+			var body map[string]interface{}
+			json.NewDecoder(r.Body).Decode(&body)
+			if body["snapshot_id"] != "s_new" {
+				t.Errorf("created a restore of %v; s_old is already open", body["snapshot_id"])
+			}
+			w.Write([]byte(`{"file_restore_id":"fr_new","agent_id":"a_web","snapshot_id":"s_new"}`))
+		case r.Method == "DELETE" && strings.HasPrefix(r.URL.Path, "/v1/restore/file/"):
+			deleted = append(deleted, strings.TrimPrefix(r.URL.Path, "/v1/restore/file/"))
+			w.Write([]byte(`{}`))
+		case strings.HasSuffix(r.URL.Path, "/browse"):
+			id := strings.Split(r.URL.Path, "/")[4]
+			body := content[restoreOf[id]]
+			w.Write([]byte(fmt.Sprintf(`{"data":[{"name":"web.config","path":"/C:/inetpub/web.config","type":"file","size":%d,"download_uris":[{"type":"http","uri":"%s/dl/%s"}]}],"pagination":{}}`, len(body), apiURL, id)))
+		case strings.HasPrefix(r.URL.Path, "/dl/"):
+			w.Write([]byte(content[restoreOf[strings.TrimPrefix(r.URL.Path, "/dl/")]]))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer api.Close()
+	apiURL = api.URL
+	setupTestEnv(t, ToolsSafe)
+	APIBaseURL = api.URL
+	diff := func() map[string]interface{} {
+		t.Helper()
+		out, err := handleFilesTool(context.Background(), map[string]interface{}{
+			"operation": "diff_versions", "agent_id": "a_web", "path": `C:\inetpub\web.config`, "format": "full",
+		})
+		if err != nil {
+			t.Fatalf("diff_versions: %v", err)
+		}
+		var got map[string]interface{}
+		if err := json.Unmarshal([]byte(out), &got); err != nil {
+			t.Fatalf("parse: %v\n%s", err, out)
+		}
+		return got
+	}
+
+	got := diff()
+	wantDiff := "@@ -1,3 +1,4 @@\n <config>\n-  <debug>false</debug>\n+  <debug>true</debug>\n </config>\n+<extra/>\n"
+	if got["status"] != "different" || got["kind"] != "text" || !strings.HasSuffix(fmt.Sprint(got["diff"]), wantDiff) {
+		t.Fatalf("text diff = %v\n%s", got, got["diff"])
+	}
+	from, to := got["from"].(map[string]interface{}), got["to"].(map[string]interface{})
+	if from["snapshot_id"] != "s_old" || from["restore"] != "reused" || to["snapshot_id"] != "s_new" || to["restore"] != "created" {
+		t.Errorf("from = %v, to = %v", from, to)
+	}
+	if len(deleted) != 1 || deleted[0] != "fr_new" {
+		t.Errorf("deleted restores = %v, want just fr_new", deleted)
+	}
+
+	content["s_new"] = "MZ\x00\x01binary"
+	got = diff()
+	cmp, _ := got["comparison"].(map[string]interface{})
+	if got["kind"] != "binary" || cmp["size_delta"] != float64(len(content["s_new"])-len(content["s_old"])) || cmp["same_sha256"] != false {
+		t.Errorf("binary diff = %v", got)
+	}
+	if to := got["to"].(map[string]interface{}); len(fmt.Sprint(to["sha256"])) != 64 {
+		t.Errorf("to.sha256 = %v", to["sha256"])
+	}
+}
+
+func TestFilesDiffSnapshotsHTTP(t *testing.T) {
+	entry := func(dir, name, typ string, size int) string {
+		return fmt.Sprintf(`{"name":%q,"path":%q,"type":%q,"size":%d,"modified_at":"2026-03-0%dT01:00:00Z"}`, name, dir+"/"+name, typ, size, 1+size%2)
+	}
+	listings := map[string]map[string][]string{"fr_1": {}, "fr_2": {}}
+	for i := 0; i < 12; i++ {
+		doc := fmt.Sprintf("contract%02d.docx", i)
+		listings["fr_1"]["/D:/Shares/Docs"] = append(listings["fr_1"]["/D:/Shares/Docs"], entry("/D:/Shares/Docs", doc, "file", 100))
+		listings["fr_2"]["/D:/Shares/Docs"] = append(listings["fr_2"]["/D:/Shares/Docs"], entry("/D:/Shares/Docs", doc+".qzkr", "file", 116))
+	}
+	listings["fr_1"]["/D:/Shares"] = []string{entry("/D:/Shares", "Docs", "dir", 0), entry("/D:/Shares", "budget.xlsx", "file", 10)}
+	listings["fr_2"]["/D:/Shares"] = []string{entry("/D:/Shares", "Docs", "dir", 0), entry("/D:/Shares", "budget.xlsx", "file", 11), entry("/D:/Shares", "HOW_TO_RECOVER_FILES.txt", "file", 1)}
+	listings["fr_2"]["/D:/Shares/Docs"] = append(listings["fr_2"]["/D:/Shares/Docs"], entry("/D:/Shares/Docs", "README_DECRYPT.txt", "file", 1))
+
+	var deleted []string
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.URL.Path == "/v1/agent/a_fs":
+			w.Write([]byte(`{"agent_id":"a_fs","hostname":"fs01","timezone":"UTC"}`))
+		case r.URL.Path == "/v1/snapshot/s_1":
+			w.Write([]byte(`{"snapshot_id":"s_1","agent_id":"a_fs","backup_started_at":"2026-03-01T23:00:00Z","locations":[{"type":"local","device_id":"d_1"}]}`))
+		case r.URL.Path == "/v1/snapshot/s_2":
+			w.Write([]byte(`{"snapshot_id":"s_2","agent_id":"a_fs","backup_started_at":"2026-03-02T07:00:00Z","locations":[{"type":"local","device_id":"d_1"}]}`))
+		case r.URL.Path == "/v1/snapshot/s_other":
+			w.Write([]byte(`{"snapshot_id":"s_other","agent_id":"a_db","backup_started_at":"2026-03-02T07:00:00Z"}`))
+		case r.Method == "GET" && r.URL.Path == "/v1/restore/file":
+			w.Write([]byte(`{"data":[],"pagination":{}}`))
+		case r.Method == "POST" && r.URL.Path == "/v1/restore/file":
+			var body map[string]interface{}
+			json.NewDecoder(r.Body).Decode(&body)
+			id := "fr_" + strings.TrimPrefix(body["snapshot_id"].(string), "s_")
+			w.Write([]byte(`{"file_restore_id":"` + id + `","agent_id":"a_fs","snapshot_id":"` + body["snapshot_id"].(string) + `"}`))
+		case r.Method == "DELETE":
+			deleted = append(deleted, path.Base(r.URL.Path))
+			w.Write([]byte(`{}`))
+		case strings.HasSuffix(r.URL.Path, "/browse"):
+			id := strings.Split(r.URL.Path, "/")[4]
+			w.Write([]byte(`{"data":[` + strings.Join(listings[id][r.URL.Query().Get("path")], ",") + `],"pagination":{}}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer api.Close()
+	setupTestEnv(t, ToolsSafe)
+	APIBaseURL = api.URL
+	diff := func(extra map[string]interface{}) (map[string]interface{}, error) {
+		t.Helper()
+		args := map[string]interface{}{"operation": "diff_snapshots", "agent_id": "a_fs", "from_snapshot_id": "s_2", "to_snapshot_id": "s_1", "browse_path": "D:/Shares", "format": "full"}
+		for k, v := range extra {
+			args[k] = v
+		}
+		out, err := handleFilesTool(context.Background(), args)
+		if err != nil {
+			return nil, err
+		}
+		var got map[string]interface{}
+		if err := json.Unmarshal([]byte(out), &got); err != nil {
+			t.Fatalf("parse: %v\n%s", err, out)
+		}
+		return got, nil
+	}
+
+	got, err := diff(nil)
+	if err != nil {
+		t.Fatalf("diff_snapshots: %v", err)
+	}
+	// The IDs were passed newest first; the result reads old -> new.
+	if got["from"].(map[string]interface{})["snapshot_id"] != "s_1" || got["notes"] == nil {
+		t.Errorf("from = %v, notes = %v", got["from"], got["notes"])
+	}
+	sum := got["summary"].(map[string]interface{})
+	if sum["added"] != float64(14) || sum["removed"] != float64(12) || sum["modified"] != float64(1) || sum["not_compared"] != float64(0) {
+		t.Errorf("summary = %v", sum)
+	}
+	kinds := map[string]string{}
+	for _, s := range got["suspicious"].([]interface{}) {
+		s := s.(map[string]interface{})
+		kinds[s["kind"].(string)] = s["severity"].(string)
+	}
+	if got["verdict"] != "suspicious" || kinds["mass_rename"] != "high" || kinds["ransom_note"] != "high" {
+		t.Errorf("verdict = %v, suspicious = %v", got["verdict"], kinds)
+	}
+	sort.Strings(deleted)
+	if strings.Join(deleted, ",") != "fr_1,fr_2" {
+		t.Errorf("deleted restores = %v", deleted)
+	}
+
+	// A walk cut short by max_entries must not read as deletions.
+	got, err = diff(map[string]interface{}{"max_entries": 3})
+	if err != nil {
+		t.Fatalf("diff_snapshots max_entries=3: %v", err)
+	}
+	sum = got["summary"].(map[string]interface{})
+	if sum["removed"] != float64(0) || sum["not_compared"] == float64(0) {
+		t.Errorf("truncated summary = %v", sum)
+	}
+
+	if _, err := diff(map[string]interface{}{"from_snapshot_id": "s_other"}); err == nil || !strings.Contains(err.Error(), "belongs to agent a_db") {
+		t.Errorf("snapshot of another agent: err = %v", err)
+	}
+}
+
+func TestSnapshotsChangeAnomaliesHTTP(t *testing.T) {
+	now := time.Now().UTC()
+	snaps := []string{"s_1", "s_2", "s_3", "s_4"}
+	listing := func(snap string) []string {
+		var out []string
+		for i := 0; i < 12; i++ {
+			name, size := fmt.Sprintf("plan%02d.xlsx", i), 100
+			switch {
+			case snap == "s_3" && i == 0:
+				size = 101
+			case snap == "s_4":
+				name, size = name+".lkx9", 116
+			}
+			out = append(out, fmt.Sprintf(`{"name":%q,"path":"/D:/Shares/%s","type":"file","size":%d}`, name, name, size))
+		}
+		if snap == "s_4" {
+			out = append(out, `{"name":"Team","path":"/D:/Shares/Team","type":"dir"}`, `{"name":"RESTORE_FILES_INFO.txt","path":"/D:/Shares/RESTORE_FILES_INFO.txt","type":"file","size":1}`)
+		} else {
+			out = append(out, `{"name":"Team","path":"/D:/Shares/Team","type":"dir"}`)
+		}
+		return out
+	}
+	var browses, snapshotPages atomic.Int32
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.URL.Path == "/v1/agent/a_fs":
+			w.Write([]byte(`{"agent_id":"a_fs","hostname":"fs01","timezone":"UTC"}`))
+		case r.URL.Path == "/v1/snapshot":
+			// Two per page, so the window spans pages and the last page
+			// (past the cutoff) is never needed.
+			snapshotPages.Add(1)
+			var data []string
+			for i := len(snaps) - 1; i >= 0; i-- {
+				at := now.Add(-time.Duration(len(snaps)-i) * 6 * time.Hour).Format(time.RFC3339)
+				data = append(data, `{"snapshot_id":"`+snaps[i]+`","agent_id":"a_fs","backup_started_at":"`+at+`","locations":[{"type":"local","device_id":"d_1"}]}`)
+			}
+			for i, id := range []string{"s_old", "s_older", "s_oldest"} {
+				data = append(data, `{"snapshot_id":"`+id+`","agent_id":"a_fs","backup_started_at":"`+now.Add(-time.Duration(30+i)*24*time.Hour).Format(time.RFC3339)+`","locations":[{"type":"local","device_id":"d_1"}]}`)
+			}
+			offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+			end := min(offset+2, len(data))
+			next := "null"
+			if end < len(data) {
+				next = strconv.Itoa(end)
+			}
+			w.Write([]byte(`{"data":[` + strings.Join(data[offset:end], ",") + `],"pagination":{"total":` + strconv.Itoa(len(data)) + `,"next_offset":` + next + `}}`))
+		case r.Method == "GET" && r.URL.Path == "/v1/restore/file":
+			w.Write([]byte(`{"data":[],"pagination":{}}`))
+		case r.Method == "POST" && r.URL.Path == "/v1/restore/file":
+			var body map[string]interface{}
+			json.NewDecoder(r.Body).Decode(&body)
+			w.Write([]byte(`{"file_restore_id":"fr_` + body["snapshot_id"].(string) + `","agent_id":"a_fs","snapshot_id":"` + body["snapshot_id"].(string) + `"}`))
+		case r.Method == "DELETE":
+			w.Write([]byte(`{}`))
+		case strings.HasSuffix(r.URL.Path, "/browse"):
+			browses.Add(1)
+			snap := strings.TrimPrefix(strings.Split(r.URL.Path, "/")[4], "fr_")
+			var data []string
+			if r.URL.Query().Get("path") == "/D:/Shares" {
+				data = listing(snap)
+			}
+			w.Write([]byte(`{"data":[` + strings.Join(data, ",") + `],"pagination":{}}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer api.Close()
+	setupTestEnv(t, ToolsSafe)
+	APIBaseURL = api.URL
+	sweep := func(extra map[string]interface{}) map[string]interface{} {
+		t.Helper()
+		args := map[string]interface{}{"operation": "change_anomalies", "agent_id": "a_fs", "browse_path": "/D:/Shares", "format": "full"}
+		for k, v := range extra {
+			args[k] = v
+		}
+		out, err := handleSnapshotsTool(context.Background(), args)
+		if err != nil {
+			t.Fatalf("change_anomalies %v: %v", extra, err)
+		}
+		var got map[string]interface{}
+		if err := json.Unmarshal([]byte(out), &got); err != nil {
+			t.Fatalf("parse: %v\n%s", err, out)
+		}
+		return got
+	}
+
+	got := sweep(nil)
+	if n := snapshotPages.Load(); n != 3 {
+		t.Errorf("read %d snapshot pages, want 3 (stopping at the page that crosses the cutoff)", n)
+	}
+	if got["verdict"] != "suspicious" || got["last_clean"].(map[string]interface{})["snapshot_id"] != "s_3" ||
+		got["first_suspicious"].(map[string]interface{})["snapshot_id"] != "s_4" {
+		t.Fatalf("verdict = %v, last_clean = %v, first_suspicious = %v", got["verdict"], got["last_clean"], got["first_suspicious"])
+	}
+	steps := got["steps"].([]interface{})
+	if len(steps) != 3 || steps[1].(map[string]interface{})["modified"] != float64(1) || steps[1].(map[string]interface{})["verdict"] != "no_red_flags" {
+		t.Errorf("steps = %v", steps)
+	}
+
+	// Three samples out of four: newest and oldest are always kept.
+	got = sweep(map[string]interface{}{"samples": 3})
+	var ids []string
+	for _, s := range got["sampled"].([]interface{}) {
+		ids = append(ids, s.(map[string]interface{})["snapshot_id"].(string))
+	}
+	if strings.Join(ids, ",") != "s_1,s_2,s_4" || got["last_clean"].(map[string]interface{})["snapshot_id"] != "s_2" || got["notes"] == nil {
+		t.Errorf("sampled = %v, last_clean = %v, notes = %v", ids, got["last_clean"], got["notes"])
+	}
+
+	// Two samples with a budget of one browse each after the three
+	// snapshot pages: the root is listed, Team/ is not, and the unwalked
+	// folder is not read as a change.
+	browses.Store(0)
+	got = sweep(map[string]interface{}{"samples": 2, "max_api_calls": 9})
+	calls := got["api_calls"].(map[string]interface{})
+	if calls["exhausted"] != true || browses.Load() != 2 || calls["used"] != float64(9) {
+		t.Errorf("api_calls = %v, browses = %d", calls, browses.Load())
+	}
+	if st := got["steps"].([]interface{})[0].(map[string]interface{}); st["removed"] != float64(12) || st["added"] != float64(13) {
+		t.Errorf("budgeted step = %v", st)
+	}
+
+	// A budget too small to page through the window says so instead of
+	// presenting the first page as the whole window.
+	snapshotPages.Store(0)
+	got = sweep(map[string]interface{}{"samples": 2, "max_api_calls": 7})
+	ids = nil
+	for _, s := range got["sampled"].([]interface{}) {
+		ids = append(ids, s.(map[string]interface{})["snapshot_id"].(string))
+	}
+	notes, _ := json.Marshal(got["notes"])
+	if snapshotPages.Load() != 1 || strings.Join(ids, ",") != "s_3,s_4" || !strings.Contains(string(notes), "were not considered") {
+		t.Errorf("pages = %d, sampled = %v, notes = %s", snapshotPages.Load(), ids, notes)
+	}
+}
+
+func TestRecoveryDRDrillHTTP(t *testing.T) {
+	defer func(d time.Duration) { waitInitialInterval = d }(waitInitialInterval)
+	waitInitialInterval = time.Millisecond
+	var mu sync.Mutex
+	polls := map[string]int{}
+	var booted, deleted []string
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case r.URL.Path == "/v1/agent":
+			w.Write([]byte(`{"data":[{"agent_id":"a_web","hostname":"web01","client_id":"c_1"},{"agent_id":"a_dc","hostname":"dc01","client_id":"c_1"},{"agent_id":"a_bad","hostname":"bad01","client_id":"c_1"},{"agent_id":"a_new","hostname":"new01","client_id":"c_1"}],"pagination":{}}`))
+		case r.URL.Path == "/v1/snapshot":
+			agent := r.URL.Query().Get("agent_id")
+			if agent == "a_new" {
+				w.Write([]byte(`{"data":[],"pagination":{}}`))
+				return
+			}
+			verify := `"verify_boot_status":"success","verify_boot_screenshot_url":"https://shots/` + agent + `.png","verify_service_status":"success"`
+			if agent == "a_dc" {
+				verify = `"verify_boot_status":"success","verify_service_status":"failed"`
+			}
+			w.Write([]byte(`{"data":[` +
+				`{"snapshot_id":"s_gone_` + agent + `","agent_id":"` + agent + `","backup_started_at":"2026-10-15T02:00:00Z","deleted":"2026-10-15T03:00:00Z","locations":[]},` +
+				`{"snapshot_id":"s_` + agent + `","agent_id":"` + agent + `","backup_started_at":"2026-10-15T01:00:00Z",` + verify + `,"locations":[{"type":"cloud","device_id":"d_cloud"},{"type":"local","device_id":"d_1"}]}` +
+				`],"pagination":{}}`))
+		case r.URL.Path == "/v1/snapshot/s_a_dc/service-verification":
+			w.Write([]byte(`{"status":"failed","services":[{"service_id":"1","name":"NTDS","state":"stopped"},{"service_id":"2","name":"DNS","state":"running"}]}`))
+		case r.Method == "POST" && r.URL.Path == "/v1/restore/virt":
+			var body map[string]interface{}
+			json.NewDecoder(r.Body).Decode(&body)
+			if body["network_type"] != "network-isolated" || body["device_id"] != "d_1" {
+				t.Errorf("boot body = %v", body)
+			}
+			id := "v_" + strings.TrimPrefix(body["snapshot_id"].(string), "s_")
+			booted = append(booted, id)
+			w.Write([]byte(`{"virt_id":"` + id + `","state":"provisioning"}`))
+		case r.Method == "GET" && strings.HasPrefix(r.URL.Path, "/v1/restore/virt/"):
+			id := strings.TrimPrefix(r.URL.Path, "/v1/restore/virt/")
+			polls[id]++
+			state := "provisioning"
+			if id == "v_a_bad" {
+				state = "error"
+			} else if polls[id] >= 2 {
+				state = "running"
+			}
+			w.Write([]byte(`{"virt_id":"` + id + `","state":"` + state + `","ip_address":"10.0.0.5","rdp_endpoint":"198.51.100.7:3390","vnc":[{"type":"local","websocket_uri":"wss://vnc/` + id + `"}]}`))
+		case r.Method == "DELETE" && strings.HasPrefix(r.URL.Path, "/v1/restore/virt/"):
+			deleted = append(deleted, strings.TrimPrefix(r.URL.Path, "/v1/restore/virt/"))
+			w.Write([]byte(`{}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer api.Close()
+	setupTestEnv(t, ToolsSafe)
+	APIBaseURL = api.URL
+
+	out, err := handleRecoveryTool(context.Background(), map[string]interface{}{"operation": "dr_drill", "client_id": "c_1", "format": "full"})
+	if err != nil {
+		t.Fatalf("dr_drill: %v", err)
+	}
+	var rep drillReport
+	if err := json.Unmarshal([]byte(out), &rep); err != nil {
+		t.Fatalf("parse: %v\n%s", err, out)
+	}
+	if rep.Result != "failed" || rep.Summary.Agents != 4 || rep.Summary.Passed != 1 || rep.Summary.Failed != 2 || rep.Summary.Skipped != 1 {
+		t.Fatalf("result = %s, summary = %+v", rep.Result, rep.Summary)
+	}
+	sort.Strings(booted)
+	sort.Strings(deleted)
+	if strings.Join(booted, ",") != "v_a_bad,v_a_dc,v_a_web" || strings.Join(deleted, ",") != strings.Join(booted, ",") {
+		t.Errorf("booted = %v, deleted = %v", booted, deleted)
+	}
+	if rep.Summary.RTO == nil || rep.Drill.NetworkType != "network-isolated" || rep.Drill.Scope != "client c_1" {
+		t.Errorf("drill = %+v, rto = %v", rep.Drill, rep.Summary.RTO)
+	}
+	byAgent := map[string]*drillAgent{}
+	for _, a := range rep.Agents {
+		byAgent[a.AgentID] = a
+	}
+	status := func(a *drillAgent) string {
+		var parts []string
+		for _, c := range a.Checks {
+			parts = append(parts, c.Name+"="+c.Status)
+		}
+		return strings.Join(parts, " ")
+	}
+	if web := byAgent["a_web"]; web.Outcome != "passed" || web.SnapshotID != "s_a_web" || web.RTOSeconds == nil || web.TornDownAt == "" ||
+		status(web) != "boot=passed remote_access=passed network=passed boot_verification=passed filesystem_verification=skipped service_verification=passed teardown=passed" {
+		t.Errorf("a_web = %+v, checks %s", web, status(web))
+	}
+	if dc := byAgent["a_dc"]; dc.Outcome != "failed" || len(dc.FailingServices) != 1 || dc.FailingServices[0].Name != "NTDS" {
+		t.Errorf("a_dc = %+v, checks %s", dc, status(dc))
+	}
+	if bad := byAgent["a_bad"]; bad.Outcome != "failed" || bad.RTOSeconds != nil || !strings.HasPrefix(status(bad), "boot=failed") || bad.FinalState != "error" {
+		t.Errorf("a_bad = %+v, checks %s", bad, status(bad))
+	}
+	if fresh := byAgent["a_new"]; fresh.Outcome != "skipped" || fresh.Reason != "no restorable snapshot" || fresh.VirtID != "" {
+		t.Errorf("a_new = %+v", fresh)
+	}
+
+	md := renderReportMarkdown(drillReportDoc(&rep))
+	for _, want := range []string{"**Result: FAILED**", "| web01 | **passed** | s_a_web |", "| dc01 | service_verification | **failed** | verify status failed; not running: NTDS |", "- new01: no restorable snapshot"} {
+		if !strings.Contains(md, want) {
+			t.Errorf("drill markdown lacks %q:\n%s", want, md)
+		}
+	}
+
+	// A chosen snapshot is only meaningful for one agent.
+	if _, err := handleRecoveryTool(context.Background(), map[string]interface{}{"operation": "dr_drill", "client_id": "c_1", "snapshot_id": "s_a_web"}); err == nil {
+		t.Error("snapshot_id with client_id: want error")
+	}
+}
+
+func TestWaitForCompletionHTTP(t *testing.T) {
+	defer func(a, b time.Duration) { waitInitialInterval, waitMaxInterval = a, b }(waitInitialInterval, waitMaxInterval)
+	waitInitialInterval, waitMaxInterval = time.Millisecond, time.Millisecond
+	var mu sync.Mutex
+	polls := map[string]int{}
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		mu.Lock()
+		defer mu.Unlock()
+		polls[r.Method+" "+r.URL.Path]++
+		n := polls[r.Method+" "+r.URL.Path]
+		switch {
+		case r.Method == "POST" && r.URL.Path == "/v1/backup":
+			var body map[string]interface{}
+			json.NewDecoder(r.Body).Decode(&body)
+			w.Write([]byte(`{"backup_id":"b_` + body["agent_id"].(string) + `"}`))
+		case r.URL.Path == "/v1/backup/b_a_fail":
+			status := "started"
+			if n >= 2 {
+				status = "failed"
+			}
+			w.Write([]byte(`{"backup_id":"b_a_fail","agent_id":"a_fail","started_at":"2026-10-16T01:00:00Z","status":"` + status + `","error_message":"VSS timeout"}`))
+		case r.URL.Path == "/v1/backup/b_a_slow":
+			w.Write([]byte(`{"backup_id":"b_a_slow","agent_id":"a_slow","started_at":"2026-10-16T01:00:00Z","status":"started"}`))
+		case r.Method == "POST" && r.URL.Path == "/v1/restore/virt":
+			w.Write([]byte(`{"virt_id":"v_1","state":"provisioning"}`))
+		case r.URL.Path == "/v1/restore/virt/v_1":
+			state := "provisioning"
+			if n >= 3 {
+				state = "running"
+			}
+			w.Write([]byte(`{"virt_id":"v_1","state":"` + state + `"}`))
+		case r.Method == "POST" && r.URL.Path == "/v1/restore/file":
+			w.Write([]byte(`{"file_restore_id":"fr_1","device_id":"d_1","snapshot_id":"s_1"}`))
+		case r.URL.Path == "/v1/restore/file/fr_1":
+			w.Write([]byte(`{"file_restore_id":"fr_1","device_id":"d_1","snapshot_id":"s_1"}`))
+		case r.URL.Path == "/v1/restore/file/fr_1/browse":
+			if n < 2 {
+				http.Error(w, `{"message":"not mounted"}`, http.StatusConflict)
+				return
+			}
+			w.Write([]byte(`{"data":[{"name":"C","path":"/C","type":"dir"}],"pagination":{}}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer api.Close()
+	setupTestEnv(t, ToolsSafe)
+	APIBaseURL = api.URL
+
+	waited := func(out string) (map[string]interface{}, waitResult) {
+		t.Helper()
+		var obj map[string]interface{}
+		if err := json.Unmarshal([]byte(out), &obj); err != nil {
+			t.Fatalf("parse: %v\n%s", err, out)
+		}
+		raw, _ := json.Marshal(obj["_wait"])
+		var res waitResult
+		json.Unmarshal(raw, &res)
+		return obj, res
+	}
+
+	out, err := handleRecoveryTool(context.Background(), map[string]interface{}{"operation": "boot_vm", "snapshot_id": "s_1", "device_id": "d_1", "wait": true})
+	if err != nil {
+		t.Fatalf("boot_vm wait: %v", err)
+	}
+	if vm, res := waited(out); vm["state"] != "running" || !res.Succeeded || !res.Terminal || res.Polls != 3 {
+		t.Errorf("boot_vm wait = %v, _wait %+v", vm, res)
+	}
+
+	out, err = handleBackupsTool(context.Background(), map[string]interface{}{"operation": "start", "agent_id": "a_fail", "wait": true})
+	if err != nil {
+		t.Fatalf("start wait: %v", err)
+	}
+	if b, res := waited(out); b["status"] != "failed" || res.Succeeded || !res.Terminal || res.State != "failed" {
+		t.Errorf("start wait = %v, _wait %+v", b, res)
+	}
+
+	out, err = handleBackupsTool(context.Background(), map[string]interface{}{"operation": "start", "agent_id": "a_slow", "wait": true, "wait_timeout_seconds": 1})
+	if err != nil {
+		t.Fatalf("start timeout: %v", err)
+	}
+	if b, res := waited(out); b["backup_id"] != "b_a_slow" || !res.TimedOut || res.Terminal || res.Note == "" {
+		t.Errorf("start timeout = %v, _wait %+v", b, res)
+	}
+
+	// No state field: ready once the restore can be browsed.
+	out, err = handleFilesTool(context.Background(), map[string]interface{}{"operation": "create_restore", "snapshot_id": "s_1", "device_id": "d_1", "wait": true})
+	if err != nil {
+		t.Fatalf("create_restore wait: %v", err)
+	}
+	if fr, res := waited(out); fr["file_restore_id"] != "fr_1" || !res.Succeeded || res.State != "ready" || res.Polls != 2 {
+		t.Errorf("create_restore wait = %v, _wait %+v", fr, res)
+	}
+
+	// Without wait the create response comes back as before.
+	before := polls["GET /v1/restore/virt/v_1"]
+	out, err = handleRecoveryTool(context.Background(), map[string]interface{}{"operation": "boot_vm", "snapshot_id": "s_1", "device_id": "d_1"})
+	if err != nil {
+		t.Fatalf("boot_vm: %v", err)
+	}
+	if strings.Contains(out, "_wait") || polls["GET /v1/restore/virt/v_1"] != before {
+		t.Errorf("boot_vm without wait polled or added _wait:\n%s", out)
+	}
+}
+
+func TestJobsBackgroundHTTP(t *testing.T) {
+	defer func(a, b time.Duration) { waitInitialInterval, waitMaxInterval = a, b }(waitInitialInterval, waitMaxInterval)
+	waitInitialInterval, waitMaxInterval = time.Millisecond, time.Millisecond
+	defer func(r *jobRegistry) { backgroundJobs = r }(backgroundJobs)
+	backgroundJobs = newJobRegistry()
+	// Followers read the poll intervals; let them finish before those are restored.
+	var registries []*jobRegistry
+	defer func() {
+		for _, r := range registries {
+			r.followers.Wait()
+		}
+	}()
+	registries = append(registries, backgroundJobs)
+	var mu sync.Mutex
+	polls := map[string]int{}
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		mu.Lock()
+		defer mu.Unlock()
+		polls[r.Method+" "+r.URL.Path]++
+		n := polls[r.Method+" "+r.URL.Path]
+		switch {
+		case r.Method == "POST" && r.URL.Path == "/v1/restore/virt":
+			w.Write([]byte(`{"virt_id":"v_1","state":"provisioning"}`))
+		case r.URL.Path == "/v1/restore/virt/v_1":
+			state := "provisioning"
+			if n >= 3 {
+				state = "running"
+			}
+			w.Write([]byte(`{"virt_id":"v_1","state":"` + state + `","vnc_password":"hunter2"}`))
+		case r.Method == "POST" && r.URL.Path == "/v1/backup":
+			w.Write([]byte(`{"backup_id":"b_slow"}`))
+		case r.URL.Path == "/v1/backup/b_slow":
+			w.Write([]byte(`{"backup_id":"b_slow","agent_id":"a_1","started_at":"2026-10-16T01:00:00Z","status":"started"}`))
+		case r.URL.Path == "/v1/backup/b_saved":
+			w.Write([]byte(`{"backup_id":"b_saved","agent_id":"a_1","started_at":"2026-10-16T01:00:00Z","status":"succeeded"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer api.Close()
+	setupTestEnv(t, ToolsSafe)
+	APIBaseURL = api.URL
+	ctx := context.Background()
+
+	settle := func(ctx context.Context, id string) map[string]interface{} {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			out, err := handleJobsTool(ctx, map[string]interface{}{"operation": "get", "job_id": id})
+			if err != nil {
+				t.Fatalf("get %s: %v", id, err)
+			}
+			var view map[string]interface{}
+			json.Unmarshal([]byte(out), &view)
+			if view["status"] != jobRunning || time.Now().After(deadline) {
+				return view
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+
+	if _, err := handleRecoveryTool(ctx, map[string]interface{}{"operation": "boot_vm", "snapshot_id": "s_1", "device_id": "d_1", "wait": true, "background": true}); err == nil {
+		t.Error("wait and background together: want error")
+	}
+	if polls["POST /v1/restore/virt"] != 0 {
+		t.Error("a rejected wait+background call still created the VM")
+	}
+
+	out, err := handleRecoveryTool(ctx, map[string]interface{}{"operation": "boot_vm", "snapshot_id": "s_1", "device_id": "d_1", "background": true})
+	if err != nil {
+		t.Fatalf("boot_vm background: %v", err)
+	}
+	var created struct {
+		VirtID    string   `json:"virt_id"`
+		Job       job      `json:"_job"`
+		NextSteps []string `json:"next_steps"`
+	}
+	if err := json.Unmarshal([]byte(out), &created); err != nil {
+		t.Fatalf("parse: %v\n%s", err, out)
+	}
+	if created.VirtID != "v_1" || created.Job.JobID == "" || created.Job.Kind != "vm" || created.Job.Tool != "slide_recovery" || created.Job.URI != "slide://jobs/"+created.Job.JobID || len(created.NextSteps) == 0 {
+		t.Fatalf("boot_vm background = %s", out)
+	}
+	vm := settle(ctx, created.Job.JobID)
+	obj, _ := vm["object"].(map[string]interface{})
+	if vm["status"] != jobSucceeded || vm["state"] != "running" || obj["virt_id"] != "v_1" {
+		t.Errorf("vm job = %v", vm)
+	}
+
+	// Another tenant cannot see it, through the tool or the resource.
+	other := newAPISession("tk_other")
+	other.key = apiSessionKey("s_other", "tk_other")
+	tenant := context.WithValue(ctx, apiSessionContextKey{}, other)
+	if _, err := handleJobsTool(tenant, map[string]interface{}{"operation": "get", "job_id": created.Job.JobID}); err == nil {
+		t.Error("other session read the job")
+	}
+	if _, err := handleResourceJob(tenant, "slide://jobs/"+created.Job.JobID); err == nil {
+		t.Error("other session read the job resource")
+	}
+	if body, err := handleResourceJob(ctx, "slide://jobs/"+created.Job.JobID); err != nil || !strings.Contains(string(body), `"status":"succeeded"`) {
+		t.Errorf("job resource = %s, %v", body, err)
+	}
+
+	// Saved jobs: ids and states only, resumed on open.
+	dir := t.TempDir()
+	path := filepath.Join(dir, "jobs.json")
+	saved := `{"jobs":[{"job_id":"job_saved","uri":"slide://jobs/job_saved","kind":"backup","label":"backup b_saved","target":{"backup_id":"b_saved"},"status":"running","started_at":"` + time.Now().UTC().Format(time.RFC3339) + `","updated_at":"x"}]}`
+	if err := os.WriteFile(path, []byte(saved), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := backgroundJobs.open(path); err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	if resumed := settle(ctx, "job_saved"); resumed["status"] != jobSucceeded || resumed["state"] != "succeeded" {
+		t.Errorf("resumed job = %v", resumed)
+	}
+
+	out, err = handleBackupsTool(ctx, map[string]interface{}{"operation": "start", "agent_id": "a_1", "background": true})
+	if err != nil {
+		t.Fatalf("start background: %v", err)
+	}
+	json.Unmarshal([]byte(out), &created)
+	out, err = handleJobsTool(ctx, map[string]interface{}{"operation": "cancel", "job_id": created.Job.JobID})
+	if err != nil || !strings.Contains(out, `"status":"canceled"`) || !strings.Contains(out, "slide_backups operation=get backup_id=b_slow") {
+		t.Errorf("cancel = %s, %v", out, err)
+	}
+	out, _ = handleJobsTool(ctx, map[string]interface{}{"operation": "list", "status": "running"})
+	if !strings.Contains(out, `"count":0`) {
+		t.Errorf("running jobs after cancel = %s", out)
+	}
+	out, _ = handleJobsTool(ctx, map[string]interface{}{"operation": "list"})
+	if !strings.Contains(out, `"count":3`) {
+		t.Errorf("jobs = %s", out)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("stat jobs file: %v", err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Errorf("jobs file mode = %v", info.Mode().Perm())
+	}
+	file, _ := os.ReadFile(path)
+	if strings.Contains(string(file), "hunter2") || strings.Count(string(file), `"job_id"`) != 3 || !strings.Contains(string(file), `"status": "canceled"`) {
+		t.Errorf("jobs file:\n%s", file)
+	}
+
+	// A fresh registry reading the file keeps finished jobs but not their objects.
+	backgroundJobs = newJobRegistry()
+	registries = append(registries, backgroundJobs)
+	if err := backgroundJobs.open(path); err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	reloaded := settle(ctx, created.Job.JobID)
+	if reloaded["status"] != jobCanceled || reloaded["object"] != nil || reloaded["object_note"] == nil {
+		t.Errorf("reloaded job = %v", reloaded)
+	}
+}
+
+func TestFilesSearchClientHTTP(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/v1/agent":
+			if r.URL.Query().Get("client_id") != "c_acme" {
+				t.Errorf("agents query = %q", r.URL.RawQuery)
+			}
+			w.Write([]byte(`{"data":[` +
+				`{"agent_id":"a_fs","hostname":"fileserver","file_index_enabled":true},` +
+				`{"agent_id":"a_lap","hostname":"laptop"},` +
+				`{"agent_id":"a_off","hostname":"kiosk","file_index_enabled":false},` +
+				`{"agent_id":"a_slow","hostname":"slow"}],"pagination":{}}`))
+		case "/v1/agent/a_fs/file-search":
+			w.Write([]byte(`{"data":[{"path":"D:\\Share\\Legal\\old-contract.pdf","modified_time":"2026-01-01T00:00:00Z"},{"path":"D:\\Share\\Contract\\notes.txt","modified_time":"2026-03-01T00:00:00Z"}],"pagination":{}}`))
+		case "/v1/agent/a_lap/file-search":
+			w.Write([]byte(`{"data":[{"path":"C:\\Users\\amy\\contract.pdf","modified_time":"2025-12-01T00:00:00Z"}],"pagination":{"next_offset":50}}`))
+		case "/v1/agent/a_slow/file-search":
+			select {
+			case <-release:
+			case <-r.Context().Done():
+			}
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer api.Close()
+	setupTestEnv(t, ToolsReadOnly)
+	APIBaseURL = api.URL
+
+	out, err := handleFilesTool(context.Background(), map[string]interface{}{
+		"operation": "search_client", "client_id": "c_acme", "search_term": "contract.pdf", "timeout_seconds": float64(1), "format": "full",
+	})
+	if err != nil {
+		t.Fatalf("search_client: %v", err)
+	}
+	var got struct {
+		Summary struct {
+			Agents        int            `json:"agents"`
+			Searched      int            `json:"searched"`
+			MatchedAgents int            `json:"matched_agents"`
+			Skipped       map[string]int `json:"skipped"`
+			TimedOut      bool           `json:"timed_out"`
+		} `json:"summary"`
+		Results []clientFileHit `json:"results"`
+		Skipped []searchSkip    `json:"skipped_agents"`
+		Note    string          `json:"note"`
+	}
+	if err := json.Unmarshal([]byte(out), &got); err != nil {
+		t.Fatalf("parse: %v\n%s", err, out)
+	}
+	s := got.Summary
+	if s.Agents != 4 || s.Searched != 2 || s.MatchedAgents != 2 || !s.TimedOut ||
+		s.Skipped[searchSkipIndexDisabled] != 1 || s.Skipped[searchSkipTimedOut] != 1 {
+		t.Errorf("summary = %+v", s)
+	}
+	var order []string
+	for _, h := range got.Results {
+		order = append(order, h.AgentID+":"+h.Match)
+	}
+	if strings.Join(order, ",") != "a_lap:exact,a_fs:name,a_fs:path" {
+		t.Errorf("ranking = %v", order)
+	}
+	if len(got.Skipped) != 2 || got.Skipped[0].AgentID != "a_off" || got.Skipped[1].AgentID != "a_slow" {
+		t.Errorf("skipped_agents = %+v", got.Skipped)
+	}
+	if !strings.Contains(got.Note, "1 agent(s) had more than 50 matches") {
+		t.Errorf("note = %q", got.Note)
+	}
+}
+
+func TestFilesDownloadResumesAndHashes(t *testing.T) {
+	content := []byte(strings.Repeat("quarterly numbers\n", 20000))
+	sum := sha256.Sum256(content)
+	var ranges []string
+	var apiURL string
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/restore/file/fr_1/browse":
+			w.Write([]byte(fmt.Sprintf(`{"data":[{"name":"Q4.xlsx","path":"/C:/Docs/Q4.xlsx","size":%d,"download_uris":[{"type":"smb","uri":"smb://x/Q4.xlsx"},{"type":"http","uri":"%s/dl/Q4.xlsx"}]}],"pagination":{}}`, len(content), apiURL)))
+		case "/v1/restore/image/ie_1/browse":
+			w.Write([]byte(`{"data":[{"disk_id":"disk_0","name":"C.vhdx","size":5242880,"download_uris":[{"type":"http","uri":"https://dl.invalid/C.vhdx"}]},{"disk_id":"disk_1","name":"D.vhdx","size":10}],"pagination":{}}`))
+		case "/dl/Q4.xlsx":
+			if r.Header.Get("Authorization") != "Bearer tk_test" {
+				t.Errorf("download from the API host without the token")
+			}
+			ranges = append(ranges, r.Header.Get("Range"))
+			http.ServeContent(w, r, "Q4.xlsx", time.Time{}, bytes.NewReader(content))
+		case "/dl/skewed.bin":
+			// Answers every range request from byte 5000, whatever was asked.
+			if r.Header.Get("Range") == "" {
+				w.Write(content)
+				return
+			}
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes 5000-%d/%d", len(content)-1, len(content)))
+			w.WriteHeader(http.StatusPartialContent)
+			w.Write(content[5000:])
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer api.Close()
+	apiURL = api.URL
+	setupTestEnv(t, ToolsSafe)
+	APIBaseURL = api.URL
+	download := func(args map[string]interface{}) (map[string]interface{}, error) {
+		args["format"] = "full"
+		out, err := handleFilesTool(context.Background(), args)
+		if err != nil {
+			return nil, err
+		}
+		var got map[string]interface{}
+		if err := json.Unmarshal([]byte(out), &got); err != nil {
+			t.Fatalf("parse: %v\n%s", err, out)
+		}
+		return got, nil
+	}
+	args := func() map[string]interface{} {
+		return map[string]interface{}{"operation": "download", "file_restore_id": "fr_1", "source_file_path": "/C:/Docs/Q4.xlsx"}
+	}
+
+	if _, err := download(args()); err == nil || !strings.Contains(err.Error(), "--download-dir") {
+		t.Fatalf("download without --download-dir: err = %v", err)
+	}
+
+	currentConfig().DownloadDir = t.TempDir()
+	dest := filepath.Join(currentConfig().DownloadDir, "fr_1", "Q4.xlsx")
+	if err := os.MkdirAll(filepath.Dir(dest), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(dest+".part", content[:100000], 0o600); err != nil {
+		t.Fatal(err)
+	}
+	got, err := download(args())
+	if err != nil {
+		t.Fatalf("download: %v", err)
+	}
+	res := got["download"].(map[string]interface{})
+	if res["sha256"] != hex.EncodeToString(sum[:]) || res["resumed_from"] != float64(100000) || res["file_path"] != dest {
+		t.Errorf("download = %v", res)
+	}
+	if len(ranges) != 1 || ranges[0] != "bytes=100000-" {
+		t.Errorf("range requests = %q, want one resuming at 100000", ranges)
+	}
+	saved, err := os.ReadFile(dest)
+	if err != nil || !bytes.Equal(saved, content) {
+		t.Fatalf("saved file differs (err %v, %d bytes)", err, len(saved))
+	}
+	if info, _ := os.Stat(dest); runtime.GOOS != "windows" && info.Mode().Perm() != 0o600 {
+		t.Errorf("mode = %04o, want 0600", info.Mode().Perm())
+	}
+	if _, err := os.Stat(dest + ".part"); !os.IsNotExist(err) {
+		t.Errorf(".part left behind: %v", err)
+	}
+
+	got, err = download(args())
+	if err != nil || got["download"].(map[string]interface{})["already_complete"] != true || len(ranges) != 1 {
+		t.Errorf("second download = %v, %v (requests %d)", got, err, len(ranges))
+	}
+
+	skewed := filepath.Join(currentConfig().DownloadDir, "fr_1", "skewed.bin")
+	if err := os.WriteFile(skewed+".part", content[:1000], 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if res, err := downloadFile(context.Background(), api.URL+"/dl/skewed.bin", "fr_1", "skewed.bin", int64(len(content))); err != nil || res.ResumedFrom != 0 {
+		t.Errorf("download answered with the wrong range = %+v, %v; want a fresh download", res, err)
+	}
+	if saved, err := os.ReadFile(skewed); err != nil || !bytes.Equal(saved, content) {
+		t.Errorf("file saved from a mismatched range differs (err %v, %d bytes)", err, len(saved))
+	}
+
+	for _, sub := range []string{"..", "../fr_1", "fr_1/../..", `..\fr_1`, "/tmp", ""} {
+		if _, err := downloadFile(context.Background(), api.URL+"/dl/Q4.xlsx", sub, "Q4.xlsx", int64(len(content))); err == nil || !strings.Contains(err.Error(), "single path element") {
+			t.Errorf("download into folder %q: err = %v", sub, err)
+		}
+	}
+
+	currentConfig().DownloadMaxMB = 1
+	_, err = handleRecoveryTool(context.Background(), map[string]interface{}{"operation": "download_image", "image_export_id": "ie_1"})
+	if err == nil || !strings.Contains(err.Error(), "pass disk_id") {
+		t.Errorf("two-disk export without disk_id: err = %v", err)
+	}
+	_, err = handleRecoveryTool(context.Background(), map[string]interface{}{"operation": "download_image", "image_export_id": "ie_1", "disk_id": "disk_0"})
+	if err == nil || !strings.Contains(err.Error(), "over the 1 MB download limit") {
+		t.Errorf("oversized image: err = %v", err)
+	}
+
+	currentConfig().Transport = TransportHTTP
+	if _, err := download(args()); err == nil || !strings.Contains(err.Error(), "stdio") {
+		t.Errorf("download over HTTP: err = %v", err)
+	}
+
+	currentConfig().Transport = TransportStdio
+	currentConfig().ToolsMode = ToolsReadOnly
+	if _, err := download(args()); err == nil {
+		t.Error("download allowed in read-only mode")
+	}
+}
+
+func TestFilesTreeHTTP(t *testing.T) {
+	dirs := map[string]string{
+		"/C:":                     `[{"name":"Users","path":"/C:/Users","type":"dir"},{"name":"boot.ini","path":"/C:/boot.ini","type":"file","size":200,"modified_at":"2026-01-02T03:04:05Z"}]`,
+		"/C:/Users":               `[{"name":"bob","path":"/C:/Users/bob","type":"dir"}]`,
+		"/C:/Users/bob":           `[{"name":"AppData","path":"/C:/Users/bob/AppData","type":"dir"},{"name":"Documents","path":"/C:/Users/bob/Documents","type":"dir"}]`,
+		"/C:/Users/bob/Documents": `[{"name":"Q4.xlsx","path":"/C:/Users/bob/Documents/Q4.xlsx","type":"file","size":2048,"modified_at":"2026-03-01T10:20:30Z"},{"name":"notes.txt","path":"/C:/Users/bob/Documents/notes.txt","type":"file","size":10}]`,
+	}
+	var mu sync.Mutex
+	var browsed []string
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/restore/file/fr_1/browse":
+			p := r.URL.Query().Get("path")
+			mu.Lock()
+			browsed = append(browsed, p)
+			mu.Unlock()
+			data, ok := dirs[p]
+			if !ok {
+				data = "[]"
+			}
+			w.Write([]byte(`{"data":` + data + `,"pagination":{}}`))
+		case "/v1/restore/image/ie_1/browse":
+			w.Write([]byte(`{"data":[{"disk_id":"disk_0","name":"C.vhdx","size":5242880},{"disk_id":"disk_1","name":"D.vhdx","size":10}],"pagination":{}}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer api.Close()
+	setupTestEnv(t, ToolsReadOnly)
+	APIBaseURL = api.URL
+	tree := func(handler func(context.Context, map[string]interface{}) (string, error), args map[string]interface{}) map[string]interface{} {
+		t.Helper()
+		args["format"] = "full"
+		out, err := handler(context.Background(), args)
+		if err != nil {
+			t.Fatalf("%v: %v", args, err)
+		}
+		var got map[string]interface{}
+		if err := json.Unmarshal([]byte(out), &got); err != nil {
+			t.Fatalf("parse: %v\n%s", err, out)
+		}
+		return got
+	}
+
+	got := tree(handleFilesTool, map[string]interface{}{
+		"operation": "tree", "file_restore_id": "fr_1", "browse_path": "C:", "exclude": []interface{}{"AppData"},
+	})
+	want := strings.Join([]string{
+		"Users/",
+		"  bob/",
+		"    Documents/ …",
+		"boot.ini  200 B  2026-01-02 03:04",
+	}, "\n")
+	if got["tree"] != want {
+		t.Errorf("tree =\n%s\nwant\n%s", got["tree"], want)
+	}
+	for _, p := range browsed {
+		if strings.Contains(p, "AppData") {
+			t.Errorf("excluded folder was browsed: %s", p)
+		}
+	}
+
+	got = tree(handleFilesTool, map[string]interface{}{
+		"operation": "tree", "file_restore_id": "fr_1", "browse_path": "/C:", "max_depth": 5, "include": "*.xlsx",
+	})
+	want = strings.Join([]string{
+		"Users/",
+		"  bob/",
+		"    Documents/",
+		"      Q4.xlsx  2.0 KiB  2026-03-01 10:20",
+	}, "\n")
+	if got["tree"] != want {
+		t.Errorf("include tree =\n%s\nwant\n%s", got["tree"], want)
+	}
+	if s := got["summary"].(map[string]interface{}); s["files"] != float64(1) || s["total_bytes"] != float64(2048) || s["truncated"] != false {
+		t.Errorf("summary = %v", s)
+	}
+
+	got = tree(handleFilesTool, map[string]interface{}{
+		"operation": "tree", "file_restore_id": "fr_1", "browse_path": "/C:", "max_depth": 5, "max_entries": 3,
+	})
+	if s := got["summary"].(map[string]interface{}); s["truncated"] != true || s["entries_browsed"] != float64(3) || got["note"] == nil {
+		t.Errorf("capped summary = %v, note = %v", s, got["note"])
+	}
+
+	got = tree(handleRecoveryTool, map[string]interface{}{
+		"operation": "tree_image", "image_export_id": "ie_1", "exclude": []interface{}{"d.*"},
+	})
+	if got["tree"] != "C.vhdx  5.0 MiB  disk_id=disk_0" {
+		t.Errorf("tree_image = %q", got["tree"])
+	}
+}
@@ -0,0 +1,235 @@
+package main
+
+// Per-session Slide identity for the HTTP transport.
+//
+// stdio and one-shot calls run against processSession: the --api-key /
+// SLIDE_API_KEY token plus the process-wide client and name-resolver
+// caches. When an HTTP request carries `Authorization: Bearer <slide
+// token>`, the tool or resource call runs against an apiSession keyed by
+// (MCP session ID, token) so the API calls, the client cache, and the
+// name-resolver cache never leak between the MSP techs sharing one
+// server. HTTP requests without one use the process token and its caches,
+// but still get an apiSession keyed by their MCP session, so background
+// jobs and resource subscriptions stay with the session that made them.
+//
+// The session travels on the context.Context handed to every handler;
+// performSingleAPIRequestContext reads the token from it.
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// apiSessionIdleTTL bounds how long an idle tenant's caches are kept when
+// the host never terminates its MCP session.
+const apiSessionIdleTTL = 30 * time.Minute
+
+// apiSession is one Slide identity plus the caches derived from it.
+type apiSession struct {
+	apiKey    string // empty means "use the process token"
+	key       string // apiSessionKey; empty for processSession
+	clients   *clientCacheStore
+	names     *nameCacheStore
+	rateLimit *rateLimitGate
+	lastUsed  time.Time
+}
+
+func newAPISession(token string) *apiSession {
+	return &apiSession{
+		apiKey:    token,
+		clients:   newClientCacheStore(),
+		names:     newNameCacheStore(),
+		rateLimit: &rateLimitGate{},
+		lastUsed:  time.Now(),
+	}
+}
+
+// processIdentityMu guards apiKey / APIBaseURL while switch_profile swaps
+// the process identity underneath in-flight calls.
+var processIdentityMu sync.RWMutex
+
+// token returns the Slide API token requests in this session authenticate
+// with. processSession defers to the package-level apiKey so tests and
+// main.go can keep assigning it directly.
+func (s *apiSession) token() string {
+	if s.apiKey != "" {
+		return s.apiKey
+	}
+	processIdentityMu.RLock()
+	defer processIdentityMu.RUnlock()
+	return apiKey
+}
+
+// baseURL returns the Slide API base URL for this session.
+func (s *apiSession) baseURL() string {
+	processIdentityMu.RLock()
+	defer processIdentityMu.RUnlock()
+	return APIBaseURL
+}
+
+// resetCaches drops everything derived from the previous identity.
+func (s *apiSession) resetCaches() {
+	s.clients.reset()
+	s.names.reset()
+}
+
+var processSession = newAPISession("")
+
+type (
+	apiSessionContextKey   struct{}
+	requestTokenContextKey struct{}
+	httpRequestContextKey  struct{}
+)
+
+// newProcessTokenSession is an HTTP session's identity on the process
+// token: the token, caches, and rate limit are processSession's, and only
+// the key is its own.
+func newProcessTokenSession(key string) *apiSession {
+	return &apiSession{
+		key:       key,
+		clients:   processSession.clients,
+		names:     processSession.names,
+		rateLimit: processSession.rateLimit,
+	}
+}
+
+// apiKeyScope labels where a session's token came from for diagnostics.
+func apiKeyScope(s *apiSession) string {
+	if s.apiKey == "" {
+		return "process"
+	}
+	return "request"
+}
+
+// sessionFromContext returns the session bound to ctx, or processSession.
+func sessionFromContext(ctx context.Context) *apiSession {
+	if ctx != nil {
+		if s, ok := ctx.Value(apiSessionContextKey{}).(*apiSession); ok {
+			return s
+		}
+	}
+	return processSession
+}
+
+// detachedContext carries s on a fresh context, for background work that
+// must outlive the request that started it and report nothing to it.
+func detachedContext(s *apiSession) (context.Context, context.CancelFunc) {
+	return context.WithCancel(context.WithValue(context.Background(), apiSessionContextKey{}, s))
+}
+
+// apiSessions holds the per-tenant sessions created for HTTP callers.
+var apiSessions = struct {
+	sync.Mutex
+	byKey map[string]*apiSession
+}{
+	byKey: map[string]*apiSession{},
+}
+
+// apiSessionKey never stores the raw token: a short SHA-256 fingerprint is
+// enough to keep two identities on the same MCP session apart.
+func apiSessionKey(mcpSessionID, token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return mcpSessionID + "|" + hex.EncodeToString(sum[:8])
+}
+
+// bindAPISession attaches the caller's apiSession to ctx. stdio and
+// one-shot calls keep using processSession.
+func bindAPISession(ctx context.Context) context.Context {
+	token, _ := ctx.Value(requestTokenContextKey{}).(string)
+	if _, overHTTP := ctx.Value(httpRequestContextKey{}).(bool); !overHTTP && token == "" {
+		return ctx
+	}
+	mcpSessionID := ""
+	if cs := server.ClientSessionFromContext(ctx); cs != nil {
+		mcpSessionID = cs.SessionID()
+	}
+	key := apiSessionKey(mcpSessionID, token)
+	now := time.Now()
+
+	apiSessions.Lock()
+	defer apiSessions.Unlock()
+	for k, s := range apiSessions.byKey {
+		if now.Sub(s.lastUsed) > apiSessionIdleTTL {
+			delete(apiSessions.byKey, k)
+		}
+	}
+	s, ok := apiSessions.byKey[key]
+	if !ok {
+		if token == "" {
+			s = newProcessTokenSession(key)
+		} else {
+			s = newAPISession(token)
+			s.key = key
+		}
+		apiSessions.byKey[key] = s
+	}
+	s.lastUsed = now
+	return context.WithValue(ctx, apiSessionContextKey{}, s)
+}
+
+// dropAPISessions forgets every tenant bound to an MCP session once the
+// transport unregisters it, and stops their background jobs.
+func dropAPISessions(mcpSessionID string) {
+	prefix := mcpSessionID + "|"
+	apiSessions.Lock()
+	for k := range apiSessions.byKey {
+		if strings.HasPrefix(k, prefix) {
+			delete(apiSessions.byKey, k)
+		}
+	}
+	apiSessions.Unlock()
+	backgroundJobs.stopOwned(sessionJobOwner(mcpSessionID), "", true)
+}
+
+// sessionHooks releases tenant caches and resource subscriptions when an
+// MCP session ends, and tracks resources/subscribe (see subscriptions.go).
+func sessionHooks() *server.Hooks {
+	hooks := &server.Hooks{}
+	hooks.AddOnUnregisterSession(func(_ context.Context, session server.ClientSession) {
+		dropAPISessions(session.SessionID())
+		resourceWatches.dropSession(session.SessionID())
+	})
+	hooks.AddAfterSubscribe(func(ctx context.Context, _ any, req *mcp.SubscribeRequest, _ *mcp.EmptyResult) {
+		resourceWatches.subscribe(ctx, req.Params.URI)
+	})
+	hooks.AddAfterUnsubscribe(func(ctx context.Context, _ any, req *mcp.UnsubscribeRequest, _ *mcp.EmptyResult) {
+		resourceWatches.unsubscribe(ctx, req.Params.URI)
+	})
+	return hooks
+}
+
+// bearerToken extracts the token from an `Authorization: Bearer` header.
+func bearerToken(r *http.Request) string {
+	h := strings.TrimSpace(r.Header.Get("Authorization"))
+	if len(h) < 7 || !strings.EqualFold(h[:7], "bearer ") {
+		return ""
+	}
+	return strings.TrimSpace(h[7:])
+}
+
+// sharedSecretBearer reports whether r's bearer token is the server's
+// --http-auth-token rather than a Slide token of the caller's own.
+func sharedSecretBearer(r *http.Request) bool {
+	secret := currentConfig().HTTPAuthToken
+	return secret != "" && subtle.ConstantTimeCompare([]byte(bearerToken(r)), []byte(secret)) == 1
+}
+
+// withRequestToken is installed as the HTTP/SSE context func so the
+// per-request Slide token reaches bindAPISession, and so requests without
+// one are still bound to their MCP session.
+func withRequestToken(ctx context.Context, r *http.Request) context.Context {
+	ctx = context.WithValue(ctx, httpRequestContextKey{}, true)
+	if token := bearerToken(r); token != "" && !sharedSecretBearer(r) {
+		return context.WithValue(ctx, requestTokenContextKey{}, token)
+	}
+	return ctx
+}
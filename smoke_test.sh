@@ -142,6 +142,7 @@ run_one_shot "slide_overview inventory"     "slide_overview"  '{"operation":"inv
 run_one_shot "slide_audit recent"           "slide_audit"     '{"operation":"recent","hours":24}'
 run_one_shot "slide_audit actions"          "slide_audit"     '{"operation":"actions","limit":5}'
 run_one_shot "slide_alerts triage"          "slide_alerts"    '{"operation":"triage"}'
+run_one_shot "slide_jobs list"              "slide_jobs"      '{"operation":"list"}'
 run_one_shot "slide_files restores"         "slide_files"     '{"operation":"list_restores","limit":3}'
 
 echo
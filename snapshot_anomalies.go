@@ -0,0 +1,296 @@
+package main
+
+// slide_snapshots change_anomalies: which recent snapshot is the last
+// clean one?
+//
+// It samples up to `samples` of an agent's recent restorable snapshots,
+// walks the same folder tree in each (diff_snapshots' walker and
+// comparison), and runs the ransomware heuristics over every step from
+// one sampled snapshot to the next. The first step that looks like an
+// attack marks the boundary: the snapshot before it is the last clean
+// restore point among those sampled.
+//
+// Walking several restores is expensive, so the operation keeps its own
+// API call budget (max_api_calls). Listing the window's snapshots comes
+// out of it first, a page at a time, and the browse share is split evenly
+// between the snapshots so each walk covers the same folders; a walk that
+// runs out leaves its remaining folders unexpanded, and those are left out
+// of the comparison rather than read as deletions. Deleting the restores
+// it created afterwards is not charged to the budget.
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+const (
+	anomalyDefaultDays     = 7
+	anomalyDefaultSamples  = 5
+	anomalyMaxSamples      = 10
+	anomalyDefaultDepth    = 4
+	anomalyDefaultBudget   = 300
+	anomalyMaxBudget       = 2000
+	anomalyMaxEntries      = snapDiffMaxEntries
+	anomalyChurnSpikeMin   = 50 // changed files before a spike is flagged
+	anomalyChurnSpikeRatio = 5  // times the median step's churn
+)
+
+// anomalyStep is the change from one sampled snapshot to the next.
+type anomalyStep struct {
+	FromSnapshotID string      `json:"from_snapshot_id"`
+	ToSnapshotID   string      `json:"to_snapshot_id"`
+	FromTime       string      `json:"from_time,omitempty"`
+	ToTime         string      `json:"to_time,omitempty"`
+	Compared       int         `json:"compared"`
+	Added          int         `json:"added"`
+	Removed        int         `json:"removed"`
+	Modified       int         `json:"modified"`
+	NotCompared    int         `json:"not_compared,omitempty"`
+	ChurnPct       float64     `json:"churn_pct"`
+	Verdict        string      `json:"verdict"`
+	Suspicious     []suspicion `json:"suspicious"`
+
+	changed int
+}
+
+// handleSnapshotsChangeAnomalies samples an agent's recent snapshots and
+// reports the last one before abnormal churn.
+func handleSnapshotsChangeAnomalies(ctx context.Context, args map[string]interface{}) (string, error) {
+	agentID, err := requireString(args, "agent_id")
+	if err != nil {
+		return "", err
+	}
+	days, ok := optionalInt(args, "days")
+	if !ok || days <= 0 {
+		days = anomalyDefaultDays
+	}
+	samples, ok := optionalInt(args, "samples")
+	if !ok || samples <= 0 {
+		samples = anomalyDefaultSamples
+	}
+	samples = max(2, min(samples, anomalyMaxSamples))
+	budgetCalls, ok := optionalInt(args, "max_api_calls")
+	if !ok || budgetCalls <= 0 {
+		budgetCalls = anomalyDefaultBudget
+	}
+	budgetCalls = min(budgetCalls, anomalyMaxBudget)
+	root, _ := optionalString(args, "browse_path")
+	if root == "" {
+		root = "/"
+	}
+	root = restoreBrowsePath(root)
+	maxDepth, maxEntries, filter, err := treeOptions(args, treeLimits{anomalyDefaultDepth, anomalyMaxEntries, anomalyMaxEntries})
+	if err != nil {
+		return "", err
+	}
+
+	// Fixed costs: the agent, the snapshot pages, the open-restore list,
+	// and one create per sample. Paging stops while every sample can
+	// still make one browse request.
+	if minimum := 3 + 2*samples; budgetCalls < minimum {
+		return "", fmt.Errorf("max_api_calls=%d leaves no browse requests for %d samples; allow at least %d", budgetCalls, samples, minimum)
+	}
+	maxPages := budgetCalls - 2 - 2*samples
+
+	agent, err := fetchAgent(ctx, agentID)
+	if err != nil {
+		return "", err
+	}
+	loc := agentLocation(agent)
+	sampled, restorable, pages, listedSince, err := sampleRecentSnapshots(ctx, agentID, days, samples, maxPages, loc)
+	if err != nil {
+		return "", err
+	}
+	overhead := 2 + pages + samples
+	share := (budgetCalls - overhead) / samples
+	if len(sampled) < 2 {
+		return "", fmt.Errorf("%s has %d restorable snapshot(s) in the last %d days; at least 2 are needed (raise days)", bestAgentName(*agent), len(sampled), days)
+	}
+
+	pool, err := newRestorePool(ctx, agentID)
+	if err != nil {
+		return "", err
+	}
+	for _, s := range sampled {
+		s.budget = newCallBudget(share)
+	}
+	_, err = fanOut(ctx, sampled, func(ctx context.Context, s *snapSide) struct{} {
+		s.err = walkSnapshot(ctx, pool, s, root, maxDepth, maxEntries, filter)
+		progressStep(ctx, -1, "walked snapshot %s", s.SnapshotID)
+		return struct{}{}
+	})
+	for _, s := range sampled {
+		if err == nil {
+			err = s.err
+		}
+	}
+	used := 2 + pages + len(pool.created)
+	cleanup, cleanupErr := pool.release(ctx)
+	if err != nil {
+		if cleanupErr != nil {
+			err = fmt.Errorf("%w (%v)", err, cleanupErr)
+		}
+		return "", err
+	}
+
+	steps := make([]*anomalyStep, 0, len(sampled)-1)
+	for i := 1; i < len(sampled); i++ {
+		steps = append(steps, compareAnomalyStep(sampled[i-1], sampled[i]))
+	}
+	flagChurnSpikes(steps)
+
+	exhausted := false
+	for _, s := range sampled {
+		// A refused request takes left below zero.
+		left := s.budget.left.Load()
+		used += share - int(max(left, 0))
+		exhausted = exhausted || left < 0
+	}
+
+	result := map[string]interface{}{
+		"agent_id":    agentID,
+		"agent_name":  bestAgentName(*agent),
+		"root":        root,
+		"window_days": days,
+		"sampled":     sampled,
+		"steps":       steps,
+		"api_calls":   map[string]interface{}{"budget": budgetCalls, "used": used, "exhausted": exhausted},
+	}
+	var notes []string
+	verdict := "no_red_flags"
+	var firstBad *anomalyStep
+	for _, st := range steps {
+		switch st.Verdict {
+		case "suspicious":
+			if firstBad == nil {
+				firstBad = st
+			}
+			verdict = "suspicious"
+		case "review":
+			if verdict == "no_red_flags" {
+				verdict = "review"
+			}
+		}
+	}
+	result["verdict"] = verdict
+	newest := sampled[len(sampled)-1]
+	if firstBad == nil {
+		result["last_clean"] = map[string]interface{}{"snapshot_id": newest.SnapshotID, "snapshot_time": newest.SnapshotTime}
+	} else {
+		result["last_clean"] = map[string]interface{}{"snapshot_id": firstBad.FromSnapshotID, "snapshot_time": firstBad.FromTime}
+		result["first_suspicious"] = map[string]interface{}{"snapshot_id": firstBad.ToSnapshotID, "snapshot_time": firstBad.ToTime}
+		result["next_step"] = fmt.Sprintf("slide_files operation=diff_snapshots agent_id=%s from_snapshot_id=%s to_snapshot_id=%s browse_path=%s for the full list of changes", agentID, firstBad.FromSnapshotID, firstBad.ToSnapshotID, root)
+		if firstBad == steps[0] {
+			notes = append(notes, "The oldest sampled step already looks suspicious; the attack may have started earlier. Re-run with a larger days window.")
+		}
+	}
+	if restorable > len(sampled) {
+		notes = append(notes, fmt.Sprintf("%d of %d restorable snapshots in the window were sampled; snapshots between samples were not examined. Use diff_snapshots on neighbours of last_clean to narrow the boundary.", len(sampled), restorable))
+	}
+	if listedSince != "" {
+		notes = append(notes, fmt.Sprintf("max_api_calls allowed %d snapshot pages, which reached back only to %s; older snapshots in the %d-day window were not considered. Raise max_api_calls or lower days.", pages, listedSince, days))
+	}
+	if exhausted {
+		notes = append(notes, fmt.Sprintf("The API call budget ran out before every folder was walked (%d browse requests per snapshot); unwalked folders were not compared. Raise max_api_calls or narrow browse_path.", share))
+	}
+	if len(notes) > 0 {
+		result["notes"] = notes
+	}
+	if cleanup != nil {
+		result["cleanup"] = cleanup
+	}
+	return formatSingle(result, args, formatCompact)
+}
+
+// sampleRecentSnapshots picks up to n of the agent's restorable snapshots
+// from the last days, spread evenly and always including the newest and
+// oldest, oldest first. It also returns how many were restorable and how
+// many snapshot pages it read. Paging stops at the cutoff or after
+// maxPages; in the latter case listedSince is the oldest start time read,
+// and older snapshots in the window were not considered.
+func sampleRecentSnapshots(ctx context.Context, agentID string, days, n, maxPages int, loc *time.Location) (picked []*snapSide, restorable, pages int, listedSince string, err error) {
+	cutoff := time.Now().Add(-time.Duration(days) * 24 * time.Hour)
+	pages = 1
+	// keep sees the last snapshot of each page that has a next one.
+	all, err := fetchPaginatedWhile(ctx, fmt.Sprintf("/v1/snapshot?agent_id=%s&sort_by=backup_start_time&sort_asc=false", agentID), func(s Snapshot) bool {
+		if t, err := time.Parse(time.RFC3339, s.BackupStartedAt); err == nil && t.Before(cutoff) {
+			return false
+		}
+		if pages == maxPages {
+			listedSince = s.BackupStartedAt
+			return false
+		}
+		pages++
+		return true
+	})
+	if err != nil {
+		return nil, 0, 0, "", err
+	}
+	var sides []*snapSide
+	for i := range all {
+		s := &all[i]
+		t, err := time.Parse(time.RFC3339, s.BackupStartedAt)
+		if err != nil || t.Before(cutoff) || s.Deleted != nil {
+			continue
+		}
+		if deviceID, _ := restoreDevice(s); deviceID == "" {
+			continue
+		}
+		sides = append(sides, &snapSide{SnapshotID: s.SnapshotID, SnapshotTime: t.In(loc).Format(time.RFC3339), snapshot: s})
+	}
+	sort.Slice(sides, func(i, j int) bool { return sides[i].snapshot.BackupStartedAt < sides[j].snapshot.BackupStartedAt })
+	restorable = len(sides)
+	if restorable <= n {
+		return sides, restorable, pages, listedSince, nil
+	}
+	picked = make([]*snapSide, 0, n)
+	for i := 0; i < n; i++ {
+		picked = append(picked, sides[i*(restorable-1)/(n-1)])
+	}
+	return picked, restorable, pages, listedSince, nil
+}
+
+// compareAnomalyStep compares two walked snapshots.
+func compareAnomalyStep(before, after *snapSide) *anomalyStep {
+	added, removed, modified, unchanged, notCompared := compareSnapIndexes(before.index, after.index)
+	compared := len(removed) + len(modified) + unchanged
+	st := &anomalyStep{
+		FromSnapshotID: before.SnapshotID, ToSnapshotID: after.SnapshotID,
+		FromTime: before.SnapshotTime, ToTime: after.SnapshotTime,
+		Compared: compared, Added: len(added), Removed: len(removed), Modified: len(modified),
+		NotCompared: notCompared,
+		Suspicious:  suspiciousChanges(added, removed, modified, compared),
+		changed:     len(added) + len(removed) + len(modified),
+	}
+	if compared > 0 {
+		st.ChurnPct = math.Round(1000*float64(len(removed)+len(modified))/float64(compared)) / 10
+	}
+	st.Verdict = suspicionVerdict(st.Suspicious)
+	return st
+}
+
+// flagChurnSpikes marks steps that changed far more files than the
+// median step, once there are enough steps for a median to mean anything.
+func flagChurnSpikes(steps []*anomalyStep) {
+	if len(steps) < 3 {
+		return
+	}
+	churn := make([]int, len(steps))
+	for i, st := range steps {
+		churn[i] = st.changed
+	}
+	sort.Ints(churn)
+	median := max(churn[len(churn)/2], 1)
+	for _, st := range steps {
+		if st.changed >= anomalyChurnSpikeMin && st.changed >= anomalyChurnSpikeRatio*median {
+			st.Suspicious = append(st.Suspicious, suspicion{
+				Kind: "churn_spike", Severity: suspicionSeverityMedium, Count: st.changed, Examples: []string{},
+				Detail: fmt.Sprintf("%d files changed in this step against a median of %d per step.", st.changed, median),
+			})
+			st.Verdict = suspicionVerdict(st.Suspicious)
+		}
+	}
+}
@@ -0,0 +1,489 @@
+package main
+
+// slide_files diff_snapshots: "what changed between last night and this
+// morning?"
+//
+// Both snapshots are restored (reusing open file restores), walked from
+// the same root with the tree walker, and compared path by path: files
+// only in the newer one are added, files only in the older one removed,
+// and files whose size or modified time moved are modified. Only folders
+// expanded in both walks are compared, so a max_entries or max_depth
+// cut-off never shows up as a wave of deletions.
+//
+// On top of the listing, a few ransomware heuristics look at the shape of
+// the change: files renamed en masse to one extension nobody uses,
+// extensions known from ransomware families, ransom notes dropped in
+// several folders, and a large share of files changed at once. They are
+// hints for the tech, not a verdict on the snapshot.
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	snapDiffDefaultDepth   = 6
+	snapDiffDefaultEntries = 5000
+	snapDiffMaxEntries     = 20000
+	snapDiffDefaultLimit   = 100
+	snapDiffMaxLimit       = 1000
+
+	suspiciousExamples      = 5
+	massRenameMin           = 10 // renames to one extension before it is flagged
+	extensionSurgeMin       = 20 // added files sharing an unknown extension
+	ransomNoteMinDirs       = 2
+	massChangeMinCompared   = 100
+	massChangeShare         = 0.5
+	suspicionSeverityHigh   = "high"
+	suspicionSeverityMedium = "medium"
+)
+
+// commonExtensions are extensions seen on ordinary Windows file systems.
+// A mass rename to one of these is a migration or a tool, not ransomware.
+var commonExtensions = map[string]bool{
+	".txt": true, ".log": true, ".csv": true, ".json": true, ".xml": true, ".ini": true, ".cfg": true, ".conf": true, ".config": true, ".yml": true, ".yaml": true, ".md": true,
+	".doc": true, ".docx": true, ".xls": true, ".xlsx": true, ".xlsm": true, ".ppt": true, ".pptx": true, ".pdf": true, ".rtf": true, ".odt": true, ".ods": true, ".msg": true, ".eml": true, ".pst": true, ".ost": true,
+	".jpg": true, ".jpeg": true, ".png": true, ".gif": true, ".bmp": true, ".tif": true, ".tiff": true, ".svg": true, ".heic": true, ".ico": true,
+	".mp3": true, ".mp4": true, ".mov": true, ".avi": true, ".wav": true, ".wmv": true, ".mkv": true,
+	".zip": true, ".7z": true, ".rar": true, ".gz": true, ".tar": true, ".cab": true, ".iso": true,
+	".exe": true, ".dll": true, ".sys": true, ".msi": true, ".bat": true, ".cmd": true, ".ps1": true, ".vbs": true, ".js": true, ".jar": true, ".py": true,
+	".htm": true, ".html": true, ".css": true, ".php": true, ".aspx": true, ".lnk": true, ".url": true, ".tmp": true, ".bak": true, ".old": true, ".dat": true, ".db": true, ".sqlite": true,
+	".mdf": true, ".ldf": true, ".ndf": true, ".bak2": true, ".vhd": true, ".vhdx": true, ".vmdk": true, ".edb": true, ".pfx": true, ".cer": true, ".crt": true, ".key": true,
+	".etl": true, ".evtx": true, ".cache": true, ".lock": true, ".pf": true, ".manifest": true, ".mui": true, ".cat": true, ".inf": true,
+}
+
+// ransomwareExtensions are extensions used by well-known ransomware
+// families.
+var ransomwareExtensions = map[string]bool{
+	".locked": true, ".encrypted": true, ".enc": true, ".crypt": true, ".crypted": true, ".crypto": true, ".cry": true,
+	".locky": true, ".zepto": true, ".odin": true, ".cerber": true, ".cerber3": true, ".wncry": true, ".wcry": true, ".wnry": true,
+	".ryk": true, ".conti": true, ".lockbit": true, ".akira": true, ".royal": true, ".blacksuit": true, ".play": true, ".medusa": true,
+	".phobos": true, ".eking": true, ".makop": true, ".djvu": true, ".stop": true, ".dharma": true, ".wallet": true, ".arena": true,
+	".hive": true, ".basta": true, ".babyk": true, ".rhysida": true, ".8base": true, ".cuba": true, ".clop": true, ".nefilim": true,
+}
+
+// ransomNoteMarkers are name fragments of ransom notes.
+var ransomNoteMarkers = []string{
+	"decrypt", "ransom", "recover_files", "recover-files", "restore_files", "restore-my-files",
+	"how_to_recover", "how-to-recover", "how_to_back_files", "readme_for_decrypt", "read_me_now", "_readme.txt", "!!!",
+}
+
+// snapFile is one file in a walked snapshot.
+type snapFile struct {
+	Path       string `json:"path"`
+	Size       int64  `json:"size"`
+	ModifiedAt string `json:"modified_at,omitempty"`
+
+	key string // lower-case path below the root
+}
+
+// snapModified is a file present in both snapshots with a different size
+// or modified time.
+type snapModified struct {
+	Path           string `json:"path"`
+	SizeBefore     int64  `json:"size_before"`
+	SizeAfter      int64  `json:"size_after"`
+	ModifiedBefore string `json:"modified_before,omitempty"`
+	ModifiedAfter  string `json:"modified_after,omitempty"`
+}
+
+// suspicion is one heuristic that fired.
+type suspicion struct {
+	Kind     string   `json:"kind"`
+	Severity string   `json:"severity"`
+	Detail   string   `json:"detail"`
+	Count    int      `json:"count"`
+	Examples []string `json:"examples"`
+}
+
+// snapIndex is a walked snapshot: its files and the folders whose
+// listing is complete, both keyed by lower-case path below the root.
+type snapIndex struct {
+	files    map[string]snapFile
+	dirs     map[string]bool
+	expanded map[string]bool
+}
+
+// snapSide is one snapshot being walked.
+type snapSide struct {
+	SnapshotID    string `json:"snapshot_id"`
+	SnapshotTime  string `json:"snapshot_time,omitempty"`
+	FileRestoreID string `json:"file_restore_id"`
+	Restore       string `json:"restore"` // "reused" or "created"
+	Entries       int    `json:"entries"`
+	Truncated     bool   `json:"truncated,omitempty"`
+
+	snapshot *Snapshot
+	budget   *callBudget // nil: unlimited
+	index    snapIndex
+	err      error
+}
+
+// handleFilesDiffSnapshots compares the folder tree under browse_path
+// between two snapshots of one agent.
+func handleFilesDiffSnapshots(ctx context.Context, args map[string]interface{}) (string, error) {
+	agentID, err := requireString(args, "agent_id")
+	if err != nil {
+		return "", err
+	}
+	fromID, err := requireString(args, "from_snapshot_id")
+	if err != nil {
+		return "", err
+	}
+	toID, err := requireString(args, "to_snapshot_id")
+	if err != nil {
+		return "", err
+	}
+	if fromID == toID {
+		return "", fmt.Errorf("from_snapshot_id and to_snapshot_id are the same snapshot")
+	}
+	root, _ := optionalString(args, "browse_path")
+	if root == "" {
+		root = "/"
+	}
+	root = restoreBrowsePath(root)
+	maxDepth, maxEntries, filter, err := treeOptions(args, treeLimits{snapDiffDefaultDepth, snapDiffDefaultEntries, snapDiffMaxEntries})
+	if err != nil {
+		return "", err
+	}
+	limit, _ := optionalInt(args, "limit")
+	if limit <= 0 {
+		limit = snapDiffDefaultLimit
+	}
+	limit = min(limit, snapDiffMaxLimit)
+
+	agent, err := fetchAgent(ctx, agentID)
+	if err != nil {
+		return "", err
+	}
+	loc := agentLocation(agent)
+	sides := []*snapSide{{SnapshotID: fromID}, {SnapshotID: toID}}
+	for _, s := range sides {
+		if s.snapshot, err = fetchSnapshot(ctx, s.SnapshotID); err != nil {
+			return "", err
+		}
+		if s.snapshot.AgentID != agentID {
+			return "", fmt.Errorf("snapshot %s belongs to agent %s, not %s", s.SnapshotID, s.snapshot.AgentID, agentID)
+		}
+		if s.snapshot.Deleted != nil {
+			return "", fmt.Errorf("snapshot %s was deleted", s.SnapshotID)
+		}
+		if t, err := time.Parse(time.RFC3339, s.snapshot.BackupStartedAt); err == nil {
+			s.SnapshotTime = t.In(loc).Format(time.RFC3339)
+		}
+	}
+	var notes []string
+	if sides[0].snapshot.BackupStartedAt > sides[1].snapshot.BackupStartedAt {
+		sides[0], sides[1] = sides[1], sides[0]
+		notes = append(notes, "from_snapshot_id was the newer snapshot; the two were swapped so changes read from older to newer.")
+	}
+
+	pool, err := newRestorePool(ctx, agentID)
+	if err != nil {
+		return "", err
+	}
+	progressStep(ctx, -1, "opening restores of %s and %s", sides[0].SnapshotID, sides[1].SnapshotID)
+	_, err = fanOut(ctx, sides, func(ctx context.Context, s *snapSide) struct{} {
+		s.err = walkSnapshot(ctx, pool, s, root, maxDepth, maxEntries, filter)
+		return struct{}{}
+	})
+	for _, s := range sides {
+		if err == nil {
+			err = s.err
+		}
+	}
+	cleanup, cleanupErr := pool.release(ctx)
+	if err != nil {
+		if cleanupErr != nil {
+			err = fmt.Errorf("%w (%v)", err, cleanupErr)
+		}
+		return "", err
+	}
+
+	before, after := sides[0], sides[1]
+	added, removed, modified, unchanged, notCompared := compareSnapIndexes(before.index, after.index)
+	dirsAdded, dirsRemoved := compareSnapDirs(before.index, after.index)
+	compared := len(removed) + len(modified) + unchanged
+	suspicious := suspiciousChanges(added, removed, modified, compared)
+
+	verdict := suspicionVerdict(suspicious)
+	if before.Truncated || after.Truncated {
+		notes = append(notes, fmt.Sprintf("A walk stopped at max_entries=%d; only folders listed in full in both snapshots were compared (not_compared counts the rest). Narrow browse_path or raise max_entries.", maxEntries))
+	}
+
+	result := map[string]interface{}{
+		"agent_id":   agentID,
+		"agent_name": bestAgentName(*agent),
+		"root":       root,
+		"from":       before,
+		"to":         after,
+		"verdict":    verdict,
+		"suspicious": suspicious,
+		"summary": map[string]interface{}{
+			"compared":     compared,
+			"added":        len(added),
+			"removed":      len(removed),
+			"modified":     len(modified),
+			"unchanged":    unchanged,
+			"dirs_added":   dirsAdded,
+			"dirs_removed": dirsRemoved,
+			"not_compared": notCompared,
+			"max_depth":    maxDepth,
+		},
+		"added":    firstN(added, limit),
+		"removed":  firstN(removed, limit),
+		"modified": firstN(modified, limit),
+	}
+	if len(added) > limit || len(removed) > limit || len(modified) > limit {
+		notes = append(notes, fmt.Sprintf("Lists are cut at limit=%d each; summary counts everything.", limit))
+	}
+	if len(notes) > 0 {
+		result["notes"] = notes
+	}
+	if cleanup != nil {
+		result["cleanup"] = cleanup
+	}
+	return formatSingle(result, args, formatCompact)
+}
+
+// walkSnapshot restores s's snapshot and indexes the tree under root,
+// within s.budget.
+func walkSnapshot(ctx context.Context, pool *restorePool, s *snapSide, root string, maxDepth, maxEntries int, filter treeFilter) error {
+	deviceID, _ := restoreDevice(s.snapshot)
+	if deviceID == "" {
+		return fmt.Errorf("snapshot %s has no local or cloud copy to restore from", s.SnapshotID)
+	}
+	restore, reused, err := pool.get(ctx, s.SnapshotID, deviceID)
+	if err != nil {
+		return fmt.Errorf("restore snapshot %s: %w", s.SnapshotID, err)
+	}
+	s.FileRestoreID, s.Restore = restore.FileRestoreID, "created"
+	if reused {
+		s.Restore = "reused"
+	}
+	rootNode := &treeNode{entry: FileRestoreEntry{Name: root, Path: root, Type: "dir"}}
+	s.Entries, s.Truncated, err = walkRestoreTree(ctx, restore.FileRestoreID, rootNode, maxDepth, maxEntries, filter, s.budget)
+	if err != nil {
+		return fmt.Errorf("snapshot %s: %w", s.SnapshotID, err)
+	}
+	s.index = indexSnapTree(rootNode, root)
+	return nil
+}
+
+// indexSnapTree flattens a walked tree.
+func indexSnapTree(rootNode *treeNode, root string) snapIndex {
+	idx := snapIndex{files: map[string]snapFile{}, dirs: map[string]bool{}, expanded: map[string]bool{}}
+	var walk func(n *treeNode)
+	walk = func(n *treeNode) {
+		key := strings.ToLower(n.rel)
+		if !isDirEntry(n.entry) {
+			idx.files[key] = snapFile{Path: path.Join(root, n.rel), Size: n.entry.Size, ModifiedAt: n.entry.ModifiedAt, key: key}
+			return
+		}
+		if n != rootNode {
+			idx.dirs[key] = true
+		}
+		if n.expanded {
+			idx.expanded[key] = true
+		}
+		for _, c := range n.children {
+			walk(c)
+		}
+	}
+	walk(rootNode)
+	return idx
+}
+
+// snapParent is the index key of key's folder.
+func snapParent(key string) string {
+	if dir := path.Dir(key); dir != "." {
+		return dir
+	}
+	return ""
+}
+
+// snapComparable reports whether key's folder was listed in full in both.
+func snapComparable(a, b snapIndex, key string) bool {
+	parent := snapParent(key)
+	return a.expanded[parent] && b.expanded[parent]
+}
+
+// compareSnapIndexes sorts files into added, removed, modified and
+// unchanged, skipping those in folders not listed in full in both.
+func compareSnapIndexes(before, after snapIndex) (added, removed []snapFile, modified []snapModified, unchanged, notCompared int) {
+	added, removed, modified = []snapFile{}, []snapFile{}, []snapModified{}
+	for key, f := range after.files {
+		if !snapComparable(before, after, key) {
+			notCompared++
+			continue
+		}
+		old, ok := before.files[key]
+		switch {
+		case !ok:
+			added = append(added, f)
+		case old.Size != f.Size || old.ModifiedAt != f.ModifiedAt:
+			modified = append(modified, snapModified{Path: f.Path, SizeBefore: old.Size, SizeAfter: f.Size, ModifiedBefore: old.ModifiedAt, ModifiedAfter: f.ModifiedAt})
+		default:
+			unchanged++
+		}
+	}
+	for key, f := range before.files {
+		if _, ok := after.files[key]; ok {
+			continue
+		}
+		if !snapComparable(before, after, key) {
+			notCompared++
+			continue
+		}
+		removed = append(removed, f)
+	}
+	sort.Slice(added, func(i, j int) bool { return added[i].key < added[j].key })
+	sort.Slice(removed, func(i, j int) bool { return removed[i].key < removed[j].key })
+	sort.Slice(modified, func(i, j int) bool { return strings.ToLower(modified[i].Path) < strings.ToLower(modified[j].Path) })
+	return added, removed, modified, unchanged, notCompared
+}
+
+// compareSnapDirs counts folders that appeared or disappeared.
+func compareSnapDirs(before, after snapIndex) (added, removed int) {
+	for key := range after.dirs {
+		if !before.dirs[key] && snapComparable(before, after, key) {
+			added++
+		}
+	}
+	for key := range before.dirs {
+		if !after.dirs[key] && snapComparable(before, after, key) {
+			removed++
+		}
+	}
+	return added, removed
+}
+
+// suspiciousChanges runs the ransomware heuristics over a comparison.
+func suspiciousChanges(added, removed []snapFile, modified []snapModified, compared int) []suspicion {
+	out := []suspicion{}
+
+	// Renames: an added file whose path minus its extension, or whose
+	// stem with another extension, was removed.
+	removedKeys := make(map[string]bool, len(removed))
+	removedStems := make(map[string]bool, len(removed))
+	for _, f := range removed {
+		removedKeys[f.key] = true
+		removedStems[strings.TrimSuffix(f.key, path.Ext(f.key))] = true
+	}
+	renames := map[string][]string{}
+	known := map[string][]string{}
+	byExt := map[string][]string{}
+	for _, f := range added {
+		ext := path.Ext(f.key)
+		byExt[ext] = append(byExt[ext], f.Path)
+		if ransomwareExtensions[ext] {
+			known[ext] = append(known[ext], f.Path)
+		}
+		stem := strings.TrimSuffix(f.key, ext)
+		if ext != "" && (removedKeys[stem] || removedStems[stem]) {
+			renames[ext] = append(renames[ext], f.Path)
+		}
+	}
+
+	flagged := map[string]bool{}
+	for _, ext := range sortedExts(known) {
+		flagged[ext] = true
+		out = append(out, newSuspicion("known_ransomware_extension", suspicionSeverityHigh, known[ext],
+			fmt.Sprintf("%d new file(s) end in %s, an extension used by ransomware.", len(known[ext]), ext)))
+	}
+	for _, ext := range sortedExts(renames) {
+		if flagged[ext] || commonExtensions[ext] || len(renames[ext]) < massRenameMin {
+			continue
+		}
+		flagged[ext] = true
+		out = append(out, newSuspicion("mass_rename", suspicionSeverityHigh, renames[ext],
+			fmt.Sprintf("%d file(s) were renamed to the unfamiliar extension %s - the pattern ransomware leaves when it encrypts in place.", len(renames[ext]), ext)))
+	}
+	for _, ext := range sortedExts(byExt) {
+		n := len(byExt[ext])
+		if ext == "" || flagged[ext] || commonExtensions[ext] || n < extensionSurgeMin || n*2 < len(added) {
+			continue
+		}
+		out = append(out, newSuspicion("unknown_extension_surge", suspicionSeverityMedium, byExt[ext],
+			fmt.Sprintf("%d of %d new files share the unfamiliar extension %s.", n, len(added), ext)))
+	}
+
+	var notes []string
+	noteDirs := map[string]bool{}
+	for _, f := range added {
+		if isRansomNoteName(path.Base(f.key)) {
+			notes = append(notes, f.Path)
+			noteDirs[snapParent(f.key)] = true
+		}
+	}
+	if len(noteDirs) >= ransomNoteMinDirs {
+		out = append(out, newSuspicion("ransom_note", suspicionSeverityHigh, notes,
+			fmt.Sprintf("Files named like ransom notes appeared in %d folders.", len(noteDirs))))
+	}
+
+	changed := len(modified) + len(removed)
+	if compared >= massChangeMinCompared && float64(changed) >= massChangeShare*float64(compared) {
+		examples := make([]string, 0, suspiciousExamples)
+		for _, m := range firstN(modified, suspiciousExamples) {
+			examples = append(examples, m.Path)
+		}
+		out = append(out, suspicion{Kind: "mass_change", Severity: suspicionSeverityMedium, Count: changed, Examples: examples,
+			Detail: fmt.Sprintf("%d of %d existing files were modified or removed (%.0f%%).", changed, compared, 100*float64(changed)/float64(compared))})
+	}
+	return out
+}
+
+// suspicionVerdict is "suspicious" with any high-severity flag, "review"
+// with only medium ones, else "no_red_flags".
+func suspicionVerdict(flags []suspicion) string {
+	verdict := "no_red_flags"
+	for _, s := range flags {
+		if s.Severity == suspicionSeverityHigh {
+			return "suspicious"
+		}
+		verdict = "review"
+	}
+	return verdict
+}
+
+// isRansomNoteName reports whether a lower-case file name looks like a
+// ransom note: a text-like file named with one of ransomNoteMarkers.
+func isRansomNoteName(name string) bool {
+	switch path.Ext(name) {
+	case "", ".txt", ".html", ".htm", ".hta", ".rtf", ".url":
+	default:
+		return false
+	}
+	for _, m := range ransomNoteMarkers {
+		if strings.Contains(name, m) {
+			return true
+		}
+	}
+	return false
+}
+
+func newSuspicion(kind, severity string, paths []string, detail string) suspicion {
+	return suspicion{Kind: kind, Severity: severity, Detail: detail, Count: len(paths), Examples: firstN(paths, suspiciousExamples)}
+}
+
+// firstN is s cut to at most n elements.
+func firstN[T any](s []T, n int) []T {
+	if len(s) > n {
+		return s[:n]
+	}
+	return s
+}
+
+// sortedExts is m's extensions in order.
+func sortedExts(m map[string][]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
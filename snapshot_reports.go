@@ -0,0 +1,596 @@
+package main
+
+// slide_snapshots reports built from agents' snapshot histories.
+//
+// calendar answers "do we have a restore point for every day this month?"
+// as a day-by-day grid. Days are bucketed by backup start time in the
+// agent's timezone, the same clock its backup schedule runs on, and a day
+// the schedule does not cover is never reported as a gap.
+//
+// verification_report answers "which agents would not boot if we needed
+// them?" across an agent, device, client, or the account: the latest
+// verified snapshot, time since the last passing boot check, the services
+// that failed to start, and agents whose boot check never passed.
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Verification outcomes for a snapshot, worst first.
+const (
+	verifyFailed     = "failed"
+	verifyWarning    = "warning"
+	verifyPending    = "pending"
+	verifyPassed     = "passed"
+	verifyUnverified = "unverified"
+)
+
+var verifyOutcomeRank = map[string]int{
+	verifyFailed:     0,
+	verifyWarning:    1,
+	verifyPending:    2,
+	verifyPassed:     3,
+	verifyUnverified: 4,
+}
+
+// snapshotVerifyOutcome folds the boot, filesystem, and service checks
+// into the worst outcome among those that ran.
+func snapshotVerifyOutcome(s Snapshot) string {
+	outcome := verifyUnverified
+	for _, status := range []*string{s.VerifyBootStatus, s.VerifyFsStatus, s.VerifyServiceStatus} {
+		if status == nil {
+			continue
+		}
+		o := verifyStatusOutcome(*status)
+		if verifyOutcomeRank[o] < verifyOutcomeRank[outcome] {
+			outcome = o
+		}
+	}
+	return outcome
+}
+
+// verifyStatusOutcome maps one verify_*_status value onto an outcome.
+// Unrecognised non-empty values are treated as still running.
+func verifyStatusOutcome(status string) string {
+	switch s := strings.ToLower(status); {
+	case s == "" || s == "skipped" || s == "none" || s == "disabled":
+		return verifyUnverified
+	case s == "success" || s == "succeeded" || s == "passed" || s == "ok":
+		return verifyPassed
+	case strings.Contains(s, "warn"):
+		return verifyWarning
+	case strings.Contains(s, "fail") || strings.Contains(s, "error"):
+		return verifyFailed
+	}
+	return verifyPending
+}
+
+// snapshotIn reports whether s has a surviving copy of the given location
+// type ("local" or "cloud").
+func snapshotIn(s Snapshot, locationType string) bool {
+	for _, l := range s.Locations {
+		if l.Type == locationType {
+			return true
+		}
+	}
+	return false
+}
+
+// fetchAgentSnapshotsSince returns every snapshot of agentID, deleted ones
+// included, whose backup started at or after since, newest first.
+func fetchAgentSnapshotsSince(ctx context.Context, agentID string, since time.Time) ([]Snapshot, error) {
+	return fetchAgentSnapshotsBetween(ctx, agentID, since, time.Time{})
+}
+
+// fetchAgentSnapshotsBetween is fetchAgentSnapshotsSince for the window
+// [since, until); a zero until means no upper bound. When until is in the
+// past, the snapshots newer than it are skipped rather than paged through.
+func fetchAgentSnapshotsBetween(ctx context.Context, agentID string, since, until time.Time) ([]Snapshot, error) {
+	endpoint := fmt.Sprintf("/v1/snapshot?agent_id=%s&snapshot_location=location_any&sort_by=backup_start_time&sort_asc=false", agentID)
+	inWindow := func(s Snapshot) bool {
+		t, err := time.Parse(time.RFC3339, s.BackupStartedAt)
+		return err == nil && !t.Before(since) && (until.IsZero() || t.Before(until))
+	}
+	reachedSince := func(s Snapshot) bool {
+		t, err := time.Parse(time.RFC3339, s.BackupStartedAt)
+		return err == nil && !t.Before(since)
+	}
+	if !until.IsZero() && until.Before(time.Now()) {
+		offset, err := snapshotOffsetBefore(ctx, endpoint, until)
+		if err != nil {
+			return nil, err
+		}
+		endpoint += fmt.Sprintf("&offset=%d", offset)
+	}
+	all, err := fetchPaginatedWhile(ctx, endpoint, reachedSince)
+	if err != nil {
+		return nil, err
+	}
+	out := all[:0]
+	for _, s := range all {
+		if inWindow(s) {
+			out = append(out, s)
+		}
+	}
+	return out, nil
+}
+
+// snapshotOffsetBefore binary-searches the newest-first listing at
+// endpoint, one snapshot per request, for the offset of the first
+// snapshot that started before until. It costs about log2(total) calls
+// however many snapshots are newer than until.
+func snapshotOffsetBefore(ctx context.Context, endpoint string, until time.Time) (int, error) {
+	probe := func(offset int) (*PaginatedResponse[Snapshot], error) {
+		body, err := makeAPIRequest(ctx, "GET", fmt.Sprintf("%s&limit=1&offset=%d", endpoint, offset), nil)
+		if err != nil {
+			return nil, err
+		}
+		var page PaginatedResponse[Snapshot]
+		if err := json.Unmarshal(body, &page); err != nil {
+			return nil, fmt.Errorf("parse snapshot page at offset %d: %w", offset, err)
+		}
+		return &page, nil
+	}
+	before := func(page *PaginatedResponse[Snapshot]) bool {
+		if len(page.Data) == 0 {
+			return true
+		}
+		t, err := time.Parse(time.RFC3339, page.Data[0].BackupStartedAt)
+		return err == nil && t.Before(until)
+	}
+
+	first, err := probe(0)
+	if err != nil {
+		return 0, err
+	}
+	if before(first) {
+		return 0, nil
+	}
+	// Invariant: the snapshot at lo is not before until; the one at hi is
+	// (or hi is past the end).
+	lo, hi := 0, first.Pagination.Total
+	for hi-lo > 1 {
+		mid := lo + (hi-lo)/2
+		page, err := probe(mid)
+		if err != nil {
+			return 0, err
+		}
+		if before(page) {
+			hi = mid
+		} else {
+			lo = mid
+		}
+	}
+	return hi, nil
+}
+
+// agentLocation is the agent's timezone, falling back to UTC.
+func agentLocation(a *Agent) *time.Location {
+	if a != nil && a.Timezone != nil && *a.Timezone != "" {
+		if loc, err := time.LoadLocation(*a.Timezone); err == nil {
+			return loc
+		}
+	}
+	return time.UTC
+}
+
+// calendarDay is one cell of the snapshot calendar.
+type calendarDay struct {
+	Date         string         `json:"date"`
+	Weekday      string         `json:"weekday"`
+	Snapshots    int            `json:"snapshots"`
+	Local        int            `json:"local"`
+	Cloud        int            `json:"cloud"`
+	Deleted      int            `json:"deleted,omitempty"`
+	Verification map[string]int `json:"verification,omitempty"`
+	Scheduled    bool           `json:"scheduled"`
+	Gap          bool           `json:"gap,omitempty"`
+	Future       bool           `json:"future,omitempty"`
+}
+
+// calendarGap is a run of consecutive scheduled days with no surviving
+// snapshot. Unscheduled days inside the run do not break it.
+type calendarGap struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	Days int    `json:"days"`
+}
+
+// Calendar grid marks, explained in the response's legend.
+const (
+	calendarMarkOK         = "✓"
+	calendarMarkAttention  = "~"
+	calendarMarkGap        = "✗"
+	calendarMarkOff        = "-"
+	calendarMarkFuture     = "·"
+	calendarGridDayWidth   = 5
+	calendarMonthArgLayout = "2006-01"
+)
+
+func handleSnapshotsCalendar(ctx context.Context, args map[string]interface{}) (string, error) {
+	agentID, err := requireString(args, "agent_id")
+	if err != nil {
+		return "", err
+	}
+	agent, err := fetchAgent(ctx, agentID)
+	if err != nil {
+		return "", err
+	}
+	loc := agentLocation(agent)
+	now := time.Now().In(loc)
+
+	monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, loc)
+	if m, _ := optionalString(args, "month"); m != "" {
+		parsed, err := time.ParseInLocation(calendarMonthArgLayout, m, loc)
+		if err != nil {
+			return "", fmt.Errorf("month must be YYYY-MM (got %q)", m)
+		}
+		monthStart = parsed
+	}
+	if monthStart.After(now) {
+		return "", fmt.Errorf("month %s has not started yet", monthStart.Format(calendarMonthArgLayout))
+	}
+	monthEnd := monthStart.AddDate(0, 1, 0)
+
+	snapshots, err := fetchAgentSnapshotsBetween(ctx, agentID, monthStart, monthEnd)
+	if err != nil {
+		return "", err
+	}
+
+	scheduled := map[time.Weekday]bool{}
+	if agent.BackupSchedule != nil && len(agent.BackupSchedule.Days) > 0 {
+		for _, d := range agent.BackupSchedule.Days {
+			scheduled[time.Weekday(d)] = true
+		}
+	} else {
+		for d := time.Sunday; d <= time.Saturday; d++ {
+			scheduled[d] = true
+		}
+	}
+
+	var days []calendarDay
+	index := map[string]int{}
+	for d := monthStart; d.Before(monthEnd); d = d.AddDate(0, 0, 1) {
+		index[d.Format("2006-01-02")] = len(days)
+		days = append(days, calendarDay{
+			Date:      d.Format("2006-01-02"),
+			Weekday:   d.Weekday().String()[:3],
+			Scheduled: scheduled[d.Weekday()],
+			Future:    d.After(now),
+		})
+	}
+
+	total, local, cloud, deleted := 0, 0, 0, 0
+	verification := map[string]int{}
+	for _, s := range snapshots {
+		started, err := time.Parse(time.RFC3339, s.BackupStartedAt)
+		if err != nil || !started.Before(monthEnd) {
+			continue
+		}
+		i, ok := index[started.In(loc).Format("2006-01-02")]
+		if !ok {
+			continue
+		}
+		day := &days[i]
+		day.Snapshots++
+		total++
+		if s.Deleted != nil {
+			day.Deleted++
+			deleted++
+			continue
+		}
+		if snapshotIn(s, "local") {
+			day.Local++
+			local++
+		}
+		if snapshotIn(s, "cloud") {
+			day.Cloud++
+			cloud++
+		}
+		outcome := snapshotVerifyOutcome(s)
+		if day.Verification == nil {
+			day.Verification = map[string]int{}
+		}
+		day.Verification[outcome]++
+		verification[outcome]++
+	}
+
+	var gaps []calendarGap
+	covered, gapDays := 0, 0
+	var open *calendarGap
+	for i := range days {
+		day := &days[i]
+		if day.Future {
+			break
+		}
+		if day.Snapshots > day.Deleted {
+			covered++
+			open = nil
+			continue
+		}
+		if !day.Scheduled {
+			continue
+		}
+		day.Gap = true
+		gapDays++
+		if open == nil {
+			gaps = append(gaps, calendarGap{From: day.Date})
+			open = &gaps[len(gaps)-1]
+		}
+		open.To = day.Date
+		open.Days++
+	}
+	if gaps == nil {
+		gaps = []calendarGap{}
+	}
+
+	result := map[string]interface{}{
+		"agent_id":   agentID,
+		"agent_name": bestAgentName(*agent),
+		"month":      monthStart.Format(calendarMonthArgLayout),
+		"timezone":   loc.String(),
+		"summary": map[string]interface{}{
+			"days_covered": covered,
+			"gap_days":     gapDays,
+			"snapshots":    total,
+			"local":        local,
+			"cloud":        cloud,
+			"deleted":      deleted,
+			"verification": verification,
+		},
+		"gaps": gaps,
+		"grid": calendarGrid(days),
+		"legend": fmt.Sprintf("%s snapshot kept locally and in the cloud, all verification passed or not run; %s snapshot present but local-only, cloud-only, or a verification failed/warned; %s gap on a scheduled day; %s no snapshot on an unscheduled day; %s future",
+			calendarMarkOK, calendarMarkAttention, calendarMarkGap, calendarMarkOff, calendarMarkFuture),
+		"days": days,
+	}
+	return formatSingle(result, args, formatCompact)
+}
+
+// calendarMark is the one-character summary of a day in the grid.
+func calendarMark(d calendarDay) string {
+	switch {
+	case d.Future:
+		return calendarMarkFuture
+	case d.Gap:
+		return calendarMarkGap
+	case d.Snapshots == d.Deleted:
+		return calendarMarkOff
+	case d.Local == 0 || d.Cloud == 0 || d.Verification[verifyFailed] > 0 || d.Verification[verifyWarning] > 0:
+		return calendarMarkAttention
+	}
+	return calendarMarkOK
+}
+
+// calendarGrid lays the days out as a Sunday-first month calendar, one
+// string per week.
+func calendarGrid(days []calendarDay) []string {
+	header := ""
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		header += fmt.Sprintf("%*s", calendarGridDayWidth, d.String()[:3])
+	}
+	grid := []string{header}
+	if len(days) == 0 {
+		return grid
+	}
+	first, _ := time.Parse("2006-01-02", days[0].Date)
+	row := strings.Repeat(" ", calendarGridDayWidth*int(first.Weekday()))
+	for i, d := range days {
+		row += fmt.Sprintf("%*d%s", calendarGridDayWidth-1, i+1, calendarMark(d))
+		if (int(first.Weekday())+i)%7 == 6 {
+			grid = append(grid, row)
+			row = ""
+		}
+	}
+	if row != "" {
+		grid = append(grid, row)
+	}
+	return grid
+}
+
+// Verification report statuses, worst first.
+const (
+	verifyReportNeverPassed = "never_passed" // verification ran in the window but boot never passed
+	verifyReportFailing     = "failing"      // the latest verified snapshot failed or warned
+	verifyReportError       = "error"        // the agent's snapshots could not be fetched
+	verifyReportUnverified  = "unverified"   // no snapshot in the window was verified at all
+	verifyReportOK          = "ok"
+)
+
+var verifyReportRank = map[string]int{
+	verifyReportNeverPassed: 0,
+	verifyReportFailing:     1,
+	verifyReportError:       2,
+	verifyReportUnverified:  3,
+	verifyReportOK:          4,
+}
+
+// verifiedSnapshot is the latest verified snapshot as shown in the report.
+type verifiedSnapshot struct {
+	SnapshotID    string  `json:"snapshot_id"`
+	StartedAt     string  `json:"backup_started_at"`
+	Outcome       string  `json:"outcome"`
+	Boot          *string `json:"verify_boot_status,omitempty"`
+	Filesystem    *string `json:"verify_fs_status,omitempty"`
+	Service       *string `json:"verify_service_status,omitempty"`
+	ScreenshotURL *string `json:"verify_boot_screenshot_url,omitempty"`
+}
+
+// agentVerification is one agent's line in the verification_report.
+type agentVerification struct {
+	AgentID            string                      `json:"agent_id"`
+	AgentName          string                      `json:"agent_name,omitempty"`
+	DeviceID           string                      `json:"device_id,omitempty"`
+	ClientID           string                      `json:"client_id,omitempty"`
+	Status             string                      `json:"status"`
+	Snapshots          int                         `json:"snapshots"`
+	Verified           int                         `json:"verified"`
+	LatestVerified     *verifiedSnapshot           `json:"latest_verified,omitempty"`
+	LastBootPassAt     string                      `json:"last_boot_pass_at,omitempty"`
+	HoursSinceBootPass *int                        `json:"hours_since_boot_pass,omitempty"`
+	FailingServices    []ServiceVerificationResult `json:"failing_services,omitempty"`
+	Note               string                      `json:"note,omitempty"`
+}
+
+// handleSnapshotsVerificationReport rolls up boot, filesystem, and service
+// verification per agent for an agent, device, client, or the account.
+func handleSnapshotsVerificationReport(ctx context.Context, args map[string]interface{}) (string, error) {
+	days, ok := optionalInt(args, "days")
+	if !ok || days <= 0 {
+		days = 30
+	}
+	problemsOnly, _ := optionalBool(args, "problems_only")
+	if _, err := reportDocFormats(args); err != nil {
+		return "", err
+	}
+
+	agents, scope, err := fetchScopedAgents(ctx, args)
+	if err != nil {
+		return "", err
+	}
+	now := time.Now().UTC()
+	since := now.AddDate(0, 0, -days)
+
+	var started atomic.Int32
+	results, err := fanOut(ctx, agents, func(ctx context.Context, a Agent) agentVerification {
+		n := int(started.Add(1))
+		progressStep(ctx, len(agents)-n, "checking verification for agent %d/%d (%s)", n, len(agents), bestAgentName(a))
+		return fetchAgentVerification(ctx, a, since, now)
+	})
+	if err != nil {
+		return "", err
+	}
+
+	summary := map[string]int{}
+	report := make([]agentVerification, 0, len(results))
+	for _, r := range results {
+		summary[r.Status]++
+		if problemsOnly && r.Status == verifyReportOK {
+			continue
+		}
+		report = append(report, r)
+	}
+	sort.SliceStable(report, func(i, j int) bool {
+		a, b := report[i], report[j]
+		if verifyReportRank[a.Status] != verifyReportRank[b.Status] {
+			return verifyReportRank[a.Status] < verifyReportRank[b.Status]
+		}
+		return a.AgentName < b.AgentName
+	})
+
+	result := map[string]interface{}{
+		"scope":         scope,
+		"window_days":   days,
+		"problems_only": problemsOnly,
+		"summary": map[string]interface{}{
+			"agents":       len(results),
+			"problems":     len(results) - summary[verifyReportOK],
+			"by_status":    summary,
+			"window_start": since.Format(time.RFC3339),
+		},
+		"agents": report,
+	}
+	doc := verificationReportDoc(scope, days, since, len(results), summary, report)
+	if result["report"], err = attachReportDocs(ctx, args, doc, "verification-report"); err != nil {
+		return "", err
+	}
+	if result["report"] == nil {
+		delete(result, "report")
+	}
+	return formatSingle(result, args, formatCompact)
+}
+
+// fetchAgentVerification summarises the verification results of a's
+// surviving snapshots since the window start. Failing services are looked
+// up only for the latest verified snapshot, and only when its service
+// check did not pass.
+func fetchAgentVerification(ctx context.Context, a Agent, since, now time.Time) agentVerification {
+	v := agentVerification{AgentID: a.AgentID, AgentName: bestAgentName(a), DeviceID: a.DeviceID}
+	if a.ClientID != nil {
+		v.ClientID = *a.ClientID
+	}
+	snapshots, err := fetchAgentSnapshotsSince(ctx, a.AgentID, since)
+	if err != nil {
+		v.Status, v.Note = verifyReportError, "error fetching snapshots"
+		return v
+	}
+
+	var lastBootPass time.Time
+	bootRan := false
+	for _, s := range snapshots {
+		if s.Deleted != nil {
+			continue
+		}
+		v.Snapshots++
+		outcome := snapshotVerifyOutcome(s)
+		if outcome == verifyUnverified {
+			continue
+		}
+		v.Verified++
+		if v.LatestVerified == nil {
+			v.LatestVerified = &verifiedSnapshot{
+				SnapshotID: s.SnapshotID, StartedAt: s.BackupStartedAt, Outcome: outcome,
+				Boot: s.VerifyBootStatus, Filesystem: s.VerifyFsStatus, Service: s.VerifyServiceStatus,
+				ScreenshotURL: s.VerifyBootScreenshotURL,
+			}
+		}
+		if s.VerifyBootStatus == nil || verifyStatusOutcome(*s.VerifyBootStatus) == verifyUnverified {
+			continue
+		}
+		bootRan = true
+		if verifyStatusOutcome(*s.VerifyBootStatus) != verifyPassed {
+			continue
+		}
+		if t, err := time.Parse(time.RFC3339, s.BackupStartedAt); err == nil && t.After(lastBootPass) {
+			lastBootPass = t
+		}
+	}
+	if !lastBootPass.IsZero() {
+		v.LastBootPassAt = lastBootPass.Format(time.RFC3339)
+		hours := int(now.Sub(lastBootPass).Hours())
+		v.HoursSinceBootPass = &hours
+	}
+
+	latest := v.LatestVerified
+	if latest != nil && latest.Service != nil {
+		if o := verifyStatusOutcome(*latest.Service); o == verifyFailed || o == verifyWarning {
+			if res, err := getSnapshotServiceVerification(ctx, latest.SnapshotID); err == nil {
+				for _, svc := range res.Services {
+					if serviceVerificationFailed(svc.State) {
+						v.FailingServices = append(v.FailingServices, svc)
+					}
+				}
+			} else {
+				v.Note = "could not fetch per-service results for " + latest.SnapshotID
+			}
+		}
+	}
+
+	switch {
+	case latest == nil:
+		v.Status = verifyReportUnverified
+	case bootRan && lastBootPass.IsZero():
+		v.Status = verifyReportNeverPassed
+	case latest.Outcome == verifyFailed || latest.Outcome == verifyWarning:
+		v.Status = verifyReportFailing
+	default:
+		v.Status = verifyReportOK
+	}
+	return v
+}
+
+// serviceVerificationFailed reports whether a per-service verification
+// state means the service did not come up in the verification boot.
+func serviceVerificationFailed(state string) bool {
+	switch strings.ToLower(state) {
+	case "running", "started", "success", "succeeded", "passed", "ok", "pending", "skipped":
+		return false
+	}
+	return true
+}
@@ -0,0 +1,307 @@
+package main
+
+// resources/subscribe for the live resources.
+//
+// A host keeping a dashboard pane open can subscribe to
+// slide://overview/health, slide://alerts/unresolved, slide://device/{id},
+// slide://agent/{id}, or slide://agent/{id}/snapshots/recent instead of
+// re-reading it. Each subscribed URI gets a watch: a goroutine that
+// fetches it once to take a baseline, then again every
+// resourcePollInterval, and sends notifications/resources/updated to the
+// subscribed sessions whenever the content differs from the last fetch.
+// The host then re-reads the resource as usual.
+//
+// Heartbeat fields (last_seen_at, minutes_stale, generated_at) are left
+// out of the comparison, so an agent checking in is not news but one
+// going stale is. A failed fetch is skipped, not reported as a change.
+//
+// A watch belongs to one API session: over HTTP each MCP session polls on
+// its own, with its own token or the server's. The SDK acknowledges
+// subscriptions to any URI; the others are simply never updated. The same
+// goes for a device or agent the API does not know and for subscriptions
+// past maxWatchesPerSession or maxResourceWatches, which are logged and
+// dropped: a subscribe hook cannot fail the request.
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// resourcePollInterval is how often a subscribed resource is re-fetched.
+// Tests shorten it.
+var resourcePollInterval = time.Minute
+
+// Each watch is a goroutine calling the Slide API once per
+// resourcePollInterval, so one session, and the server as a whole, only
+// get so many. Tests lower them.
+var (
+	maxWatchesPerSession = 25
+	maxResourceWatches   = 250
+)
+
+// volatileResourceFields change on every fetch or every agent heartbeat
+// without anything a dashboard would show having changed.
+var volatileResourceFields = map[string]bool{
+	"generated_at":  true,
+	"as_of":         true,
+	"last_seen_at":  true,
+	"minutes_stale": true,
+	"next_steps":    true,
+}
+
+// subscribableResource returns the reader for a URI that supports
+// subscriptions.
+func subscribableResource(uri string) (func(context.Context, string) ([]byte, error), bool) {
+	switch uri {
+	case resourceURIHealth:
+		return handleResourceHealth, true
+	case resourceURIAlertsOpen:
+		return handleResourceAlertsOpen, true
+	}
+	if id, ok := strings.CutPrefix(uri, "slide://device/"); ok && id != "" && !strings.Contains(id, "/") {
+		return handleResourceDevice, true
+	}
+	if rest, ok := strings.CutPrefix(uri, "slide://agent/"); ok {
+		id, recent := strings.CutSuffix(rest, "/snapshots/recent")
+		if id == "" || strings.Contains(id, "/") {
+			return nil, false
+		}
+		if recent {
+			return handleResourceAgentRecentSnapshots, true
+		}
+		return handleResourceAgent, true
+	}
+	return nil, false
+}
+
+// resourceWatch polls one URI for one Slide identity.
+type resourceWatch struct {
+	uri      string
+	fetch    func(context.Context, string) ([]byte, error)
+	srv      *server.MCPServer
+	sessions map[string]bool // subscribed MCP session IDs; guarded by the registry
+	cancel   context.CancelFunc
+}
+
+type resourceWatchRegistry struct {
+	mu      sync.Mutex
+	watches map[string]*resourceWatch // by apiSession key + " " + URI
+	running sync.WaitGroup            // one per watch goroutine still polling
+}
+
+var resourceWatches = &resourceWatchRegistry{watches: map[string]*resourceWatch{}}
+
+// subscribe adds the calling MCP session to uri's watch, starting the
+// watch if it is the first subscriber. A new watch takes its baseline
+// before it starts, and a URI whose device or agent is not found is not
+// watched.
+func (r *resourceWatchRegistry) subscribe(ctx context.Context, uri string) {
+	fetch, ok := subscribableResource(uri)
+	cs := server.ClientSessionFromContext(ctx)
+	srv := server.ServerFromContext(ctx)
+	if !ok || cs == nil || srv == nil {
+		return
+	}
+	sessionID := cs.SessionID()
+	ctx = bindAPISession(ctx)
+	api := sessionFromContext(ctx)
+	key := api.key + " " + uri
+
+	r.mu.Lock()
+	_, exists := r.watches[key]
+	err := r.admitLocked(key, sessionID)
+	if err == nil && exists {
+		r.watches[key].sessions[sessionID] = true
+	}
+	r.mu.Unlock()
+	if err != nil {
+		log.Printf("Warning: not watching %s: %v", uri, err)
+		return
+	}
+	if exists {
+		return
+	}
+
+	body, err := fetch(ctx, uri)
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound {
+		log.Printf("Warning: not watching %s: %v", uri, err)
+		return
+	}
+	last := ""
+	if err == nil {
+		last = resourceFingerprint(body)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.admitLocked(key, sessionID); err != nil {
+		log.Printf("Warning: not watching %s: %v", uri, err)
+		return
+	}
+	w, ok := r.watches[key]
+	if !ok {
+		w = &resourceWatch{uri: uri, fetch: fetch, srv: srv, sessions: map[string]bool{}}
+		var wctx context.Context
+		wctx, w.cancel = detachedContext(api)
+		r.watches[key] = w
+		r.running.Add(1)
+		go r.run(wctx, w, last)
+	}
+	w.sessions[sessionID] = true
+}
+
+// admitLocked reports whether sessionID may subscribe to the watch at key
+// without going over the caps. Already being subscribed is always fine.
+func (r *resourceWatchRegistry) admitLocked(key, sessionID string) error {
+	w, exists := r.watches[key]
+	if exists && w.sessions[sessionID] {
+		return nil
+	}
+	mine := 0
+	for _, other := range r.watches {
+		if other.sessions[sessionID] {
+			mine++
+		}
+	}
+	if mine >= maxWatchesPerSession {
+		return fmt.Errorf("this session already has %d subscriptions (limit %d)", mine, maxWatchesPerSession)
+	}
+	if !exists && len(r.watches) >= maxResourceWatches {
+		return fmt.Errorf("the server is already polling %d resources (limit %d)", len(r.watches), maxResourceWatches)
+	}
+	return nil
+}
+
+// unsubscribe removes the calling MCP session from uri's watches.
+func (r *resourceWatchRegistry) unsubscribe(ctx context.Context, uri string) {
+	cs := server.ClientSessionFromContext(ctx)
+	if cs == nil {
+		return
+	}
+	r.remove(cs.SessionID(), func(w *resourceWatch) bool { return w.uri == uri })
+}
+
+// dropSession removes an ended MCP session from every watch.
+func (r *resourceWatchRegistry) dropSession(sessionID string) {
+	r.remove(sessionID, func(*resourceWatch) bool { return true })
+}
+
+// remove takes sessionID off the matching watches and stops the ones
+// left without subscribers.
+func (r *resourceWatchRegistry) remove(sessionID string, match func(*resourceWatch) bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for key, w := range r.watches {
+		if !match(w) {
+			continue
+		}
+		delete(w.sessions, sessionID)
+		if len(w.sessions) == 0 {
+			w.cancel()
+			delete(r.watches, key)
+		}
+	}
+}
+
+// run fetches w every resourcePollInterval until its context ends,
+// notifying whenever the content differs from last. An empty last (the
+// baseline fetch failed) is filled in by an immediate fetch.
+func (r *resourceWatchRegistry) run(ctx context.Context, w *resourceWatch, last string) {
+	defer r.running.Done()
+	failing := false
+	poll := func() bool {
+		body, err := w.fetch(ctx, w.uri)
+		if ctx.Err() != nil {
+			return false
+		}
+		switch {
+		case err != nil:
+			if !failing {
+				log.Printf("Warning: subscribed resource %s could not be fetched: %v", w.uri, err)
+			}
+			failing = true
+		default:
+			failing = false
+			sum := resourceFingerprint(body)
+			if last != "" && sum != last {
+				r.notify(w)
+			}
+			last = sum
+		}
+		return true
+	}
+	if last == "" && !poll() {
+		return
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(resourcePollInterval):
+		}
+		if !poll() {
+			return
+		}
+	}
+}
+
+// notify sends notifications/resources/updated to w's subscribers,
+// dropping any whose session has gone.
+func (r *resourceWatchRegistry) notify(w *resourceWatch) {
+	r.mu.Lock()
+	sessions := make([]string, 0, len(w.sessions))
+	for id := range w.sessions {
+		sessions = append(sessions, id)
+	}
+	r.mu.Unlock()
+	for _, id := range sessions {
+		err := w.srv.SendNotificationToSpecificClient(id, mcp.MethodNotificationResourceUpdated, map[string]any{"uri": w.uri})
+		if errors.Is(err, server.ErrSessionNotFound) {
+			r.remove(id, func(x *resourceWatch) bool { return x == w })
+		}
+	}
+}
+
+// resourceFingerprint hashes a resource body without its volatile fields.
+// Bodies that are not JSON are hashed as they are.
+func resourceFingerprint(body []byte) string {
+	var v interface{}
+	if err := json.Unmarshal(body, &v); err == nil {
+		if stable, err := json.Marshal(withoutVolatileFields(v)); err == nil {
+			body = stable
+		}
+	}
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:])
+}
+
+func withoutVolatileFields(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, child := range t {
+			if volatileResourceFields[k] {
+				delete(t, k)
+				continue
+			}
+			t[k] = withoutVolatileFields(child)
+		}
+	case []interface{}:
+		for i, child := range t {
+			t[i] = withoutVolatileFields(child)
+		}
+	}
+	return v
+}
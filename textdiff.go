@@ -0,0 +1,221 @@
+package main
+
+// Unified diffs of text files, for slide_files diff_versions.
+//
+// The line diff is Myers' O(ND) algorithm after trimming the common prefix
+// and suffix, which is what most edits to a document leave behind. The
+// edit distance is capped so a file that was rewritten from scratch
+// returns "too different" instead of spending seconds and megabytes on a
+// diff nobody will read.
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	diffContextLines = 3
+	diffMaxEdits     = 4000 // Myers D bound
+)
+
+// diffOp is one line of an edit script: ' ' kept, '-' removed, '+' added.
+type diffOp struct {
+	kind byte
+	line string
+}
+
+// diffStats counts an edit script.
+type diffStats struct {
+	Added   int `json:"added"`
+	Removed int `json:"removed"`
+	Hunks   int `json:"hunks"`
+}
+
+// splitLines splits text into lines without their endings, so a file
+// that only switched between CRLF and LF compares equal.
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	lines := strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+	for i, l := range lines {
+		lines[i] = strings.TrimSuffix(l, "\r")
+	}
+	return lines
+}
+
+// diffLines returns the edit script turning a into b, or ok=false when
+// they differ by more than diffMaxEdits lines.
+func diffLines(a, b []string) (ops []diffOp, ok bool) {
+	pre := 0
+	for pre < len(a) && pre < len(b) && a[pre] == b[pre] {
+		pre++
+	}
+	suf := 0
+	for suf < len(a)-pre && suf < len(b)-pre && a[len(a)-1-suf] == b[len(b)-1-suf] {
+		suf++
+	}
+	mid, ok := myers(a[pre:len(a)-suf], b[pre:len(b)-suf])
+	if !ok {
+		return nil, false
+	}
+	ops = make([]diffOp, 0, pre+len(mid)+suf)
+	for _, l := range a[:pre] {
+		ops = append(ops, diffOp{' ', l})
+	}
+	ops = append(ops, mid...)
+	for _, l := range a[len(a)-suf:] {
+		ops = append(ops, diffOp{' ', l})
+	}
+	return ops, true
+}
+
+// myers is the shortest edit script from a to b. trace[d] holds the
+// furthest x on diagonals -d-1..d+1 before round d, which is all the
+// backtrack needs, so memory is O(D²) rather than O(D·(N+M)).
+func myers(a, b []string) ([]diffOp, bool) {
+	n, m := len(a), len(b)
+	maxD := min(n+m, diffMaxEdits)
+	off := maxD + 1
+	v := make([]int, 2*maxD+3)
+	var trace [][]int
+	for d := 0; d <= maxD; d++ {
+		trace = append(trace, append([]int(nil), v[off-d-1:off+d+2]...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[off+k-1] < v[off+k+1]) {
+				x = v[off+k+1]
+			} else {
+				x = v[off+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[off+k] = x
+			if x >= n && y >= m {
+				return myersBacktrack(a, b, trace), true
+			}
+		}
+	}
+	return nil, false
+}
+
+func myersBacktrack(a, b []string, trace [][]int) []diffOp {
+	x, y := len(a), len(b)
+	var rev []diffOp
+	for d := len(trace) - 1; d >= 0; d-- {
+		v := trace[d]
+		at := func(k int) int { return v[k+d+1] }
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && at(k-1) < at(k+1)) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := at(prevK)
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			rev = append(rev, diffOp{' ', a[x-1]})
+			x--
+			y--
+		}
+		if d > 0 {
+			if x == prevX {
+				rev = append(rev, diffOp{'+', b[y-1]})
+				y--
+			} else {
+				rev = append(rev, diffOp{'-', a[x-1]})
+				x--
+			}
+		}
+	}
+	for i, j := 0, len(rev)-1; i < j; i, j = i+1, j-1 {
+		rev[i], rev[j] = rev[j], rev[i]
+	}
+	return rev
+}
+
+// unifiedDiff renders ops as a unified diff with diffContextLines of
+// context, stopping after maxLines output lines.
+func unifiedDiff(fromName, toName string, ops []diffOp, maxLines int) (text string, stats diffStats, truncated bool) {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "--- %s\n+++ %s\n", fromName, toName)
+	lines := 2
+	for i := 0; i < len(ops); {
+		if ops[i].kind == ' ' {
+			i++
+			continue
+		}
+		// A hunk runs from diffContextLines before this change to
+		// diffContextLines after the last change within 2*context of it.
+		start := max(0, i-diffContextLines)
+		end := i
+		for j := i; j < len(ops); j++ {
+			if ops[j].kind != ' ' {
+				end = j
+			} else if j-end > 2*diffContextLines {
+				break
+			}
+		}
+		end = min(len(ops), end+1+diffContextLines)
+
+		aLine, bLine := 1, 1
+		for _, op := range ops[:start] {
+			if op.kind != '+' {
+				aLine++
+			}
+			if op.kind != '-' {
+				bLine++
+			}
+		}
+		aCount, bCount := 0, 0
+		for _, op := range ops[start:end] {
+			if op.kind != '+' {
+				aCount++
+			}
+			if op.kind != '-' {
+				bCount++
+			}
+			switch op.kind {
+			case '+':
+				stats.Added++
+			case '-':
+				stats.Removed++
+			}
+		}
+		stats.Hunks++
+		if truncated {
+			i = end
+			continue
+		}
+		fmt.Fprintf(&sb, "@@ -%s +%s @@\n", hunkRange(aLine, aCount), hunkRange(bLine, bCount))
+		lines++
+		for _, op := range ops[start:end] {
+			if lines >= maxLines {
+				truncated = true
+				break
+			}
+			sb.WriteByte(op.kind)
+			sb.WriteString(op.line)
+			sb.WriteByte('\n')
+			lines++
+		}
+		i = end
+	}
+	return sb.String(), stats, truncated
+}
+
+// hunkRange is a unified diff range: "start,count", with start pointing
+// before the hunk when count is zero.
+func hunkRange(start, count int) string {
+	if count == 0 {
+		start--
+	}
+	if count == 1 {
+		return fmt.Sprintf("%d", start)
+	}
+	return fmt.Sprintf("%d,%d", start, count)
+}
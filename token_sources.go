@@ -0,0 +1,155 @@
+package main
+
+// Slide API token sources.
+//
+// A token passed with --api-key or SLIDE_API_KEY ends up in shell history
+// and MCP host JSON configs, so it can also come from, in order:
+//
+//	SLIDE_API_KEY_FILE     a file only the current user can read
+//	SLIDE_API_KEY_COMMAND  stdout of a command, e.g. `op read op://...`
+//	SLIDE_API_KEY_KEYRING  a Linux Secret Service item (see keyring_linux.go)
+//
+// Profiles accept the same sources as api_key_file / api_key_command /
+// api_key_keyring. Only the first line of a file, command output, or
+// keyring secret is used, so `pass` entries with trailing metadata work.
+// Whichever source wins is recorded in ServerConfig.APIKeySource for
+// --doctor and --debug; the token itself only ever appears masked.
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+)
+
+const (
+	maxTokenFileBytes   = 4 << 10
+	tokenCommandTimeout = 30 * time.Second
+
+	// keyringService is the `service` attribute the Secret Service item is
+	// stored under: secret-tool store --label=Slide service slide-mcp-server account <name>
+	keyringService = "slide-mcp-server"
+)
+
+// apiKeySourceErr keeps a token source failure around for --doctor and
+// --debug, which still run so they can report it.
+var apiKeySourceErr error
+
+// tokenSources are the non-plaintext ways to supply a token. At most one
+// is consulted: file, then command, then keyring.
+type tokenSources struct {
+	File    string
+	Command string
+	Keyring string
+}
+
+// envTokenSources reads the SLIDE_API_KEY_* variables.
+func envTokenSources() tokenSources {
+	return tokenSources{
+		File:    os.Getenv("SLIDE_API_KEY_FILE"),
+		Command: os.Getenv("SLIDE_API_KEY_COMMAND"),
+		Keyring: os.Getenv("SLIDE_API_KEY_KEYRING"),
+	}
+}
+
+// resolve returns the token from the first configured source. kind is
+// "file", "command", or "keyring"; both are empty when nothing is set.
+func (s tokenSources) resolve() (token, kind string, err error) {
+	switch {
+	case s.File != "":
+		token, err = readTokenFile(s.File)
+		return token, "file", err
+	case s.Command != "":
+		token, err = runTokenCommand(s.Command)
+		return token, "command", err
+	case s.Keyring != "":
+		token, err = readKeyringToken(s.Keyring)
+		return token, "keyring", err
+	}
+	return "", "", nil
+}
+
+// detail describes the source of the given kind without exposing secrets.
+func (s tokenSources) detail(kind string) string {
+	switch kind {
+	case "file":
+		return s.File
+	case "command":
+		return commandName(s.Command)
+	case "keyring":
+		return "account=" + s.Keyring
+	}
+	return ""
+}
+
+// readTokenFile reads a token file, refusing files that other users can
+// read or write (the same rule ssh applies to private keys). Windows ACLs
+// don't map onto mode bits, so the check is skipped there.
+func readTokenFile(path string) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	if !info.Mode().IsRegular() {
+		return "", fmt.Errorf("%s is not a regular file", path)
+	}
+	if runtime.GOOS != "windows" && info.Mode().Perm()&0o077 != 0 {
+		return "", fmt.Errorf("%s is accessible by other users (mode %04o); run: chmod 600 %s", path, info.Mode().Perm(), path)
+	}
+	if info.Size() > maxTokenFileBytes {
+		return "", fmt.Errorf("%s is larger than %d bytes; expected a single token", path, maxTokenFileBytes)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return firstLineToken(string(data), path)
+}
+
+// runTokenCommand runs command through the platform shell and uses its
+// stdout. stdin is left unattached so the command can never read the MCP
+// stream; its stderr goes to our log so password-manager prompts and
+// errors stay visible. Error messages never include stdout.
+func runTokenCommand(command string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), tokenCommandTimeout)
+	defer cancel()
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", command)
+	}
+	cmd.Stderr = os.Stderr
+	out, err := cmd.Output()
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return "", fmt.Errorf("token command timed out after %s", tokenCommandTimeout)
+	}
+	if err != nil {
+		return "", fmt.Errorf("token command failed: %v", err)
+	}
+	return firstLineToken(string(out), "token command output")
+}
+
+// firstLineToken trims the input down to its first non-blank line.
+func firstLineToken(s, what string) (string, error) {
+	for _, line := range strings.Split(s, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			return line, nil
+		}
+	}
+	return "", fmt.Errorf("%s is empty", what)
+}
+
+// commandName is the program a token command runs, which is all the
+// diagnostics show: the rest of the line may carry vault paths or worse.
+func commandName(command string) string {
+	if fields := strings.Fields(command); len(fields) > 0 {
+		return fields[0]
+	}
+	return ""
+}
@@ -1,22 +1,31 @@
 package main
 
-// slide_admin: users + accounts + user avatar. Renamed from
-// slide_user_management; clients moved to their own slide_clients tool.
+// slide_admin: users + accounts + user avatar + profile switching.
+// Renamed from slide_user_management; clients moved to their own
+// slide_clients tool.
 
-import "fmt"
+import (
+	"context"
+	"fmt"
+	"slices"
 
-func handleAdminTool(args map[string]interface{}) (string, error) {
-	return HandleToolWithOperations(CreateToolConfig("slide_admin", ToolOperations{
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+func handleAdminTool(ctx context.Context, args map[string]interface{}) (string, error) {
+	return HandleToolWithOperations(ctx, CreateToolConfig("slide_admin", ToolOperations{
 		"list_users":      listUsers,
 		"get_user":        getUser,
 		"get_user_avatar": handleGetUserAvatar,
 		"list_accounts":   listAccounts,
 		"get_account":     getAccount,
 		"update_account":  updateAccount,
+		"switch_profile":  handleAdminSwitchProfile,
 	}), args)
 }
 
-var adminOperationEnums = []string{"list_users", "get_user", "get_user_avatar", "list_accounts", "get_account", "update_account"}
+var adminOperationEnums = []string{"list_users", "get_user", "get_user_avatar", "list_accounts", "get_account", "update_account", "switch_profile"}
 
 func getAdminToolInfo() ToolInfo {
 	props := map[string]interface{}{
@@ -33,6 +42,10 @@ func getAdminToolInfo() ToolInfo {
 			"type":        "string",
 			"description": "Account ID. Required for `get_account`, `update_account`.",
 		},
+		"profile": map[string]interface{}{
+			"type":        "string",
+			"description": "Profile name from the server's config.yaml. Required for `switch_profile`.",
+		},
 		"alert_emails": map[string]interface{}{
 			"type":        "array",
 			"description": "Email addresses to receive account-level alerts. Required for `update_account`.",
@@ -51,7 +64,9 @@ func getAdminToolInfo() ToolInfo {
 			"REACH FOR THIS whenever the user mentions 'Slide users', 'who has access to Slide', 'add a user', " +
 			"'change alert email recipients', 'Slide account settings', or any account-level admin task. " +
 			"Operations: `list_users`, `get_user`, `get_user_avatar` (returns a data: URL), " +
-			"`list_accounts`, `get_account`, `update_account` (currently just `alert_emails`). " +
+			"`list_accounts`, `get_account`, `update_account` (currently just `alert_emails`), " +
+			"`switch_profile` (hop to another named Slide account from the server's config.yaml, e.g. " +
+			"'switch to the partner account'; the permission tier never rises above the startup tier). " +
 			"Note: client management moved to `slide_clients`.",
 		InputSchema: map[string]interface{}{
 			"type":       "object",
@@ -62,10 +77,48 @@ func getAdminToolInfo() ToolInfo {
 				{"if": ifOp("get_user_avatar"), "then": req("user_id")},
 				{"if": ifOp("get_account"), "then": req("account_id")},
 				{"if": ifOp("update_account"), "then": req("account_id", "alert_emails")},
+				{"if": ifOp("switch_profile"), "then": req("profile")},
 			},
 		},
 	}
 }
 
+// handleAdminSwitchProfile swaps the server's Slide identity to another
+// named profile. Only the single-user transports can switch: over HTTP the
+// identity belongs to the caller, not the server.
+func handleAdminSwitchProfile(ctx context.Context, args map[string]interface{}) (string, error) {
+	name, err := requireString(args, "profile")
+	if err != nil {
+		if pf := profileRuntime.file; pf != nil && len(pf.Profiles) > 0 {
+			return "", fmt.Errorf("profile is required (available: %v)", pf.names())
+		}
+		return "", err
+	}
+	previous := currentConfig()
+	if previous.Transport == TransportHTTP {
+		return "", fmt.Errorf("switch_profile is not available over the HTTP transport; send the target account's token as Authorization: Bearer instead")
+	}
+
+	next, capped, err := switchProfile(name)
+	if err != nil {
+		return "", err
+	}
+	if next.ToolsMode != previous.ToolsMode || !slices.Equal(next.DisabledTools, previous.DisabledTools) {
+		if srv := server.ServerFromContext(ctx); srv != nil {
+			srv.SendNotificationToAllClients(mcp.MethodNotificationToolsListChanged, nil)
+		}
+	}
+
+	return toJSONString(map[string]interface{}{
+		"profile":            next.Profile,
+		"previous_profile":   previous.Profile,
+		"base_url":           next.BaseURL,
+		"tools_mode":         next.ToolsMode,
+		"tools_mode_capped":  capped,
+		"disabled_tools":     next.DisabledTools,
+		"account":            accountSummary(ctx),
+		"available_profiles": profileRuntime.file.names(),
+	})
+}
+
 // (handleGetUserAvatar continues to live in tools_v127.go)
-var _ = fmt.Sprintf
@@ -1,10 +1,12 @@
 package main
 
+import "context"
+
 // handleAgentsTool handles all agent-related operations through a single meta-tool
-func handleAgentsTool(args map[string]interface{}) (string, error) {
+func handleAgentsTool(ctx context.Context, args map[string]interface{}) (string, error) {
 	agentRes := ResolutionSpec{IDKey: "agent_id", Kind: "agent"}
 	deviceRes := ResolutionSpec{IDKey: "device_id", Kind: "device"}
-	return HandleToolWithOperations(CreateToolConfigWithResolutions("slide_agents", ToolOperations{
+	return HandleToolWithOperations(ctx, CreateToolConfigWithResolutions("slide_agents", ToolOperations{
 		// Core CRUD (kept stable from v2.x)
 		"list":              listAgents,
 		"get":               getAgent,
@@ -4,14 +4,15 @@ package main
 // groups unresolved alerts by severity hint and returns the worst-first.
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"sort"
 	"time"
 )
 
-func handleAlertsTool(args map[string]interface{}) (string, error) {
-	return HandleToolWithOperations(CreateToolConfig("slide_alerts", ToolOperations{
+func handleAlertsTool(ctx context.Context, args map[string]interface{}) (string, error) {
+	return HandleToolWithOperations(ctx, CreateToolConfig("slide_alerts", ToolOperations{
 		"list":   handleAlertsList,
 		"get":    getAlert,
 		"update": updateAlert,
@@ -71,8 +72,8 @@ func getAlertsToolInfo() ToolInfo {
 	}
 }
 
-func handleAlertsList(args map[string]interface{}) (string, error) {
-	return listAlerts(args)
+func handleAlertsList(ctx context.Context, args map[string]interface{}) (string, error) {
+	return listAlerts(ctx, args)
 }
 
 // alertSeverity returns a rough priority hint based on the alert_type
@@ -108,7 +109,7 @@ func alertSeverityLabel(score int) string {
 	}
 }
 
-func handleAlertsTriage(args map[string]interface{}) (string, error) {
+func handleAlertsTriage(ctx context.Context, args map[string]interface{}) (string, error) {
 	limit, _ := optionalInt(args, "limit")
 	if limit == 0 {
 		limit = 50
@@ -123,7 +124,7 @@ func handleAlertsTriage(args map[string]interface{}) (string, error) {
 		q += "&agent_id=" + aid
 	}
 
-	data, err := makeAPIRequest("GET", q, nil)
+	data, err := makeAPIRequest(ctx, "GET", q, nil)
 	if err != nil {
 		return "", err
 	}
@@ -4,12 +4,13 @@ package main
 // compliance + "what just changed in our account?" questions.
 
 import (
+	"context"
 	"fmt"
 	"time"
 )
 
-func handleAuditTool(args map[string]interface{}) (string, error) {
-	return HandleToolWithOperations(CreateToolConfig("slide_audit", ToolOperations{
+func handleAuditTool(ctx context.Context, args map[string]interface{}) (string, error) {
+	return HandleToolWithOperations(ctx, CreateToolConfig("slide_audit", ToolOperations{
 		"list":      handleAuditList,
 		"get":       handleAuditGet,
 		"actions":   handleAuditActions,
@@ -87,7 +88,7 @@ func getAuditToolInfo() ToolInfo {
 	}
 }
 
-func handleAuditList(args map[string]interface{}) (string, error) {
+func handleAuditList(ctx context.Context, args map[string]interface{}) (string, error) {
 	limit, _ := optionalInt(args, "limit")
 	offset, _ := optionalInt(args, "offset")
 	actionName, _ := optionalString(args, "audit_action_name")
@@ -100,7 +101,7 @@ func handleAuditList(args map[string]interface{}) (string, error) {
 		sortAscPtr = &v
 	}
 
-	resp, err := listAudits(auditQueryOpts{
+	resp, err := listAudits(ctx, auditQueryOpts{
 		Limit:           limit,
 		Offset:          offset,
 		ActionName:      actionName,
@@ -116,22 +117,22 @@ func handleAuditList(args map[string]interface{}) (string, error) {
 	return formatList(resp.Data, resp.Pagination, args, formatSummary, summarizeAudit)
 }
 
-func handleAuditGet(args map[string]interface{}) (string, error) {
+func handleAuditGet(ctx context.Context, args map[string]interface{}) (string, error) {
 	auditID, err := requireString(args, "audit_id")
 	if err != nil {
 		return "", err
 	}
-	a, err := getAudit(auditID)
+	a, err := getAudit(ctx, auditID)
 	if err != nil {
 		return "", err
 	}
 	return formatSingle(a, args, formatCompact)
 }
 
-func handleAuditActions(args map[string]interface{}) (string, error) {
+func handleAuditActions(ctx context.Context, args map[string]interface{}) (string, error) {
 	limit, _ := optionalInt(args, "limit")
 	offset, _ := optionalInt(args, "offset")
-	resp, err := listAuditActions(limit, offset)
+	resp, err := listAuditActions(ctx, limit, offset)
 	if err != nil {
 		return "", err
 	}
@@ -140,10 +141,10 @@ func handleAuditActions(args map[string]interface{}) (string, error) {
 	})
 }
 
-func handleAuditResources(args map[string]interface{}) (string, error) {
+func handleAuditResources(ctx context.Context, args map[string]interface{}) (string, error) {
 	limit, _ := optionalInt(args, "limit")
 	offset, _ := optionalInt(args, "offset")
-	resp, err := listAuditResourceTypes(limit, offset)
+	resp, err := listAuditResourceTypes(ctx, limit, offset)
 	if err != nil {
 		return "", err
 	}
@@ -154,7 +155,7 @@ func handleAuditResources(args map[string]interface{}) (string, error) {
 
 // handleAuditRecent is a convenience wrapper: "show me the last N hours".
 // Defaults to 24h, capped at 30 days.
-func handleAuditRecent(args map[string]interface{}) (string, error) {
+func handleAuditRecent(ctx context.Context, args map[string]interface{}) (string, error) {
 	hours, ok := optionalInt(args, "hours")
 	if !ok || hours <= 0 {
 		hours = 24
@@ -172,7 +173,7 @@ func handleAuditRecent(args map[string]interface{}) (string, error) {
 	actionName, _ := optionalString(args, "audit_action_name")
 	resourceType, _ := optionalString(args, "audit_resource_type_name")
 
-	resp, err := listAudits(auditQueryOpts{
+	resp, err := listAudits(ctx, auditQueryOpts{
 		Limit:          limit,
 		Offset:         offset,
 		ActionName:     actionName,
@@ -2,32 +2,39 @@ package main
 
 // slide_backups: list/get/start backup runs PLUS the v4 status_for_client
 // and status_for_device convenience ops that answer "did backups run last
-// night for X?" in a single tool call.
+// night for X?" in a single tool call, and status_all for the same
+// question across the whole account.
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"sort"
+	"strings"
+	"sync/atomic"
 	"time"
 )
 
-func handleBackupsTool(args map[string]interface{}) (string, error) {
-	return HandleToolWithOperations(CreateToolConfigWithResolutions("slide_backups", ToolOperations{
+func handleBackupsTool(ctx context.Context, args map[string]interface{}) (string, error) {
+	return HandleToolWithOperations(ctx, CreateToolConfigWithResolutions("slide_backups", ToolOperations{
 		"list":              handleBackupsList,
 		"get":               handleBackupsGet,
-		"start":             handleBackupsStart,
+		"start":             withWait(handleBackupsStart, "backup"),
 		"status_for_client": handleBackupsStatusForClient,
 		"status_for_device": handleBackupsStatusForDevice,
+		"status_all":        handleBackupsStatusAll,
+		"rpo_report":        handleBackupsRPOReport,
 		"recent_for_agent":  handleBackupsRecentForAgent,
 	}, map[string]ResolutionSpec{
 		"start":             {IDKey: "agent_id", Kind: "agent"},
 		"status_for_client": {IDKey: "client_id", Kind: "client"},
 		"status_for_device": {IDKey: "device_id", Kind: "device"},
+		"rpo_report":        {IDKey: "client_id", Kind: "client"},
 		"recent_for_agent":  {IDKey: "agent_id", Kind: "agent"},
 	}), args)
 }
 
-var backupsOperationEnums = []string{"list", "get", "start", "status_for_client", "status_for_device", "recent_for_agent"}
+var backupsOperationEnums = []string{"list", "get", "start", "status_for_client", "status_for_device", "status_all", "rpo_report", "recent_for_agent"}
 
 func getBackupsToolInfo() ToolInfo {
 	props := map[string]interface{}{
@@ -38,19 +45,19 @@ func getBackupsToolInfo() ToolInfo {
 		},
 		"agent_id": map[string]interface{}{
 			"type":        "string",
-			"description": "Agent ID. Required for `start` and `recent_for_agent` (alternative: `name_hint`). Optional filter for `list`.",
+			"description": "Agent ID. Required for `start` and `recent_for_agent` (alternative: `name_hint`). Optional filter for `list` and scope for `rpo_report`.",
 		},
 		"device_id": map[string]interface{}{
 			"type":        "string",
-			"description": "Device ID. Required for `status_for_device` (alternative: `name_hint`). Optional filter for `list`.",
+			"description": "Device ID. Required for `status_for_device` (alternative: `name_hint`). Optional filter for `list` and `rpo_report`.",
 		},
 		"client_id": map[string]interface{}{
 			"type":        "string",
-			"description": "Client ID. Required for `status_for_client` (alternative: `name_hint`).",
+			"description": "Client ID. Required for `status_for_client` (alternative: `name_hint`). Optional scope for `rpo_report`.",
 		},
 		"name_hint": map[string]interface{}{
 			"type":        "string",
-			"description": "Alternative to *_id: a hostname / display name / client name (case-insensitive substring). For `start` and `recent_for_agent` resolves to an agent; for `status_for_device` to a device; for `status_for_client` to a client.",
+			"description": "Alternative to *_id: a hostname / display name / client name (case-insensitive substring). For `start` and `recent_for_agent` resolves to an agent; for `status_for_device` to a device; for `status_for_client` and `rpo_report` to a client.",
 		},
 		"snapshot_id": map[string]interface{}{
 			"type":        "string",
@@ -62,16 +69,28 @@ func getBackupsToolInfo() ToolInfo {
 		},
 		"hours": map[string]interface{}{
 			"type":        "number",
-			"description": "Time window in hours for `status_for_*` and `recent_for_agent`. Default 24.",
+			"description": "Time window in hours for `status_for_*`, `status_all`, and `recent_for_agent`. Default 24.",
 			"minimum":     1,
 			"maximum":     720,
 		},
+		"problems_only": map[string]interface{}{
+			"type":        "boolean",
+			"description": "For `status_all`: return only agents whose backups failed, are missing from the window, or could not be checked. For `rpo_report`: return only RPO violations and agents that could not be judged. Totals still cover every agent.",
+		},
+		"rpo_minutes": map[string]interface{}{
+			"type":        "number",
+			"description": "For `rpo_report`: RPO target in minutes applied to every agent instead of each agent's backup schedule interval. Only time inside the agent's schedule window counts against it.",
+			"minimum":     1,
+		},
 		"sort_by": map[string]interface{}{
 			"type":        "string",
 			"description": "Sort field for `list`.",
 			"enum":        []string{"id", "start_time"},
 		},
 	}
+	for k, v := range waitProperties("`start`") {
+		props[k] = v
+	}
 	for k, v := range commonListProperties() {
 		if _, exists := props[k]; !exists {
 			props[k] = v
@@ -82,14 +101,18 @@ func getBackupsToolInfo() ToolInfo {
 		Name: "slide_backups",
 		Description: "Slide MCP - inspect and launch backup runs. " +
 			"REACH FOR THIS whenever the user mentions a backup, 'did backups run', 'did backups run last night', " +
-			"'backup failed', 'kick off a backup', 'start a backup', 'incremental backup', 'why did the backup fail', " +
+			"'backup failed', 'kick off a backup', 'start a backup', 'incremental backup', 'why did the backup fail', 'RPO', 'out of policy', " +
 			"or any per-run backup question. (For backup-SCHEDULE changes use slide_agents set_schedule.) " +
-			"Operations: `list` (paginated history with filters), `get` (single backup detail), `start` (kick off a new backup), " +
+			"Operations: `list` (paginated history with filters), `get` (single backup detail), `start` (kick off a new backup; `wait=true` returns once it succeeds or fails, `background=true` tracks it as a `slide_jobs` job), " +
 			"`status_for_client` (last-N-hours summary for every agent under a client), " +
 			"`status_for_device` (last-N-hours summary for every agent on a device), " +
+			"`status_all` (last-N-hours summary for every agent in the account, grouped by client and device, worst first; problems_only=true for just failing/missing agents), " +
+			"`rpo_report` (each agent's last successful backup against its schedule interval or rpo_minutes, counting only scheduled hours/days and honoring pauses; violations first with overdue_minutes), " +
 			"`recent_for_agent` (last-N-hours runs for one agent). " +
 			"All three status_for_*/recent_for_agent ops accept name_hint as an alternative to the *_id. " +
-			"The `status_for_*` ops answer \"did backups run last night for X?\" in one call.",
+			"The `status_for_*` ops answer \"did backups run last night for X?\" in one call; " +
+			"`status_all` answers \"did backups run last night across everything?\"; " +
+			"`rpo_report` answers \"is anything out of RPO policy?\".",
 		InputSchema: map[string]interface{}{
 			"type":       "object",
 			"properties": props,
@@ -105,16 +128,19 @@ func getBackupsToolInfo() ToolInfo {
 	}
 }
 
-func handleBackupsList(args map[string]interface{}) (string, error) {
-	return listBackups(args)
+func handleBackupsList(ctx context.Context, args map[string]interface{}) (string, error) {
+	return listBackups(ctx, args)
 }
-func handleBackupsGet(args map[string]interface{}) (string, error) {
-	return getBackup(args)
+func handleBackupsGet(ctx context.Context, args map[string]interface{}) (string, error) {
+	return getBackup(ctx, args)
 }
-func handleBackupsStart(args map[string]interface{}) (string, error) {
-	return startBackup(args)
+func handleBackupsStart(ctx context.Context, args map[string]interface{}) (string, error) {
+	return startBackup(ctx, args)
 }
 
+// backupFetchErrorStatus marks an agent whose backups could not be fetched.
+const backupFetchErrorStatus = "error fetching backups"
+
 // agentBackupStatus is the per-agent rollup returned by status_for_*.
 type agentBackupStatus struct {
 	AgentID          string `json:"agent_id"`
@@ -129,7 +155,7 @@ type agentBackupStatus struct {
 	LastErrorMessage string `json:"last_error_message,omitempty"`
 }
 
-func handleBackupsStatusForClient(args map[string]interface{}) (string, error) {
+func handleBackupsStatusForClient(ctx context.Context, args map[string]interface{}) (string, error) {
 	clientID, err := requireString(args, "client_id")
 	if err != nil {
 		return "", err
@@ -139,7 +165,7 @@ func handleBackupsStatusForClient(args map[string]interface{}) (string, error) {
 		hours = 24
 	}
 
-	devicesData, err := makeAPIRequest("GET", fmt.Sprintf("/v1/device?client_id=%s&limit=50", clientID), nil)
+	devicesData, err := makeAPIRequest(ctx, "GET", fmt.Sprintf("/v1/device?client_id=%s&limit=50", clientID), nil)
 	if err != nil {
 		return "", err
 	}
@@ -148,21 +174,29 @@ func handleBackupsStatusForClient(args map[string]interface{}) (string, error) {
 		return "", fmt.Errorf("parse devices: %w", err)
 	}
 
-	agents := []Agent{}
-	for _, d := range devices.Data {
-		ad, err := makeAPIRequest("GET", fmt.Sprintf("/v1/agent?device_id=%s&limit=50", d.DeviceID), nil)
+	perDevice, err := fanOut(ctx, devices.Data, func(ctx context.Context, d Device) []Agent {
+		progressStep(ctx, -1, "listing agents on device %s", d.Hostname)
+		ad, err := makeAPIRequest(ctx, "GET", fmt.Sprintf("/v1/agent?device_id=%s&limit=50", d.DeviceID), nil)
 		if err != nil {
-			continue
+			return nil
 		}
 		var p PaginatedResponse[Agent]
-		if json.Unmarshal(ad, &p) == nil {
-			agents = append(agents, p.Data...)
+		if json.Unmarshal(ad, &p) != nil {
+			return nil
 		}
+		return p.Data
+	})
+	if err != nil {
+		return "", err
+	}
+	agents := []Agent{}
+	for _, deviceAgents := range perDevice {
+		agents = append(agents, deviceAgents...)
 	}
-	return runBackupsStatus(args, agents, hours, fmt.Sprintf("client %s", clientID))
+	return runBackupsStatus(ctx, args, agents, hours, fmt.Sprintf("client %s", clientID))
 }
 
-func handleBackupsStatusForDevice(args map[string]interface{}) (string, error) {
+func handleBackupsStatusForDevice(ctx context.Context, args map[string]interface{}) (string, error) {
 	deviceID, err := requireString(args, "device_id")
 	if err != nil {
 		return "", err
@@ -171,7 +205,7 @@ func handleBackupsStatusForDevice(args map[string]interface{}) (string, error) {
 	if !ok || hours <= 0 {
 		hours = 24
 	}
-	agentsData, err := makeAPIRequest("GET", fmt.Sprintf("/v1/agent?device_id=%s&limit=50", deviceID), nil)
+	agentsData, err := makeAPIRequest(ctx, "GET", fmt.Sprintf("/v1/agent?device_id=%s&limit=50", deviceID), nil)
 	if err != nil {
 		return "", err
 	}
@@ -179,10 +213,10 @@ func handleBackupsStatusForDevice(args map[string]interface{}) (string, error) {
 	if err := json.Unmarshal(agentsData, &p); err != nil {
 		return "", fmt.Errorf("parse agents: %w", err)
 	}
-	return runBackupsStatus(args, p.Data, hours, fmt.Sprintf("device %s", deviceID))
+	return runBackupsStatus(ctx, args, p.Data, hours, fmt.Sprintf("device %s", deviceID))
 }
 
-func handleBackupsRecentForAgent(args map[string]interface{}) (string, error) {
+func handleBackupsRecentForAgent(ctx context.Context, args map[string]interface{}) (string, error) {
 	agentID, err := requireString(args, "agent_id")
 	if err != nil {
 		return "", err
@@ -192,7 +226,7 @@ func handleBackupsRecentForAgent(args map[string]interface{}) (string, error) {
 		hours = 24
 	}
 	cutoff := time.Now().UTC().Add(-time.Duration(hours) * time.Hour)
-	data, err := makeAPIRequest("GET", fmt.Sprintf("/v1/backup?agent_id=%s&limit=50&sort_by=start_time", agentID), nil)
+	data, err := makeAPIRequest(ctx, "GET", fmt.Sprintf("/v1/backup?agent_id=%s&limit=50&sort_by=start_time", agentID), nil)
 	if err != nil {
 		return "", err
 	}
@@ -207,71 +241,329 @@ func handleBackupsRecentForAgent(args map[string]interface{}) (string, error) {
 
 // runBackupsStatus is the shared implementation: fetch each agent's recent
 // backups, summarise success/failure counts, and emit a per-client/device rollup.
-func runBackupsStatus(args map[string]interface{}, agents []Agent, hours int, scope string) (string, error) {
+func runBackupsStatus(ctx context.Context, args map[string]interface{}, agents []Agent, hours int, scope string) (string, error) {
 	cutoff := time.Now().UTC().Add(-time.Duration(hours) * time.Hour)
 
-	statuses := make([]agentBackupStatus, 0, len(agents))
+	statuses, err := collectBackupStatuses(ctx, agents, cutoff)
+	if err != nil {
+		return "", err
+	}
 	totalSuccess, totalFail, totalProg := 0, 0, 0
+	for _, s := range statuses {
+		totalSuccess += s.Successful
+		totalFail += s.Failed
+		totalProg += s.InProgress
+	}
 
-	for _, a := range agents {
-		data, err := makeAPIRequest("GET", fmt.Sprintf("/v1/backup?agent_id=%s&limit=50&sort_by=start_time", a.AgentID), nil)
-		if err != nil {
-			statuses = append(statuses, agentBackupStatus{
-				AgentID: a.AgentID, AgentName: bestAgentName(a),
-				LastStatus: "error fetching backups",
-			})
-			continue
+	out := map[string]interface{}{
+		"scope":        scope,
+		"window_hours": hours,
+		"summary": map[string]interface{}{
+			"agents":       len(agents),
+			"successful":   totalSuccess,
+			"failed":       totalFail,
+			"in_progress":  totalProg,
+			"window_start": cutoff.Format(time.RFC3339),
+		},
+		"agents_status": statuses,
+	}
+	return formatSingle(out, args, formatCompact)
+}
+
+// collectBackupStatuses fetches every agent's backups since cutoff on the
+// shared worker pool and returns the per-agent rollups in input order.
+// Per-agent failures are reported inline, but a canceled call returns an
+// error rather than a rollup with half the agents missing.
+func collectBackupStatuses(ctx context.Context, agents []Agent, cutoff time.Time) ([]agentBackupStatus, error) {
+	// Progress counts agents as they are dispatched: a notification sent
+	// just before the result can be dropped by the HTTP transport.
+	var started atomic.Int32
+	perAgent, err := fanOut(ctx, agents, func(ctx context.Context, a Agent) *agentBackupStatus {
+		n := int(started.Add(1))
+		progressStep(ctx, len(agents)-n, "checking backups for agent %d/%d (%s)", n, len(agents), bestAgentName(a))
+		return fetchAgentBackupStatus(ctx, a, cutoff)
+	})
+	if err != nil {
+		return nil, err
+	}
+	statuses := make([]agentBackupStatus, 0, len(agents))
+	for _, s := range perAgent {
+		statuses = append(statuses, *s)
+	}
+	return statuses, nil
+}
+
+// fetchAgentBackupStatus summarises one agent's backups since cutoff. A
+// failed request or an unparseable page is reported inline, so the agent
+// still shows up in the rollup.
+func fetchAgentBackupStatus(ctx context.Context, a Agent, cutoff time.Time) *agentBackupStatus {
+	data, err := makeAPIRequest(ctx, "GET", fmt.Sprintf("/v1/backup?agent_id=%s&limit=50&sort_by=start_time", a.AgentID), nil)
+	if err != nil {
+		return &agentBackupStatus{
+			AgentID: a.AgentID, AgentName: bestAgentName(a),
+			LastStatus: backupFetchErrorStatus,
 		}
-		var p PaginatedResponse[Backup]
-		if err := json.Unmarshal(data, &p); err != nil {
-			continue
+	}
+	var p PaginatedResponse[Backup]
+	if err := json.Unmarshal(data, &p); err != nil {
+		return &agentBackupStatus{
+			AgentID: a.AgentID, AgentName: bestAgentName(a),
+			LastStatus: backupFetchErrorStatus,
+		}
+	}
+	recent := filterBackupsAfter(p.Data, cutoff)
+
+	s := &agentBackupStatus{AgentID: a.AgentID, AgentName: bestAgentName(a)}
+	for _, b := range recent {
+		s.Total++
+		switch b.Status {
+		case "succeeded":
+			s.Successful++
+		case "failed":
+			s.Failed++
+		default:
+			s.InProgress++
+		}
+	}
+	// Latest backup overall for "last status"
+	sort.Slice(recent, func(i, j int) bool { return recent[i].StartedAt > recent[j].StartedAt })
+	if len(recent) > 0 {
+		latest := recent[0]
+		s.LastStatus = latest.Status
+		if latest.EndedAt != nil {
+			s.LastEndedAt = *latest.EndedAt
+		}
+		s.LastErrorCode = latest.ErrorCode
+		if latest.ErrorMessage != nil {
+			s.LastErrorMessage = *latest.ErrorMessage
 		}
-		recent := filterBackupsAfter(p.Data, cutoff)
-
-		s := agentBackupStatus{AgentID: a.AgentID, AgentName: bestAgentName(a)}
-		for _, b := range recent {
-			s.Total++
-			switch b.Status {
-			case "succeeded":
-				s.Successful++
-				totalSuccess++
-			case "failed":
-				s.Failed++
-				totalFail++
-			default:
-				s.InProgress++
-				totalProg++
+	}
+	return s
+}
+
+// Backup health buckets used by status_all, worst first.
+const (
+	backupHealthFailed   = "failed"   // latest run in the window failed
+	backupHealthMissing  = "missing"  // no runs in the window at all
+	backupHealthError    = "error"    // the agent's backups could not be fetched
+	backupHealthDegraded = "degraded" // some runs failed, the latest did not
+	backupHealthRunning  = "running"  // only in-progress runs so far
+	backupHealthOK       = "ok"
+)
+
+var backupHealthRank = map[string]int{
+	backupHealthFailed:   0,
+	backupHealthMissing:  1,
+	backupHealthError:    2,
+	backupHealthDegraded: 3,
+	backupHealthRunning:  4,
+	backupHealthOK:       5,
+}
+
+// classifyBackupHealth buckets one agent's window.
+func classifyBackupHealth(s agentBackupStatus) string {
+	switch {
+	case s.LastStatus == backupFetchErrorStatus:
+		return backupHealthError
+	case s.Total == 0:
+		return backupHealthMissing
+	case s.LastStatus == "failed":
+		return backupHealthFailed
+	case s.Failed > 0:
+		return backupHealthDegraded
+	case s.Successful == 0:
+		return backupHealthRunning
+	}
+	return backupHealthOK
+}
+
+// isBackupProblem reports whether a health bucket needs attention.
+func isBackupProblem(health string) bool {
+	return backupHealthRank[health] <= backupHealthRank[backupHealthDegraded]
+}
+
+// accountAgentBackupStatus is one agent in the status_all rollup.
+type accountAgentBackupStatus struct {
+	agentBackupStatus
+	Health string `json:"health"`
+}
+
+type deviceBackupGroup struct {
+	DeviceID   string                     `json:"device_id"`
+	DeviceName string                     `json:"device_name,omitempty"`
+	Agents     int                        `json:"agents"`
+	Problems   int                        `json:"problems"`
+	Worst      string                     `json:"worst"`
+	AgentsList []accountAgentBackupStatus `json:"agents_status"`
+}
+
+type clientBackupGroup struct {
+	ClientID   string              `json:"client_id,omitempty"`
+	ClientName string              `json:"client_name"`
+	Agents     int                 `json:"agents"`
+	Problems   int                 `json:"problems"`
+	Worst      string              `json:"worst"`
+	Devices    []deviceBackupGroup `json:"devices"`
+
+	deviceIDs []string
+}
+
+// handleBackupsStatusAll answers "did backups run last night across
+// everything?": every agent in the account, grouped by client and device,
+// with the worst clients, devices, and agents first.
+func handleBackupsStatusAll(ctx context.Context, args map[string]interface{}) (string, error) {
+	hours, ok := optionalInt(args, "hours")
+	if !ok || hours <= 0 {
+		hours = 24
+	}
+	problemsOnly, _ := optionalBool(args, "problems_only")
+	cutoff := time.Now().UTC().Add(-time.Duration(hours) * time.Hour)
+
+	clients, devices, agents, err := fetchInventoryEntities(ctx)
+	if err != nil {
+		return "", err
+	}
+	statuses, err := collectBackupStatuses(ctx, agents, cutoff)
+	if err != nil {
+		return "", err
+	}
+
+	clientNames := make(map[string]string, len(clients))
+	for _, c := range clients {
+		clientNames[c.ClientID] = c.Name
+	}
+	deviceByID := make(map[string]Device, len(devices))
+	for _, d := range devices {
+		deviceByID[d.DeviceID] = d
+	}
+	deviceOf := make(map[string]string, len(agents))
+	for _, a := range agents {
+		deviceOf[a.AgentID] = a.DeviceID
+	}
+
+	summary := map[string]int{}
+	totalSuccess, totalFail, totalProg := 0, 0, 0
+	groups := map[string]*clientBackupGroup{}
+	deviceGroups := map[string]*deviceBackupGroup{}
+	for _, s := range statuses {
+		health := classifyBackupHealth(s)
+		summary[health]++
+		totalSuccess += s.Successful
+		totalFail += s.Failed
+		totalProg += s.InProgress
+
+		deviceID := deviceOf[s.AgentID]
+		dg, ok := deviceGroups[deviceID]
+		if !ok {
+			d := deviceByID[deviceID]
+			name := d.DisplayName
+			if name == "" {
+				name = d.Hostname
+			}
+			dg = &deviceBackupGroup{DeviceID: deviceID, DeviceName: name, Worst: backupHealthOK}
+			deviceGroups[deviceID] = dg
+
+			clientID := ""
+			if d.ClientID != nil {
+				clientID = *d.ClientID
+			}
+			cg, ok := groups[clientID]
+			if !ok {
+				name := clientNames[clientID]
+				if clientID == "" {
+					name = "(no client)"
+				} else if name == "" {
+					name = clientID
+				}
+				cg = &clientBackupGroup{ClientID: clientID, ClientName: name, Worst: backupHealthOK}
+				groups[clientID] = cg
 			}
+			cg.deviceIDs = append(cg.deviceIDs, deviceID)
 		}
-		// Latest backup overall for "last status"
-		sort.Slice(recent, func(i, j int) bool { return recent[i].StartedAt > recent[j].StartedAt })
-		if len(recent) > 0 {
-			latest := recent[0]
-			s.LastStatus = latest.Status
-			if latest.EndedAt != nil {
-				s.LastEndedAt = *latest.EndedAt
+		dg.Agents++
+		if isBackupProblem(health) {
+			dg.Problems++
+		}
+		if backupHealthRank[health] < backupHealthRank[dg.Worst] {
+			dg.Worst = health
+		}
+		if problemsOnly && !isBackupProblem(health) {
+			continue
+		}
+		dg.AgentsList = append(dg.AgentsList, accountAgentBackupStatus{agentBackupStatus: s, Health: health})
+	}
+
+	// Roll device totals up into their clients, then order everything
+	// worst first.
+	out := make([]clientBackupGroup, 0, len(groups))
+	for _, cg := range groups {
+		devs := make([]deviceBackupGroup, 0, len(cg.deviceIDs))
+		for _, deviceID := range cg.deviceIDs {
+			dg := *deviceGroups[deviceID]
+			cg.Agents += dg.Agents
+			cg.Problems += dg.Problems
+			if backupHealthRank[dg.Worst] < backupHealthRank[cg.Worst] {
+				cg.Worst = dg.Worst
 			}
-			s.LastErrorCode = latest.ErrorCode
-			if latest.ErrorMessage != nil {
-				s.LastErrorMessage = *latest.ErrorMessage
+			if problemsOnly && dg.Problems == 0 {
+				continue
 			}
+			sort.SliceStable(dg.AgentsList, func(i, j int) bool {
+				a, b := dg.AgentsList[i], dg.AgentsList[j]
+				if backupHealthRank[a.Health] != backupHealthRank[b.Health] {
+					return backupHealthRank[a.Health] < backupHealthRank[b.Health]
+				}
+				return a.AgentName < b.AgentName
+			})
+			devs = append(devs, dg)
 		}
-		statuses = append(statuses, s)
+		if problemsOnly && cg.Problems == 0 {
+			continue
+		}
+		sort.SliceStable(devs, func(i, j int) bool {
+			return worseGroup(devs[i].Worst, devs[i].Problems, devs[i].DeviceName, devs[j].Worst, devs[j].Problems, devs[j].DeviceName)
+		})
+		cg.Devices = devs
+		out = append(out, *cg)
 	}
+	sort.SliceStable(out, func(i, j int) bool {
+		return worseGroup(out[i].Worst, out[i].Problems, out[i].ClientName, out[j].Worst, out[j].Problems, out[j].ClientName)
+	})
 
-	out := map[string]interface{}{
-		"scope":        scope,
-		"window_hours": hours,
+	problems := 0
+	for health, n := range summary {
+		if isBackupProblem(health) {
+			problems += n
+		}
+	}
+	result := map[string]interface{}{
+		"scope":         "account",
+		"window_hours":  hours,
+		"problems_only": problemsOnly,
 		"summary": map[string]interface{}{
-			"agents":       len(agents),
+			"agents":       len(statuses),
+			"problems":     problems,
+			"by_health":    summary,
 			"successful":   totalSuccess,
 			"failed":       totalFail,
 			"in_progress":  totalProg,
 			"window_start": cutoff.Format(time.RFC3339),
 		},
-		"agents_status": statuses,
+		"clients": out,
 	}
-	return formatSingle(out, args, formatCompact)
+	return formatSingle(result, args, formatCompact)
+}
+
+// worseGroup orders client/device groups: worst health first, then the
+// most problem agents, then by name for a stable read.
+func worseGroup(worstA string, problemsA int, nameA string, worstB string, problemsB int, nameB string) bool {
+	if backupHealthRank[worstA] != backupHealthRank[worstB] {
+		return backupHealthRank[worstA] < backupHealthRank[worstB]
+	}
+	if problemsA != problemsB {
+		return problemsA > problemsB
+	}
+	return strings.ToLower(nameA) < strings.ToLower(nameB)
 }
 
 func filterBackupsAfter(backups []Backup, cutoff time.Time) []Backup {
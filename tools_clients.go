@@ -1,11 +1,13 @@
 package main
 
+import "context"
+
 // slide_clients: client (organisational unit) CRUD. Split out of the
 // v3 slide_user_management mega-tool because in MSP workflows clients
 // are touched far more often than users/accounts.
 
-func handleClientsTool(args map[string]interface{}) (string, error) {
-	return HandleToolWithOperations(CreateToolConfigWithResolutions("slide_clients", ToolOperations{
+func handleClientsTool(ctx context.Context, args map[string]interface{}) (string, error) {
+	return HandleToolWithOperations(ctx, CreateToolConfigWithResolutions("slide_clients", ToolOperations{
 		"list":   listClients,
 		"get":    getClient,
 		"create": createClient,
@@ -1,9 +1,11 @@
 package main
 
+import "context"
+
 // handleDevicesTool handles all device-related operations through a single meta-tool
-func handleDevicesTool(args map[string]interface{}) (string, error) {
+func handleDevicesTool(ctx context.Context, args map[string]interface{}) (string, error) {
 	deviceResolution := ResolutionSpec{IDKey: "device_id", Kind: "device"}
-	return HandleToolWithOperations(CreateToolConfigWithResolutions("slide_devices", ToolOperations{
+	return HandleToolWithOperations(ctx, CreateToolConfigWithResolutions("slide_devices", ToolOperations{
 		"list":     listDevices,
 		"get":      getDevice,
 		"update":   updateDevice,
@@ -6,34 +6,47 @@ package main
 // workflow an MSP tech actually executes.
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 )
 
-func handleFilesTool(args map[string]interface{}) (string, error) {
-	return HandleToolWithOperations(CreateToolConfigWithResolutions("slide_files", ToolOperations{
+func handleFilesTool(ctx context.Context, args map[string]interface{}) (string, error) {
+	return HandleToolWithOperations(ctx, CreateToolConfigWithResolutions("slide_files", ToolOperations{
 		"search":          handleFilesSearch,
 		"versions":        handleFilesVersions,
 		"list_restores":   listFileRestores,
 		"get_restore":     getFileRestore,
-		"create_restore":  createFileRestore,
+		"create_restore":  withWait(createFileRestore, "file_restore"),
 		"delete_restore":  deleteFileRestore,
 		"browse":          handleFilesBrowse,
 		"list_pushes":     listFileRestorePushes,
-		"create_push":     createFileRestorePush,
+		"create_push":     withWait(createFileRestorePush, "push"),
 		"update_push":     updateFileRestorePush,
 		"get_push_status": handleFilesGetPushStatus,
+		"recover_file":    handleFilesRecoverFile,
+		"search_client":   handleFilesSearchClient,
+		"download":        handleFilesDownload,
+		"tree":            handleFilesTree,
+		"diff_versions":   handleFilesDiffVersions,
+		"diff_snapshots":  handleFilesDiffSnapshots,
 	}, map[string]ResolutionSpec{
-		"search":   {IDKey: "agent_id", Kind: "agent"},
-		"versions": {IDKey: "agent_id", Kind: "agent"},
+		"search":         {IDKey: "agent_id", Kind: "agent"},
+		"versions":       {IDKey: "agent_id", Kind: "agent"},
+		"recover_file":   {IDKey: "agent_id", Kind: "agent"},
+		"diff_versions":  {IDKey: "agent_id", Kind: "agent"},
+		"diff_snapshots": {IDKey: "agent_id", Kind: "agent"},
+		// device_id also scopes search_client; name_hint only names clients.
+		"search_client": {IDKey: "client_id", Kind: "client"},
 	}), args)
 }
 
 var filesOperationEnums = []string{
-	"search", "versions",
+	"search", "search_client", "versions", "diff_versions", "diff_snapshots",
 	"list_restores", "get_restore", "create_restore", "delete_restore",
-	"browse",
+	"browse", "tree", "download",
 	"list_pushes", "create_push", "update_push", "get_push_status",
+	"recover_file",
 }
 
 func getFilesToolInfo() ToolInfo {
@@ -45,19 +58,27 @@ func getFilesToolInfo() ToolInfo {
 		},
 		"agent_id": map[string]interface{}{
 			"type":        "string",
-			"description": "ID of the agent to search. Required for `search` and `versions` (alternative: pass `name_hint`).",
+			"description": "ID of the agent to search. Required for `search`, `versions`, `diff_versions`, `diff_snapshots`, and `recover_file` (alternative: pass `name_hint`).",
 		},
 		"name_hint": map[string]interface{}{
 			"type":        "string",
-			"description": "Alternative to agent_id for `search` and `versions`: an agent hostname or display name (case-insensitive substring match). Use this when the user says 'Bob's laptop' or 'the file server'.",
+			"description": "Alternative to agent_id for `search`, `versions`, `diff_versions`, `diff_snapshots`, and `recover_file`: an agent hostname or display name (case-insensitive substring match). Use this when the user says 'Bob's laptop' or 'the file server'. For `search_client` it names the client instead ('ACME').",
 		},
 		"search_term": map[string]interface{}{
 			"type":        "string",
-			"description": "File name (or substring) to search for. Required for `search`. Matches anywhere in the path.",
+			"description": "File name (or substring) to search for. Required for `search` and `search_client`; for `recover_file`, pass this or `path`. Matches anywhere in the path.",
 		},
 		"path": map[string]interface{}{
 			"type":        "string",
-			"description": "Full file path to look up versions for, e.g. `C:\\Users\\bob\\Documents\\Q4-budget.xlsx`. Required for `versions`.",
+			"description": "Full file path to look up versions for, e.g. `C:\\Users\\bob\\Documents\\Q4-budget.xlsx`. Required for `versions` and `diff_versions`. For `recover_file`, skips the search.",
+		},
+		"from_snapshot_id": map[string]interface{}{
+			"type":        "string",
+			"description": "The older snapshot to compare from. Required for `diff_snapshots`. For `diff_versions`, defaults to the newest older snapshot whose copy differs in size or modified time.",
+		},
+		"to_snapshot_id": map[string]interface{}{
+			"type":        "string",
+			"description": "The newer snapshot to compare to. Required for `diff_snapshots`. For `diff_versions`, defaults to the newest snapshot holding the file.",
 		},
 		"sort_by_search": map[string]interface{}{
 			"type":        "string",
@@ -71,27 +92,61 @@ func getFilesToolInfo() ToolInfo {
 		},
 		"snapshot_id": map[string]interface{}{
 			"type":        "string",
-			"description": "Snapshot ID to restore from. Required for `create_restore`.",
+			"description": "Snapshot ID to restore from. Required for `create_restore`. For `recover_file`, picks the version exactly.",
+		},
+		"date": map[string]interface{}{
+			"type":        "string",
+			"description": "For `recover_file`: restore the version from this day (YYYY-MM-DD, agent's timezone). If the file changed during the day, the candidates are returned instead.",
+		},
+		"before": map[string]interface{}{
+			"type":        "string",
+			"description": "For `recover_file`: restore the newest version taken before this time (RFC 3339) or day (YYYY-MM-DD, meaning before it starts). Default: the newest version.",
 		},
 		"device_id": map[string]interface{}{
 			"type":        "string",
-			"description": "Device that owns the snapshot. Required for `create_restore`.",
+			"description": "Device that owns the snapshot. Required for `create_restore`. For `search_client`, searches just this device's agents.",
+		},
+		"client_id": map[string]interface{}{
+			"type":        "string",
+			"description": "Client whose agents `search_client` searches (alternative: `name_hint` with the client name, or `device_id`).",
+		},
+		"timeout_seconds": map[string]interface{}{
+			"type":        "integer",
+			"description": "Deadline for the whole `search_client` sweep (default 30, max 120). Agents not searched in time are reported as timed_out.",
 		},
 		"file_restore_id": map[string]interface{}{
 			"type":        "string",
-			"description": "Restore session ID. Required for `get_restore`, `delete_restore`, `browse`, `list_pushes`, `create_push`, `update_push`, `get_push_status`.",
+			"description": "Restore session ID. Required for `get_restore`, `delete_restore`, `browse`, `tree`, `download`, `list_pushes`, `create_push`, `update_push`, `get_push_status`.",
 		},
 		"browse_path": map[string]interface{}{
 			"type":        "string",
-			"description": "Folder path inside the restore to browse, e.g. `/C:/Users`. Required for `browse`.",
+			"description": "Folder path inside the restore to browse, e.g. `/C:/Users`. Required for `browse`; for `tree` and `diff_snapshots`, where the walk starts (default `/`).",
+		},
+		"max_depth": map[string]interface{}{
+			"type":        "integer",
+			"description": "For `tree` and `diff_snapshots`: how many folder levels to walk below browse_path (default 3 for `tree`, 6 for `diff_snapshots`; max 10).",
+		},
+		"max_entries": map[string]interface{}{
+			"type":        "integer",
+			"description": "For `tree`: stop after this many entries (default 500, max 5000); folders left unexpanded are marked with `…`. For `diff_snapshots`: per snapshot (default 5000, max 20000).",
+		},
+		"include": map[string]interface{}{
+			"type":        "array",
+			"items":       map[string]interface{}{"type": "string"},
+			"description": "For `tree` and `diff_snapshots`: only list files matching one of these globs, e.g. `[\"*.docx\", \"*.pdf\"]` (case-insensitive). A pattern with `/` matches the path below browse_path, otherwise the name. Folders are kept when something inside them matches.",
+		},
+		"exclude": map[string]interface{}{
+			"type":        "array",
+			"items":       map[string]interface{}{"type": "string"},
+			"description": "For `tree` and `diff_snapshots`: skip entries matching one of these globs, e.g. `[\"AppData\", \"*.tmp\"]`. Excluded folders are not walked.",
 		},
 		"source_file_path": map[string]interface{}{
 			"type":        "string",
-			"description": "Path of the file inside the restore to push back. Required for `create_push`.",
+			"description": "Path of the file inside the restore. Required for `create_push` and `download`.",
 		},
 		"destination_folder": map[string]interface{}{
 			"type":        "string",
-			"description": "Destination on the protected system. Must end in `\\SlideRestore` (e.g. `C:\\SlideRestore`). Required for `create_push`.",
+			"description": "Destination on the protected system. Must end in `\\SlideRestore` (e.g. `C:\\SlideRestore`). Required for `create_push`; `recover_file` defaults to `C:\\SlideRestore`.",
 		},
 		"file_restore_push_id": map[string]interface{}{
 			"type":        "string",
@@ -103,6 +158,9 @@ func getFilesToolInfo() ToolInfo {
 			"enum":        []string{"canceled"},
 		},
 	}
+	for k, v := range waitProperties("`create_restore` and `create_push`") {
+		props[k] = v
+	}
 	for k, v := range commonListProperties() {
 		props[k] = v
 	}
@@ -113,12 +171,19 @@ func getFilesToolInfo() ToolInfo {
 			"REACH FOR THIS whenever the user mentions a filename, a lost file, 'recover X', 'restore X', " +
 			"'previous version', 'yesterday's copy', 'find Q4-budget on Bob's laptop', 'push it back to the server', " +
 			"or any file-level recovery from a Slide-protected system. The headline 'I lost a file, can you get it back?' tool. " +
-			"Operations: `search` (find a file across an agent's snapshots), `versions` (list snapshots that contain a path), " +
-			"`list_restores`/`get_restore`/`create_restore`/`delete_restore` (manage restore sessions), `browse` (walk a restore), " +
-			"`list_pushes`/`create_push`/`update_push`/`get_push_status` (push a file back to the protected system). " +
+			"Operations: `search` (find a file across an agent's snapshots), " +
+			"`search_client` (the same across every file-indexed agent of a client or device, when nobody knows which machine has it), `versions` (list snapshots that contain a path), " +
+			"`diff_versions` (what changed in a file between two snapshots: a unified diff for text, size/SHA-256/modified time for binaries; opens and then deletes the restores it needs), " +
+			"`diff_snapshots` (what changed under a folder between two snapshots: added/removed/modified files by size and modified time, with ransomware red flags such as mass renames to one unfamiliar extension or ransom notes; the first call after a suspected ransomware hit), " +
+			"`list_restores`/`get_restore`/`create_restore`/`delete_restore` (manage restore sessions), `browse` (one folder of a restore), " +
+			"`tree` (a whole restore, or a folder of it, as an indented listing with sizes and modified times, in one call; bounded by `max_depth`/`max_entries`, filtered by `include`/`exclude` globs), " +
+			"`download` (save a restored file to the local --download-dir with resume and SHA-256; stdio only, off unless configured), " +
+			"`list_pushes`/`create_push`/`update_push`/`get_push_status` (push a file back to the protected system), " +
+			"`recover_file` (all of the above in one call: finds the file, picks the version for `date`/`before`, restores it, and pushes it to SlideRestore; returns `needs_choice` with candidates when the file or version is ambiguous). " +
 			"Identifying the agent: pass `agent_id` OR `name_hint` (e.g. name_hint='bob' resolves Bob's laptop). " +
 			"Example: {operation:'search', name_hint:'bob', search_term:'Q4-budget'} returns every snapshot containing 'Q4-budget' for Bob's laptop. " +
-			"Typical recovery flow: `search` -> pick a path -> `versions` -> pick a snapshot -> `create_restore` -> `browse` -> `create_push`.",
+			"`create_restore` and `create_push` accept `wait=true` to return only once the restore is browsable or the push has finished, or `background=true` to follow a long push as a `slide_jobs` job. " +
+			"Typical recovery flow: `search` -> pick a path -> `versions` -> pick a snapshot -> `create_restore` -> `browse` -> `create_push`, or `recover_file` for the same thing in one call.",
 		InputSchema: map[string]interface{}{
 			"type":       "object",
 			"properties": props,
@@ -130,20 +195,46 @@ func getFilesToolInfo() ToolInfo {
 						req("search_term"),
 					},
 				}},
+				{"if": ifOp("search_client"), "then": map[string]interface{}{
+					"allOf": []map[string]interface{}{
+						reqEither("client_id", "device_id", "name_hint"),
+						req("search_term"),
+					},
+				}},
 				{"if": ifOp("versions"), "then": map[string]interface{}{
 					"allOf": []map[string]interface{}{
 						reqEither("agent_id", "name_hint"),
 						req("path"),
 					},
 				}},
+				{"if": ifOp("diff_versions"), "then": map[string]interface{}{
+					"allOf": []map[string]interface{}{
+						reqEither("agent_id", "name_hint"),
+						req("path"),
+					},
+				}},
+				{"if": ifOp("diff_snapshots"), "then": map[string]interface{}{
+					"allOf": []map[string]interface{}{
+						reqEither("agent_id", "name_hint"),
+						req("from_snapshot_id", "to_snapshot_id"),
+					},
+				}},
 				{"if": ifOp("get_restore"), "then": req("file_restore_id")},
 				{"if": ifOp("create_restore"), "then": req("snapshot_id", "device_id")},
 				{"if": ifOp("delete_restore"), "then": req("file_restore_id")},
 				{"if": ifOp("browse"), "then": req("file_restore_id", "browse_path")},
+				{"if": ifOp("tree"), "then": req("file_restore_id")},
+				{"if": ifOp("download"), "then": req("file_restore_id", "source_file_path")},
 				{"if": ifOp("list_pushes"), "then": req("file_restore_id")},
 				{"if": ifOp("create_push"), "then": req("file_restore_id", "source_file_path", "destination_folder")},
 				{"if": ifOp("update_push"), "then": req("file_restore_id", "file_restore_push_id", "state")},
 				{"if": ifOp("get_push_status"), "then": req("file_restore_id", "file_restore_push_id")},
+				{"if": ifOp("recover_file"), "then": map[string]interface{}{
+					"allOf": []map[string]interface{}{
+						reqEither("agent_id", "name_hint"),
+						reqEither("search_term", "path"),
+					},
+				}},
 			},
 		},
 	}
@@ -151,7 +242,7 @@ func getFilesToolInfo() ToolInfo {
 
 // --- search & versions handlers ----------------------------------------
 
-func handleFilesSearch(args map[string]interface{}) (string, error) {
+func handleFilesSearch(ctx context.Context, args map[string]interface{}) (string, error) {
 	agentID, err := requireString(args, "agent_id")
 	if err != nil {
 		return "", err
@@ -172,7 +263,7 @@ func handleFilesSearch(args map[string]interface{}) (string, error) {
 		sortAscPtr = &v
 	}
 
-	resp, err := searchAgentFiles(agentID, searchTerm, limit, offset, sortBy, sortAscPtr)
+	resp, err := searchAgentFiles(ctx, agentID, searchTerm, limit, offset, sortBy, sortAscPtr)
 	if err != nil {
 		return "", err
 	}
@@ -185,7 +276,7 @@ func handleFilesSearch(args map[string]interface{}) (string, error) {
 	})
 }
 
-func handleFilesVersions(args map[string]interface{}) (string, error) {
+func handleFilesVersions(ctx context.Context, args map[string]interface{}) (string, error) {
 	agentID, err := requireString(args, "agent_id")
 	if err != nil {
 		return "", err
@@ -205,7 +296,7 @@ func handleFilesVersions(args map[string]interface{}) (string, error) {
 		sortAscPtr = &v
 	}
 
-	resp, err := listPathVersions(agentID, path, limit, offset, sortBy, sortAscPtr)
+	resp, err := listPathVersions(ctx, agentID, path, limit, offset, sortBy, sortAscPtr)
 	if err != nil {
 		return "", err
 	}
@@ -221,14 +312,14 @@ func handleFilesVersions(args map[string]interface{}) (string, error) {
 // handleFilesBrowse forwards to browseFileRestore, mapping our `browse_path`
 // arg onto the legacy `path` parameter so the slide_files tool stays
 // internally consistent.
-func handleFilesBrowse(args map[string]interface{}) (string, error) {
+func handleFilesBrowse(ctx context.Context, args map[string]interface{}) (string, error) {
 	if bp, ok := args["browse_path"].(string); ok {
 		args["path"] = bp
 	}
-	return browseFileRestore(args)
+	return browseFileRestore(ctx, args)
 }
 
-func handleFilesGetPushStatus(args map[string]interface{}) (string, error) {
+func handleFilesGetPushStatus(ctx context.Context, args map[string]interface{}) (string, error) {
 	fileRestoreID, err := requireString(args, "file_restore_id")
 	if err != nil {
 		return "", err
@@ -238,7 +329,7 @@ func handleFilesGetPushStatus(args map[string]interface{}) (string, error) {
 		return "", err
 	}
 	endpoint := fmt.Sprintf("/v1/restore/file/%s/push/%s", fileRestoreID, pushID)
-	body, err := makeAPIRequest("GET", endpoint, nil)
+	body, err := makeAPIRequest(ctx, "GET", endpoint, nil)
 	if err != nil {
 		return "", err
 	}
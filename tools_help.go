@@ -20,6 +20,7 @@ package main
 // //go:embed; no network is touched.
 
 import (
+	"context"
 	_ "embed"
 	"encoding/json"
 	"fmt"
@@ -37,16 +38,16 @@ var helpTroubleshootMD string
 //go:embed docs/help/examples.md
 var helpExamplesMD string
 
-func handleHelpTool(args map[string]interface{}) (string, error) {
-	return HandleToolWithOperations(CreateToolConfig("slide_help", ToolOperations{
-		"getting_started":  handleHelpGettingStarted,
-		"examples":         handleHelpExamples,
-		"glossary":         handleHelpGlossary,
-		"troubleshoot":     handleHelpTroubleshoot,
-		"list_prompts":     handleHelpListPrompts,
-		"list_resources":   handleHelpListResources,
-		"what_can_you_do":  handleHelpWhatCanYouDo,
-		"debug":            handleHelpDebug,
+func handleHelpTool(ctx context.Context, args map[string]interface{}) (string, error) {
+	return HandleToolWithOperations(ctx, CreateToolConfig("slide_help", ToolOperations{
+		"getting_started": handleHelpGettingStarted,
+		"examples":        handleHelpExamples,
+		"glossary":        handleHelpGlossary,
+		"troubleshoot":    handleHelpTroubleshoot,
+		"list_prompts":    handleHelpListPrompts,
+		"list_resources":  handleHelpListResources,
+		"what_can_you_do": handleHelpWhatCanYouDo,
+		"debug":           handleHelpDebug,
 	}), args)
 }
 
@@ -90,24 +91,24 @@ func getHelpToolInfo() ToolInfo {
 
 // --- handlers ---------------------------------------------------------
 
-func handleHelpGettingStarted(_ map[string]interface{}) (string, error) {
+func handleHelpGettingStarted(_ context.Context, _ map[string]interface{}) (string, error) {
 	mode := ""
-	if config != nil {
-		mode = config.ToolsMode
+	if cfg := currentConfig(); cfg != nil {
+		mode = cfg.ToolsMode
 	}
 	prefix := fmt.Sprintf("slide-mcp-server v%s, permission tier=%s\n\n", Version, mode)
 	return prefix + helpWelcomeMD, nil
 }
 
-func handleHelpExamples(_ map[string]interface{}) (string, error) {
+func handleHelpExamples(_ context.Context, _ map[string]interface{}) (string, error) {
 	return helpExamplesMD, nil
 }
 
-func handleHelpGlossary(_ map[string]interface{}) (string, error) {
+func handleHelpGlossary(_ context.Context, _ map[string]interface{}) (string, error) {
 	return helpGlossaryMD, nil
 }
 
-func handleHelpTroubleshoot(_ map[string]interface{}) (string, error) {
+func handleHelpTroubleshoot(_ context.Context, _ map[string]interface{}) (string, error) {
 	return helpTroubleshootMD, nil
 }
 
@@ -118,7 +119,7 @@ type helpPromptEntry struct {
 	Description string `json:"description"`
 }
 
-func handleHelpListPrompts(_ map[string]interface{}) (string, error) {
+func handleHelpListPrompts(_ context.Context, _ map[string]interface{}) (string, error) {
 	prompts := []helpPromptEntry{
 		{"slide.welcome", "One-message intro for first-time users. No arguments."},
 		{"slide.daily-status", "Daily ops summary across the account. Optional client + hours."},
@@ -143,7 +144,7 @@ type helpResourceEntry struct {
 	Description string `json:"description"`
 }
 
-func handleHelpListResources(_ map[string]interface{}) (string, error) {
+func handleHelpListResources(_ context.Context, _ map[string]interface{}) (string, error) {
 	resources := []helpResourceEntry{
 		{resourceURIWelcome, "One-page primer with example questions; no API calls. Cheap, safe to read at conversation start."},
 		{resourceURIHelpGlossary, "Slide-specific glossary."},
@@ -157,10 +158,13 @@ func handleHelpListResources(_ map[string]interface{}) (string, error) {
 		{resourceURITplDevice, "URI template: slide://device/{device_id}."},
 		{resourceURITplAgent, "URI template: slide://agent/{agent_id}."},
 		{resourceURITplAgentRecents, "URI template: slide://agent/{agent_id}/snapshots/recent."},
+		{resourceURITplJob, "URI template: slide://jobs/{job_id} - a background=true job started in this session."},
 	}
 	out := map[string]interface{}{
 		"resources": resources,
-		"hint":      "Resources are read-only; prefer them over tool calls when you just need context.",
+		"hint": "Resources are read-only; prefer them over tool calls when you just need context. " +
+			"Health, unresolved alerts, and the device and agent templates accept resources/subscribe and send " +
+			"notifications/resources/updated when they change.",
 	}
 	b, err := json.MarshalIndent(out, "", "  ")
 	if err != nil {
@@ -173,8 +177,8 @@ func handleHelpListResources(_ map[string]interface{}) (string, error) {
 // --debug CLI subcommand) as pretty-printed JSON. Read-only; safe to call
 // from any permission tier. The api_key field is masked; no secrets are
 // emitted, so the LLM can echo the entire response back to the user.
-func handleHelpDebug(_ map[string]interface{}) (string, error) {
-	info := gatherDebugInfo()
+func handleHelpDebug(ctx context.Context, _ map[string]interface{}) (string, error) {
+	info := gatherDebugInfo(ctx)
 	out, err := json.MarshalIndent(info, "", "  ")
 	if err != nil {
 		return "", fmt.Errorf("failed to marshal debug info: %w", err)
@@ -182,10 +186,10 @@ func handleHelpDebug(_ map[string]interface{}) (string, error) {
 	return string(out), nil
 }
 
-func handleHelpWhatCanYouDo(_ map[string]interface{}) (string, error) {
+func handleHelpWhatCanYouDo(_ context.Context, _ map[string]interface{}) (string, error) {
 	mode := ""
-	if config != nil {
-		mode = config.ToolsMode
+	if cfg := currentConfig(); cfg != nil {
+		mode = cfg.ToolsMode
 	}
 
 	always := []string{
@@ -199,6 +203,7 @@ func handleHelpWhatCanYouDo(_ map[string]interface{}) (string, error) {
 	safeOnly := []string{
 		"Kick off on-demand backups, create/manage restore sessions, push files back to protected systems.",
 		"Boot recovery VMs from snapshots, export disk images (VHD/VHDX/VMDK/QCOW2/RAW), generate RDP bookmarks.",
+		"Follow long backups, exports, restores, and pushes as background jobs (background=true, then slide_jobs).",
 		"Create and update DR networks, IPSec connections, port forwards, and WireGuard peers.",
 		"Update agent backup schedules, retention policies, restore defaults, volume settings, alert configs.",
 		"Update device hostnames / display names / network configuration. Create or modify VLANs.",
@@ -212,15 +217,15 @@ func handleHelpWhatCanYouDo(_ map[string]interface{}) (string, error) {
 	out := map[string]interface{}{
 		"permission_tier": mode,
 		"always_available": map[string]interface{}{
-			"description": "Available in every permission tier (read-only, safe, full).",
+			"description":  "Available in every permission tier (read-only, safe, full).",
 			"capabilities": always,
 		},
 		"requires_safe_or_full": map[string]interface{}{
-			"description": "Available in `safe` (the default) and `full`. Blocked in `read-only`.",
+			"description":  "Available in `safe` (the default) and `full`. Blocked in `read-only`.",
 			"capabilities": safeOnly,
 		},
 		"requires_full": map[string]interface{}{
-			"description": "Available only in `full` (irreversible / power-cycle operations).",
+			"description":  "Available only in `full` (irreversible / power-cycle operations).",
 			"capabilities": fullOnly,
 		},
 		"note": fmt.Sprintf("Current tier is %q. Change it in Claude Desktop -> Settings -> Extensions -> Slide Backup -> Tool permissions.", mode),
@@ -0,0 +1,116 @@
+package main
+
+// slide_jobs: the background jobs that background=true starts (see
+// jobs.go). Everything here is local bookkeeping; only the jobs
+// themselves poll the Slide API.
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+func handleJobsTool(ctx context.Context, args map[string]interface{}) (string, error) {
+	return HandleToolWithOperations(ctx, CreateToolConfig("slide_jobs", ToolOperations{
+		"list":   handleJobsList,
+		"get":    handleJobsGet,
+		"cancel": handleJobsCancel,
+	}), args)
+}
+
+var jobsOperationEnums = []string{"list", "get", "cancel"}
+
+func getJobsToolInfo() ToolInfo {
+	props := map[string]interface{}{
+		"operation": map[string]interface{}{
+			"type":        "string",
+			"description": "The operation to perform.",
+			"enum":        jobsOperationEnums,
+		},
+		"job_id": map[string]interface{}{
+			"type":        "string",
+			"description": "Job ID (job_...) from a `_job` block. Required for `get`, `cancel`.",
+		},
+		"status": map[string]interface{}{
+			"type":        "string",
+			"description": "For `list`: only jobs with this status.",
+			"enum":        jobStatuses,
+		},
+	}
+	// Nothing here pages or sorts.
+	common := commonListProperties()
+	for _, k := range []string{"format", "fields", "hints"} {
+		props[k] = common[k]
+	}
+	return ToolInfo{
+		Name: "slide_jobs",
+		Description: "Slide MCP - background jobs. " +
+			"REACH FOR THIS when an earlier call returned a `_job` block, or the user asks 'is that export done yet?', " +
+			"'what is still running?', or 'stop watching that backup'. " +
+			"`background=true` on `slide_backups start`, `slide_recovery boot_vm` / `export_image`, and " +
+			"`slide_files create_restore` / `create_push` returns a job_id at once and keeps following the new object on the server. " +
+			"Operations: `list` (this session's jobs, newest first), `get` (status plus the last object seen), " +
+			"`cancel` (stop following; the backup, export, or push itself carries on in Slide). " +
+			"Jobs are also readable as slide://jobs/{job_id}. Finished jobs are kept for 24 hours.",
+		InputSchema: map[string]interface{}{
+			"type":       "object",
+			"properties": props,
+			"required":   []string{"operation"},
+			"allOf": []map[string]interface{}{
+				{"if": ifOp("get"), "then": req("job_id")},
+				{"if": ifOp("cancel"), "then": req("job_id")},
+			},
+		},
+	}
+}
+
+func handleJobsList(ctx context.Context, args map[string]interface{}) (string, error) {
+	status, _ := optionalString(args, "status")
+	if status != "" && !slices.Contains(jobStatuses, status) {
+		return "", fmt.Errorf("status must be one of %s", strings.Join(jobStatuses, ", "))
+	}
+	list := backgroundJobs.list(ctx, status)
+	running := 0
+	for _, j := range list {
+		if j.Status == jobRunning {
+			running++
+		}
+	}
+	return formatSingle(map[string]interface{}{
+		"jobs":    list,
+		"count":   len(list),
+		"running": running,
+	}, args, formatCompact)
+}
+
+func handleJobsGet(ctx context.Context, args map[string]interface{}) (string, error) {
+	id, err := requireString(args, "job_id")
+	if err != nil {
+		return "", err
+	}
+	j, obj, err := backgroundJobs.get(ctx, id)
+	if err != nil {
+		return "", err
+	}
+	return formatSingle(jobView(j, obj), args, formatCompact)
+}
+
+func handleJobsCancel(ctx context.Context, args map[string]interface{}) (string, error) {
+	id, err := requireString(args, "job_id")
+	if err != nil {
+		return "", err
+	}
+	j, err := backgroundJobs.cancel(ctx, id)
+	if err != nil {
+		return "", err
+	}
+	out := jobView(j, nil)
+	delete(out, "object_note")
+	if j.Status == jobCanceled {
+		out["note"] = "No longer followed. The work itself carries on in Slide; " + jobFollowUp(j) + " shows where it stands."
+	} else {
+		out["note"] = fmt.Sprintf("The job had already finished (%s); nothing to cancel.", j.Status)
+	}
+	return formatSingle(out, args, formatCompact)
+}
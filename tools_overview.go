@@ -6,6 +6,7 @@ package main
 // in natural language ("what's the state of things?").
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"strings"
@@ -13,8 +14,8 @@ import (
 	"time"
 )
 
-func handleOverviewTool(args map[string]interface{}) (string, error) {
-	return HandleToolWithOperations(CreateToolConfigWithResolutions("slide_overview", ToolOperations{
+func handleOverviewTool(ctx context.Context, args map[string]interface{}) (string, error) {
+	return HandleToolWithOperations(ctx, CreateToolConfigWithResolutions("slide_overview", ToolOperations{
 		"inventory":  handleOverviewInventory,
 		"health":     handleOverviewHealth,
 		"for_client": handleOverviewForClient,
@@ -81,8 +82,8 @@ func getOverviewToolInfo() ToolInfo {
 // handleOverviewInventory preserves the legacy clients->devices->agents
 // hierarchy that the old list_all_clients_devices_and_agents returned, in a
 // shape Claude Desktop is already used to.
-func handleOverviewInventory(args map[string]interface{}) (string, error) {
-	return listAllClientsDevicesAndAgents(args)
+func handleOverviewInventory(ctx context.Context, args map[string]interface{}) (string, error) {
+	return listAllClientsDevicesAndAgents(ctx, args)
 }
 
 // healthEntry is one row in the health summary.
@@ -97,7 +98,7 @@ type healthEntry struct {
 	Detail       string `json:"detail,omitempty"`
 }
 
-func handleOverviewHealth(args map[string]interface{}) (string, error) {
+func handleOverviewHealth(ctx context.Context, args map[string]interface{}) (string, error) {
 	staleMinutes, ok := optionalInt(args, "stale_minutes")
 	if !ok || staleMinutes <= 0 {
 		staleMinutes = 30
@@ -113,11 +114,11 @@ func handleOverviewHealth(args map[string]interface{}) (string, error) {
 	wg.Add(2)
 	go func() {
 		defer wg.Done()
-		devices, deviceErr = fetchAllPaginated[Device]("/v1/device")
+		devices, deviceErr = fetchAllPaginated[Device](ctx, "/v1/device")
 	}()
 	go func() {
 		defer wg.Done()
-		agents, agentErr = fetchAllPaginated[Agent]("/v1/agent")
+		agents, agentErr = fetchAllPaginated[Agent](ctx, "/v1/agent")
 	}()
 	wg.Wait()
 	if deviceErr != nil {
@@ -126,6 +127,7 @@ func handleOverviewHealth(args map[string]interface{}) (string, error) {
 	if agentErr != nil {
 		return "", fmt.Errorf("failed to list agents: %w", agentErr)
 	}
+	progressStep(ctx, 0, "checking last-seen for %d devices and %d agents", len(devices), len(agents))
 
 	now := time.Now().UTC()
 	entries := make([]healthEntry, 0, len(devices)+len(agents))
@@ -225,13 +227,13 @@ func handleOverviewHealth(args map[string]interface{}) (string, error) {
 }
 
 // handleOverviewForClient: client + devices + agents + open alerts in one shot.
-func handleOverviewForClient(args map[string]interface{}) (string, error) {
+func handleOverviewForClient(ctx context.Context, args map[string]interface{}) (string, error) {
 	clientID, err := requireString(args, "client_id")
 	if err != nil {
 		return "", err
 	}
 
-	clientData, err := makeAPIRequest("GET", fmt.Sprintf("/v1/client/%s", clientID), nil)
+	clientData, err := makeAPIRequest(ctx, "GET", fmt.Sprintf("/v1/client/%s", clientID), nil)
 	if err != nil {
 		return "", err
 	}
@@ -240,7 +242,7 @@ func handleOverviewForClient(args map[string]interface{}) (string, error) {
 		return "", fmt.Errorf("parse client: %w", err)
 	}
 
-	devicesData, err := makeAPIRequest("GET", fmt.Sprintf("/v1/device?client_id=%s&limit=50", clientID), nil)
+	devicesData, err := makeAPIRequest(ctx, "GET", fmt.Sprintf("/v1/device?client_id=%s&limit=50", clientID), nil)
 	if err != nil {
 		return "", err
 	}
@@ -262,7 +264,7 @@ func handleOverviewForClient(args map[string]interface{}) (string, error) {
 		})
 	}
 
-	alertsData, _ := makeAPIRequest("GET", fmt.Sprintf("/v1/alert?resolved=false&limit=50"), nil)
+	alertsData, _ := makeAPIRequest(ctx, "GET", fmt.Sprintf("/v1/alert?resolved=false&limit=50"), nil)
 	var alerts PaginatedResponse[Alert]
 	_ = json.Unmarshal(alertsData, &alerts)
 	openAlerts := make([]map[string]interface{}, 0)
@@ -301,13 +303,13 @@ func handleOverviewForClient(args map[string]interface{}) (string, error) {
 }
 
 // handleOverviewForDevice: device + agents + last 24h backups (count) + open alerts.
-func handleOverviewForDevice(args map[string]interface{}) (string, error) {
+func handleOverviewForDevice(ctx context.Context, args map[string]interface{}) (string, error) {
 	deviceID, err := requireString(args, "device_id")
 	if err != nil {
 		return "", err
 	}
 
-	deviceData, err := makeAPIRequest("GET", fmt.Sprintf("/v1/device/%s", deviceID), nil)
+	deviceData, err := makeAPIRequest(ctx, "GET", fmt.Sprintf("/v1/device/%s", deviceID), nil)
 	if err != nil {
 		return "", err
 	}
@@ -316,7 +318,7 @@ func handleOverviewForDevice(args map[string]interface{}) (string, error) {
 		return "", fmt.Errorf("parse device: %w", err)
 	}
 
-	agentsData, err := makeAPIRequest("GET", fmt.Sprintf("/v1/agent?device_id=%s&limit=50", deviceID), nil)
+	agentsData, err := makeAPIRequest(ctx, "GET", fmt.Sprintf("/v1/agent?device_id=%s&limit=50", deviceID), nil)
 	if err != nil {
 		return "", err
 	}
@@ -338,7 +340,7 @@ func handleOverviewForDevice(args map[string]interface{}) (string, error) {
 		})
 	}
 
-	alertsData, _ := makeAPIRequest("GET", fmt.Sprintf("/v1/alert?device_id=%s&resolved=false&limit=50", deviceID), nil)
+	alertsData, _ := makeAPIRequest(ctx, "GET", fmt.Sprintf("/v1/alert?device_id=%s&resolved=false&limit=50", deviceID), nil)
 	var alerts PaginatedResponse[Alert]
 	_ = json.Unmarshal(alertsData, &alerts)
 	openAlerts := make([]map[string]interface{}, 0, len(alerts.Data))
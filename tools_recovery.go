@@ -1,27 +1,34 @@
 package main
 
+import "context"
+
 // slide_recovery: the "I need to actually recover something" toolkit.
 // Consolidates virtual machines (boot snapshots), image exports
 // (download disk images), and DR networks (so booted VMs can talk to
 // the world). Replaces the v3 trio of slide_vms + slide_restores image
 // surface + slide_networks under one task-oriented umbrella.
 
-func handleRecoveryTool(args map[string]interface{}) (string, error) {
-	return HandleToolWithOperations(CreateToolConfig("slide_recovery", ToolOperations{
+func handleRecoveryTool(ctx context.Context, args map[string]interface{}) (string, error) {
+	return HandleToolWithOperations(ctx, CreateToolConfigWithResolutions("slide_recovery", ToolOperations{
 		// Virtual machines
 		"list_vms":         listVirtualMachines,
 		"get_vm":           getVirtualMachine,
-		"boot_vm":          createVirtualMachine,
+		"boot_vm":          withWait(createVirtualMachine, "vm"),
 		"update_vm":        updateVirtualMachine,
 		"delete_vm":        deleteVirtualMachine,
 		"get_rdp_bookmark": generateRDPBookmark,
+		"dr_drill":         handleRecoveryDRDrill,
 
 		// Image exports
 		"list_images":  listImageExports,
 		"get_image":    getImageExport,
-		"export_image": createImageExport,
+		"export_image": withWait(createImageExport, "image_export"),
 		"delete_image": deleteImageExport,
 		"browse_image": browseImageExport,
+		"tree_image":   handleRecoveryTreeImage,
+
+		// Local downloads (off unless --download-dir is set)
+		"download_image": handleRecoveryDownloadImage,
 
 		// DR networks
 		"list_networks":  listNetworks,
@@ -40,12 +47,14 @@ func handleRecoveryTool(args map[string]interface{}) (string, error) {
 		"create_wg_peer":      createNetworkWGPeer,
 		"update_wg_peer":      updateNetworkWGPeer,
 		"delete_wg_peer":      deleteNetworkWGPeer,
+	}, map[string]ResolutionSpec{
+		"dr_drill": {IDKey: "agent_id", Kind: "agent"},
 	}), args)
 }
 
 var recoveryOperationEnums = []string{
-	"list_vms", "get_vm", "boot_vm", "update_vm", "delete_vm", "get_rdp_bookmark",
-	"list_images", "get_image", "export_image", "delete_image", "browse_image",
+	"list_vms", "get_vm", "boot_vm", "update_vm", "delete_vm", "get_rdp_bookmark", "dr_drill",
+	"list_images", "get_image", "export_image", "delete_image", "browse_image", "tree_image", "download_image",
 	"list_networks", "get_network", "create_network", "update_network", "delete_network",
 	"create_ipsec", "update_ipsec", "delete_ipsec",
 	"create_port_forward", "update_port_forward", "delete_port_forward",
@@ -62,20 +71,31 @@ func getRecoveryToolInfo() ToolInfo {
 
 		// VM identification
 		"virt_id":        map[string]interface{}{"type": "string", "description": "VM ID. Required for `get_vm`, `update_vm`, `delete_vm`, `get_rdp_bookmark`."},
-		"snapshot_id":    map[string]interface{}{"type": "string", "description": "Snapshot to boot/export. Required for `boot_vm` and `export_image`."},
+		"snapshot_id":    map[string]interface{}{"type": "string", "description": "Snapshot to boot/export. Required for `boot_vm` and `export_image`. Optional for a single-agent `dr_drill` (default: the agent's latest restorable snapshot)."},
 		"device_id":      map[string]interface{}{"type": "string", "description": "Device that will host the VM/image. Required for `boot_vm` and `export_image`."},
 		"cpu_count":      map[string]interface{}{"type": "number", "description": "VM vCPUs.", "enum": []int{1, 2, 4, 8, 16}},
 		"memory_in_mb":   map[string]interface{}{"type": "number", "description": "VM memory in MB."},
 		"disk_bus":       map[string]interface{}{"type": "string", "description": "Disk bus.", "enum": []string{"sata", "virtio"}},
 		"network_model":  map[string]interface{}{"type": "string", "description": "Network model.", "enum": []string{"hypervisor_default", "e1000", "rtl8139", "virtio"}},
-		"network_type":   map[string]interface{}{"type": "string", "description": "Network attachment.", "enum": []string{"network", "network-isolated", "bridge", "network-id"}},
+		"network_type":   map[string]interface{}{"type": "string", "description": "Network attachment. `dr_drill` defaults to `network-isolated`.", "enum": []string{"network", "network-isolated", "bridge", "network-id"}},
 		"network_source": map[string]interface{}{"type": "string", "description": "Network ID when `network_type` is `network-id`."},
 		"boot_mods":      map[string]interface{}{"type": "array", "description": "Boot modifications.", "items": map[string]interface{}{"type": "string"}},
 		"state":          map[string]interface{}{"type": "string", "description": "VM lifecycle state for `update_vm`.", "enum": []string{"running", "stopped", "paused"}},
 		"expires_at":     map[string]interface{}{"type": "string", "description": "RFC3339 expiry timestamp for `update_vm`."},
 
+		// DR drill
+		"agent_id":             map[string]interface{}{"type": "string", "description": "Agent to drill with `dr_drill` (alternative: `name_hint`, or `client_id` for every agent of a client)."},
+		"name_hint":            map[string]interface{}{"type": "string", "description": "Alternative to agent_id for `dr_drill`: an agent hostname or display name."},
+		"boot_timeout_seconds": map[string]interface{}{"type": "integer", "description": "For `dr_drill`: how long each VM may take to reach running before the boot check fails (default 600, max 1800)."},
+		"parallel":             map[string]interface{}{"type": "integer", "description": "For `dr_drill`: how many agents to drill at once (default 2, max 8)."},
+		"report":               map[string]interface{}{"type": "string", "enum": reportFormats, "description": "For `dr_drill`: also render the drill report as a self-contained Markdown and/or HTML document for the compliance file, attached to the result and saved under --report-dir when set."},
+
 		// Image export
-		"image_export_id": map[string]interface{}{"type": "string", "description": "Image export ID. Required for `get_image`, `delete_image`, `browse_image`."},
+		"image_export_id": map[string]interface{}{"type": "string", "description": "Image export ID. Required for `get_image`, `delete_image`, `browse_image`, `tree_image`, `download_image`."},
+		"disk_id":         map[string]interface{}{"type": "string", "description": "Disk to fetch with `download_image` (disk_id or file name from `browse_image`). Optional when the export has one disk."},
+		"include":         map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}, "description": "For `tree_image`: only list disks whose file name matches one of these globs (case-insensitive)."},
+		"exclude":         map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}, "description": "For `tree_image`: skip disks whose file name matches one of these globs."},
+		"max_entries":     map[string]interface{}{"type": "integer", "description": "For `tree_image`: list at most this many disks (default 500, max 5000)."},
 		"image_type":      map[string]interface{}{"type": "string", "description": "Disk image format. Required for `export_image`.", "enum": []string{"vhd", "vhdx", "vmdk", "qcow2", "raw"}},
 
 		// Network identification
@@ -85,7 +105,7 @@ func getRecoveryToolInfo() ToolInfo {
 		"bridge_device_id": map[string]interface{}{"type": "string", "description": "Device whose LAN to bridge into. Used with `create_network` when type=bridge-lan."},
 		"router_prefix":    map[string]interface{}{"type": "string", "description": "Router IP in CIDR notation (e.g. `192.168.1.1/24`)."},
 		"comments":         map[string]interface{}{"type": "string", "description": "Free-form comments."},
-		"client_id":        map[string]interface{}{"type": "string", "description": "Client ID to assign or filter by."},
+		"client_id":        map[string]interface{}{"type": "string", "description": "Client ID to assign or filter by. For `dr_drill`, drills every agent of the client."},
 		"dhcp":             map[string]interface{}{"type": "boolean", "description": "Enable DHCP on the DR network."},
 		"dhcp_range_start": map[string]interface{}{"type": "string", "description": "DHCP range start IP."},
 		"dhcp_range_end":   map[string]interface{}{"type": "string", "description": "DHCP range end IP."},
@@ -103,6 +123,9 @@ func getRecoveryToolInfo() ToolInfo {
 		// WireGuard
 		"wg_peer_id": map[string]interface{}{"type": "string", "description": "WireGuard peer ID. Required for update/delete."},
 	}
+	for k, v := range waitProperties("`boot_vm` and `export_image`") {
+		props[k] = v
+	}
 	for k, v := range commonListProperties() {
 		if _, exists := props[k]; !exists {
 			props[k] = v
@@ -116,8 +139,10 @@ func getRecoveryToolInfo() ToolInfo {
 			"'boot a VM from a snapshot', 'recovery VM', 'spin up a recovered server', 'image export' (VHD/VHDX/VMDK/QCOW2/RAW), " +
 			"RDP into a recovered server, DR network, VPN/WireGuard/IPSec to a recovered VM, or 'I need to fail over to a Slide snapshot'. " +
 			"Three families: " +
-			"VMs (`list_vms`, `get_vm`, `boot_vm` <- creates a running VM from a snapshot, `update_vm` to start/stop/pause, `delete_vm`, `get_rdp_bookmark`), " +
-			"image exports (`list_images`, `get_image`, `export_image` <- VHD/VHDX/VMDK/QCOW2/RAW for external virtualization, `delete_image`, `browse_image`), " +
+			"VMs (`list_vms`, `get_vm`, `boot_vm` <- creates a running VM from a snapshot (`wait=true` returns once it is running), `update_vm` to start/stop/pause, `delete_vm`, `get_rdp_bookmark`, " +
+			"`dr_drill` <- a whole DR test for one agent or every agent of a client: boots the latest snapshot on an isolated network, waits for running, checks remote access and verification, records the observed RTO, deletes the VM, and returns a report for the compliance file; `report=markdown|html|both` adds it as a document), " +
+			"image exports (`list_images`, `get_image`, `export_image` <- VHD/VHDX/VMDK/QCOW2/RAW for external virtualization (`wait=true` returns once its disks are listed; `background=true` tracks a long export as a `slide_jobs` job), `delete_image`, `browse_image`, `tree_image` <- the export's disks as a compact listing with sizes, " +
+			"`download_image` <- saves a disk to the local --download-dir with resume and SHA-256; stdio only, off unless configured), " +
 			"and DR networks for booted VMs (`list_networks`/`get_network`/`create_network`/`update_network`/`delete_network` plus `create_ipsec`/`create_port_forward`/`create_wg_peer` and matching update/delete). " +
 			"Use this when the user wants to actually recover something - boot a server, get a disk image, set up VPN access to recovered VMs.",
 		InputSchema: map[string]interface{}{
@@ -130,11 +155,14 @@ func getRecoveryToolInfo() ToolInfo {
 				{"if": ifOp("update_vm"), "then": req("virt_id")},
 				{"if": ifOp("delete_vm"), "then": req("virt_id")},
 				{"if": ifOp("get_rdp_bookmark"), "then": req("virt_id")},
+				{"if": ifOp("dr_drill"), "then": reqEither("agent_id", "name_hint", "client_id")},
 
 				{"if": ifOp("get_image"), "then": req("image_export_id")},
 				{"if": ifOp("export_image"), "then": req("snapshot_id", "device_id", "image_type")},
 				{"if": ifOp("delete_image"), "then": req("image_export_id")},
 				{"if": ifOp("browse_image"), "then": req("image_export_id")},
+				{"if": ifOp("tree_image"), "then": req("image_export_id")},
+				{"if": ifOp("download_image"), "then": req("image_export_id")},
 
 				{"if": ifOp("get_network"), "then": req("network_id")},
 				{"if": ifOp("create_network"), "then": req("name", "type")},
@@ -1,33 +1,42 @@
 package main
 
 // slide_snapshots: list / list_deleted / get / get_service_verification +
-// v4 `recent_for_agent` convenience.
+// v4 `recent_for_agent` convenience, the `calendar` and
+// `verification_report` reports (snapshot_reports.go), and the
+// `change_anomalies` ransomware sweep (snapshot_anomalies.go).
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 )
 
-func handleSnapshotsTool(args map[string]interface{}) (string, error) {
-	return HandleToolWithOperations(CreateToolConfigWithResolutions("slide_snapshots", ToolOperations{
+func handleSnapshotsTool(ctx context.Context, args map[string]interface{}) (string, error) {
+	return HandleToolWithOperations(ctx, CreateToolConfigWithResolutions("slide_snapshots", ToolOperations{
 		"list":                     listSnapshots,
 		"list_deleted":             listDeletedSnapshots,
 		"get":                      getSnapshot,
 		"get_service_verification": handleSnapshotGetServiceVerification,
 		"recent_for_agent":         handleSnapshotsRecentForAgent,
+		"calendar":                 handleSnapshotsCalendar,
+		"verification_report":      handleSnapshotsVerificationReport,
+		"change_anomalies":         handleSnapshotsChangeAnomalies,
 	}, map[string]ResolutionSpec{
-		"recent_for_agent": {IDKey: "agent_id", Kind: "agent"},
+		"recent_for_agent":    {IDKey: "agent_id", Kind: "agent"},
+		"calendar":            {IDKey: "agent_id", Kind: "agent"},
+		"verification_report": {IDKey: "agent_id", Kind: "agent"},
+		"change_anomalies":    {IDKey: "agent_id", Kind: "agent"},
 	}), args)
 }
 
-func listDeletedSnapshots(args map[string]interface{}) (string, error) {
+func listDeletedSnapshots(ctx context.Context, args map[string]interface{}) (string, error) {
 	if _, exists := args["snapshot_location"]; !exists {
 		args["snapshot_location"] = "exists_deleted"
 	}
-	return listSnapshots(args)
+	return listSnapshots(ctx, args)
 }
 
-var snapshotsOperationEnums = []string{"list", "list_deleted", "get", "get_service_verification", "recent_for_agent"}
+var snapshotsOperationEnums = []string{"list", "list_deleted", "get", "get_service_verification", "recent_for_agent", "calendar", "verification_report", "change_anomalies"}
 
 func getSnapshotsToolInfo() ToolInfo {
 	props := map[string]interface{}{
@@ -38,11 +47,19 @@ func getSnapshotsToolInfo() ToolInfo {
 		},
 		"agent_id": map[string]interface{}{
 			"type":        "string",
-			"description": "Filter by agent. Required for `recent_for_agent` (alternative: pass `name_hint`).",
+			"description": "Filter by agent. Required for `recent_for_agent`, `calendar`, and `change_anomalies` (alternative: pass `name_hint`). Optional scope for `verification_report`.",
 		},
 		"name_hint": map[string]interface{}{
 			"type":        "string",
-			"description": "Alternative to agent_id for `recent_for_agent`: an agent hostname or display name.",
+			"description": "Alternative to agent_id for `recent_for_agent`, `calendar`, `verification_report`, and `change_anomalies`: an agent hostname or display name.",
+		},
+		"device_id": map[string]interface{}{
+			"type":        "string",
+			"description": "Scope `verification_report` to the agents on one device.",
+		},
+		"client_id": map[string]interface{}{
+			"type":        "string",
+			"description": "Scope `verification_report` to one client's agents.",
 		},
 		"snapshot_id": map[string]interface{}{
 			"type":        "string",
@@ -55,10 +72,50 @@ func getSnapshotsToolInfo() ToolInfo {
 		},
 		"days": map[string]interface{}{
 			"type":        "number",
-			"description": "Window in days for `recent_for_agent`. Default 14.",
+			"description": "Window in days for `recent_for_agent` (default 14), `verification_report` (default 30), and `change_anomalies` (default 7).",
 			"minimum":     1,
 			"maximum":     90,
 		},
+		"month": map[string]interface{}{
+			"type":        "string",
+			"description": "Month for `calendar` as YYYY-MM, in the agent's timezone. Default: the current month.",
+			"pattern":     "^[0-9]{4}-[0-9]{2}$",
+		},
+		"report": map[string]interface{}{
+			"type":        "string",
+			"enum":        reportFormats,
+			"description": "For `verification_report`: also render the report as a self-contained Markdown and/or HTML document for the client file, attached to the result and saved under --report-dir when set.",
+		},
+		"problems_only": map[string]interface{}{
+			"type":        "boolean",
+			"description": "For `verification_report`: return only agents that are failing, never passed, unverified, or could not be checked. Totals still cover every agent.",
+		},
+		"samples": map[string]interface{}{
+			"type":        "integer",
+			"description": "For `change_anomalies`: how many of the window's snapshots to walk, spread evenly and including the newest and oldest (default 5, min 2, max 10).",
+		},
+		"max_api_calls": map[string]interface{}{
+			"type":        "integer",
+			"description": "For `change_anomalies`: API call budget for the whole sweep (default 300, max 2000), listing the window's snapshots first, the rest split evenly between the sampled snapshots' folder walks.",
+		},
+		"browse_path": map[string]interface{}{
+			"type":        "string",
+			"description": "For `change_anomalies`: the folder to walk in each snapshot, e.g. `/D:/Shares` (default `/`). A data folder finds churn with far fewer calls than a whole drive.",
+		},
+		"max_depth": map[string]interface{}{
+			"type":        "integer",
+			"description": "For `change_anomalies`: folder levels to walk below browse_path (default 4, max 10).",
+		},
+		"include": map[string]interface{}{
+			"type":        "array",
+			"items":       map[string]interface{}{"type": "string"},
+			"description": "For `change_anomalies`: only compare files matching one of these globs (case-insensitive).",
+		},
+		"exclude": map[string]interface{}{
+			"type":        "array",
+			"items":       map[string]interface{}{"type": "string"},
+			"description": "For `change_anomalies`: skip entries matching one of these globs, e.g. `[\"AppData\", \"Windows\"]`. Excluded folders are not walked.",
+		},
 		"sort_by": map[string]interface{}{
 			"type":        "string",
 			"description": "Sort field for `list`/`list_deleted`/`recent_for_agent`.",
@@ -78,7 +135,10 @@ func getSnapshotsToolInfo() ToolInfo {
 			"'what backups do I have for X', 'last successful backup', 'show me snapshots from yesterday', " +
 			"'when was the last verified boot', or wants to inspect (not restore) historical recovery points. " +
 			"Operations: `list`, `list_deleted`, `get`, `get_service_verification` (Slide API v1.27.0 per-service results), " +
-			"`recent_for_agent` (last N days for a single agent, default 14 - the answer to \"what restore points do I have for X?\"; accepts agent_id OR name_hint). " +
+			"`recent_for_agent` (last N days for a single agent, default 14 - the answer to \"what restore points do I have for X?\"; accepts agent_id OR name_hint), " +
+			"`calendar` (day-by-day snapshot grid for one agent and month: counts split local vs cloud and by verification outcome, with gap days on the backup schedule called out), " +
+			"`verification_report` (per-agent boot/filesystem/service verification health for an agent, device, client, or the whole account: latest verified snapshot, hours since the last passing boot check, failing services, and agents that never passed; `report=markdown|html|both` adds a document to hand to the client), " +
+			"`change_anomalies` (ransomware sweep: walks a few of an agent's recent snapshots within an API call budget, flags abnormal churn, ransom notes, and mass renames to unfamiliar extensions, and names the last clean snapshot to restore from). " +
 			"Get/list responses include verify_service_status.",
 		InputSchema: map[string]interface{}{
 			"type":       "object",
@@ -88,12 +148,14 @@ func getSnapshotsToolInfo() ToolInfo {
 				{"if": ifOp("get"), "then": req("snapshot_id")},
 				{"if": ifOp("get_service_verification"), "then": req("snapshot_id")},
 				{"if": ifOp("recent_for_agent"), "then": reqEither("agent_id", "name_hint")},
+				{"if": ifOp("calendar"), "then": reqEither("agent_id", "name_hint")},
+				{"if": ifOp("change_anomalies"), "then": reqEither("agent_id", "name_hint")},
 			},
 		},
 	}
 }
 
-func handleSnapshotsRecentForAgent(args map[string]interface{}) (string, error) {
+func handleSnapshotsRecentForAgent(ctx context.Context, args map[string]interface{}) (string, error) {
 	agentID, err := requireString(args, "agent_id")
 	if err != nil {
 		return "", err
@@ -107,7 +169,7 @@ func handleSnapshotsRecentForAgent(args map[string]interface{}) (string, error)
 		limit = 50
 	}
 	endpoint := fmt.Sprintf("/v1/snapshot?agent_id=%s&limit=%d&sort_by=backup_start_time&sort_asc=false", agentID, limit)
-	data, err := makeAPIRequest("GET", endpoint, nil)
+	data, err := makeAPIRequest(ctx, "GET", endpoint, nil)
 	if err != nil {
 		return "", err
 	}
@@ -5,8 +5,10 @@ package main
 // tools_snapshots.go, and tools_user_management.go.
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"strings"
 )
 
 // helpers ---------------------------------------------------------------------
@@ -50,6 +52,35 @@ func optionalInt(args map[string]interface{}, key string) (int, bool) {
 	return 0, false
 }
 
+// optionalStrings reads a string array, also accepting one
+// comma-separated string.
+func optionalStrings(args map[string]interface{}, key string) ([]string, error) {
+	var out []string
+	switch v := args[key].(type) {
+	case nil:
+		return nil, nil
+	case string:
+		for _, s := range strings.Split(v, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				out = append(out, s)
+			}
+		}
+	case []interface{}:
+		for _, item := range v {
+			s, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("%s must be an array of strings", key)
+			}
+			out = append(out, s)
+		}
+	case []string:
+		out = v
+	default:
+		return nil, fmt.Errorf("%s must be an array of strings", key)
+	}
+	return out, nil
+}
+
 func toJSONString(v interface{}) (string, error) {
 	out, err := json.MarshalIndent(v, "", "  ")
 	if err != nil {
@@ -60,12 +91,12 @@ func toJSONString(v interface{}) (string, error) {
 
 // agent: services -------------------------------------------------------------
 
-func handleAgentListServices(args map[string]interface{}) (string, error) {
+func handleAgentListServices(ctx context.Context, args map[string]interface{}) (string, error) {
 	agentID, err := requireString(args, "agent_id")
 	if err != nil {
 		return "", err
 	}
-	services, err := listAgentServices(agentID)
+	services, err := listAgentServices(ctx, agentID)
 	if err != nil {
 		return "", err
 	}
@@ -75,7 +106,7 @@ func handleAgentListServices(args map[string]interface{}) (string, error) {
 	})
 }
 
-func handleAgentUpdateServices(args map[string]interface{}) (string, error) {
+func handleAgentUpdateServices(ctx context.Context, args map[string]interface{}) (string, error) {
 	agentID, err := requireString(args, "agent_id")
 	if err != nil {
 		return "", err
@@ -100,7 +131,7 @@ func handleAgentUpdateServices(args map[string]interface{}) (string, error) {
 		}
 		items = append(items, AgentServiceUpdateItem{ServiceID: sid, VerifyOnBoot: vob})
 	}
-	updated, err := updateAgentServices(agentID, items)
+	updated, err := updateAgentServices(ctx, agentID, items)
 	if err != nil {
 		return "", err
 	}
@@ -112,7 +143,7 @@ func handleAgentUpdateServices(args map[string]interface{}) (string, error) {
 
 // agent: backup schedule ------------------------------------------------------
 
-func handleAgentSetSchedule(args map[string]interface{}) (string, error) {
+func handleAgentSetSchedule(ctx context.Context, args map[string]interface{}) (string, error) {
 	agentID, err := requireString(args, "agent_id")
 	if err != nil {
 		return "", err
@@ -141,10 +172,10 @@ func handleAgentSetSchedule(args map[string]interface{}) (string, error) {
 	payload := map[string]interface{}{
 		"backup_schedule": sched,
 	}
-	return patchAgentJSON(agentID, payload)
+	return patchAgentJSON(ctx, agentID, payload)
 }
 
-func handleAgentClearSchedule(args map[string]interface{}) (string, error) {
+func handleAgentClearSchedule(ctx context.Context, args map[string]interface{}) (string, error) {
 	agentID, err := requireString(args, "agent_id")
 	if err != nil {
 		return "", err
@@ -153,12 +184,12 @@ func handleAgentClearSchedule(args map[string]interface{}) (string, error) {
 	payload := map[string]interface{}{
 		"backup_schedule": nil,
 	}
-	return patchAgentJSON(agentID, payload)
+	return patchAgentJSON(ctx, agentID, payload)
 }
 
 // agent: pause / resume backups ----------------------------------------------
 
-func handleAgentPauseBackups(args map[string]interface{}) (string, error) {
+func handleAgentPauseBackups(ctx context.Context, args map[string]interface{}) (string, error) {
 	agentID, err := requireString(args, "agent_id")
 	if err != nil {
 		return "", err
@@ -171,10 +202,10 @@ func handleAgentPauseBackups(args map[string]interface{}) (string, error) {
 	} else {
 		return "", fmt.Errorf("provide either indefinite=true or paused_until=<RFC3339 timestamp>")
 	}
-	return patchAgentJSON(agentID, payload)
+	return patchAgentJSON(ctx, agentID, payload)
 }
 
-func handleAgentResumeBackups(args map[string]interface{}) (string, error) {
+func handleAgentResumeBackups(ctx context.Context, args map[string]interface{}) (string, error) {
 	agentID, err := requireString(args, "agent_id")
 	if err != nil {
 		return "", err
@@ -182,12 +213,12 @@ func handleAgentResumeBackups(args map[string]interface{}) (string, error) {
 	payload := map[string]interface{}{
 		"backup_resume": true,
 	}
-	return patchAgentJSON(agentID, payload)
+	return patchAgentJSON(ctx, agentID, payload)
 }
 
 // agent: retention / restore defaults / volumes / misc ------------------------
 
-func handleAgentSetRetention(args map[string]interface{}) (string, error) {
+func handleAgentSetRetention(ctx context.Context, args map[string]interface{}) (string, error) {
 	agentID, err := requireString(args, "agent_id")
 	if err != nil {
 		return "", err
@@ -214,10 +245,10 @@ func handleAgentSetRetention(args map[string]interface{}) (string, error) {
 			RetentionPolicyMaxAgeMonths: maxAge,
 		},
 	}
-	return patchAgentJSON(agentID, payload)
+	return patchAgentJSON(ctx, agentID, payload)
 }
 
-func handleAgentSetRestoreDefaults(args map[string]interface{}) (string, error) {
+func handleAgentSetRestoreDefaults(ctx context.Context, args map[string]interface{}) (string, error) {
 	agentID, err := requireString(args, "agent_id")
 	if err != nil {
 		return "", err
@@ -246,10 +277,10 @@ func handleAgentSetRestoreDefaults(args map[string]interface{}) (string, error)
 	payload := map[string]interface{}{
 		"default_restore_settings": settings,
 	}
-	return patchAgentJSON(agentID, payload)
+	return patchAgentJSON(ctx, agentID, payload)
 }
 
-func handleAgentSetVolumes(args map[string]interface{}) (string, error) {
+func handleAgentSetVolumes(ctx context.Context, args map[string]interface{}) (string, error) {
 	agentID, err := requireString(args, "agent_id")
 	if err != nil {
 		return "", err
@@ -288,10 +319,10 @@ func handleAgentSetVolumes(args map[string]interface{}) (string, error) {
 	if len(payload) == 0 {
 		return "", fmt.Errorf("provide at least one of: volumes, volumes_include_default")
 	}
-	return patchAgentJSON(agentID, payload)
+	return patchAgentJSON(ctx, agentID, payload)
 }
 
-func handleAgentSetFileIndex(args map[string]interface{}) (string, error) {
+func handleAgentSetFileIndex(ctx context.Context, args map[string]interface{}) (string, error) {
 	agentID, err := requireString(args, "agent_id")
 	if err != nil {
 		return "", err
@@ -300,12 +331,12 @@ func handleAgentSetFileIndex(args map[string]interface{}) (string, error) {
 	if !ok {
 		return "", fmt.Errorf("file_index_enabled is required (boolean)")
 	}
-	return patchAgentJSON(agentID, map[string]interface{}{
+	return patchAgentJSON(ctx, agentID, map[string]interface{}{
 		"file_index_enabled": enabled,
 	})
 }
 
-func handleAgentSetTimezone(args map[string]interface{}) (string, error) {
+func handleAgentSetTimezone(ctx context.Context, args map[string]interface{}) (string, error) {
 	agentID, err := requireString(args, "agent_id")
 	if err != nil {
 		return "", err
@@ -314,12 +345,12 @@ func handleAgentSetTimezone(args map[string]interface{}) (string, error) {
 	if err != nil {
 		return "", err
 	}
-	return patchAgentJSON(agentID, map[string]interface{}{
+	return patchAgentJSON(ctx, agentID, map[string]interface{}{
 		"timezone": tz,
 	})
 }
 
-func handleAgentSetComments(args map[string]interface{}) (string, error) {
+func handleAgentSetComments(ctx context.Context, args map[string]interface{}) (string, error) {
 	agentID, err := requireString(args, "agent_id")
 	if err != nil {
 		return "", err
@@ -328,12 +359,12 @@ func handleAgentSetComments(args map[string]interface{}) (string, error) {
 	if !ok {
 		return "", fmt.Errorf("comments is required")
 	}
-	return patchAgentJSON(agentID, map[string]interface{}{
+	return patchAgentJSON(ctx, agentID, map[string]interface{}{
 		"comments": comments,
 	})
 }
 
-func handleAgentUpdateAlertConfig(args map[string]interface{}) (string, error) {
+func handleAgentUpdateAlertConfig(ctx context.Context, args map[string]interface{}) (string, error) {
 	agentID, err := requireString(args, "agent_id")
 	if err != nil {
 		return "", err
@@ -365,26 +396,26 @@ func handleAgentUpdateAlertConfig(args map[string]interface{}) (string, error) {
 	if !used {
 		return "", fmt.Errorf("provide one of: indefinite, resume, pause_for_minutes")
 	}
-	return patchAgentJSON(agentID, map[string]interface{}{
+	return patchAgentJSON(ctx, agentID, map[string]interface{}{
 		"alert_configs": []AlertConfig{cfg},
 	})
 }
 
 // device: network -------------------------------------------------------------
 
-func handleDeviceGetNetwork(args map[string]interface{}) (string, error) {
+func handleDeviceGetNetwork(ctx context.Context, args map[string]interface{}) (string, error) {
 	deviceID, err := requireString(args, "device_id")
 	if err != nil {
 		return "", err
 	}
-	dn, err := getDeviceNetwork(deviceID)
+	dn, err := getDeviceNetwork(ctx, deviceID)
 	if err != nil {
 		return "", err
 	}
 	return toJSONString(dn)
 }
 
-func handleDeviceUpdateNetwork(args map[string]interface{}) (string, error) {
+func handleDeviceUpdateNetwork(ctx context.Context, args map[string]interface{}) (string, error) {
 	deviceID, err := requireString(args, "device_id")
 	if err != nil {
 		return "", err
@@ -408,7 +439,7 @@ func handleDeviceUpdateNetwork(args map[string]interface{}) (string, error) {
 	if len(payload) == 0 {
 		return "", fmt.Errorf("provide at least one of: network_mode, network_address, network_gateway, dns_server_primary, dns_server_secondary")
 	}
-	dn, err := updateDeviceNetwork(deviceID, payload)
+	dn, err := updateDeviceNetwork(ctx, deviceID, payload)
 	if err != nil {
 		return "", err
 	}
@@ -417,12 +448,12 @@ func handleDeviceUpdateNetwork(args map[string]interface{}) (string, error) {
 
 // device: VLAN ----------------------------------------------------------------
 
-func handleDeviceListVLANs(args map[string]interface{}) (string, error) {
+func handleDeviceListVLANs(ctx context.Context, args map[string]interface{}) (string, error) {
 	deviceID, err := requireString(args, "device_id")
 	if err != nil {
 		return "", err
 	}
-	vlans, err := listDeviceVLANs(deviceID)
+	vlans, err := listDeviceVLANs(ctx, deviceID)
 	if err != nil {
 		return "", err
 	}
@@ -432,7 +463,7 @@ func handleDeviceListVLANs(args map[string]interface{}) (string, error) {
 	})
 }
 
-func handleDeviceGetVLAN(args map[string]interface{}) (string, error) {
+func handleDeviceGetVLAN(ctx context.Context, args map[string]interface{}) (string, error) {
 	deviceID, err := requireString(args, "device_id")
 	if err != nil {
 		return "", err
@@ -441,14 +472,14 @@ func handleDeviceGetVLAN(args map[string]interface{}) (string, error) {
 	if err != nil {
 		return "", err
 	}
-	vlan, err := getDeviceVLAN(deviceID, vlanID)
+	vlan, err := getDeviceVLAN(ctx, deviceID, vlanID)
 	if err != nil {
 		return "", err
 	}
 	return toJSONString(vlan)
 }
 
-func handleDeviceCreateVLAN(args map[string]interface{}) (string, error) {
+func handleDeviceCreateVLAN(ctx context.Context, args map[string]interface{}) (string, error) {
 	deviceID, err := requireString(args, "device_id")
 	if err != nil {
 		return "", err
@@ -476,14 +507,14 @@ func handleDeviceCreateVLAN(args map[string]interface{}) (string, error) {
 	if v, ok := optionalString(args, "gateway"); ok {
 		payload["gateway"] = v
 	}
-	vlan, err := createDeviceVLAN(deviceID, payload)
+	vlan, err := createDeviceVLAN(ctx, deviceID, payload)
 	if err != nil {
 		return "", err
 	}
 	return toJSONString(vlan)
 }
 
-func handleDeviceUpdateVLAN(args map[string]interface{}) (string, error) {
+func handleDeviceUpdateVLAN(ctx context.Context, args map[string]interface{}) (string, error) {
 	deviceID, err := requireString(args, "device_id")
 	if err != nil {
 		return "", err
@@ -511,14 +542,14 @@ func handleDeviceUpdateVLAN(args map[string]interface{}) (string, error) {
 	if len(payload) == 0 {
 		return "", fmt.Errorf("provide at least one of: name, vlan_tag, network_mode, ip_address, gateway")
 	}
-	vlan, err := updateDeviceVLAN(deviceID, vlanID, payload)
+	vlan, err := updateDeviceVLAN(ctx, deviceID, vlanID, payload)
 	if err != nil {
 		return "", err
 	}
 	return toJSONString(vlan)
 }
 
-func handleDeviceDeleteVLAN(args map[string]interface{}) (string, error) {
+func handleDeviceDeleteVLAN(ctx context.Context, args map[string]interface{}) (string, error) {
 	deviceID, err := requireString(args, "device_id")
 	if err != nil {
 		return "", err
@@ -527,7 +558,7 @@ func handleDeviceDeleteVLAN(args map[string]interface{}) (string, error) {
 	if err != nil {
 		return "", err
 	}
-	if err := deleteDeviceVLAN(deviceID, vlanID); err != nil {
+	if err := deleteDeviceVLAN(ctx, deviceID, vlanID); err != nil {
 		return "", err
 	}
 	return toJSONString(map[string]interface{}{
@@ -539,12 +570,12 @@ func handleDeviceDeleteVLAN(args map[string]interface{}) (string, error) {
 
 // snapshot: service verification ---------------------------------------------
 
-func handleSnapshotGetServiceVerification(args map[string]interface{}) (string, error) {
+func handleSnapshotGetServiceVerification(ctx context.Context, args map[string]interface{}) (string, error) {
 	snapshotID, err := requireString(args, "snapshot_id")
 	if err != nil {
 		return "", err
 	}
-	res, err := getSnapshotServiceVerification(snapshotID)
+	res, err := getSnapshotServiceVerification(ctx, snapshotID)
 	if err != nil {
 		return "", err
 	}
@@ -553,12 +584,12 @@ func handleSnapshotGetServiceVerification(args map[string]interface{}) (string,
 
 // user_management: avatar -----------------------------------------------------
 
-func handleGetUserAvatar(args map[string]interface{}) (string, error) {
+func handleGetUserAvatar(ctx context.Context, args map[string]interface{}) (string, error) {
 	userID, err := requireString(args, "user_id")
 	if err != nil {
 		return "", err
 	}
-	av, err := getUserAvatar(userID)
+	av, err := getUserAvatar(ctx, userID)
 	if err != nil {
 		return "", err
 	}
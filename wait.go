@@ -0,0 +1,359 @@
+package main
+
+// wait=true for operations that start something and return at once.
+//
+// start, boot_vm, export_image, create_restore, and create_push answer
+// with an object that is still on its way: a backup that is running, a VM
+// that is provisioning. Left alone, the LLM polls get_* for it, a call
+// per look. With wait=true the server does the polling instead: it
+// re-reads the object with backoff until it reaches a terminal state,
+// reports each look as a progress notification, and returns the final
+// object with a _wait block saying how it ended. Running out of
+// wait_timeout_seconds is not an error; the object is returned as last
+// seen with timed_out=true so the caller can keep going with get_*.
+//
+// Image exports and file restores carry no state field, so unless the
+// API starts returning one they count as ready once their browse listing
+// answers.
+//
+// background=true hands the same polling to a tracked job instead (see
+// jobs.go) for work that outlasts any sensible call.
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	waitDefaultTimeout = 300 // seconds
+	waitMaxTimeout     = 1800
+)
+
+// Poll backoff: the first look comes soon after the create, later ones
+// spread out. Tests shorten both.
+var (
+	waitInitialInterval = 2 * time.Second
+	waitMaxInterval     = 15 * time.Second
+)
+
+// waitOutcome is where a polled object stands.
+type waitOutcome int
+
+const (
+	waitPending waitOutcome = iota
+	waitSucceeded
+	waitFailed
+)
+
+// waitPoll reads the object once and says where it stands.
+type waitPoll func(ctx context.Context) (obj interface{}, state string, outcome waitOutcome, err error)
+
+// waitResult is the _wait block of a waited-for response.
+type waitResult struct {
+	State         string  `json:"state"`
+	Terminal      bool    `json:"terminal"`
+	Succeeded     bool    `json:"succeeded"`
+	TimedOut      bool    `json:"timed_out"`
+	WaitedSeconds float64 `json:"waited_seconds"`
+	Polls         int     `json:"polls"`
+	Note          string  `json:"note,omitempty"`
+}
+
+// waitFor polls until the object is terminal or timeout has passed since
+// start, returning the last object seen. Only a failed read or a canceled
+// context is an error.
+func waitFor(ctx context.Context, label string, start time.Time, timeout time.Duration, poll waitPoll) (interface{}, waitResult, error) {
+	var res waitResult
+	interval := waitInitialInterval
+	for {
+		obj, state, outcome, err := poll(ctx)
+		if err != nil {
+			return nil, res, err
+		}
+		res.Polls++
+		res.State = state
+		res.WaitedSeconds = roundSeconds(time.Since(start))
+		progressStep(ctx, -1, "%s: %s after %.0fs", label, state, res.WaitedSeconds)
+		if outcome != waitPending {
+			res.Terminal, res.Succeeded = true, outcome == waitSucceeded
+			return obj, res, nil
+		}
+		left := timeout - time.Since(start)
+		if left <= 0 {
+			res.TimedOut = true
+			return obj, res, nil
+		}
+		select {
+		case <-ctx.Done():
+			return obj, res, ctx.Err()
+		case <-time.After(min(interval, left)):
+		}
+		interval = min(time.Duration(float64(interval)*1.5), waitMaxInterval)
+	}
+}
+
+// stateOutcome classifies a state or status value: one of done is
+// success, anything failed, errored, or canceled is failure, and the
+// rest is still going.
+func stateOutcome(state string, done ...string) waitOutcome {
+	s := strings.ToLower(state)
+	for _, d := range done {
+		if s == d {
+			return waitSucceeded
+		}
+	}
+	if strings.Contains(s, "fail") || strings.Contains(s, "error") || strings.Contains(s, "cancel") {
+		return waitFailed
+	}
+	return waitPending
+}
+
+// waitProperties are the wait, wait_timeout_seconds, and background
+// schema properties for a tool whose ops (backquoted, comma-separated)
+// support them.
+func waitProperties(ops string) map[string]interface{} {
+	return map[string]interface{}{
+		"wait": map[string]interface{}{
+			"type":        "boolean",
+			"description": "For " + ops + ": poll until the new object reaches a terminal state and return it with a `_wait` block, instead of returning at once. Progress is reported while waiting.",
+		},
+		"background": map[string]interface{}{
+			"type":        "boolean",
+			"description": "For " + ops + ": return at once with a `_job` block and keep following the new object on the server; check it later with `slide_jobs operation=get job_id=...`. Use this instead of `wait` for work that can take longer than a few minutes.",
+		},
+		"wait_timeout_seconds": map[string]interface{}{
+			"type":        "integer",
+			"description": fmt.Sprintf("With `wait=true`: stop waiting after this many seconds and return the object as last seen with `_wait.timed_out=true` (default %d, max %d).", waitDefaultTimeout, waitMaxTimeout),
+		},
+	}
+}
+
+// waitTimeout reads wait_timeout_seconds.
+func waitTimeout(args map[string]interface{}) time.Duration {
+	secs, ok := optionalInt(args, "wait_timeout_seconds")
+	if !ok || secs <= 0 {
+		secs = waitDefaultTimeout
+	}
+	return time.Duration(min(secs, waitMaxTimeout)) * time.Second
+}
+
+// waitTarget builds the poll for an object a create call returned.
+type waitTarget func(created map[string]interface{}) (label string, poll waitPoll, err error)
+
+// waitKind is something wait=true and background=true know how to follow:
+// the create-response fields that identify one, and its poll.
+type waitKind struct {
+	ids    []string
+	target waitTarget
+}
+
+// waitKinds are keyed by the kind a background job records, so a job
+// loaded from --jobs-file can rebuild its poll from the saved ids.
+var waitKinds = map[string]waitKind{
+	"backup":       {[]string{"backup_id"}, waitForBackup},
+	"vm":           {[]string{"virt_id"}, waitForVM},
+	"image_export": {[]string{"image_export_id"}, waitForImageExport},
+	"file_restore": {[]string{"file_restore_id"}, waitForFileRestore},
+	"push":         {[]string{"file_restore_id", "file_restore_push_id"}, waitForPush},
+}
+
+// withWait adds wait=true and background=true to a create handler that
+// returns an object of the given kind. Without either the handler's
+// response is returned untouched.
+func withWait(create func(context.Context, map[string]interface{}) (string, error), kind string) func(context.Context, map[string]interface{}) (string, error) {
+	return func(ctx context.Context, args map[string]interface{}) (string, error) {
+		wait, _ := optionalBool(args, "wait")
+		background, _ := optionalBool(args, "background")
+		if wait && background {
+			return "", fmt.Errorf("pass wait=true or background=true, not both")
+		}
+		start := time.Now()
+		out, err := create(ctx, args)
+		if err != nil || !wait && !background {
+			return out, err
+		}
+		var created map[string]interface{}
+		if err := json.Unmarshal([]byte(out), &created); err != nil {
+			return "", fmt.Errorf("parse created object: %w", err)
+		}
+		k := waitKinds[kind]
+		label, poll, err := k.target(created)
+		if err != nil {
+			return "", err
+		}
+		if background {
+			ids := make(map[string]string, len(k.ids))
+			for _, id := range k.ids {
+				ids[id], _ = created[id].(string)
+			}
+			tool, _ := args["_tool"].(string)
+			op, _ := args["operation"].(string)
+			j := backgroundJobs.start(ctx, &job{Kind: kind, Tool: tool, Operation: op, Label: label, Target: ids}, start, poll)
+			created["_job"] = j
+			return waitResponse(created, args)
+		}
+		obj, res, err := waitFor(ctx, label, start, waitTimeout(args), poll)
+		if err != nil {
+			return "", fmt.Errorf("%s was created, but waiting for it stopped: %w", label, err)
+		}
+		if res.TimedOut {
+			res.Note = fmt.Sprintf("Still %s after %.0fs; keep checking with the matching get operation, or re-run with a larger wait_timeout_seconds next time.", res.State, res.WaitedSeconds)
+		}
+		final, _ := obj.(map[string]interface{})
+		if final == nil {
+			final = map[string]interface{}{}
+		}
+		final["_wait"] = res
+		return waitResponse(final, args)
+	}
+}
+
+// waitResponse renders a waited-for or backgrounded object with the
+// next_steps hints the plain create response goes without.
+func waitResponse(obj map[string]interface{}, args map[string]interface{}) (string, error) {
+	out, err := toJSONString(obj)
+	if err != nil {
+		return "", err
+	}
+	return augmentJSONResponse(out, args), nil
+}
+
+// createdID is a string field of a create response.
+func createdID(created map[string]interface{}, key string) (string, error) {
+	id, _ := created[key].(string)
+	if id == "" {
+		return "", fmt.Errorf("cannot wait: the create response has no %s", key)
+	}
+	return id, nil
+}
+
+// getObject is a GET handler's response as a map, for polling.
+func getObject(ctx context.Context, get func(context.Context, map[string]interface{}) (string, error), args map[string]interface{}) (map[string]interface{}, error) {
+	out, err := get(ctx, args)
+	if err != nil {
+		return nil, err
+	}
+	var obj map[string]interface{}
+	if err := json.Unmarshal([]byte(out), &obj); err != nil {
+		return nil, fmt.Errorf("parse response: %w", err)
+	}
+	return obj, nil
+}
+
+// objectState is an object's "state" or, failing that, "status" field.
+func objectState(obj map[string]interface{}) string {
+	for _, k := range []string{"state", "status"} {
+		if s, ok := obj[k].(string); ok && s != "" {
+			return s
+		}
+	}
+	return ""
+}
+
+// waitForBackup follows a backup from slide_backups start until it
+// succeeds or fails.
+func waitForBackup(created map[string]interface{}) (string, waitPoll, error) {
+	id, err := createdID(created, "backup_id")
+	if err != nil {
+		return "", nil, err
+	}
+	return "backup " + id, func(ctx context.Context) (interface{}, string, waitOutcome, error) {
+		obj, err := getObject(ctx, getBackup, map[string]interface{}{"backup_id": id})
+		if err != nil {
+			return nil, "", waitPending, err
+		}
+		state := objectState(obj)
+		return obj, state, stateOutcome(state, "succeeded", "success", "completed"), nil
+	}, nil
+}
+
+// waitForVM follows a VM from boot_vm until it is running.
+func waitForVM(created map[string]interface{}) (string, waitPoll, error) {
+	id, err := createdID(created, "virt_id")
+	if err != nil {
+		return "", nil, err
+	}
+	return "VM " + id, func(ctx context.Context) (interface{}, string, waitOutcome, error) {
+		obj, err := getObject(ctx, getVirtualMachine, map[string]interface{}{"virt_id": id})
+		if err != nil {
+			return nil, "", waitPending, err
+		}
+		state := objectState(obj)
+		return obj, state, stateOutcome(state, "running"), nil
+	}, nil
+}
+
+// waitForPush follows a push from create_push until it completes.
+func waitForPush(created map[string]interface{}) (string, waitPoll, error) {
+	restoreID, err := createdID(created, "file_restore_id")
+	if err != nil {
+		return "", nil, err
+	}
+	pushID, err := createdID(created, "file_restore_push_id")
+	if err != nil {
+		return "", nil, err
+	}
+	return "push " + pushID, func(ctx context.Context) (interface{}, string, waitOutcome, error) {
+		data, err := makeAPIRequest(ctx, "GET", fmt.Sprintf("/v1/restore/file/%s/push/%s", restoreID, pushID), nil)
+		if err != nil {
+			return nil, "", waitPending, err
+		}
+		var obj map[string]interface{}
+		if err := json.Unmarshal(data, &obj); err != nil {
+			return nil, "", waitPending, fmt.Errorf("parse push status: %w", err)
+		}
+		state := objectState(obj)
+		return obj, state, stateOutcome(state, "completed", "complete", "succeeded", "success"), nil
+	}, nil
+}
+
+// waitForImageExport follows an export from export_image until its disks
+// are listed.
+func waitForImageExport(created map[string]interface{}) (string, waitPoll, error) {
+	id, err := createdID(created, "image_export_id")
+	if err != nil {
+		return "", nil, err
+	}
+	browse := fmt.Sprintf("/v1/restore/image/%s/browse", id)
+	return "image export " + id, readyWhenBrowsable(getImageExport, map[string]interface{}{"image_export_id": id}, browse), nil
+}
+
+// waitForFileRestore follows a restore from create_restore until it can
+// be browsed.
+func waitForFileRestore(created map[string]interface{}) (string, waitPoll, error) {
+	id, err := createdID(created, "file_restore_id")
+	if err != nil {
+		return "", nil, err
+	}
+	browse := fmt.Sprintf("/v1/restore/file/%s/browse?path=%s", id, url.QueryEscape("/"))
+	return "file restore " + id, readyWhenBrowsable(getFileRestore, map[string]interface{}{"file_restore_id": id}, browse), nil
+}
+
+// readyWhenBrowsable polls an object with no state of its own: it is
+// ready once browse lists something. A state field, if the API returns
+// one, takes precedence.
+func readyWhenBrowsable(get func(context.Context, map[string]interface{}) (string, error), args map[string]interface{}, browse string) waitPoll {
+	return func(ctx context.Context) (interface{}, string, waitOutcome, error) {
+		obj, err := getObject(ctx, get, args)
+		if err != nil {
+			return nil, "", waitPending, err
+		}
+		if state := objectState(obj); state != "" {
+			return obj, state, stateOutcome(state, "ready", "complete", "completed", "succeeded", "success", "available"), nil
+		}
+		data, err := makeAPIRequest(ctx, "GET", browse, nil)
+		if err != nil {
+			// Not mounted yet; a missing object fails the read above.
+			return obj, "preparing", waitPending, nil
+		}
+		var p PaginatedResponse[json.RawMessage]
+		if err := json.Unmarshal(data, &p); err != nil || len(p.Data) == 0 {
+			return obj, "preparing", waitPending, nil
+		}
+		return obj, "ready", waitSucceeded, nil
+	}
+}
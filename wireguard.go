@@ -0,0 +1,200 @@
+package main
+
+// WireGuard peer configs for DR networks.
+//
+// Slide returns a peer's private key once, in the create_wg_peer
+// response. That response is the only place this server shows it: the
+// config is returned inline, attached as the peer's config resource, and
+// rendered as a QR code (PNG and text) so it can be scanned straight into
+// the WireGuard phone app. Every later view - list/get network,
+// update_wg_peer, and the slide://network/{network_id}/wg-peer/{wg_peer_id}/config
+// resource - carries the config with the key replaced by a placeholder.
+// The key is never cached or logged. Once the peer exists, create_wg_peer
+// always returns the key: a failed network lookup or QR render becomes a
+// warning, not an error.
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	qrcode "github.com/skip2/go-qrcode"
+)
+
+const (
+	wgConfigMIMEType         = "text/plain"
+	resourceURITplWGPeerConf = "slide://network/{network_id}/wg-peer/{wg_peer_id}/config"
+
+	// wgPrivateKeyPlaceholder stands in for the key outside create_wg_peer.
+	wgPrivateKeyPlaceholder = "<private key shown only once, when the peer was created>"
+
+	// wgServerKeyPlaceholder stands in for the network's public key when
+	// create_wg_peer could not look it up.
+	wgServerKeyPlaceholder = "<server public key: copy it from get_network>"
+
+	// wgPeerGuidance is the wireguard_guidance for network views.
+	wgPeerGuidance = "WG peers include their WireGuard config in _wireguard_config and as the config_resource_uri resource. The private key is shown only once, by create_wg_peer; here it is a placeholder. If it was lost, delete the peer and create a new one."
+
+	// wgQRPixels is the PNG QR code's edge length.
+	wgQRPixels = 512
+)
+
+// generateWireGuardConfig builds the client .conf for peer. An empty
+// WGPrivateKey is written as wgPrivateKeyPlaceholder.
+func generateWireGuardConfig(peer NetworkWGPeer, serverPublicKey, networkPrefix string) string {
+	privateKey := peer.WGPrivateKey
+	if privateKey == "" {
+		privateKey = wgPrivateKeyPlaceholder
+	}
+	return fmt.Sprintf(`[Interface]
+PrivateKey = %s
+Address = %s
+
+[Peer]
+PublicKey = %s
+Endpoint = %s
+AllowedIPs = %s
+PersistentKeepalive = 25
+`,
+		privateKey,
+		peer.WGAddress,
+		serverPublicKey,
+		peer.WGEndpoint,
+		joinNetworks(peer.RemoteNetworks, networkPrefix))
+}
+
+// joinNetworks is the AllowedIPs list: the peer's remote networks, else
+// the network's own prefix, else all traffic.
+func joinNetworks(networks []string, defaultNetwork string) string {
+	if len(networks) == 0 {
+		if defaultNetwork != "" {
+			return defaultNetwork
+		}
+		return "0.0.0.0/0, ::/0"
+	}
+	return strings.Join(networks, ", ")
+}
+
+// wgConfigResourceURI is the config resource URI for a peer.
+func wgConfigResourceURI(networkID, peerID string) string {
+	return "slide://network/" + networkID + "/wg-peer/" + peerID + "/config"
+}
+
+// wgConfigFilename is the suggested .conf name for a peer.
+func wgConfigFilename(peerID string) string {
+	return fmt.Sprintf("slide-%s.conf", peerID)
+}
+
+// redactWGPeer drops the private key, if the API returned one.
+func redactWGPeer(peer NetworkWGPeer) NetworkWGPeer {
+	peer.WGPrivateKey = ""
+	return peer
+}
+
+// wgPeerView is the redacted output for one of n's peers.
+func wgPeerView(peer NetworkWGPeer, n Network) map[string]interface{} {
+	peer = redactWGPeer(peer)
+	return map[string]interface{}{
+		"wg_peer_id":          peer.WGPeerID,
+		"peer_name":           peer.PeerName,
+		"wg_public_key":       peer.WGPublicKey,
+		"wg_address":          peer.WGAddress,
+		"wg_endpoint":         peer.WGEndpoint,
+		"remote_networks":     peer.RemoteNetworks,
+		"_wireguard_config":   generateWireGuardConfig(peer, n.WGPublicKey, n.WGPrefix),
+		"config_resource_uri": wgConfigResourceURI(n.NetworkID, peer.WGPeerID),
+	}
+}
+
+// wgPeerViews is wgPeerView for every peer on n, or nil when it has none.
+func wgPeerViews(n Network) []map[string]interface{} {
+	if len(n.WGPeers) == 0 {
+		return nil
+	}
+	views := make([]map[string]interface{}, len(n.WGPeers))
+	for i, peer := range n.WGPeers {
+		views[i] = wgPeerView(peer, n)
+	}
+	return views
+}
+
+// fetchNetwork returns one network with its peers.
+func fetchNetwork(ctx context.Context, networkID string) (*Network, error) {
+	data, err := makeAPIRequest(ctx, "GET", fmt.Sprintf("/v1/network/%s", networkID), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get network details: %w", err)
+	}
+	var n Network
+	if err := json.Unmarshal(data, &n); err != nil {
+		return nil, fmt.Errorf("failed to parse network response: %w", err)
+	}
+	return &n, nil
+}
+
+// wireGuardQR renders conf as a PNG and as a text QR code made of block
+// characters, for terminals and chat clients that cannot show images.
+func wireGuardQR(conf string) (png []byte, text string, err error) {
+	qr, err := qrcode.New(conf, qrcode.Medium)
+	if err != nil {
+		return nil, "", fmt.Errorf("render WireGuard QR code: %w", err)
+	}
+	png, err = qr.PNG(wgQRPixels)
+	if err != nil {
+		return nil, "", fmt.Errorf("render WireGuard QR code: %w", err)
+	}
+	return png, qr.ToSmallString(false), nil
+}
+
+// attachWireGuardConfig adds the full config to the current tool result
+// as the peer's config resource and as a PNG QR code, and returns the
+// text QR code for the JSON body.
+func attachWireGuardConfig(ctx context.Context, uri, conf string) (string, error) {
+	png, text, err := wireGuardQR(conf)
+	if err != nil {
+		return "", err
+	}
+	attachContent(ctx, mcp.NewEmbeddedResource(mcp.TextResourceContents{
+		URI:      uri,
+		MIMEType: wgConfigMIMEType,
+		Text:     conf,
+	}))
+	attachContent(ctx, mcp.NewImageContent(base64.StdEncoding.EncodeToString(png), "image/png"))
+	return text, nil
+}
+
+// parseWGConfigURI splits slide://network/{network_id}/wg-peer/{wg_peer_id}/config.
+func parseWGConfigURI(uri string) (networkID, peerID string, err error) {
+	rest, ok := strings.CutPrefix(uri, "slide://network/")
+	if ok {
+		rest, ok = strings.CutSuffix(rest, "/config")
+	}
+	if ok {
+		networkID, peerID, ok = strings.Cut(rest, "/wg-peer/")
+	}
+	if !ok || networkID == "" || peerID == "" || strings.Contains(peerID, "/") {
+		return "", "", fmt.Errorf("URI %q does not match %s", uri, resourceURITplWGPeerConf)
+	}
+	return networkID, peerID, nil
+}
+
+// handleResourceWGPeerConfig serves a peer's config as a .conf file, with
+// the private key replaced by a placeholder.
+func handleResourceWGPeerConfig(ctx context.Context, uri string) ([]byte, error) {
+	networkID, peerID, err := parseWGConfigURI(uri)
+	if err != nil {
+		return nil, err
+	}
+	n, err := fetchNetwork(ctx, networkID)
+	if err != nil {
+		return nil, err
+	}
+	for _, peer := range n.WGPeers {
+		if peer.WGPeerID == peerID {
+			return []byte(generateWireGuardConfig(redactWGPeer(peer), n.WGPublicKey, n.WGPrefix)), nil
+		}
+	}
+	return nil, fmt.Errorf("WireGuard peer %s not found on network %s", peerID, networkID)
+}